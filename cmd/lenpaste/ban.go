@@ -0,0 +1,122 @@
+// Copyright (C) 2021-2023 Leonid Maslakov.
+
+// This file is part of Lenpaste.
+
+// Lenpaste is free software: you can redistribute it
+// and/or modify it under the terms of the
+// GNU Affero Public License as published by the
+// Free Software Foundation, either version 3 of the License,
+// or (at your option) any later version.
+
+// Lenpaste is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+// or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero Public License for more details.
+
+// You should have received a copy of the GNU Affero Public License along with Lenpaste.
+// If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/lcomrade/lenpaste/internal/cli"
+	"github.com/lcomrade/lenpaste/internal/netshare"
+	"github.com/lcomrade/lenpaste/internal/storage"
+)
+
+// Administrator name written to the audit log by the ban command.
+const banAdminCLI = "cli"
+
+// lenpaste ban add|list|remove [RANGE|ID]
+func banMain() {
+	c := cli.NewCommand(Version, "ban", "add|list|remove [RANGE|ID]")
+
+	flagDbDriver := c.AddStringVar("db-driver", "sqlite3", "Currently supported drivers: \"sqlite3\" and \"postgres\".", nil)
+	flagDbSource := c.AddStringVar("db-source", "", "DB source.", &cli.FlagOptions{Required: true})
+	flagReason := c.AddStringVar("reason", "", "Reason shown to the banned clients. Used by \"add\".", nil)
+	flagExpiresIn := c.AddDurationVar("expires-in", "", "Ban lifetime, the ban never expires if empty. Examples: 12h, 30d, 365d. Used by \"add\".", nil)
+
+	c.Parse()
+
+	args := c.Args()
+	if len(args) == 0 {
+		exitOnError(errors.New("expected \"add\", \"list\" or \"remove\" argument, see -help"))
+	}
+
+	// Open DB and apply pending migrations
+	err := storage.InitDB(*flagDbDriver, *flagDbSource)
+	if err != nil {
+		exitOnError(err)
+	}
+
+	db, err := storage.NewPool(*flagDbDriver, *flagDbSource, 1, 0)
+	if err != nil {
+		exitOnError(err)
+	}
+	defer db.Close()
+
+	switch args[0] {
+	case "add":
+		if len(args) != 2 {
+			exitOnError(errors.New("\"add\" expects the IP address or CIDR range"))
+		}
+
+		ban, err := netshare.BanAdd(db, banAdminCLI, args[1], *flagReason, int64(flagExpiresIn.Seconds()), "")
+		if err != nil {
+			if err == netshare.ErrBadRequest {
+				exitOnError(errors.New("invalid IP address or range \"" + args[1] + "\" or too long reason"))
+			}
+
+			if err == storage.ErrBanExists {
+				exitOnError(errors.New("range \"" + args[1] + "\" is already banned"))
+			}
+
+			exitOnError(err)
+		}
+
+		fmt.Println("ID:   ", ban.ID)
+		fmt.Println("Range:", ban.CIDR)
+
+	case "list":
+		if len(args) != 1 {
+			exitOnError(errors.New("\"list\" does not accept arguments"))
+		}
+
+		bans, err := db.BanList()
+		if err != nil {
+			exitOnError(err)
+		}
+
+		fmt.Printf("%-10s %-45s %-20s %-20s %s\n", "ID", "RANGE", "CREATED", "EXPIRES", "REASON")
+		for _, ban := range bans {
+			expires := "never"
+			if ban.ExpireTime > 0 {
+				expires = formatTime(ban.ExpireTime)
+			}
+
+			fmt.Printf("%-10s %-45s %-20s %-20s %s\n", ban.ID, ban.CIDR, formatTime(ban.CreateTime), expires, ban.Reason)
+		}
+
+	case "remove":
+		if len(args) != 2 {
+			exitOnError(errors.New("\"remove\" expects the ban ID"))
+		}
+
+		err = netshare.BanDelete(db, banAdminCLI, args[1])
+		if err != nil {
+			if err == netshare.ErrNotFound {
+				exitOnError(errors.New("ban \"" + args[1] + "\" not found"))
+			}
+
+			exitOnError(err)
+		}
+
+		fmt.Println("Removed ban", args[1])
+
+	default:
+		exitOnError(errors.New("unknown argument \"" + args[0] + "\", see -help"))
+	}
+}
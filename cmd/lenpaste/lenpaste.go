@@ -24,16 +24,25 @@ import (
 	"io"
 	"net/http"
 	"os"
+	"os/signal"
 	"strconv"
+	"syscall"
 	"time"
 
 	"github.com/lcomrade/lenpaste/internal/apiv1"
+	"github.com/lcomrade/lenpaste/internal/apiv2"
+	"github.com/lcomrade/lenpaste/internal/auth"
 	"github.com/lcomrade/lenpaste/internal/cli"
 	"github.com/lcomrade/lenpaste/internal/config"
+	"github.com/lcomrade/lenpaste/internal/hastebin"
+	"github.com/lcomrade/lenpaste/internal/lenpasswd"
 	"github.com/lcomrade/lenpaste/internal/logger"
 	"github.com/lcomrade/lenpaste/internal/netshare"
+	"github.com/lcomrade/lenpaste/internal/oidc"
+	"github.com/lcomrade/lenpaste/internal/privatebin"
 	"github.com/lcomrade/lenpaste/internal/raw"
 	"github.com/lcomrade/lenpaste/internal/storage"
+	"github.com/lcomrade/lenpaste/internal/termbin"
 	"github.com/lcomrade/lenpaste/internal/web"
 )
 
@@ -65,13 +74,52 @@ func exitOnError(e error) {
 func main() {
 	var err error
 
+	// Run subcommand
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "migrate":
+			migrateMain()
+			return
+		case "token":
+			tokenMain()
+			return
+		case "passwd":
+			passwdMain()
+			return
+		case "user":
+			userMain()
+			return
+		case "ban":
+			banMain()
+			return
+		}
+	}
+
 	// Read environment variables and CLI flags
 	c := cli.New(Version)
 
+	c.AddCommand("migrate", "Show, apply or revert database schema migrations.")
+	c.AddCommand("token", "Create, list or revoke API tokens.")
+	c.AddCommand("passwd", "Add, change or delete users of the lenpasswd file.")
+	c.AddCommand("user", "Add, list or delete user accounts.")
+	c.AddCommand("ban", "Ban IP addresses and CIDR ranges, list or remove bans.")
+
 	flagAddress := c.AddStringVar("address", ":80", "HTTP server ADDRESS:PORT.", nil)
+	flagTCPAddress := c.AddStringVar("tcp-address", "", "Plain TCP server ADDRESS:PORT. Text sent to it (for example with netcat) is saved as a paste. Disabled if empty.", nil)
+	flagTCPIdleTimeout := c.AddDurationVar("tcp-idle-timeout", "5s", "The TCP server saves the paste if the client sends nothing during this time. Examples: 5s, 1m.", nil)
+	flagPublicURL := c.AddStringVar("public-url", "", "Public URL of this server, for example: https://paste.example.org. Required for the TCP server.", nil)
+	flagTrustedProxies := c.AddStringVar("trusted-proxies", netshare.DefaultTrustedProxies, "Comma separated list of reverse proxy IP addresses and CIDR ranges. Forwarded, X-Forwarded-* and X-Real-IP headers are used only in requests from them. If \"none\" trust nobody.", &cli.FlagOptions{
+		PreHook: func(s string) (string, error) {
+			if s == "none" {
+				return "", nil
+			}
+
+			return s, nil
+		},
+	})
 
-	flagDbDriver := c.AddStringVar("db-driver", "sqlite3", "Currently supported drivers: \"sqlite3\" and \"postgres\".", nil)
-	flagDbSource := c.AddStringVar("db-source", "", "DB source.", &cli.FlagOptions{Required: true})
+	flagDbDriver := c.AddStringVar("db-driver", "sqlite3", "Currently supported drivers: \"sqlite3\", \"postgres\" and \"memory\".", nil)
+	flagDbSource := c.AddStringVar("db-source", "", "DB source. Required for all drivers except \"memory\".", nil)
 	flagDbMaxOpenConns := c.AddIntVar("db-max-open-conns", 25, "Maximum number of connections to the database.", nil)
 	flagDbMaxIdleConns := c.AddIntVar("db-max-idle-conns", 5, "Maximum number of idle connections to the database.", nil)
 	flagDbCleanupPeriod := c.AddDurationVar("db-cleanup-period", "1m", "Interval at which the DB is cleared of expired but not yet deleted pastes.", nil)
@@ -96,6 +144,15 @@ func main() {
 	flagNewPastesPer5Min := c.AddUintVar("new-pastes-per-5min", 15, "Maximum number of pastes that can be CREATED in 5 minutes from one IP. If 0 disable rate-limit.", nil)
 	flagNewPastesPer15Min := c.AddUintVar("new-pastes-per-15min", 30, "Maximum number of pastes that can be CREATED in 15 minutes from one IP. If 0 disable rate-limit.", nil)
 	flagNewPastesPer1Hour := c.AddUintVar("new-pastes-per-1hour", 40, "Maximum number of pastes that can be CREATED in 1 hour from one IP. If 0 disable rate-limit.", nil)
+	flagAuthPer5Min := c.AddUintVar("auth-attempts-per-5min", 10, "Maximum number of login and sign up attempts in 5 minutes from one IP. If 0 disable rate-limit.", nil)
+	flagAuthPer15Min := c.AddUintVar("auth-attempts-per-15min", 20, "Maximum number of login and sign up attempts in 15 minutes from one IP. If 0 disable rate-limit.", nil)
+	flagAuthPer1Hour := c.AddUintVar("auth-attempts-per-1hour", 50, "Maximum number of login and sign up attempts in 1 hour from one IP. If 0 disable rate-limit.", nil)
+	flagSearchPer5Min := c.AddUintVar("search-requests-per-5min", 30, "Maximum number of search requests in 5 minutes from one IP. If 0 disable rate-limit.", nil)
+	flagSearchPer15Min := c.AddUintVar("search-requests-per-15min", 60, "Maximum number of search requests in 15 minutes from one IP. If 0 disable rate-limit.", nil)
+	flagSearchPer1Hour := c.AddUintVar("search-requests-per-1hour", 200, "Maximum number of search requests in 1 hour from one IP. If 0 disable rate-limit.", nil)
+	flagRateLimitIPv4Prefix := c.AddIntVar("rate-limit-ipv4-prefix", netshare.DefaultRateLimitOptions.IPv4Prefix, "IPv4 addresses with the same prefix of this length share the rate limits. From 0 to 32.", nil)
+	flagRateLimitIPv6Prefix := c.AddIntVar("rate-limit-ipv6-prefix", netshare.DefaultRateLimitOptions.IPv6Prefix, "IPv6 addresses with the same prefix of this length share the rate limits. From 0 to 128.", nil)
+	flagRateLimitMaxClients := c.AddIntVar("rate-limit-max-clients", netshare.DefaultRateLimitOptions.MaxClients, "Maximum number of clients remembered by every rate limit. Least recently seen clients are forgotten first.", nil)
 
 	flagServerAbout := c.AddStringVar("server-about", "", "Path to the TXT file that contains the server description.", nil)
 	flagServerRules := c.AddStringVar("server-rules", "", "Path to the TXT file that contains the server rules.", nil)
@@ -107,11 +164,137 @@ func main() {
 	flagUiDefaultLifetime := c.AddStringVar("ui-default-lifetime", "", "Lifetime of paste will be set by default in WEB interface. Examples: 10min, 1h, 1d, 2w, 6mon, 1y.", nil)
 	flagUiDefaultTheme := c.AddStringVar("ui-default-theme", "dark", "Sets the default theme for the WEB interface. Examples: dark, light, my_theme.", nil)
 	flagUiThemesDir := c.AddStringVar("ui-themes-dir", "", "Loads external WEB interface themes from directory.", nil)
+	flagUiDisableHistory := c.AddBoolVar("ui-disable-history", "Disables the history of pastes that is stored in the browser (history.js).")
+
+	flagHastebinAPI := c.AddBoolVar("hastebin-api", "Enables the hastebin compatible API: POST /documents and GET /documents/<key>.")
+	flagPrivateBinAPI := c.AddBoolVar("privatebin-api", "Enables the PrivateBin compatible JSON API.")
 
 	flagLenPasswdFile := c.AddStringVar("lenpasswd-file", "", "File in LenPasswd format. If set, authorization will be required to create pastes.", nil)
 
+	flagLDAPURL := c.AddStringVar("ldap-url", "", "LDAP server URL, for example: ldaps://ldap.example.org. If set, users and passwords are checked with LDAP bind instead of the LenPasswd file.", nil)
+	flagLDAPStartTLS := c.AddBoolVar("ldap-start-tls", "Use StartTLS with the ldap:// URL.")
+	flagLDAPUserDN := c.AddStringVar("ldap-user-dn", "", "User DN template, \"%s\" is replaced with the user name. Example: uid=%s,ou=people,dc=example,dc=org.", nil)
+	flagLDAPGroupFilter := c.AddStringVar("ldap-group-filter", "", "Optional LDAP filter that the user entry must match. Example: (memberOf=cn=lenpaste,ou=groups,dc=example,dc=org).", nil)
+	flagLDAPCacheTTL := c.AddDurationVar("ldap-cache-ttl", "5m", "Successful LDAP logins are cached for this time. If 0 disable cache.", nil)
+
+	flagAccounts := c.AddBoolVar("accounts", "Enables user accounts in the WEB interface. Logged in users can create pastes without LenPasswd authorization and manage their pastes.")
+	flagRegistration := c.AddStringVar("registration", config.RegistrationOpen, "Who can create user accounts: \"open\" - anyone, \"admin\" - only administrators, \"closed\" - only the \"lenpaste user add\" command.", nil)
+	flagOIDCIssuer := c.AddStringVar("oidc-issuer", "", "OpenID Connect issuer URL, for example: https://id.example.org/realms/company. If set, users log in with the identity provider to create pastes.", nil)
+	flagOIDCClientID := c.AddStringVar("oidc-client-id", "", "OpenID Connect client ID. Required with \"-oidc-issuer\".", nil)
+	flagOIDCClientSecret := c.AddStringVar("oidc-client-secret", "", "OpenID Connect client secret. Empty for public clients.", nil)
+	flagSessionLifetime := c.AddDurationVar("session-lifetime", "30d", "Lifetime of the user login session. Examples: 12h, 1w, 30d.", nil)
+	flagReportsAutoHide := c.AddIntVar("reports-auto-hide", 0, "Hide the paste until an administrator checks it when it is reported from this number of different IP addresses. If 0 never hide pastes.", nil)
+
 	c.Parse()
 
+	// -db-source flag
+	if *flagDbSource == "" && *flagDbDriver != "memory" {
+		exitOnError(errors.New("\"-db-source\" flag is missing"))
+	}
+
+	// -trusted-proxies flag
+	trustedProxies, err := netshare.ParseTrustedProxies(*flagTrustedProxies)
+	if err != nil {
+		exitOnError(errors.New("\"-trusted-proxies\" flag: " + err.Error()))
+	}
+
+	netshare.SetTrustedProxies(trustedProxies)
+
+	// -tcp-address flag
+	if *flagTCPAddress != "" {
+		if *flagPublicURL == "" {
+			exitOnError(errors.New("\"-public-url\" flag is required for the TCP server"))
+		}
+
+		if *flagLenPasswdFile != "" {
+			exitOnError(errors.New("TCP server can not be used with \"-lenpasswd-file\" flag because it does not support authorization"))
+		}
+
+		if *flagLDAPURL != "" {
+			exitOnError(errors.New("TCP server can not be used with \"-ldap-url\" flag because it does not support authorization"))
+		}
+
+		if *flagOIDCIssuer != "" {
+			exitOnError(errors.New("TCP server can not be used with \"-oidc-issuer\" flag because it does not support authorization"))
+		}
+
+		if *flagTCPIdleTimeout <= 0 {
+			exitOnError(errors.New("TCP server idle timeout must be greater than 0"))
+		}
+	}
+
+	// -registration and -session-lifetime flags
+	switch *flagRegistration {
+	case config.RegistrationOpen, config.RegistrationAdmin, config.RegistrationClosed:
+	default:
+		exitOnError(errors.New("\"-registration\" flag must be \"open\", \"admin\" or \"closed\""))
+	}
+
+	if *flagSessionLifetime <= 0 {
+		exitOnError(errors.New("\"-session-lifetime\" flag must be greater than zero"))
+	}
+
+	// -reports-auto-hide flag
+	if *flagReportsAutoHide < 0 {
+		exitOnError(errors.New("\"-reports-auto-hide\" flag can not be negative"))
+	}
+
+	// Rate limit flags
+	if *flagRateLimitIPv4Prefix < 0 || *flagRateLimitIPv4Prefix > 32 {
+		exitOnError(errors.New("\"-rate-limit-ipv4-prefix\" flag must be from 0 to 32"))
+	}
+
+	if *flagRateLimitIPv6Prefix < 0 || *flagRateLimitIPv6Prefix > 128 {
+		exitOnError(errors.New("\"-rate-limit-ipv6-prefix\" flag must be from 0 to 128"))
+	}
+
+	if *flagRateLimitMaxClients <= 0 {
+		exitOnError(errors.New("\"-rate-limit-max-clients\" flag must be greater than 0"))
+	}
+
+	rateLimitOpts := netshare.RateLimitOptions{
+		IPv4Prefix: *flagRateLimitIPv4Prefix,
+		IPv6Prefix: *flagRateLimitIPv6Prefix,
+		MaxClients: *flagRateLimitMaxClients,
+	}
+
+	newRateLimit := func(per5Min, per15Min, per1Hour uint) *netshare.RateLimitSystem {
+		return netshare.NewRateLimitWindows(rateLimitOpts, []netshare.RateLimitWindow{
+			{Period: 5 * time.Minute, Limit: per5Min},
+			{Period: 15 * time.Minute, Limit: per15Min},
+			{Period: time.Hour, Limit: per1Hour},
+		})
+	}
+
+	// -ldap-url flag
+	var authProvider auth.Provider
+	if *flagLDAPURL != "" {
+		if *flagLenPasswdFile != "" {
+			exitOnError(errors.New("\"-ldap-url\" and \"-lenpasswd-file\" flags can not be used together"))
+		}
+
+		authProvider, err = auth.NewLDAP(*flagLDAPURL, *flagLDAPUserDN, *flagLDAPGroupFilter, *flagLDAPStartTLS, *flagLDAPCacheTTL)
+		if err != nil {
+			exitOnError(err)
+		}
+
+	} else if *flagLenPasswdFile != "" {
+		authProvider = auth.NewFile(*flagLenPasswdFile)
+	}
+
+	// Logged in users skip the authorization, so anyone could bypass it by signing up
+	oidcEnabled := *flagOIDCIssuer != ""
+
+	err = config.CheckRegistration(*flagRegistration, *flagAccounts || oidcEnabled, netshare.Auth{Provider: authProvider, OIDC: oidcEnabled})
+	if err != nil {
+		exitOnError(errors.New("\"-registration open\" can not be used with \"-lenpasswd-file\", \"-ldap-url\" or \"-oidc-issuer\" flags, use \"admin\" or \"closed\""))
+	}
+
+	// -oidc-issuer flag
+	if *flagOIDCIssuer != "" && *flagOIDCClientID == "" {
+		exitOnError(errors.New("\"-oidc-client-id\" flag is required with \"-oidc-issuer\""))
+	}
+
 	// -body-max-length flag
 	if *flagBodyMaxLen == 0 {
 		exitOnError(errors.New("maximum body length cannot be 0"))
@@ -159,15 +342,26 @@ func main() {
 	// Settings
 	log := logger.New("2006/01/02 15:04:05")
 
-	db, err := storage.NewPool(*flagDbDriver, *flagDbSource, *flagDbMaxOpenConns, *flagDbMaxIdleConns)
+	db, err := storage.Open(*flagDbDriver, *flagDbSource, *flagDbMaxOpenConns, *flagDbMaxIdleConns)
 	if err != nil {
 		exitOnError(err)
 	}
 
+	// OpenID Connect provider
+	var oidcProvider *oidc.Provider
+	if *flagOIDCIssuer != "" {
+		oidcProvider, err = oidc.Discover(*flagOIDCIssuer, *flagOIDCClientID, *flagOIDCClientSecret)
+		if err != nil {
+			exitOnError(err)
+		}
+	}
+
 	cfg := config.Config{
 		Log:               log,
-		RateLimitGet:      netshare.NewRateLimitSystem(*flagGetPastesPer5Min, *flagGetPastesPer15Min, *flagGetPastesPer1Hour),
-		RateLimitNew:      netshare.NewRateLimitSystem(*flagNewPastesPer5Min, *flagNewPastesPer15Min, *flagNewPastesPer1Hour),
+		RateLimitGet:      newRateLimit(*flagGetPastesPer5Min, *flagGetPastesPer15Min, *flagGetPastesPer1Hour),
+		RateLimitNew:      newRateLimit(*flagNewPastesPer5Min, *flagNewPastesPer15Min, *flagNewPastesPer1Hour),
+		RateLimitAuth:     newRateLimit(*flagAuthPer5Min, *flagAuthPer15Min, *flagAuthPer1Hour),
+		RateLimitSearch:   newRateLimit(*flagSearchPer5Min, *flagSearchPer15Min, *flagSearchPer1Hour),
 		Version:           Version,
 		TitleMaxLen:       *flagTitleMaxLen,
 		BodyMaxLen:        *flagBodyMaxLen,
@@ -181,13 +375,25 @@ func main() {
 		UiDefaultLifetime: *flagUiDefaultLifetime,
 		UiDefaultTheme:    *flagUiDefaultTheme,
 		UiThemesDir:       *flagUiThemesDir,
-		LenPasswdFile:     *flagLenPasswdFile,
+		UiDisableHistory:  *flagUiDisableHistory,
+		Auth:              netshare.Auth{Provider: authProvider, OIDC: oidcProvider != nil},
+		OIDC:              oidcProvider,
+		Accounts:          *flagAccounts || oidcProvider != nil,
+		Registration:      *flagRegistration,
+		SessionLifetime:   *flagSessionLifetime,
+		ReportsAutoHide:   *flagReportsAutoHide,
+		PublicURL:         *flagPublicURL,
+		TCPIdleTimeout:    *flagTCPIdleTimeout,
 	}
 
 	apiv1Data := apiv1.Load(db, cfg)
+	apiv2Data := apiv2.Load(db, cfg)
 
 	rawData := raw.Load(db, cfg)
 
+	hastebinData := hastebin.Load(db, cfg)
+	privatebinData := privatebin.Load(db, cfg)
+
 	// Init data base
 	err = storage.InitDB(*flagDbDriver, *flagDbSource)
 	if err != nil {
@@ -202,14 +408,41 @@ func main() {
 
 	// Handlers
 	http.HandleFunc("/", func(rw http.ResponseWriter, req *http.Request) {
+		// Upload with "curl -T file host"
+		if req.Method == "PUT" && req.URL.Path == "/" {
+			rawData.Hand(rw, req)
+			return
+		}
+
+		// PrivateBin clients use the same path as the WEB interface
+		if *flagPrivateBinAPI && privatebin.IsRequest(req) {
+			privatebinData.Hand(rw, req)
+			return
+		}
+
 		webData.Handler(rw, req)
 	})
+	http.HandleFunc("/raw", func(rw http.ResponseWriter, req *http.Request) {
+		rawData.Hand(rw, req)
+	})
 	http.HandleFunc("/raw/", func(rw http.ResponseWriter, req *http.Request) {
 		rawData.Hand(rw, req)
 	})
 	http.HandleFunc("/api/", func(rw http.ResponseWriter, req *http.Request) {
 		apiv1Data.Hand(rw, req)
 	})
+	http.HandleFunc("/api/v2/", func(rw http.ResponseWriter, req *http.Request) {
+		apiv2Data.Hand(rw, req)
+	})
+
+	if *flagHastebinAPI {
+		http.HandleFunc("/documents", func(rw http.ResponseWriter, req *http.Request) {
+			hastebinData.Hand(rw, req)
+		})
+		http.HandleFunc("/documents/", func(rw http.ResponseWriter, req *http.Request) {
+			hastebinData.Hand(rw, req)
+		})
+	}
 
 	// Run background job
 	go func(cleanJobPeriod time.Duration) {
@@ -222,11 +455,55 @@ func main() {
 
 			log.Info("Delete " + strconv.FormatInt(count, 10) + " expired pastes")
 
+			// Delete expired login sessions
+			_, err = db.SessionDeleteExpired()
+			if err != nil {
+				log.Error(errors.New("Delete expired sessions: " + err.Error()))
+			}
+
+			// Delete expired bans
+			_, err = db.BanDeleteExpired()
+			if err != nil {
+				log.Error(errors.New("Delete expired bans: " + err.Error()))
+			}
+
 			// Wait
 			time.Sleep(cleanJobPeriod)
 		}
 	}(*flagDbCleanupPeriod)
 
+	// Reload LenPasswd file on SIGHUP.
+	// It is also reloaded when its modification time changes.
+	if *flagLenPasswdFile != "" {
+		sighup := make(chan os.Signal, 1)
+		signal.Notify(sighup, syscall.SIGHUP)
+
+		go func() {
+			for range sighup {
+				err := lenpasswd.Reload(*flagLenPasswdFile)
+				if err != nil {
+					log.Error(errors.New("Reload LenPasswd file: " + err.Error()))
+					continue
+				}
+
+				log.Info("Reload LenPasswd file " + *flagLenPasswdFile)
+			}
+		}()
+	}
+
+	// Run TCP server
+	if *flagTCPAddress != "" {
+		termbinData := termbin.Load(db, cfg)
+
+		go func() {
+			log.Info("Run TCP server on " + *flagTCPAddress)
+			err := termbinData.ListenAndServe(*flagTCPAddress)
+			if err != nil {
+				exitOnError(err)
+			}
+		}()
+	}
+
 	// Run HTTP server
 	log.Info("Run HTTP server on " + *flagAddress)
 	err = http.ListenAndServe(*flagAddress, nil)
@@ -0,0 +1,123 @@
+// Copyright (C) 2021-2023 Leonid Maslakov.
+
+// This file is part of Lenpaste.
+
+// Lenpaste is free software: you can redistribute it
+// and/or modify it under the terms of the
+// GNU Affero Public License as published by the
+// Free Software Foundation, either version 3 of the License,
+// or (at your option) any later version.
+
+// Lenpaste is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+// or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero Public License for more details.
+
+// You should have received a copy of the GNU Affero Public License along with Lenpaste.
+// If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/lcomrade/lenpaste/internal/cli"
+	"github.com/lcomrade/lenpaste/internal/storage"
+)
+
+// lenpaste migrate status|up|down [VERSION]
+func migrateMain() {
+	c := cli.NewCommand(Version, "migrate", "status|up|down [VERSION]")
+
+	flagDbDriver := c.AddStringVar("db-driver", "sqlite3", "Currently supported drivers: \"sqlite3\" and \"postgres\".", nil)
+	flagDbSource := c.AddStringVar("db-source", "", "DB source.", &cli.FlagOptions{Required: true})
+
+	c.Parse()
+
+	args := c.Args()
+	if len(args) == 0 || len(args) > 2 {
+		exitOnError(errors.New("expected \"status\", \"up\" or \"down\" argument, see -help"))
+	}
+
+	// Target version
+	target := -1
+	if len(args) == 2 {
+		var err error
+		target, err = strconv.Atoi(args[1])
+		if err != nil || target < 0 {
+			exitOnError(errors.New("invalid schema version \"" + args[1] + "\""))
+		}
+	}
+
+	// Open DB
+	db, err := storage.NewPool(*flagDbDriver, *flagDbSource, 1, 0)
+	if err != nil {
+		exitOnError(err)
+	}
+	defer db.Close()
+
+	switch args[0] {
+	case "status":
+		if target != -1 {
+			exitOnError(errors.New("\"status\" does not accept a version"))
+		}
+
+		status, err := db.MigrateStatus()
+		if err != nil {
+			exitOnError(err)
+		}
+
+		fmt.Printf("%-8s %-8s %-20s %s\n", "VERSION", "STATUS", "APPLIED", "NAME")
+		for _, m := range status {
+			state := "pending"
+			applyTime := "-"
+			if m.Applied {
+				state = "applied"
+				applyTime = time.Unix(m.ApplyTime, 0).Format("2006/01/02 15:04:05")
+			}
+
+			fmt.Printf("%-8d %-8s %-20s %s\n", m.Version, state, applyTime, m.Name)
+		}
+
+	case "up":
+		if target == -1 {
+			target = 0
+		}
+
+		count, err := db.MigrateUp(target)
+		if err != nil {
+			exitOnError(err)
+		}
+
+		fmt.Println("Applied", count, "migrations")
+
+	case "down":
+		// Revert only the last migration by default
+		if target == -1 {
+			version, err := db.SchemaVersion()
+			if err != nil {
+				exitOnError(err)
+			}
+
+			if version == 0 {
+				fmt.Println("Reverted 0 migrations")
+				return
+			}
+
+			target = version - 1
+		}
+
+		count, err := db.MigrateDown(target)
+		if err != nil {
+			exitOnError(err)
+		}
+
+		fmt.Println("Reverted", count, "migrations")
+
+	default:
+		exitOnError(errors.New("unknown argument \"" + args[0] + "\", see -help"))
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright (C) 2021-2023 Leonid Maslakov.
+
+// This file is part of Lenpaste.
+
+// Lenpaste is free software: you can redistribute it
+// and/or modify it under the terms of the
+// GNU Affero Public License as published by the
+// Free Software Foundation, either version 3 of the License,
+// or (at your option) any later version.
+
+// Lenpaste is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+// or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero Public License for more details.
+
+// You should have received a copy of the GNU Affero Public License along with Lenpaste.
+// If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/lcomrade/lenpaste/internal/cli"
+	"github.com/lcomrade/lenpaste/internal/lenpasswd"
+	"golang.org/x/term"
+)
+
+// lenpaste passwd add|del FILE USER
+func passwdMain() {
+	c := cli.NewCommand(Version, "passwd", "add|del FILE USER")
+
+	flagAlgorithm := c.AddStringVar("algorithm", lenpasswd.AlgBcrypt, "Password hash algorithm: \""+strings.Join(lenpasswd.Algorithms, "\", \"")+"\". Used by \"add\".", nil)
+
+	c.Parse()
+
+	args := c.Args()
+	if len(args) != 3 {
+		exitOnError(errors.New("expected \"add\" or \"del\", file and user arguments, see -help"))
+	}
+
+	path := args[1]
+	user := args[2]
+
+	switch args[0] {
+	case "add":
+		password, err := readNewPassword()
+		if err != nil {
+			exitOnError(err)
+		}
+
+		hash, err := lenpasswd.HashPassword(password, *flagAlgorithm)
+		if err != nil {
+			exitOnError(err)
+		}
+
+		err = lenpasswd.SetUser(path, user, hash)
+		if err != nil {
+			exitOnError(err)
+		}
+
+		fmt.Println("Saved password of user", user)
+
+	case "del":
+		err := lenpasswd.DelUser(path, user)
+		if err != nil {
+			exitOnError(err)
+		}
+
+		fmt.Println("Deleted user", user)
+
+	default:
+		exitOnError(errors.New("unknown argument \"" + args[0] + "\", see -help"))
+	}
+}
+
+// readNewPassword asks for the password twice if stdin is a terminal,
+// otherwise it reads the first line of stdin.
+func readNewPassword() (string, error) {
+	fd := int(os.Stdin.Fd())
+
+	if term.IsTerminal(fd) == false {
+		password, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && password == "" {
+			return "", errors.New("read password: " + err.Error())
+		}
+
+		password = strings.TrimRight(password, "\r\n")
+		if password == "" {
+			return "", errors.New("empty password")
+		}
+
+		return password, nil
+	}
+
+	fmt.Fprint(os.Stderr, "New password: ")
+	password, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", errors.New("read password: " + err.Error())
+	}
+
+	if len(password) == 0 {
+		return "", errors.New("empty password")
+	}
+
+	fmt.Fprint(os.Stderr, "Retype new password: ")
+	password2, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", errors.New("read password: " + err.Error())
+	}
+
+	if string(password) != string(password2) {
+		return "", errors.New("passwords do not match")
+	}
+
+	return string(password), nil
+}
@@ -0,0 +1,146 @@
+// Copyright (C) 2021-2023 Leonid Maslakov.
+
+// This file is part of Lenpaste.
+
+// Lenpaste is free software: you can redistribute it
+// and/or modify it under the terms of the
+// GNU Affero Public License as published by the
+// Free Software Foundation, either version 3 of the License,
+// or (at your option) any later version.
+
+// Lenpaste is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+// or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero Public License for more details.
+
+// You should have received a copy of the GNU Affero Public License along with Lenpaste.
+// If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lcomrade/lenpaste/internal/cli"
+	"github.com/lcomrade/lenpaste/internal/storage"
+)
+
+// lenpaste token create|list|revoke [ID]
+func tokenMain() {
+	c := cli.NewCommand(Version, "token", "create|list|revoke [ID]")
+
+	flagDbDriver := c.AddStringVar("db-driver", "sqlite3", "Currently supported drivers: \"sqlite3\" and \"postgres\".", nil)
+	flagDbSource := c.AddStringVar("db-source", "", "DB source.", &cli.FlagOptions{Required: true})
+	flagName := c.AddStringVar("name", "", "Token name, for example the name of the service that uses it. Used by \"create\".", nil)
+	flagScopes := c.AddStringVar("scopes", storage.ScopeCreate, "Comma separated list of token scopes: \""+strings.Join(storage.Scopes, "\", \"")+"\". Used by \"create\".", nil)
+	flagExpiresIn := c.AddDurationVar("expires-in", "", "Token lifetime, the token never expires if empty. Examples: 12h, 30d, 365d. Used by \"create\".", nil)
+
+	c.Parse()
+
+	args := c.Args()
+	if len(args) == 0 {
+		exitOnError(errors.New("expected \"create\", \"list\" or \"revoke\" argument, see -help"))
+	}
+
+	// Open DB and apply pending migrations
+	err := storage.InitDB(*flagDbDriver, *flagDbSource)
+	if err != nil {
+		exitOnError(err)
+	}
+
+	db, err := storage.NewPool(*flagDbDriver, *flagDbSource, 1, 0)
+	if err != nil {
+		exitOnError(err)
+	}
+	defer db.Close()
+
+	switch args[0] {
+	case "create":
+		if len(args) != 1 {
+			exitOnError(errors.New("\"create\" does not accept arguments"))
+		}
+
+		scopes, err := storage.ParseScopes(*flagScopes)
+		if err != nil {
+			exitOnError(errors.New("invalid -scopes \"" + *flagScopes + "\""))
+		}
+
+		token := storage.APIToken{
+			Name:   *flagName,
+			Scopes: scopes,
+		}
+
+		if *flagExpiresIn > 0 {
+			token.ExpireTime = time.Now().Add(*flagExpiresIn).Unix()
+		}
+
+		// The token is shown only once, the DB stores its hash
+		var secret string
+		secret, token.TokenHash, err = storage.NewSecret()
+		if err != nil {
+			exitOnError(err)
+		}
+
+		id, _, err := db.TokenAdd(token)
+		if err != nil {
+			exitOnError(err)
+		}
+
+		fmt.Println("ID:   ", id)
+		fmt.Println("Token:", secret)
+
+	case "list":
+		if len(args) != 1 {
+			exitOnError(errors.New("\"list\" does not accept arguments"))
+		}
+
+		tokens, err := db.TokenList()
+		if err != nil {
+			exitOnError(err)
+		}
+
+		fmt.Printf("%-10s %-20s %-20s %-20s %-30s %s\n", "ID", "CREATED", "EXPIRES", "LAST USED", "SCOPES", "NAME")
+		for _, token := range tokens {
+			expires := "never"
+			if token.ExpireTime > 0 {
+				expires = formatTime(token.ExpireTime)
+				if token.Expired() {
+					expires = "expired"
+				}
+			}
+
+			lastUsed := "-"
+			if token.LastUsedTime > 0 {
+				lastUsed = formatTime(token.LastUsedTime)
+			}
+
+			fmt.Printf("%-10s %-20s %-20s %-20s %-30s %s\n", token.ID, formatTime(token.CreateTime), expires, lastUsed, strings.Join(token.Scopes, ","), token.Name)
+		}
+
+	case "revoke":
+		if len(args) != 2 {
+			exitOnError(errors.New("\"revoke\" expects the token ID"))
+		}
+
+		err = db.TokenDelete(args[1])
+		if err != nil {
+			if err == storage.ErrNotFoundToken {
+				exitOnError(errors.New("token \"" + args[1] + "\" not found"))
+			}
+
+			exitOnError(err)
+		}
+
+		fmt.Println("Revoked token", args[1])
+
+	default:
+		exitOnError(errors.New("unknown argument \"" + args[0] + "\", see -help"))
+	}
+}
+
+func formatTime(t int64) string {
+	return time.Unix(t, 0).Format("2006/01/02 15:04:05")
+}
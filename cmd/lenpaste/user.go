@@ -0,0 +1,129 @@
+// Copyright (C) 2021-2023 Leonid Maslakov.
+
+// This file is part of Lenpaste.
+
+// Lenpaste is free software: you can redistribute it
+// and/or modify it under the terms of the
+// GNU Affero Public License as published by the
+// Free Software Foundation, either version 3 of the License,
+// or (at your option) any later version.
+
+// Lenpaste is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+// or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero Public License for more details.
+
+// You should have received a copy of the GNU Affero Public License along with Lenpaste.
+// If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/lcomrade/lenpaste/internal/cli"
+	"github.com/lcomrade/lenpaste/internal/netshare"
+	"github.com/lcomrade/lenpaste/internal/storage"
+)
+
+// lenpaste user add|list|del [NAME]
+func userMain() {
+	c := cli.NewCommand(Version, "user", "add|list|del [NAME]")
+
+	flagDbDriver := c.AddStringVar("db-driver", "sqlite3", "Currently supported drivers: \"sqlite3\" and \"postgres\".", nil)
+	flagDbSource := c.AddStringVar("db-source", "", "DB source.", &cli.FlagOptions{Required: true})
+	flagAdmin := c.AddBoolVar("admin", "Makes the user an administrator. Used by \"add\".")
+
+	c.Parse()
+
+	args := c.Args()
+	if len(args) == 0 {
+		exitOnError(errors.New("expected \"add\", \"list\" or \"del\" argument, see -help"))
+	}
+
+	// Open DB and apply pending migrations
+	err := storage.InitDB(*flagDbDriver, *flagDbSource)
+	if err != nil {
+		exitOnError(err)
+	}
+
+	db, err := storage.NewPool(*flagDbDriver, *flagDbSource, 1, 0)
+	if err != nil {
+		exitOnError(err)
+	}
+	defer db.Close()
+
+	switch args[0] {
+	case "add":
+		if len(args) != 2 {
+			exitOnError(errors.New("\"add\" expects the user name"))
+		}
+
+		password, err := readNewPassword()
+		if err != nil {
+			exitOnError(err)
+		}
+
+		user, err := netshare.UserAdd(db, args[1], password, *flagAdmin)
+		if err != nil {
+			switch err {
+			case storage.ErrUserExists:
+				exitOnError(errors.New("user \"" + args[1] + "\" already exists"))
+			case netshare.ErrBadRequest:
+				exitOnError(errors.New("user name may contain only latin letters, digits and \"-\", \"_\", \".\" characters and password must be at least " + strconv.Itoa(netshare.MinLengthUserPassword) + " characters long"))
+			case netshare.ErrPayloadTooLarge:
+				exitOnError(errors.New("password is too long"))
+			}
+
+			exitOnError(err)
+		}
+
+		fmt.Println("ID:", user.ID)
+
+	case "list":
+		if len(args) != 1 {
+			exitOnError(errors.New("\"list\" does not accept arguments"))
+		}
+
+		users, err := db.UserList()
+		if err != nil {
+			exitOnError(err)
+		}
+
+		fmt.Printf("%-10s %-20s %-6s %s\n", "ID", "CREATED", "ADMIN", "NAME")
+		for _, user := range users {
+			admin := "no"
+			if user.Admin {
+				admin = "yes"
+			}
+
+			fmt.Printf("%-10s %-20s %-6s %s\n", user.ID, formatTime(user.CreateTime), admin, user.Name)
+		}
+
+	case "del":
+		if len(args) != 2 {
+			exitOnError(errors.New("\"del\" expects the user name"))
+		}
+
+		user, err := db.UserGetByName(args[1])
+		if err != nil {
+			if err == storage.ErrNotFoundUser {
+				exitOnError(errors.New("user \"" + args[1] + "\" not found"))
+			}
+
+			exitOnError(err)
+		}
+
+		err = db.UserDelete(user.ID)
+		if err != nil {
+			exitOnError(err)
+		}
+
+		fmt.Println("Deleted user", user.Name)
+
+	default:
+		exitOnError(errors.New("unknown argument \"" + args[0] + "\", see -help"))
+	}
+}
@@ -10,6 +10,30 @@ if [ -n "$LENPASTE_ADDRESS" ]; then
 fi
 
 
+# LENPASTE_TCP_ADDRESS
+if [ -n "$LENPASTE_TCP_ADDRESS" ]; then
+	RUN_CMD="$RUN_CMD -tcp-address '$LENPASTE_TCP_ADDRESS'"
+fi
+
+
+# LENPASTE_TCP_IDLE_TIMEOUT
+if [ -n "$LENPASTE_TCP_IDLE_TIMEOUT" ]; then
+	RUN_CMD="$RUN_CMD -tcp-idle-timeout '$LENPASTE_TCP_IDLE_TIMEOUT'"
+fi
+
+
+# LENPASTE_PUBLIC_URL
+if [ -n "$LENPASTE_PUBLIC_URL" ]; then
+	RUN_CMD="$RUN_CMD -public-url '$LENPASTE_PUBLIC_URL'"
+fi
+
+
+# LENPASTE_TRUSTED_PROXIES
+if [ -n "$LENPASTE_TRUSTED_PROXIES" ]; then
+	RUN_CMD="$RUN_CMD -trusted-proxies '$LENPASTE_TRUSTED_PROXIES'"
+fi
+
+
 # LENPASTE_DB_DRIVER
 if [ -n "$LENPASTE_DB_DRIVER" ]; then
 	RUN_CMD="$RUN_CMD -db-driver '$LENPASTE_DB_DRIVER'"
@@ -20,7 +44,7 @@ fi
 if [ -z "$LENPASTE_DB_DRIVER" ] || [ "$LENPASTE_DB_DRIVER" = "sqlite3" ]; then
 	RUN_CMD="$RUN_CMD -db-source /data/lenpaste.db"
 
-else
+elif [ "$LENPASTE_DB_DRIVER" != "memory" ]; then
 	RUN_CMD="$RUN_CMD -db-source '$LENPASTE_DB_SOURCE'"
 fi
 
@@ -99,6 +123,48 @@ if [ -n "$LENPASTE_NEW_PASTES_PER_1HOUR" ]; then
 fi
 
 
+# Rate limits to log in and sign up
+if [ -n "$LENPASTE_AUTH_ATTEMPTS_PER_5MIN" ]; then
+	RUN_CMD="$RUN_CMD -auth-attempts-per-5min '$LENPASTE_AUTH_ATTEMPTS_PER_5MIN'"
+fi
+
+if [ -n "$LENPASTE_AUTH_ATTEMPTS_PER_15MIN" ]; then
+	RUN_CMD="$RUN_CMD -auth-attempts-per-15min '$LENPASTE_AUTH_ATTEMPTS_PER_15MIN'"
+fi
+
+if [ -n "$LENPASTE_AUTH_ATTEMPTS_PER_1HOUR" ]; then
+	RUN_CMD="$RUN_CMD -auth-attempts-per-1hour '$LENPASTE_AUTH_ATTEMPTS_PER_1HOUR'"
+fi
+
+
+# Rate limits to search
+if [ -n "$LENPASTE_SEARCH_REQUESTS_PER_5MIN" ]; then
+	RUN_CMD="$RUN_CMD -search-requests-per-5min '$LENPASTE_SEARCH_REQUESTS_PER_5MIN'"
+fi
+
+if [ -n "$LENPASTE_SEARCH_REQUESTS_PER_15MIN" ]; then
+	RUN_CMD="$RUN_CMD -search-requests-per-15min '$LENPASTE_SEARCH_REQUESTS_PER_15MIN'"
+fi
+
+if [ -n "$LENPASTE_SEARCH_REQUESTS_PER_1HOUR" ]; then
+	RUN_CMD="$RUN_CMD -search-requests-per-1hour '$LENPASTE_SEARCH_REQUESTS_PER_1HOUR'"
+fi
+
+
+# Rate limit clients
+if [ -n "$LENPASTE_RATE_LIMIT_IPV4_PREFIX" ]; then
+	RUN_CMD="$RUN_CMD -rate-limit-ipv4-prefix '$LENPASTE_RATE_LIMIT_IPV4_PREFIX'"
+fi
+
+if [ -n "$LENPASTE_RATE_LIMIT_IPV6_PREFIX" ]; then
+	RUN_CMD="$RUN_CMD -rate-limit-ipv6-prefix '$LENPASTE_RATE_LIMIT_IPV6_PREFIX'"
+fi
+
+if [ -n "$LENPASTE_RATE_LIMIT_MAX_CLIENTS" ]; then
+	RUN_CMD="$RUN_CMD -rate-limit-max-clients '$LENPASTE_RATE_LIMIT_MAX_CLIENTS'"
+fi
+
+
 
 # Server about
 if [ -f "/data/about" ]; then
@@ -142,6 +208,42 @@ if [ -n "$LENPASTE_UI_DEFAULT_THEME" ]; then
 fi
 
 
+# LENPASTE_UI_DISABLE_HISTORY
+if [ "$LENPASTE_UI_DISABLE_HISTORY" = "true" ]; then
+	RUN_CMD="$RUN_CMD -ui-disable-history"
+
+else
+	if [ "$LENPASTE_UI_DISABLE_HISTORY" != "" ] && [ "$LENPASTE_UI_DISABLE_HISTORY" != "false" ]; then
+		echo "[ENTRYPOINT] Error: unknown: LENPASTE_UI_DISABLE_HISTORY = $LENPASTE_UI_DISABLE_HISTORY"
+		exit 2
+	fi
+fi
+
+
+# LENPASTE_HASTEBIN_API
+if [ "$LENPASTE_HASTEBIN_API" = "true" ]; then
+	RUN_CMD="$RUN_CMD -hastebin-api"
+
+else
+	if [ "$LENPASTE_HASTEBIN_API" != "" ] && [ "$LENPASTE_HASTEBIN_API" != "false" ]; then
+		echo "[ENTRYPOINT] Error: unknown: LENPASTE_HASTEBIN_API = $LENPASTE_HASTEBIN_API"
+		exit 2
+	fi
+fi
+
+
+# LENPASTE_PRIVATEBIN_API
+if [ "$LENPASTE_PRIVATEBIN_API" = "true" ]; then
+	RUN_CMD="$RUN_CMD -privatebin-api"
+
+else
+	if [ "$LENPASTE_PRIVATEBIN_API" != "" ] && [ "$LENPASTE_PRIVATEBIN_API" != "false" ]; then
+		echo "[ENTRYPOINT] Error: unknown: LENPASTE_PRIVATEBIN_API = $LENPASTE_PRIVATEBIN_API"
+		exit 2
+	fi
+fi
+
+
 # External UI themes
 if [ -d "/data/themes" ]; then
 	RUN_CMD="$RUN_CMD -ui-themes-dir /data/themes"
@@ -154,6 +256,90 @@ if [ -f "/data/lenpasswd" ]; then
 fi
 
 
+# LENPASTE_ACCOUNTS
+if [ "$LENPASTE_ACCOUNTS" = "true" ]; then
+	RUN_CMD="$RUN_CMD -accounts"
+
+else
+	if [ "$LENPASTE_ACCOUNTS" != "" ] && [ "$LENPASTE_ACCOUNTS" != "false" ]; then
+		echo "[ENTRYPOINT] Error: unknown: LENPASTE_ACCOUNTS = $LENPASTE_ACCOUNTS"
+		exit 2
+	fi
+fi
+
+
+# LENPASTE_REGISTRATION
+if [ -n "$LENPASTE_REGISTRATION" ]; then
+	RUN_CMD="$RUN_CMD -registration '$LENPASTE_REGISTRATION'"
+fi
+
+
+# LENPASTE_OIDC_ISSUER
+if [ -n "$LENPASTE_OIDC_ISSUER" ]; then
+	RUN_CMD="$RUN_CMD -oidc-issuer '$LENPASTE_OIDC_ISSUER'"
+fi
+
+
+# LENPASTE_OIDC_CLIENT_ID
+if [ -n "$LENPASTE_OIDC_CLIENT_ID" ]; then
+	RUN_CMD="$RUN_CMD -oidc-client-id '$LENPASTE_OIDC_CLIENT_ID'"
+fi
+
+
+# LENPASTE_OIDC_CLIENT_SECRET
+if [ -n "$LENPASTE_OIDC_CLIENT_SECRET" ]; then
+	RUN_CMD="$RUN_CMD -oidc-client-secret '$LENPASTE_OIDC_CLIENT_SECRET'"
+fi
+
+
+# LENPASTE_REPORTS_AUTO_HIDE
+if [ -n "$LENPASTE_REPORTS_AUTO_HIDE" ]; then
+	RUN_CMD="$RUN_CMD -reports-auto-hide '$LENPASTE_REPORTS_AUTO_HIDE'"
+fi
+
+
+# LENPASTE_LDAP_URL
+if [ -n "$LENPASTE_LDAP_URL" ]; then
+	RUN_CMD="$RUN_CMD -ldap-url '$LENPASTE_LDAP_URL'"
+fi
+
+
+# LENPASTE_LDAP_START_TLS
+if [ "$LENPASTE_LDAP_START_TLS" = "true" ]; then
+	RUN_CMD="$RUN_CMD -ldap-start-tls"
+
+else
+	if [ "$LENPASTE_LDAP_START_TLS" != "" ] && [ "$LENPASTE_LDAP_START_TLS" != "false" ]; then
+		echo "[ENTRYPOINT] Error: unknown: LENPASTE_LDAP_START_TLS = $LENPASTE_LDAP_START_TLS"
+		exit 2
+	fi
+fi
+
+
+# LENPASTE_LDAP_USER_DN
+if [ -n "$LENPASTE_LDAP_USER_DN" ]; then
+	RUN_CMD="$RUN_CMD -ldap-user-dn '$LENPASTE_LDAP_USER_DN'"
+fi
+
+
+# LENPASTE_LDAP_GROUP_FILTER
+if [ -n "$LENPASTE_LDAP_GROUP_FILTER" ]; then
+	RUN_CMD="$RUN_CMD -ldap-group-filter '$LENPASTE_LDAP_GROUP_FILTER'"
+fi
+
+
+# LENPASTE_LDAP_CACHE_TTL
+if [ -n "$LENPASTE_LDAP_CACHE_TTL" ]; then
+	RUN_CMD="$RUN_CMD -ldap-cache-ttl '$LENPASTE_LDAP_CACHE_TTL'"
+fi
+
+
+# LENPASTE_SESSION_LIFETIME
+if [ -n "$LENPASTE_SESSION_LIFETIME" ]; then
+	RUN_CMD="$RUN_CMD -session-lifetime '$LENPASTE_SESSION_LIFETIME'"
+fi
+
+
 # Run Lenpaste
 echo "[ENTRYPOINT] $RUN_CMD"
 sh -c "$RUN_CMD"
@@ -6,6 +6,9 @@ replace github.com/lcomrade/lenpaste/internal => ./internal
 
 require (
 	github.com/alecthomas/chroma/v2 v2.4.0
+	github.com/go-ldap/ldap/v3 v3.4.6
 	github.com/lib/pq v1.10.7
 	github.com/mattn/go-sqlite3 v1.14.16
+	golang.org/x/crypto v0.17.0
+	golang.org/x/term v0.15.0
 )
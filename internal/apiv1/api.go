@@ -29,10 +29,11 @@ import (
 
 type Data struct {
 	Log logger.Logger
-	DB  storage.DB
+	DB  storage.Store
 
-	RateLimitNew *netshare.RateLimitSystem
-	RateLimitGet *netshare.RateLimitSystem
+	RateLimitNew    *netshare.RateLimitSystem
+	RateLimitGet    *netshare.RateLimitSystem
+	RateLimitSearch *netshare.RateLimitSystem
 
 	Lexers []string
 
@@ -49,12 +50,12 @@ type Data struct {
 	AdminName string
 	AdminMail string
 
-	LenPasswdFile string
+	Auth netshare.Auth
 
 	UiDefaultLifeTime string
 }
 
-func Load(db storage.DB, cfg config.Config) *Data {
+func Load(db storage.Store, cfg config.Config) *Data {
 	lexers := chromaLexers.Names(false)
 
 	return &Data{
@@ -62,6 +63,7 @@ func Load(db storage.DB, cfg config.Config) *Data {
 		Log:               cfg.Log,
 		RateLimitNew:      cfg.RateLimitNew,
 		RateLimitGet:      cfg.RateLimitGet,
+		RateLimitSearch:   cfg.RateLimitSearch,
 		Lexers:            lexers,
 		Version:           cfg.Version,
 		TitleMaxLen:       cfg.TitleMaxLen,
@@ -72,7 +74,7 @@ func Load(db storage.DB, cfg config.Config) *Data {
 		ServerTermsOfUse:  cfg.ServerTermsOfUse,
 		AdminName:         cfg.AdminName,
 		AdminMail:         cfg.AdminMail,
-		LenPasswdFile:     cfg.LenPasswdFile,
+		Auth:              cfg.Auth,
 		UiDefaultLifeTime: cfg.UiDefaultLifetime,
 	}
 }
@@ -83,16 +85,34 @@ func (data *Data) Hand(rw http.ResponseWriter, req *http.Request) {
 
 	rw.Header().Set("Server", config.Software+"/"+data.Version)
 
-	switch req.URL.Path {
-	// Search engines
-	case "/api/v1/new":
-		err = data.newHand(rw, req)
-	case "/api/v1/get":
-		err = data.getHand(rw, req)
-	case "/api/v1/getServerInfo":
-		err = data.getServerInfoHand(rw, req)
-	default:
-		err = netshare.ErrNotFound
+	// Check API token and ban list
+	req, err = netshare.AuthRequest(req, data.DB)
+	if err == nil {
+		err = netshare.CheckBanRequest(req, data.DB)
+	}
+
+	if err == nil {
+		switch req.URL.Path {
+		// Search engines
+		case "/api/v1/new":
+			err = data.newHand(rw, req)
+		case "/api/v1/get":
+			err = data.getHand(rw, req)
+		case "/api/v1/diff":
+			err = data.diffHand(rw, req)
+		case "/api/v1/edit":
+			err = data.editHand(rw, req)
+		case "/api/v1/delete":
+			err = data.deleteHand(rw, req)
+		case "/api/v1/list":
+			err = data.listHand(rw, req)
+		case "/api/v1/search":
+			err = data.searchHand(rw, req)
+		case "/api/v1/getServerInfo":
+			err = data.getServerInfoHand(rw, req)
+		default:
+			err = netshare.ErrNotFound
+		}
 	}
 
 	// Log
@@ -0,0 +1,66 @@
+// Copyright (C) 2021-2023 Leonid Maslakov.
+
+// This file is part of Lenpaste.
+
+// Lenpaste is free software: you can redistribute it
+// and/or modify it under the terms of the
+// GNU Affero Public License as published by the
+// Free Software Foundation, either version 3 of the License,
+// or (at your option) any later version.
+
+// Lenpaste is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+// or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero Public License for more details.
+
+// You should have received a copy of the GNU Affero Public License along with Lenpaste.
+// If not, see <https://www.gnu.org/licenses/>.
+
+package apiv1
+
+import (
+	"encoding/json"
+	"github.com/lcomrade/lenpaste/internal/netshare"
+	"net/http"
+)
+
+type deletePasteAnswer struct {
+	ID string `json:"id"`
+}
+
+// POST /api/v1/delete
+func (data *Data) deleteHand(rw http.ResponseWriter, req *http.Request) error {
+	// Check method
+	if req.Method != "POST" {
+		return netshare.ErrMethodNotAllowed
+	}
+
+	// Check rate limit
+	err := data.RateLimitNew.CheckAndUse(netshare.GetClientAddr(req))
+	if err != nil {
+		return err
+	}
+
+	// Read form
+	req.ParseForm()
+
+	pasteID := req.PostForm.Get("id")
+	deleteToken := req.PostForm.Get("deleteToken")
+
+	// Delete token is not needed with the API token
+	_, tokenExist := netshare.RequestToken(req)
+
+	if pasteID == "" || (deleteToken == "" && tokenExist == false) {
+		return netshare.ErrBadRequest
+	}
+
+	// Delete paste
+	err = netshare.PasteDeleteByToken(req, data.DB, pasteID, deleteToken)
+	if err != nil {
+		return err
+	}
+
+	// Return response
+	rw.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(rw).Encode(deletePasteAnswer{ID: pasteID})
+}
@@ -0,0 +1,99 @@
+// Copyright (C) 2021-2023 Leonid Maslakov.
+
+// This file is part of Lenpaste.
+
+// Lenpaste is free software: you can redistribute it
+// and/or modify it under the terms of the
+// GNU Affero Public License as published by the
+// Free Software Foundation, either version 3 of the License,
+// or (at your option) any later version.
+
+// Lenpaste is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+// or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero Public License for more details.
+
+// You should have received a copy of the GNU Affero Public License along with Lenpaste.
+// If not, see <https://www.gnu.org/licenses/>.
+
+package apiv1
+
+import (
+	"encoding/json"
+	"github.com/lcomrade/lenpaste/internal/diff"
+	"github.com/lcomrade/lenpaste/internal/netshare"
+	"net/http"
+	"strconv"
+)
+
+const maxDiffContext = 100
+
+type diffPasteInfo struct {
+	ID       string `json:"id"`
+	Revision int    `json:"revision"`
+	Syntax   string `json:"syntax"`
+}
+
+type diffAnswer struct {
+	A     diffPasteInfo `json:"a"`
+	B     diffPasteInfo `json:"b"`
+	Hunks []diff.Hunk   `json:"hunks"`
+}
+
+// GET /api/v1/diff
+func (data *Data) diffHand(rw http.ResponseWriter, req *http.Request) error {
+	// Check rate limit
+	err := data.RateLimitGet.CheckAndUse(netshare.GetClientAddr(req))
+	if err != nil {
+		return err
+	}
+
+	// Check method
+	if req.Method != "GET" {
+		return netshare.ErrMethodNotAllowed
+	}
+
+	// Read form
+	req.ParseForm()
+
+	idA := req.Form.Get("idA")
+	idB := req.Form.Get("idB")
+
+	if idA == "" || idB == "" {
+		return netshare.ErrBadRequest
+	}
+
+	context := 3
+	contextStr := req.Form.Get("context")
+	if contextStr != "" {
+		context, err = strconv.Atoi(contextStr)
+		if err != nil || context < 0 || context > maxDiffContext {
+			return netshare.ErrBadRequest
+		}
+	}
+
+	// Get pastes
+	pasteA, pasteB, err := netshare.PasteGetForDiff(
+		req, data.DB, data.RateLimitGet,
+		idA, req.Form.Get("revA"), req.Form.Get("passwordA"),
+		idB, req.Form.Get("revB"), req.Form.Get("passwordB"),
+	)
+	if err != nil {
+		return err
+	}
+
+	// Find difference
+	resp := diffAnswer{
+		A:     diffPasteInfo{ID: pasteA.ID, Revision: pasteA.Revision, Syntax: pasteA.Syntax},
+		B:     diffPasteInfo{ID: pasteB.ID, Revision: pasteB.Revision, Syntax: pasteB.Syntax},
+		Hunks: diff.Hunks(diff.Lines(diff.SplitLines(pasteA.Body), diff.SplitLines(pasteB.Body)), context),
+	}
+
+	if resp.Hunks == nil {
+		resp.Hunks = []diff.Hunk{}
+	}
+
+	// Return response
+	rw.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(rw).Encode(resp)
+}
@@ -0,0 +1,63 @@
+// Copyright (C) 2021-2023 Leonid Maslakov.
+
+// This file is part of Lenpaste.
+
+// Lenpaste is free software: you can redistribute it
+// and/or modify it under the terms of the
+// GNU Affero Public License as published by the
+// Free Software Foundation, either version 3 of the License,
+// or (at your option) any later version.
+
+// Lenpaste is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+// or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero Public License for more details.
+
+// You should have received a copy of the GNU Affero Public License along with Lenpaste.
+// If not, see <https://www.gnu.org/licenses/>.
+
+package apiv1
+
+import (
+	"encoding/json"
+	"github.com/lcomrade/lenpaste/internal/netshare"
+	"net/http"
+)
+
+type editPasteAnswer struct {
+	ID       string `json:"id"`
+	Revision int    `json:"revision"`
+	EditTime int64  `json:"editTime"`
+}
+
+// POST /api/v1/edit
+func (data *Data) editHand(rw http.ResponseWriter, req *http.Request) error {
+	// Check method
+	if req.Method != "POST" {
+		return netshare.ErrMethodNotAllowed
+	}
+
+	// Get paste ID and edit token
+	req.ParseForm()
+
+	pasteID := req.PostForm.Get("id")
+	editToken := req.PostForm.Get("editToken")
+
+	if pasteID == "" || editToken == "" {
+		return netshare.ErrBadRequest
+	}
+
+	// Get form data and save new revision
+	paste, err := netshare.PasteEditFromForm(req, data.DB, data.RateLimitNew, pasteID, editToken, data.TitleMaxLen, data.BodyMaxLen, data.Lexers)
+	if err != nil {
+		return err
+	}
+
+	// Return response
+	rw.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(rw).Encode(editPasteAnswer{
+		ID:       paste.ID,
+		Revision: paste.Revision,
+		EditTime: paste.EditTime,
+	})
+}
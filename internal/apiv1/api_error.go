@@ -30,12 +30,17 @@ import (
 type errorType struct {
 	Code  int    `json:"code"`
 	Error string `json:"error"`
+
+	// Only for the banned clients
+	BanReason     string `json:"banReason,omitempty"`
+	BanExpireTime int64  `json:"banExpireTime,omitempty"`
 }
 
 func (data *Data) writeError(rw http.ResponseWriter, req *http.Request, e error) (int, error) {
 	var resp errorType
 
 	var eTmp429 *netshare.ErrTooManyRequests
+	var eTmpBan *netshare.ErrBanned
 
 	if e == netshare.ErrBadRequest {
 		resp.Code = 400
@@ -46,10 +51,33 @@ func (data *Data) writeError(rw http.ResponseWriter, req *http.Request, e error)
 		resp.Code = 401
 		resp.Error = "Unauthorized"
 
+	} else if e == netshare.ErrPasswordRequired {
+		rw.Header().Add("WWW-Authenticate", "Basic")
+		resp.Code = 401
+		resp.Error = "Password Required"
+
+	} else if e == netshare.ErrForbidden {
+		resp.Code = 403
+		resp.Error = "Forbidden"
+
+	} else if e == netshare.ErrWrongPassword {
+		resp.Code = 403
+		resp.Error = "Wrong Password"
+
+	} else if errors.As(e, &eTmpBan) {
+		resp.Code = 403
+		resp.Error = "Banned"
+		resp.BanReason = eTmpBan.Reason
+		resp.BanExpireTime = eTmpBan.ExpireTime
+
 	} else if e == storage.ErrNotFoundID {
 		resp.Code = 404
 		resp.Error = "Could not find ID"
 
+	} else if e == storage.ErrNotFoundRevision {
+		resp.Code = 404
+		resp.Error = "Could not find revision"
+
 	} else if e == netshare.ErrNotFound {
 		resp.Code = 404
 		resp.Error = "Not Found"
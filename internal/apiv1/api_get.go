@@ -49,7 +49,19 @@ func (data *Data) getHand(rw http.ResponseWriter, req *http.Request) error {
 	}
 
 	// Get paste
-	paste, err := data.DB.PasteGet(pasteID)
+	paste, err := netshare.PasteGetRevision(data.DB, pasteID, req.Form.Get("rev"))
+	if err != nil {
+		return err
+	}
+
+	// Private paste can be opened only by its owner
+	err = netshare.PasteCheckVisibility(req, paste)
+	if err != nil {
+		return err
+	}
+
+	// Check paste password
+	err = netshare.PasteCheckPassword(req, paste, data.RateLimitGet, netshare.PastePassword(req, true))
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,81 @@
+// Copyright (C) 2021-2023 Leonid Maslakov.
+
+// This file is part of Lenpaste.
+
+// Lenpaste is free software: you can redistribute it
+// and/or modify it under the terms of the
+// GNU Affero Public License as published by the
+// Free Software Foundation, either version 3 of the License,
+// or (at your option) any later version.
+
+// Lenpaste is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+// or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero Public License for more details.
+
+// You should have received a copy of the GNU Affero Public License along with Lenpaste.
+// If not, see <https://www.gnu.org/licenses/>.
+
+package apiv1
+
+import (
+	"encoding/json"
+	"github.com/lcomrade/lenpaste/internal/netshare"
+	"net/http"
+)
+
+type listPaste struct {
+	ID          string `json:"id"`
+	Title       string `json:"title"`
+	Syntax      string `json:"syntax"`
+	CreateTime  int64  `json:"createTime"`
+	DeleteTime  int64  `json:"deleteTime"`
+	EditTime    int64  `json:"editTime"`
+	Author      string `json:"author"`
+	AuthorEmail string `json:"authorEmail"`
+	AuthorURL   string `json:"authorURL"`
+}
+
+type listAnswer struct {
+	Page     int         `json:"page"`
+	NextPage bool        `json:"nextPage"`
+	Pastes   []listPaste `json:"pastes"`
+}
+
+// GET /api/v1/list
+func (data *Data) listHand(rw http.ResponseWriter, req *http.Request) error {
+	// Check method
+	if req.Method != "GET" {
+		return netshare.ErrMethodNotAllowed
+	}
+
+	// Get recent public pastes
+	page, err := netshare.PasteList(req, data.DB, data.RateLimitGet)
+	if err != nil {
+		return err
+	}
+
+	resp := listAnswer{
+		Page:     page.Page,
+		NextPage: page.NextPage,
+		Pastes:   make([]listPaste, 0, len(page.Pastes)),
+	}
+
+	for _, paste := range page.Pastes {
+		resp.Pastes = append(resp.Pastes, listPaste{
+			ID:          paste.ID,
+			Title:       paste.Title,
+			Syntax:      paste.Syntax,
+			CreateTime:  paste.CreateTime,
+			DeleteTime:  paste.DeleteTime,
+			EditTime:    paste.EditTime,
+			Author:      paste.Author,
+			AuthorEmail: paste.AuthorEmail,
+			AuthorURL:   paste.AuthorURL,
+		})
+	}
+
+	// Return response
+	rw.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(rw).Encode(resp)
+}
@@ -20,15 +20,16 @@ package apiv1
 
 import (
 	"encoding/json"
-	"github.com/lcomrade/lenpaste/internal/lenpasswd"
 	"github.com/lcomrade/lenpaste/internal/netshare"
 	"net/http"
 )
 
 type newPasteAnswer struct {
-	ID         string `json:"id"`
-	CreateTime int64  `json:"createTime"`
-	DeleteTime int64  `json:"deleteTime"`
+	ID          string `json:"id"`
+	CreateTime  int64  `json:"createTime"`
+	DeleteTime  int64  `json:"deleteTime"`
+	DeleteToken string `json:"deleteToken"`
+	EditToken   string `json:"editToken"`
 }
 
 // POST /api/v1/new
@@ -36,20 +37,9 @@ func (data *Data) newHand(rw http.ResponseWriter, req *http.Request) error {
 	var err error
 
 	// Check auth
-	if data.LenPasswdFile != "" {
-		authOk := false
-
-		user, pass, authExist := req.BasicAuth()
-		if authExist == true {
-			authOk, err = lenpasswd.LoadAndCheck(data.LenPasswdFile, user, pass)
-			if err != nil {
-				return err
-			}
-		}
-
-		if authOk == false {
-			return netshare.ErrUnauthorized
-		}
+	err = netshare.CheckCreateAuth(req, data.Auth)
+	if err != nil {
+		return err
 	}
 
 	// Check method
@@ -58,12 +48,18 @@ func (data *Data) newHand(rw http.ResponseWriter, req *http.Request) error {
 	}
 
 	// Get form data and create paste
-	pasteID, createTime, deleteTime, err := netshare.PasteAddFromForm(req, data.DB, data.RateLimitNew, data.TitleMaxLen, data.BodyMaxLen, data.MaxLifeTime, data.Lexers)
+	paste, err := netshare.PasteAddFromForm(req, data.DB, data.RateLimitNew, data.TitleMaxLen, data.BodyMaxLen, data.MaxLifeTime, data.Lexers)
 	if err != nil {
 		return err
 	}
 
 	// Return response
 	rw.Header().Set("Content-Type", "application/json")
-	return json.NewEncoder(rw).Encode(newPasteAnswer{ID: pasteID, CreateTime: createTime, DeleteTime: deleteTime})
+	return json.NewEncoder(rw).Encode(newPasteAnswer{
+		ID:          paste.ID,
+		CreateTime:  paste.CreateTime,
+		DeleteTime:  paste.DeleteTime,
+		DeleteToken: paste.DeleteToken,
+		EditToken:   paste.EditToken,
+	})
 }
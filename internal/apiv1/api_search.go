@@ -0,0 +1,79 @@
+// Copyright (C) 2021-2023 Leonid Maslakov.
+
+// This file is part of Lenpaste.
+
+// Lenpaste is free software: you can redistribute it
+// and/or modify it under the terms of the
+// GNU Affero Public License as published by the
+// Free Software Foundation, either version 3 of the License,
+// or (at your option) any later version.
+
+// Lenpaste is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+// or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero Public License for more details.
+
+// You should have received a copy of the GNU Affero Public License along with Lenpaste.
+// If not, see <https://www.gnu.org/licenses/>.
+
+package apiv1
+
+import (
+	"encoding/json"
+	"github.com/lcomrade/lenpaste/internal/netshare"
+	"net/http"
+)
+
+type searchResult struct {
+	ID         string `json:"id"`
+	Title      string `json:"title"`
+	Syntax     string `json:"syntax"`
+	CreateTime int64  `json:"createTime"`
+	Snippet    string `json:"snippet"`
+}
+
+type searchAnswer struct {
+	Query    string         `json:"query"`
+	Page     int            `json:"page"`
+	NextPage bool           `json:"nextPage"`
+	Results  []searchResult `json:"results"`
+}
+
+// GET /api/v1/search
+func (data *Data) searchHand(rw http.ResponseWriter, req *http.Request) error {
+	// Check method
+	if req.Method != "GET" {
+		return netshare.ErrMethodNotAllowed
+	}
+
+	// Search pastes
+	page, err := netshare.PasteSearch(req, data.DB, data.RateLimitSearch)
+	if err != nil {
+		return err
+	}
+
+	if page.Query == "" {
+		return netshare.ErrBadRequest
+	}
+
+	resp := searchAnswer{
+		Query:    page.Query,
+		Page:     page.Page,
+		NextPage: page.NextPage,
+		Results:  make([]searchResult, 0, len(page.Results)),
+	}
+
+	for _, result := range page.Results {
+		resp.Results = append(resp.Results, searchResult{
+			ID:         result.ID,
+			Title:      result.Title,
+			Syntax:     result.Syntax,
+			CreateTime: result.CreateTime,
+			Snippet:    netshare.SearchSnippetHTML(result.Snippet),
+		})
+	}
+
+	// Return response
+	rw.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(rw).Encode(resp)
+}
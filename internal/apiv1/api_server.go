@@ -38,6 +38,7 @@ type serverInfoType struct {
 	Syntaxes          []string `json:"syntaxes"`
 	UiDefaultLifeTime string   `json:"uiDefaultLifeTime"`
 	AuthRequired      bool     `json:"authRequired"`
+	AuthMethod        string   `json:"authMethod"`
 }
 
 // GET /api/v1/getServerInfo
@@ -61,7 +62,8 @@ func (data *Data) getServerInfoHand(rw http.ResponseWriter, req *http.Request) e
 		AdminMail:         data.AdminMail,
 		Syntaxes:          data.Lexers,
 		UiDefaultLifeTime: data.UiDefaultLifeTime,
-		AuthRequired:      data.LenPasswdFile != "",
+		AuthRequired:      data.Auth.Required(),
+		AuthMethod:        data.Auth.Method(),
 	}
 
 	// Return response
@@ -0,0 +1,665 @@
+// Copyright (C) 2021-2023 Leonid Maslakov.
+
+// This file is part of Lenpaste.
+
+// Lenpaste is free software: you can redistribute it
+// and/or modify it under the terms of the
+// GNU Affero Public License as published by the
+// Free Software Foundation, either version 3 of the License,
+// or (at your option) any later version.
+
+// Lenpaste is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+// or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero Public License for more details.
+
+// You should have received a copy of the GNU Affero Public License along with Lenpaste.
+// If not, see <https://www.gnu.org/licenses/>.
+
+package apiv1
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lcomrade/lenpaste/internal/config"
+	"github.com/lcomrade/lenpaste/internal/logger"
+	"github.com/lcomrade/lenpaste/internal/netshare"
+	"github.com/lcomrade/lenpaste/internal/storage"
+)
+
+func newTestData() *Data {
+	return Load(storage.NewMemory(), config.Config{
+		Log:             logger.New("2006/01/02 15:04:05"),
+		RateLimitNew:    netshare.NewRateLimitSystem(0, 0, 0),
+		RateLimitGet:    netshare.NewRateLimitSystem(0, 0, 0),
+		RateLimitSearch: netshare.NewRateLimitSystem(0, 0, 0),
+		TitleMaxLen:     100,
+		BodyMaxLen:      20000,
+		MaxLifeTime:     -1,
+	})
+}
+
+func TestNewAndGet(t *testing.T) {
+	data := newTestData()
+
+	// Create paste
+	form := url.Values{}
+	form.Set("title", "Test")
+	form.Set("body", "Hello\r\nworld")
+
+	req := httptest.NewRequest("POST", "/api/v1/new", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rw := httptest.NewRecorder()
+	data.Hand(rw, req)
+
+	if rw.Code != http.StatusOK {
+		t.Fatal("expected 200 but got", rw.Code, rw.Body.String())
+	}
+
+	var newResp newPasteAnswer
+	err := json.NewDecoder(rw.Body).Decode(&newResp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Get paste
+	req = httptest.NewRequest("GET", "/api/v1/get?id="+newResp.ID, nil)
+	rw = httptest.NewRecorder()
+	data.Hand(rw, req)
+
+	if rw.Code != http.StatusOK {
+		t.Fatal("expected 200 but got", rw.Code, rw.Body.String())
+	}
+
+	var paste storage.Paste
+	err = json.NewDecoder(rw.Body).Decode(&paste)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if paste.Title != "Test" || paste.Body != "Hello\nworld" || paste.Syntax != "plaintext" {
+		t.Error("unexpected paste:", paste)
+	}
+
+	// Get unknown paste
+	req = httptest.NewRequest("GET", "/api/v1/get?id=unknown", nil)
+	rw = httptest.NewRecorder()
+	data.Hand(rw, req)
+
+	if rw.Code != http.StatusNotFound {
+		t.Error("expected 404 but got", rw.Code)
+	}
+}
+
+func postForm(data *Data, path string, form url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", path, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rw := httptest.NewRecorder()
+	data.Hand(rw, req)
+
+	return rw
+}
+
+func TestDelete(t *testing.T) {
+	data := newTestData()
+
+	// Create paste
+	rw := postForm(data, "/api/v1/new", url.Values{"body": {"Hello"}})
+	if rw.Code != http.StatusOK {
+		t.Fatal("expected 200 but got", rw.Code, rw.Body.String())
+	}
+
+	var newResp newPasteAnswer
+	err := json.NewDecoder(rw.Body).Decode(&newResp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if newResp.DeleteToken == "" {
+		t.Fatal("delete token is empty")
+	}
+
+	// Wrong token
+	rw = postForm(data, "/api/v1/delete", url.Values{"id": {newResp.ID}, "deleteToken": {"wrong"}})
+	if rw.Code != http.StatusForbidden {
+		t.Fatal("expected 403 but got", rw.Code)
+	}
+
+	// Right token
+	rw = postForm(data, "/api/v1/delete", url.Values{"id": {newResp.ID}, "deleteToken": {newResp.DeleteToken}})
+	if rw.Code != http.StatusOK {
+		t.Fatal("expected 200 but got", rw.Code, rw.Body.String())
+	}
+
+	_, err = data.DB.PasteGet(newResp.ID)
+	if err != storage.ErrNotFoundID {
+		t.Error("expected ErrNotFoundID but got", err)
+	}
+}
+
+func TestEditAndGetRevision(t *testing.T) {
+	data := newTestData()
+
+	// Create paste
+	rw := postForm(data, "/api/v1/new", url.Values{"title": {"v1"}, "body": {"Body 1"}})
+	if rw.Code != http.StatusOK {
+		t.Fatal("expected 200 but got", rw.Code, rw.Body.String())
+	}
+
+	var newResp newPasteAnswer
+	err := json.NewDecoder(rw.Body).Decode(&newResp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Wrong token
+	rw = postForm(data, "/api/v1/edit", url.Values{"id": {newResp.ID}, "editToken": {newResp.DeleteToken}, "body": {"Body 2"}})
+	if rw.Code != http.StatusForbidden {
+		t.Fatal("expected 403 but got", rw.Code)
+	}
+
+	// Edit paste
+	rw = postForm(data, "/api/v1/edit", url.Values{"id": {newResp.ID}, "editToken": {newResp.EditToken}, "title": {"v2"}, "body": {"Body 2"}})
+	if rw.Code != http.StatusOK {
+		t.Fatal("expected 200 but got", rw.Code, rw.Body.String())
+	}
+
+	var editResp editPasteAnswer
+	err = json.NewDecoder(rw.Body).Decode(&editResp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if editResp.Revision != 2 {
+		t.Error("expected revision 2 but got", editResp.Revision)
+	}
+
+	// Get revisions
+	for rev, title := range map[string]string{"": "v2", "1": "v1", "2": "v2"} {
+		req := httptest.NewRequest("GET", "/api/v1/get?id="+newResp.ID+"&rev="+rev, nil)
+		rw = httptest.NewRecorder()
+		data.Hand(rw, req)
+
+		if rw.Code != http.StatusOK {
+			t.Fatal("expected 200 but got", rw.Code, rw.Body.String())
+		}
+
+		var paste storage.Paste
+		err = json.NewDecoder(rw.Body).Decode(&paste)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if paste.Title != title {
+			t.Error("rev", rev, "expected title", title, "but got", paste.Title)
+		}
+	}
+
+	// Unknown revision
+	req := httptest.NewRequest("GET", "/api/v1/get?id="+newResp.ID+"&rev=3", nil)
+	rw = httptest.NewRecorder()
+	data.Hand(rw, req)
+
+	if rw.Code != http.StatusNotFound {
+		t.Error("expected 404 but got", rw.Code)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	data := newTestData()
+
+	// Create pastes
+	var ids []string
+	for _, body := range []string{"a\nb\nc", "a\nB\nc"} {
+		rw := postForm(data, "/api/v1/new", url.Values{"body": {body}})
+		if rw.Code != http.StatusOK {
+			t.Fatal("expected 200 but got", rw.Code, rw.Body.String())
+		}
+
+		var newResp newPasteAnswer
+		err := json.NewDecoder(rw.Body).Decode(&newResp)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ids = append(ids, newResp.ID)
+	}
+
+	// Get diff
+	req := httptest.NewRequest("GET", "/api/v1/diff?idA="+ids[0]+"&idB="+ids[1]+"&context=0", nil)
+	rw := httptest.NewRecorder()
+	data.Hand(rw, req)
+
+	if rw.Code != http.StatusOK {
+		t.Fatal("expected 200 but got", rw.Code, rw.Body.String())
+	}
+
+	var resp diffAnswer
+	err := json.NewDecoder(rw.Body).Decode(&resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(resp.Hunks) != 1 || len(resp.Hunks[0].Lines) != 2 || resp.Hunks[0].Lines[0].Text != "b" || resp.Hunks[0].Lines[1].Text != "B" {
+		t.Error("unexpected hunks:", resp.Hunks)
+	}
+}
+
+func TestPassword(t *testing.T) {
+	data := newTestData()
+
+	// Create paste
+	rw := postForm(data, "/api/v1/new", url.Values{"body": {"Secret"}, "password": {"qwerty"}})
+	if rw.Code != http.StatusOK {
+		t.Fatal("expected 200 but got", rw.Code, rw.Body.String())
+	}
+
+	var newResp newPasteAnswer
+	err := json.NewDecoder(rw.Body).Decode(&newResp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Without password
+	req := httptest.NewRequest("GET", "/api/v1/get?id="+newResp.ID, nil)
+	rw = httptest.NewRecorder()
+	data.Hand(rw, req)
+	if rw.Code != http.StatusUnauthorized {
+		t.Fatal("expected 401 but got", rw.Code)
+	}
+
+	if strings.Contains(rw.Body.String(), "Secret") {
+		t.Fatal("paste body leaked:", rw.Body.String())
+	}
+
+	// Wrong password
+	req = httptest.NewRequest("GET", "/api/v1/get?id="+newResp.ID+"&password=wrong", nil)
+	rw = httptest.NewRecorder()
+	data.Hand(rw, req)
+	if rw.Code != http.StatusForbidden {
+		t.Fatal("expected 403 but got", rw.Code)
+	}
+
+	// Right password
+	req = httptest.NewRequest("GET", "/api/v1/get?id="+newResp.ID+"&password=qwerty", nil)
+	rw = httptest.NewRecorder()
+	data.Hand(rw, req)
+	if rw.Code != http.StatusOK || strings.Contains(rw.Body.String(), "Secret") == false {
+		t.Fatal("expected 200 but got", rw.Code, rw.Body.String())
+	}
+
+	// HTTP Basic authentication
+	req = httptest.NewRequest("GET", "/api/v1/get?id="+newResp.ID, nil)
+	req.SetBasicAuth("", "qwerty")
+	rw = httptest.NewRecorder()
+	data.Hand(rw, req)
+	if rw.Code != http.StatusOK {
+		t.Fatal("expected 200 but got", rw.Code, rw.Body.String())
+	}
+}
+
+func TestEncrypted(t *testing.T) {
+	data := newTestData()
+
+	// Not encrypted body
+	rw := postForm(data, "/api/v1/new", url.Values{"body": {"Hello"}, "encrypted": {"true"}})
+	if rw.Code != http.StatusBadRequest {
+		t.Fatal("expected 400 but got", rw.Code)
+	}
+
+	// Too short body
+	rw = postForm(data, "/api/v1/new", url.Values{"body": {"AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA"}, "encrypted": {"true"}})
+	if rw.Code != http.StatusBadRequest {
+		t.Fatal("expected 400 but got", rw.Code)
+	}
+
+	// Ciphertext of the longest allowed text
+	body := base64.StdEncoding.EncodeToString(make([]byte, 20000*4+netshare.EncryptedNonceSize+netshare.EncryptedTagSize))
+	rw = postForm(data, "/api/v1/new", url.Values{"body": {body}, "encrypted": {"true"}, "syntax": {"Go"}, "lineEnd": {"CRLF"}})
+	if rw.Code != http.StatusOK {
+		t.Fatal("expected 200 but got", rw.Code, rw.Body.String())
+	}
+
+	var newResp newPasteAnswer
+	err := json.NewDecoder(rw.Body).Decode(&newResp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rw = postForm(data, "/api/v1/new", url.Values{"body": {body + "AAAA"}, "encrypted": {"true"}})
+	if rw.Code != http.StatusRequestEntityTooLarge {
+		t.Fatal("expected 413 but got", rw.Code)
+	}
+
+	// Body must be stored as is
+	paste, err := data.DB.PasteGet(newResp.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if paste.Encrypted == false || paste.Body != body {
+		t.Error("encrypted paste was changed")
+	}
+}
+
+func TestFiles(t *testing.T) {
+	data := newTestData()
+
+	// Duplicate file names
+	rw := postForm(data, "/api/v1/new", url.Values{
+		"body":            {"FROM alpine"},
+		"fileName":        {"Dockerfile"},
+		"extraFileName":   {"Dockerfile"},
+		"extraFileBody":   {"#!/bin/sh"},
+		"extraFileSyntax": {"Bash"},
+	})
+	if rw.Code != http.StatusBadRequest {
+		t.Fatal("expected 400 but got", rw.Code)
+	}
+
+	// Create paste
+	rw = postForm(data, "/api/v1/new", url.Values{
+		"body":            {"FROM alpine"},
+		"syntax":          {"Docker"},
+		"fileName":        {"Dockerfile"},
+		"extraFileName":   {"entrypoint.sh", ""},
+		"extraFileBody":   {"#!/bin/sh\r\n", "log"},
+		"extraFileSyntax": {"Bash", ""},
+	})
+	if rw.Code != http.StatusOK {
+		t.Fatal("expected 200 but got", rw.Code, rw.Body.String())
+	}
+
+	var newResp newPasteAnswer
+	err := json.NewDecoder(rw.Body).Decode(&newResp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Get paste
+	req := httptest.NewRequest("GET", "/api/v1/get?id="+newResp.ID, nil)
+	rw = httptest.NewRecorder()
+	data.Hand(rw, req)
+
+	var paste storage.Paste
+	err = json.NewDecoder(rw.Body).Decode(&paste)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect := []storage.PasteFile{
+		{Name: "Dockerfile", Body: "FROM alpine", Syntax: "Docker"},
+		{Name: "entrypoint.sh", Body: "#!/bin/sh\n", Syntax: "Bash"},
+		{Name: "file3", Body: "log", Syntax: "plaintext"},
+	}
+
+	if len(paste.Files) != len(expect) {
+		t.Fatal("unexpected files:", paste.Files)
+	}
+
+	for i := range expect {
+		if paste.Files[i] != expect[i] {
+			t.Error("expected", expect[i], "but got", paste.Files[i])
+		}
+	}
+
+	// Multi-file paste can not be edited
+	rw = postForm(data, "/api/v1/edit", url.Values{"id": {newResp.ID}, "editToken": {newResp.EditToken}, "body": {"New"}})
+	if rw.Code != http.StatusBadRequest {
+		t.Error("expected 400 but got", rw.Code)
+	}
+}
+
+func TestFork(t *testing.T) {
+	data := newTestData()
+
+	// Fork of unknown paste
+	rw := postForm(data, "/api/v1/new", url.Values{
+		"body":   {"Forked text."},
+		"forkOf": {"unknown"},
+	})
+	if rw.Code != http.StatusNotFound {
+		t.Fatal("expected 404 but got", rw.Code)
+	}
+
+	// Create original paste
+	rw = postForm(data, "/api/v1/new", url.Values{"body": {"Original text."}})
+	if rw.Code != http.StatusOK {
+		t.Fatal("expected 200 but got", rw.Code, rw.Body.String())
+	}
+
+	var origResp newPasteAnswer
+	err := json.NewDecoder(rw.Body).Decode(&origResp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Create fork
+	rw = postForm(data, "/api/v1/new", url.Values{
+		"body":   {"Forked text."},
+		"forkOf": {origResp.ID},
+	})
+	if rw.Code != http.StatusOK {
+		t.Fatal("expected 200 but got", rw.Code, rw.Body.String())
+	}
+
+	var forkResp newPasteAnswer
+	err = json.NewDecoder(rw.Body).Decode(&forkResp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Get fork
+	req := httptest.NewRequest("GET", "/api/v1/get?id="+forkResp.ID, nil)
+	rw = httptest.NewRecorder()
+	data.Hand(rw, req)
+
+	var paste storage.Paste
+	err = json.NewDecoder(rw.Body).Decode(&paste)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if paste.ForkedFrom != origResp.ID {
+		t.Error("expected forkedFrom", origResp.ID, "but got", paste.ForkedFrom)
+	}
+
+	// Fork of the paste that the client can not read
+	for _, form := range []url.Values{
+		{"body": {"Private text."}, "visibility": {"private"}},
+		{"body": {"Protected text."}, "password": {"secret"}},
+	} {
+		rw = postForm(data, "/api/v1/new", form)
+		if rw.Code != http.StatusOK {
+			t.Fatal("expected 200 but got", rw.Code, rw.Body.String())
+		}
+
+		var hiddenResp newPasteAnswer
+		err = json.NewDecoder(rw.Body).Decode(&hiddenResp)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rw = postForm(data, "/api/v1/new", url.Values{
+			"body":   {"Forked text."},
+			"forkOf": {hiddenResp.ID},
+		})
+		if rw.Code == http.StatusOK {
+			t.Error("expected error but got 200 for", form)
+		}
+
+		if form.Get("visibility") == "private" && rw.Code != http.StatusNotFound {
+			t.Error("expected 404 but got", rw.Code)
+		}
+	}
+}
+
+func TestSearch(t *testing.T) {
+	data := newTestData()
+
+	// Public paste can not be secret
+	rw := postForm(data, "/api/v1/new", url.Values{
+		"body":       {"Public text."},
+		"visibility": {"public"},
+		"oneUse":     {"true"},
+	})
+	if rw.Code != http.StatusBadRequest {
+		t.Fatal("expected 400 but got", rw.Code)
+	}
+
+	// Create pastes
+	rw = postForm(data, "/api/v1/new", url.Values{"body": {"Public <text>."}, "visibility": {"public"}})
+	if rw.Code != http.StatusOK {
+		t.Fatal("expected 200 but got", rw.Code, rw.Body.String())
+	}
+
+	rw = postForm(data, "/api/v1/new", url.Values{"body": {"Private text."}})
+	if rw.Code != http.StatusOK {
+		t.Fatal("expected 200 but got", rw.Code, rw.Body.String())
+	}
+
+	// Search
+	req := httptest.NewRequest("GET", "/api/v1/search?q=text", nil)
+	rw = httptest.NewRecorder()
+	data.Hand(rw, req)
+
+	if rw.Code != http.StatusOK {
+		t.Fatal("expected 200 but got", rw.Code, rw.Body.String())
+	}
+
+	var resp searchAnswer
+	err := json.NewDecoder(rw.Body).Decode(&resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(resp.Results) != 1 || resp.NextPage {
+		t.Fatal("expected one result but got", resp)
+	}
+
+	if resp.Results[0].Snippet != "Public &lt;<mark>text</mark>&gt;." {
+		t.Error("unexpected snippet:", resp.Results[0].Snippet)
+	}
+
+	// Empty query
+	req = httptest.NewRequest("GET", "/api/v1/search?q=", nil)
+	rw = httptest.NewRecorder()
+	data.Hand(rw, req)
+
+	if rw.Code != http.StatusBadRequest {
+		t.Error("expected 400 but got", rw.Code)
+	}
+
+	// Offset of the page does not fit into int
+	req = httptest.NewRequest("GET", "/api/v1/search?q=text&page=461168601842738792", nil)
+	rw = httptest.NewRecorder()
+	data.Hand(rw, req)
+
+	if rw.Code != http.StatusBadRequest {
+		t.Error("expected 400 but got", rw.Code)
+	}
+}
+
+func TestVisibility(t *testing.T) {
+	data := newTestData()
+
+	// Unknown visibility
+	rw := postForm(data, "/api/v1/new", url.Values{"body": {"Text."}, "visibility": {"hidden"}})
+	if rw.Code != http.StatusBadRequest {
+		t.Fatal("expected 400 but got", rw.Code)
+	}
+
+	// Create pastes
+	ids := make(map[string]newPasteAnswer)
+	for _, visibility := range []string{"private", "unlisted", "public"} {
+		rw = postForm(data, "/api/v1/new", url.Values{"body": {"Text."}, "visibility": {visibility}})
+		if rw.Code != http.StatusOK {
+			t.Fatal("expected 200 but got", rw.Code, rw.Body.String())
+		}
+
+		var resp newPasteAnswer
+		err := json.NewDecoder(rw.Body).Decode(&resp)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ids[visibility] = resp
+	}
+
+	// Private paste can be got only with the edit token
+	req := httptest.NewRequest("GET", "/api/v1/get?id="+ids["private"].ID, nil)
+	rw = httptest.NewRecorder()
+	data.Hand(rw, req)
+
+	if rw.Code != http.StatusNotFound {
+		t.Error("expected 404 but got", rw.Code)
+	}
+
+	req = httptest.NewRequest("GET", "/api/v1/get?id="+ids["private"].ID+"&editToken="+ids["private"].EditToken, nil)
+	rw = httptest.NewRecorder()
+	data.Hand(rw, req)
+
+	if rw.Code != http.StatusOK {
+		t.Error("expected 200 but got", rw.Code)
+	}
+
+	// Only public paste is listed
+	req = httptest.NewRequest("GET", "/api/v1/list", nil)
+	rw = httptest.NewRecorder()
+	data.Hand(rw, req)
+
+	var list listAnswer
+	err := json.NewDecoder(rw.Body).Decode(&list)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(list.Pastes) != 1 || list.Pastes[0].ID != ids["public"].ID || list.NextPage {
+		t.Error("unexpected list:", list)
+	}
+
+	// Offset of the page does not fit into int
+	for _, page := range []string{"0", "-1", "461168601842738792"} {
+		req = httptest.NewRequest("GET", "/api/v1/list?page="+page, nil)
+		rw = httptest.NewRecorder()
+		data.Hand(rw, req)
+
+		if rw.Code != http.StatusBadRequest {
+			t.Error("expected 400 for page", page, "but got", rw.Code)
+		}
+	}
+}
+
+func TestBan(t *testing.T) {
+	data := newTestData()
+
+	expireTime := time.Now().Unix() + 3600
+
+	_, err := data.DB.BanAdd(storage.Ban{CIDR: "192.0.2.1/32", Reason: "Spam", ExpireTime: expireTime})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// httptest uses 192.0.2.1
+	rw := postForm(data, "/api/v1/new", url.Values{"body": {"Hello"}})
+	if rw.Code != http.StatusForbidden {
+		t.Fatal("expected 403 but got", rw.Code, rw.Body.String())
+	}
+
+	var resp errorType
+	err = json.NewDecoder(rw.Body).Decode(&resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.Error != "Banned" || resp.BanReason != "Spam" || resp.BanExpireTime != expireTime {
+		t.Errorf("unexpected error: %+v", resp)
+	}
+}
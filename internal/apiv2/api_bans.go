@@ -0,0 +1,112 @@
+// Copyright (C) 2021-2023 Leonid Maslakov.
+
+// This file is part of Lenpaste.
+
+// Lenpaste is free software: you can redistribute it
+// and/or modify it under the terms of the
+// GNU Affero Public License as published by the
+// Free Software Foundation, either version 3 of the License,
+// or (at your option) any later version.
+
+// Lenpaste is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+// or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero Public License for more details.
+
+// You should have received a copy of the GNU Affero Public License along with Lenpaste.
+// If not, see <https://www.gnu.org/licenses/>.
+
+package apiv2
+
+import (
+	"encoding/json"
+	"github.com/lcomrade/lenpaste/internal/netshare"
+	"github.com/lcomrade/lenpaste/internal/storage"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+type newBanRequest struct {
+	Range      string `json:"range"`
+	Reason     string `json:"reason"`
+	Expiration int64  `json:"expiration"`
+}
+
+type banListAnswer struct {
+	Bans []storage.Ban `json:"bans"`
+}
+
+type deleteBanAnswer struct {
+	ID string `json:"id"`
+}
+
+// Pattern: /api/v2/bans
+func (data *Data) bansHand(rw http.ResponseWriter, req *http.Request) error {
+	switch req.Method {
+	case "GET":
+		return data.banListHand(rw, req)
+	case "POST":
+		return data.banAddHand(rw, req)
+	}
+
+	return netshare.ErrMethodNotAllowed
+}
+
+// GET /api/v2/bans
+func (data *Data) banListHand(rw http.ResponseWriter, req *http.Request) error {
+	bans, err := netshare.AdminBanList(req, data.DB)
+	if err != nil {
+		return err
+	}
+
+	if bans == nil {
+		bans = []storage.Ban{}
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(rw).Encode(banListAnswer{Bans: bans})
+}
+
+// POST /api/v2/bans
+func (data *Data) banAddHand(rw http.ResponseWriter, req *http.Request) error {
+	_, err := netshare.RequestAdmin(req)
+	if err != nil {
+		return err
+	}
+
+	var newBan newBanRequest
+	err = data.readJSON(req, &newBan)
+	if err != nil {
+		return err
+	}
+
+	ban, err := netshare.AdminBanAdd(req, data.DB, newBan.Range, newBan.Reason, newBan.Expiration, "")
+	if err != nil {
+		return err
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Header().Set("Location", "/api/v2/bans/"+url.PathEscape(ban.ID))
+	rw.WriteHeader(http.StatusCreated)
+	return json.NewEncoder(rw).Encode(ban)
+}
+
+// Pattern: /api/v2/bans/{id}
+func (data *Data) banHand(rw http.ResponseWriter, req *http.Request, banID string) error {
+	if banID == "" || strings.Contains(banID, "/") {
+		return netshare.ErrNotFound
+	}
+
+	if req.Method != "DELETE" {
+		return netshare.ErrMethodNotAllowed
+	}
+
+	err := netshare.AdminBanDelete(req, data.DB, banID)
+	if err != nil {
+		return err
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(rw).Encode(deleteBanAnswer{ID: banID})
+}
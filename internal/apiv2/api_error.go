@@ -0,0 +1,136 @@
+// Copyright (C) 2021-2023 Leonid Maslakov.
+
+// This file is part of Lenpaste.
+
+// Lenpaste is free software: you can redistribute it
+// and/or modify it under the terms of the
+// GNU Affero Public License as published by the
+// Free Software Foundation, either version 3 of the License,
+// or (at your option) any later version.
+
+// Lenpaste is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+// or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero Public License for more details.
+
+// You should have received a copy of the GNU Affero Public License along with Lenpaste.
+// If not, see <https://www.gnu.org/licenses/>.
+
+package apiv2
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/lcomrade/lenpaste/internal/netshare"
+	"github.com/lcomrade/lenpaste/internal/storage"
+	"net/http"
+	"sort"
+	"strconv"
+)
+
+var (
+	errInvalidJSON          = errors.New("apiv2: invalid JSON")
+	errUnsupportedMediaType = errors.New("apiv2: unsupported media type")
+)
+
+type errorType struct {
+	Error errorInfo `json:"error"`
+}
+
+type errorInfo struct {
+	Status     int    `json:"status"`
+	Code       string `json:"code"`
+	Message    string `json:"message"`
+	RetryAfter int64  `json:"retryAfter,omitempty"`
+
+	// Only for the banned clients
+	BanReason     string `json:"banReason,omitempty"`
+	BanExpireTime int64  `json:"banExpireTime,omitempty"`
+}
+
+// errorList maps known errors to the API error codes.
+// The codes are listed in the ErrorInfo schema of the OpenAPI document, see errorCodes.
+var errorList = []struct {
+	err  error
+	info errorInfo
+}{
+	{netshare.ErrBadRequest, errorInfo{Status: 400, Code: "bad_request", Message: "Bad Request"}},
+	{errInvalidJSON, errorInfo{Status: 400, Code: "invalid_json", Message: "Request body is not a valid JSON"}},
+	{netshare.ErrUnauthorized, errorInfo{Status: 401, Code: "unauthorized", Message: "Unauthorized"}},
+	{netshare.ErrPasswordRequired, errorInfo{Status: 401, Code: "password_required", Message: "Password Required"}},
+	{netshare.ErrForbidden, errorInfo{Status: 403, Code: "forbidden", Message: "Forbidden"}},
+	{netshare.ErrWrongPassword, errorInfo{Status: 403, Code: "wrong_password", Message: "Wrong Password"}},
+	{netshare.ErrNotFound, errorInfo{Status: 404, Code: "not_found", Message: "Not Found"}},
+	{storage.ErrNotFoundID, errorInfo{Status: 404, Code: "paste_not_found", Message: "Could not find ID"}},
+	{storage.ErrNotFoundRevision, errorInfo{Status: 404, Code: "revision_not_found", Message: "Could not find revision"}},
+	{netshare.ErrMethodNotAllowed, errorInfo{Status: 405, Code: "method_not_allowed", Message: "Method Not Allowed"}},
+	{netshare.ErrPayloadTooLarge, errorInfo{Status: 413, Code: "payload_too_large", Message: "Payload Too Large"}},
+	{errUnsupportedMediaType, errorInfo{Status: 415, Code: "unsupported_media_type", Message: "Content-Type must be application/json"}},
+}
+
+// errorCodes returns all API error codes sorted by the HTTP status.
+func errorCodes() []string {
+	var infos []errorInfo
+	for _, item := range errorList {
+		infos = append(infos, item.info)
+	}
+
+	infos = append(infos, errorBanned, errorTooManyRequests, errorInternal)
+
+	sort.SliceStable(infos, func(i, j int) bool {
+		return infos[i].Status < infos[j].Status
+	})
+
+	codes := make([]string, len(infos))
+	for i, info := range infos {
+		codes[i] = info.Code
+	}
+
+	return codes
+}
+
+var (
+	errorBanned          = errorInfo{Status: 403, Code: "banned", Message: "Your IP address is banned"}
+	errorTooManyRequests = errorInfo{Status: 429, Code: "too_many_requests", Message: "Too Many Requests"}
+	errorInternal        = errorInfo{Status: 500, Code: "internal_error", Message: "Internal Server Error"}
+)
+
+func (data *Data) writeError(rw http.ResponseWriter, req *http.Request, e error) (int, error) {
+	resp := errorType{Error: errorInternal}
+
+	var eTmp429 *netshare.ErrTooManyRequests
+	var eTmpBan *netshare.ErrBanned
+
+	if errors.As(e, &eTmp429) {
+		resp.Error = errorTooManyRequests
+		resp.Error.RetryAfter = eTmp429.RetryAfter
+		rw.Header().Set("Retry-After", strconv.FormatInt(eTmp429.RetryAfter, 10))
+
+	} else if errors.As(e, &eTmpBan) {
+		resp.Error = errorBanned
+		resp.Error.BanReason = eTmpBan.Reason
+		resp.Error.BanExpireTime = eTmpBan.ExpireTime
+
+	} else {
+		for _, item := range errorList {
+			if e == item.err {
+				resp.Error = item.info
+				break
+			}
+		}
+	}
+
+	if resp.Error.Status == 401 {
+		rw.Header().Add("WWW-Authenticate", "Basic")
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(resp.Error.Status)
+
+	err := json.NewEncoder(rw).Encode(resp)
+	if err != nil {
+		return 500, err
+	}
+
+	return resp.Error.Status, nil
+}
@@ -0,0 +1,446 @@
+// Copyright (C) 2021-2023 Leonid Maslakov.
+
+// This file is part of Lenpaste.
+
+// Lenpaste is free software: you can redistribute it
+// and/or modify it under the terms of the
+// GNU Affero Public License as published by the
+// Free Software Foundation, either version 3 of the License,
+// or (at your option) any later version.
+
+// Lenpaste is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+// or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero Public License for more details.
+
+// You should have received a copy of the GNU Affero Public License along with Lenpaste.
+// If not, see <https://www.gnu.org/licenses/>.
+
+package apiv2
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/lcomrade/lenpaste/internal/netshare"
+	"github.com/lcomrade/lenpaste/internal/storage"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// openAPITemplate is the OpenAPI document without the types of the schema properties.
+// It keeps the paths and the descriptions, default values and examples of the properties.
+//
+//go:embed openapi.json
+var openAPITemplate []byte
+
+// openAPISchemaTypes are the Go types of the schemas.
+// Properties of these schemas, their types and references are generated from the struct fields with JSON tags.
+var openAPISchemaTypes = map[string]interface{}{
+	"NewPaste":     newPasteRequest{},
+	"PasteFile":    storage.PasteFile{},
+	"CreatedPaste": newPasteAnswer{},
+	"Paste":        storage.Paste{},
+	"DeletedPaste": deletePasteAnswer{},
+	"NewBan":       newBanRequest{},
+	"Ban":          storage.Ban{},
+	"BanList":      banListAnswer{},
+	"DeletedBan":   deleteBanAnswer{},
+	"ServerInfo":   serverInfoType{},
+	"Error":        errorType{},
+	"ErrorInfo":    errorInfo{},
+}
+
+// openAPISchemaAliases are other Go types that are described by the same schemas.
+var openAPISchemaAliases = map[string]interface{}{
+	"PasteFile": newPasteRequestFile{},
+}
+
+// openAPIEnums returns the allowed values of the schema properties.
+// The key is the schema name and the property name separated by a dot.
+func openAPIEnums() map[string][]string {
+	return map[string][]string{
+		"NewPaste.lineEnd":      netshare.LineEnds,
+		"NewPaste.visibility":   storage.Visibilities,
+		"ServerInfo.authMethod": netshare.AuthMethods,
+		"ErrorInfo.code":        errorCodes(),
+	}
+}
+
+var (
+	openAPIOnce sync.Once
+	openAPIJSON []byte
+	openAPIErr  error
+)
+
+// openAPIDocument returns the OpenAPI document served at /api/v2/openapi.json.
+// It is generated once from the template and the Go types.
+func openAPIDocument() ([]byte, error) {
+	openAPIOnce.Do(func() {
+		openAPIJSON, openAPIErr = buildOpenAPI()
+	})
+
+	return openAPIJSON, openAPIErr
+}
+
+// buildOpenAPI adds the properties of the schemas from openAPISchemaTypes and openAPIEnums to the template.
+// Every property of the template must be the field of the Go type and vice versa.
+func buildOpenAPI() ([]byte, error) {
+	var doc map[string]interface{}
+
+	err := json.Unmarshal(openAPITemplate, &doc)
+	if err != nil {
+		return nil, err
+	}
+
+	components, _ := doc["components"].(map[string]interface{})
+	schemas, _ := components["schemas"].(map[string]interface{})
+
+	refs := make(map[reflect.Type]string)
+	for name, value := range openAPISchemaTypes {
+		refs[reflect.TypeOf(value)] = name
+	}
+
+	for name, value := range openAPISchemaAliases {
+		refs[reflect.TypeOf(value)] = name
+	}
+
+	enums := openAPIEnums()
+	usedEnums := 0
+
+	for name, value := range openAPISchemaTypes {
+		schema, ok := schemas[name].(map[string]interface{})
+		if ok == false {
+			return nil, errors.New("apiv2: OpenAPI schema " + name + " is not described")
+		}
+
+		tmplProps, _ := schema["properties"].(map[string]interface{})
+
+		props, err := openAPIProperties(reflect.TypeOf(value), refs)
+		if err != nil {
+			return nil, errors.New("apiv2: OpenAPI schema " + name + ": " + err.Error())
+		}
+
+		for propName, tmplProp := range tmplProps {
+			prop, ok := props[propName]
+			if ok == false {
+				return nil, errors.New("apiv2: OpenAPI schema " + name + ": unknown property " + propName)
+			}
+
+			tmplPropMap, _ := tmplProp.(map[string]interface{})
+			for key, value := range tmplPropMap {
+				if _, generated := prop[key]; generated {
+					return nil, errors.New("apiv2: OpenAPI schema " + name + ": property " + propName + ": " + key + " is generated")
+				}
+
+				prop[key] = value
+			}
+		}
+
+		for propName, prop := range props {
+			if _, ok := tmplProps[propName]; ok == false {
+				return nil, errors.New("apiv2: OpenAPI schema " + name + ": property " + propName + " is not described")
+			}
+
+			enum, ok := enums[name+"."+propName]
+			if ok {
+				prop["enum"] = enum
+				usedEnums++
+			}
+		}
+
+		schema["properties"] = props
+	}
+
+	if usedEnums != len(enums) {
+		return nil, errors.New("apiv2: OpenAPI enum of unknown property")
+	}
+
+	return json.MarshalIndent(doc, "", "\t")
+}
+
+// openAPIProperties returns the properties of the struct type.
+// Property name is read from the JSON tag, fields without it are skipped.
+func openAPIProperties(t reflect.Type, refs map[reflect.Type]string) (map[string]map[string]interface{}, error) {
+	props := make(map[string]map[string]interface{})
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+
+		prop, err := openAPIType(field.Type, refs)
+		if err != nil {
+			return nil, errors.New("field " + field.Name + ": " + err.Error())
+		}
+
+		props[name] = prop
+	}
+
+	return props, nil
+}
+
+// openAPIType returns the schema of the Go type.
+// Structs must be schemas themselves and are referenced.
+func openAPIType(t reflect.Type, refs map[reflect.Type]string) (map[string]interface{}, error) {
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}, nil
+
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}, nil
+
+	case reflect.Int, reflect.Int32:
+		return map[string]interface{}{"type": "integer"}, nil
+
+	case reflect.Int64:
+		return map[string]interface{}{"type": "integer", "format": "int64"}, nil
+
+	case reflect.Slice:
+		items, err := openAPIType(t.Elem(), refs)
+		if err != nil {
+			return nil, err
+		}
+
+		return map[string]interface{}{"type": "array", "items": items}, nil
+
+	case reflect.Struct:
+		name, ok := refs[t]
+		if ok {
+			return map[string]interface{}{"$ref": "#/components/schemas/" + name}, nil
+		}
+	}
+
+	return nil, errors.New("unsupported type " + t.String())
+}
+
+// OpenAPI is the part of the OpenAPI 3 document that is needed to render the API documentation.
+type OpenAPI struct {
+	Info       OpenAPIInfo                             `json:"info"`
+	Servers    []OpenAPIServer                         `json:"servers"`
+	Paths      map[string]map[string]*OpenAPIOperation `json:"paths"`
+	Components OpenAPIComponents                       `json:"components"`
+}
+
+type OpenAPIInfo struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description"`
+}
+
+type OpenAPIServer struct {
+	URL string `json:"url"`
+}
+
+type OpenAPIComponents struct {
+	Schemas    map[string]OpenAPISchema    `json:"schemas"`
+	Parameters map[string]OpenAPIParameter `json:"parameters"`
+	Responses  map[string]OpenAPIResponse  `json:"responses"`
+}
+
+type OpenAPIOperation struct {
+	Method string `json:"-"` // Filled by OpenAPIDoc
+	Path   string `json:"-"` // Full path including the server URL, filled by OpenAPIDoc
+
+	OperationID string                     `json:"operationId"`
+	Summary     string                     `json:"summary"`
+	Description string                     `json:"description"`
+	Parameters  []OpenAPIParameter         `json:"parameters"`
+	RequestBody *OpenAPIRequestBody        `json:"requestBody"`
+	Responses   map[string]OpenAPIResponse `json:"responses"`
+}
+
+type OpenAPIParameter struct {
+	Ref         string        `json:"$ref"`
+	Name        string        `json:"name"`
+	In          string        `json:"in"`
+	Description string        `json:"description"`
+	Required    bool          `json:"required"`
+	Schema      OpenAPISchema `json:"schema"`
+}
+
+type OpenAPIRequestBody struct {
+	Required bool                        `json:"required"`
+	Content  map[string]OpenAPIMediaType `json:"content"`
+}
+
+type OpenAPIResponse struct {
+	Ref         string                      `json:"$ref"`
+	Description string                      `json:"description"`
+	Content     map[string]OpenAPIMediaType `json:"content"`
+}
+
+type OpenAPIMediaType struct {
+	Schema OpenAPISchema `json:"schema"`
+}
+
+type OpenAPISchema struct {
+	Ref         string                   `json:"$ref"`
+	Type        string                   `json:"type"`
+	Format      string                   `json:"format"`
+	Description string                   `json:"description"`
+	Enum        []string                 `json:"enum"`
+	Default     interface{}              `json:"default"`
+	Items       *OpenAPISchema           `json:"items"`
+	Properties  map[string]OpenAPISchema `json:"properties"`
+	Required    []string                 `json:"required"`
+}
+
+// OpenAPIField is the schema property prepared for the documentation.
+type OpenAPIField struct {
+	Name     string
+	Required bool
+	Schema   OpenAPISchema
+}
+
+// OpenAPIDoc parses the OpenAPI document served at /api/v2/openapi.json.
+// References to the parameters and responses are resolved, schema references are kept.
+func OpenAPIDoc() (*OpenAPI, error) {
+	docJSON, err := openAPIDocument()
+	if err != nil {
+		return nil, err
+	}
+
+	var doc OpenAPI
+
+	err = json.Unmarshal(docJSON, &doc)
+	if err != nil {
+		return nil, err
+	}
+
+	var server string
+	if len(doc.Servers) != 0 {
+		server = doc.Servers[0].URL
+	}
+
+	for path, methods := range doc.Paths {
+		for method, op := range methods {
+			op.Method = strings.ToUpper(method)
+			op.Path = server + path
+
+			for i, param := range op.Parameters {
+				if param.Ref != "" {
+					op.Parameters[i] = doc.Components.Parameters[refName(param.Ref)]
+				}
+			}
+
+			for code, resp := range op.Responses {
+				if resp.Ref != "" {
+					op.Responses[code] = doc.Components.Responses[refName(resp.Ref)]
+				}
+			}
+		}
+	}
+
+	return &doc, nil
+}
+
+func refName(ref string) string {
+	return ref[strings.LastIndex(ref, "/")+1:]
+}
+
+// Operations returns all operations sorted by path.
+// Operations of the same path are sorted by the HTTP method: GET, POST, PUT, PATCH, DELETE.
+func (doc *OpenAPI) Operations() []*OpenAPIOperation {
+	methodOrder := map[string]int{"GET": 0, "POST": 1, "PUT": 2, "PATCH": 3, "DELETE": 4}
+
+	var ops []*OpenAPIOperation
+	for _, methods := range doc.Paths {
+		for _, op := range methods {
+			ops = append(ops, op)
+		}
+	}
+
+	sort.Slice(ops, func(i, j int) bool {
+		if ops[i].Path != ops[j].Path {
+			return ops[i].Path < ops[j].Path
+		}
+
+		return methodOrder[ops[i].Method] < methodOrder[ops[j].Method]
+	})
+
+	return ops
+}
+
+// SchemaNames returns names of all schemas from the components sorted alphabetically.
+func (doc *OpenAPI) SchemaNames() []string {
+	var names []string
+	for name := range doc.Components.Schemas {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// Schema returns the schema from the components by its name.
+func (doc *OpenAPI) Schema(name string) OpenAPISchema {
+	return doc.Components.Schemas[name]
+}
+
+// RefName returns the name of the referenced schema or an empty string.
+func (schema OpenAPISchema) RefName() string {
+	if schema.Ref == "" {
+		return ""
+	}
+
+	return refName(schema.Ref)
+}
+
+// DefaultString returns the default value of the schema as a string or an empty string if there is no default.
+func (schema OpenAPISchema) DefaultString() string {
+	if schema.Default == nil {
+		return ""
+	}
+
+	return fmt.Sprint(schema.Default)
+}
+
+// Fields returns the schema properties sorted by name.
+func (schema OpenAPISchema) Fields() []OpenAPIField {
+	var fields []OpenAPIField
+	for name, prop := range schema.Properties {
+		field := OpenAPIField{Name: name, Schema: prop}
+
+		for _, required := range schema.Required {
+			if required == name {
+				field.Required = true
+				break
+			}
+		}
+
+		fields = append(fields, field)
+	}
+
+	sort.Slice(fields, func(i, j int) bool {
+		return fields[i].Name < fields[j].Name
+	})
+
+	return fields
+}
+
+// GET /api/v2/openapi.json
+func (data *Data) openAPIHand(rw http.ResponseWriter, req *http.Request) error {
+	// Check method
+	if req.Method != "GET" {
+		return netshare.ErrMethodNotAllowed
+	}
+
+	docJSON, err := openAPIDocument()
+	if err != nil {
+		return err
+	}
+
+	// Return response
+	rw.Header().Set("Content-Type", "application/json")
+	_, err = rw.Write(docJSON)
+	return err
+}
@@ -0,0 +1,307 @@
+// Copyright (C) 2021-2023 Leonid Maslakov.
+
+// This file is part of Lenpaste.
+
+// Lenpaste is free software: you can redistribute it
+// and/or modify it under the terms of the
+// GNU Affero Public License as published by the
+// Free Software Foundation, either version 3 of the License,
+// or (at your option) any later version.
+
+// Lenpaste is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+// or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero Public License for more details.
+
+// You should have received a copy of the GNU Affero Public License along with Lenpaste.
+// If not, see <https://www.gnu.org/licenses/>.
+
+package apiv2
+
+import (
+	"encoding/json"
+	"github.com/lcomrade/lenpaste/internal/netshare"
+	"github.com/lcomrade/lenpaste/internal/storage"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Request body may be longer than the paste text because of the JSON escaping and other fields.
+const (
+	maxRequestLenPerRune = 6
+	maxRequestLenExtra   = 64 * 1024
+)
+
+type newPasteRequest struct {
+	Title       string                `json:"title"`
+	Body        string                `json:"body"`
+	LineEnd     string                `json:"lineEnd"`
+	Syntax      string                `json:"syntax"`
+	OneUse      bool                  `json:"oneUse"`
+	Visibility  string                `json:"visibility"`
+	Expiration  int64                 `json:"expiration"`
+	Author      string                `json:"author"`
+	AuthorEmail string                `json:"authorEmail"`
+	AuthorURL   string                `json:"authorURL"`
+	Password    string                `json:"password"`
+	Encrypted   bool                  `json:"encrypted"`
+	ForkOf      string                `json:"forkOf"`
+	Files       []newPasteRequestFile `json:"files"`
+}
+
+type newPasteRequestFile struct {
+	Name   string `json:"name"`
+	Body   string `json:"body"`
+	Syntax string `json:"syntax"`
+}
+
+type newPasteAnswer struct {
+	ID          string `json:"id"`
+	CreateTime  int64  `json:"createTime"`
+	DeleteTime  int64  `json:"deleteTime"`
+	Visibility  string `json:"visibility"`
+	DeleteToken string `json:"deleteToken"`
+	EditToken   string `json:"editToken"`
+}
+
+type deletePasteAnswer struct {
+	ID string `json:"id"`
+}
+
+// readJSON decodes the JSON request body.
+// Its length is limited by the max paste length.
+func (data *Data) readJSON(req *http.Request, v interface{}) error {
+	contentType := req.Header.Get("Content-Type")
+	if contentType != "" {
+		mediaType, _, err := mime.ParseMediaType(contentType)
+		if err != nil || mediaType != "application/json" {
+			return errUnsupportedMediaType
+		}
+	}
+
+	var body io.Reader = req.Body
+
+	maxLen := int64(-1)
+	if data.BodyMaxLen > 0 {
+		maxLen = int64(data.BodyMaxLen)*maxRequestLenPerRune + maxRequestLenExtra
+		body = io.LimitReader(req.Body, maxLen+1)
+	}
+
+	raw, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+
+	if maxLen >= 0 && int64(len(raw)) > maxLen {
+		return netshare.ErrPayloadTooLarge
+	}
+
+	err = json.Unmarshal(raw, v)
+	if err != nil {
+		return errInvalidJSON
+	}
+
+	return nil
+}
+
+// form converts the JSON request to the form of the API v1,
+// so the paste is checked exactly like the one created by the web interface.
+func (newPaste newPasteRequest) form() (url.Values, error) {
+	form := url.Values{}
+
+	form.Set("title", newPaste.Title)
+	form.Set("body", newPaste.Body)
+	form.Set("lineEnd", newPaste.LineEnd)
+	form.Set("syntax", newPaste.Syntax)
+	form.Set("visibility", newPaste.Visibility)
+	form.Set("author", newPaste.Author)
+	form.Set("authorEmail", newPaste.AuthorEmail)
+	form.Set("authorURL", newPaste.AuthorURL)
+	form.Set("password", newPaste.Password)
+	form.Set("forkOf", newPaste.ForkOf)
+
+	if newPaste.OneUse {
+		form.Set("oneUse", "true")
+	}
+
+	if newPaste.Encrypted {
+		form.Set("encrypted", "true")
+	}
+
+	if newPaste.Expiration != 0 {
+		form.Set("expiration", strconv.FormatInt(newPaste.Expiration, 10))
+	}
+
+	// The first file is the paste body itself
+	if len(newPaste.Files) != 0 {
+		if newPaste.Body != "" {
+			return nil, netshare.ErrBadRequest
+		}
+
+		form.Set("body", newPaste.Files[0].Body)
+		form.Set("fileName", newPaste.Files[0].Name)
+		form.Set("syntax", newPaste.Files[0].Syntax)
+
+		for _, file := range newPaste.Files[1:] {
+			form.Add("extraFileName", file.Name)
+			form.Add("extraFileBody", file.Body)
+			form.Add("extraFileSyntax", file.Syntax)
+		}
+	}
+
+	return form, nil
+}
+
+// Pattern: /api/v2/pastes
+func (data *Data) pastesHand(rw http.ResponseWriter, req *http.Request) error {
+	var err error
+
+	// Check auth
+	err = netshare.CheckCreateAuth(req, data.Auth)
+	if err != nil {
+		return err
+	}
+
+	// Check method
+	if req.Method != "POST" {
+		return netshare.ErrMethodNotAllowed
+	}
+
+	// Read request
+	var newPaste newPasteRequest
+	err = data.readJSON(req, &newPaste)
+	if err != nil {
+		return err
+	}
+
+	form, err := newPaste.form()
+	if err != nil {
+		return err
+	}
+
+	// Form is already set, so it is not read from the request body again
+	req.PostForm = form
+	req.Form = form
+
+	// Create paste
+	paste, err := netshare.PasteAddFromForm(req, data.DB, data.RateLimitNew, data.TitleMaxLen, data.BodyMaxLen, data.MaxLifeTime, data.Lexers)
+	if err != nil {
+		return err
+	}
+
+	// Return response
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Header().Set("Location", "/api/v2/pastes/"+url.PathEscape(paste.ID))
+	rw.WriteHeader(http.StatusCreated)
+	return json.NewEncoder(rw).Encode(newPasteAnswer{
+		ID:          paste.ID,
+		CreateTime:  paste.CreateTime,
+		DeleteTime:  paste.DeleteTime,
+		Visibility:  paste.Visibility,
+		DeleteToken: paste.DeleteToken,
+		EditToken:   paste.EditToken,
+	})
+}
+
+// Pattern: /api/v2/pastes/{id}
+func (data *Data) pasteHand(rw http.ResponseWriter, req *http.Request, pasteID string) error {
+	if pasteID == "" || strings.Contains(pasteID, "/") {
+		return netshare.ErrNotFound
+	}
+
+	switch req.Method {
+	case "GET":
+		return data.pasteGetHand(rw, req, pasteID)
+	case "DELETE":
+		return data.pasteDeleteHand(rw, req, pasteID)
+	}
+
+	return netshare.ErrMethodNotAllowed
+}
+
+// GET /api/v2/pastes/{id}
+func (data *Data) pasteGetHand(rw http.ResponseWriter, req *http.Request, pasteID string) error {
+	// Check rate limit
+	err := data.RateLimitGet.CheckAndUse(netshare.GetClientAddr(req))
+	if err != nil {
+		return err
+	}
+
+	req.ParseForm()
+
+	// Get paste
+	paste, err := netshare.PasteGetRevision(data.DB, pasteID, req.Form.Get("rev"))
+	if err != nil {
+		return err
+	}
+
+	// Private paste can be opened only by its owner
+	err = netshare.PasteCheckVisibility(req, paste)
+	if err != nil {
+		return err
+	}
+
+	// Check paste password
+	err = netshare.PasteCheckPassword(req, paste, data.RateLimitGet, netshare.PastePassword(req, true))
+	if err != nil {
+		return err
+	}
+
+	// If "one use" paste
+	if paste.OneUse == true {
+		if req.Form.Get("openOneUse") == "true" {
+			// Delete paste
+			err = data.DB.PasteDelete(pasteID)
+			if err != nil {
+				return err
+			}
+
+		} else {
+			// Remove secret data
+			paste = storage.Paste{
+				ID:     paste.ID,
+				OneUse: true,
+			}
+		}
+	}
+
+	// Return response
+	rw.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(rw).Encode(paste)
+}
+
+// DELETE /api/v2/pastes/{id}
+func (data *Data) pasteDeleteHand(rw http.ResponseWriter, req *http.Request, pasteID string) error {
+	// Check rate limit
+	err := data.RateLimitNew.CheckAndUse(netshare.GetClientAddr(req))
+	if err != nil {
+		return err
+	}
+
+	// Get delete token
+	deleteToken := req.Header.Get("X-Delete-Token")
+	if deleteToken == "" {
+		deleteToken = req.URL.Query().Get("deleteToken")
+	}
+
+	// Delete token is not needed with the API token
+	_, tokenExist := netshare.RequestToken(req)
+
+	if deleteToken == "" && tokenExist == false {
+		return netshare.ErrBadRequest
+	}
+
+	// Delete paste
+	err = netshare.PasteDeleteByToken(req, data.DB, pasteID, deleteToken)
+	if err != nil {
+		return err
+	}
+
+	// Return response
+	rw.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(rw).Encode(deletePasteAnswer{ID: pasteID})
+}
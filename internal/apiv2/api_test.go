@@ -0,0 +1,530 @@
+// Copyright (C) 2021-2023 Leonid Maslakov.
+
+// This file is part of Lenpaste.
+
+// Lenpaste is free software: you can redistribute it
+// and/or modify it under the terms of the
+// GNU Affero Public License as published by the
+// Free Software Foundation, either version 3 of the License,
+// or (at your option) any later version.
+
+// Lenpaste is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+// or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero Public License for more details.
+
+// You should have received a copy of the GNU Affero Public License along with Lenpaste.
+// If not, see <https://www.gnu.org/licenses/>.
+
+package apiv2
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/lcomrade/lenpaste/internal/auth"
+	"github.com/lcomrade/lenpaste/internal/config"
+	"github.com/lcomrade/lenpaste/internal/logger"
+	"github.com/lcomrade/lenpaste/internal/netshare"
+	"github.com/lcomrade/lenpaste/internal/storage"
+)
+
+func newTestData() *Data {
+	return Load(storage.NewMemory(), config.Config{
+		Log:          logger.New("2006/01/02 15:04:05"),
+		RateLimitNew: netshare.NewRateLimitSystem(0, 0, 0),
+		RateLimitGet: netshare.NewRateLimitSystem(0, 0, 0),
+		TitleMaxLen:  100,
+		BodyMaxLen:   20000,
+		MaxLifeTime:  -1,
+	})
+}
+
+func doRequest(data *Data, method string, path string, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	rw := httptest.NewRecorder()
+	data.Hand(rw, req)
+
+	return rw
+}
+
+func readErrorCode(t *testing.T, rw *httptest.ResponseRecorder) string {
+	var resp errorType
+	err := json.NewDecoder(rw.Body).Decode(&resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.Error.Status != rw.Code {
+		t.Error("error status", resp.Error.Status, "does not match HTTP status", rw.Code)
+	}
+
+	return resp.Error.Code
+}
+
+func TestCreateGetDelete(t *testing.T) {
+	data := newTestData()
+
+	// Create paste
+	rw := doRequest(data, "POST", "/api/v2/pastes", `{"title":"Test","body":"Hello\r\nworld","syntax":"Go"}`)
+	if rw.Code != http.StatusCreated {
+		t.Fatal("expected 201 but got", rw.Code, rw.Body.String())
+	}
+
+	var created newPasteAnswer
+	err := json.NewDecoder(rw.Body).Decode(&created)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rw.Header().Get("Location") != "/api/v2/pastes/"+created.ID {
+		t.Error("unexpected Location header:", rw.Header().Get("Location"))
+	}
+
+	if created.Visibility != storage.VisibilityUnlisted || created.DeleteToken == "" {
+		t.Error("unexpected answer:", created)
+	}
+
+	// Get paste
+	rw = doRequest(data, "GET", "/api/v2/pastes/"+created.ID, "")
+	if rw.Code != http.StatusOK {
+		t.Fatal("expected 200 but got", rw.Code, rw.Body.String())
+	}
+
+	var paste storage.Paste
+	err = json.NewDecoder(rw.Body).Decode(&paste)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if paste.Title != "Test" || paste.Body != "Hello\nworld" || paste.Syntax != "Go" {
+		t.Error("unexpected paste:", paste)
+	}
+
+	// Delete paste with wrong token
+	rw = doRequest(data, "DELETE", "/api/v2/pastes/"+created.ID+"?deleteToken=wrong", "")
+	if rw.Code != http.StatusForbidden || readErrorCode(t, rw) != "forbidden" {
+		t.Fatal("expected 403 but got", rw.Code)
+	}
+
+	// Delete paste
+	req := httptest.NewRequest("DELETE", "/api/v2/pastes/"+created.ID, nil)
+	req.Header.Set("X-Delete-Token", created.DeleteToken)
+	rw = httptest.NewRecorder()
+	data.Hand(rw, req)
+
+	if rw.Code != http.StatusOK {
+		t.Fatal("expected 200 but got", rw.Code, rw.Body.String())
+	}
+
+	rw = doRequest(data, "GET", "/api/v2/pastes/"+created.ID, "")
+	if rw.Code != http.StatusNotFound || readErrorCode(t, rw) != "paste_not_found" {
+		t.Error("expected 404 but got", rw.Code)
+	}
+}
+
+func TestCreateFiles(t *testing.T) {
+	data := newTestData()
+
+	rw := doRequest(data, "POST", "/api/v2/pastes", `{"files":[{"name":"main.go","body":"package main","syntax":"Go"},{"name":"README","body":"Read me"}]}`)
+	if rw.Code != http.StatusCreated {
+		t.Fatal("expected 201 but got", rw.Code, rw.Body.String())
+	}
+
+	var created newPasteAnswer
+	err := json.NewDecoder(rw.Body).Decode(&created)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	paste, err := data.DB.PasteGet(created.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(paste.Files) != 2 || paste.Files[0].Name != "main.go" || paste.Files[1].Syntax != "plaintext" {
+		t.Error("unexpected files:", paste.Files)
+	}
+
+	// Body and files can not be set together
+	rw = doRequest(data, "POST", "/api/v2/pastes", `{"body":"text","files":[{"body":"text"}]}`)
+	if rw.Code != http.StatusBadRequest || readErrorCode(t, rw) != "bad_request" {
+		t.Error("expected 400 but got", rw.Code)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	data := newTestData()
+
+	tests := []struct {
+		method string
+		path   string
+		body   string
+		status int
+		code   string
+	}{
+		{"POST", "/api/v2/pastes", `{"body":`, 400, "invalid_json"},
+		{"POST", "/api/v2/pastes", `{"title":"No body"}`, 400, "bad_request"},
+		{"POST", "/api/v2/pastes", `{"body":"text","syntax":"unknown"}`, 400, "bad_request"},
+		{"POST", "/api/v2/pastes", `{"body":"` + strings.Repeat("a", 20001) + `"}`, 413, "payload_too_large"},
+		{"GET", "/api/v2/pastes", "", 405, "method_not_allowed"},
+		{"PUT", "/api/v2/pastes/abc", "", 405, "method_not_allowed"},
+		{"GET", "/api/v2/pastes/abc", "", 404, "paste_not_found"},
+		{"GET", "/api/v2/pastes/abc/def", "", 404, "not_found"},
+		{"GET", "/api/v2/unknown", "", 404, "not_found"},
+	}
+
+	for _, test := range tests {
+		rw := doRequest(data, test.method, test.path, test.body)
+		if rw.Code != test.status {
+			t.Error(test.method, test.path, "expected", test.status, "but got", rw.Code)
+			continue
+		}
+
+		code := readErrorCode(t, rw)
+		if code != test.code {
+			t.Error(test.method, test.path, "expected", test.code, "but got", code)
+		}
+	}
+
+	// Wrong content type
+	req := httptest.NewRequest("POST", "/api/v2/pastes", strings.NewReader("body=text"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rw := httptest.NewRecorder()
+	data.Hand(rw, req)
+
+	if rw.Code != http.StatusUnsupportedMediaType || readErrorCode(t, rw) != "unsupported_media_type" {
+		t.Error("expected 415 but got", rw.Code)
+	}
+}
+
+func TestServer(t *testing.T) {
+	data := newTestData()
+
+	rw := doRequest(data, "GET", "/api/v2/server", "")
+	if rw.Code != http.StatusOK {
+		t.Fatal("expected 200 but got", rw.Code)
+	}
+
+	var info serverInfoType
+	err := json.NewDecoder(rw.Body).Decode(&info)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.Software != "Lenpaste" || info.BodyMaxLen != 20000 || len(info.Syntaxes) == 0 {
+		t.Error("unexpected server info:", info)
+	}
+
+	if info.AuthRequired || info.AuthMethod != netshare.AuthMethodNone {
+		t.Error("unexpected auth method:", info.AuthRequired, info.AuthMethod)
+	}
+
+	// Report the active auth method
+	data.Auth.OIDC = true
+
+	rw = doRequest(data, "GET", "/api/v2/server", "")
+	info = serverInfoType{}
+	err = json.NewDecoder(rw.Body).Decode(&info)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.AuthRequired == false || info.AuthMethod != netshare.AuthMethodOIDC {
+		t.Error("unexpected auth method:", info.AuthRequired, info.AuthMethod)
+	}
+}
+
+func TestOpenAPI(t *testing.T) {
+	data := newTestData()
+
+	rw := doRequest(data, "GET", "/api/v2/openapi.json", "")
+	if rw.Code != http.StatusOK {
+		t.Fatal("expected 200 but got", rw.Code)
+	}
+
+	doc, err := OpenAPIDoc()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every route must be documented
+	ops := make(map[string]bool)
+	for _, op := range doc.Operations() {
+		ops[op.Method+" "+op.Path] = true
+	}
+
+	for _, op := range []string{"POST /api/v2/pastes", "GET /api/v2/pastes/{id}", "DELETE /api/v2/pastes/{id}", "GET /api/v2/bans", "POST /api/v2/bans", "DELETE /api/v2/bans/{id}", "GET /api/v2/server", "GET /api/v2/openapi.json"} {
+		if ops[op] == false {
+			t.Error("operation is not documented:", op)
+		}
+	}
+
+	// Every error code must be documented
+	codes := make(map[string]bool)
+	for _, code := range doc.Schema("ErrorInfo").Properties["code"].Enum {
+		codes[code] = true
+	}
+
+	for _, item := range errorList {
+		if codes[item.info.Code] == false {
+			t.Error("error code is not documented:", item.info.Code)
+		}
+	}
+
+	if codes[errorBanned.Code] == false || codes[errorTooManyRequests.Code] == false || codes[errorInternal.Code] == false {
+		t.Error("error codes are not documented")
+	}
+}
+
+func TestOpenAPISchemas(t *testing.T) {
+	doc, err := OpenAPIDoc()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Schema properties are the JSON fields of the Go types
+	for name, value := range openAPISchemaTypes {
+		schema := doc.Schema(name)
+
+		fields := make(map[string]bool)
+		valueType := reflect.TypeOf(value)
+		for i := 0; i < valueType.NumField(); i++ {
+			fieldName := strings.Split(valueType.Field(i).Tag.Get("json"), ",")[0]
+			if fieldName != "" && fieldName != "-" {
+				fields[fieldName] = true
+			}
+		}
+
+		if len(fields) != len(schema.Properties) {
+			t.Error(name, "expected properties", fields, "but got", schema.Fields())
+		}
+
+		for fieldName := range fields {
+			prop, ok := schema.Properties[fieldName]
+			if ok == false || (prop.Type == "" && prop.Ref == "") {
+				t.Error(name, "property is not documented:", fieldName)
+			}
+		}
+	}
+
+	if doc.Schema("NewPaste").Properties["expiration"].Format != "int64" {
+		t.Error("unexpected expiration format:", doc.Schema("NewPaste").Properties["expiration"])
+	}
+
+	if doc.Schema("BanList").Properties["bans"].Items.RefName() != "Ban" {
+		t.Error("unexpected bans items:", doc.Schema("BanList").Properties["bans"].Items)
+	}
+
+	// Enums are the constants
+	enums := map[string][]string{
+		"ServerInfo.authMethod": {netshare.AuthMethodNone, auth.MethodLenPasswd, auth.MethodLDAP, netshare.AuthMethodOIDC},
+		"NewPaste.visibility":   {storage.VisibilityPrivate, storage.VisibilityUnlisted, storage.VisibilityPublic},
+		"NewPaste.lineEnd":      {"LF", "CRLF", "CR"},
+	}
+
+	for key, expect := range enums {
+		parts := strings.SplitN(key, ".", 2)
+		enum := doc.Schema(parts[0]).Properties[parts[1]].Enum
+		if strings.Join(enum, ",") != strings.Join(expect, ",") {
+			t.Error(key, "expected enum", expect, "but got", enum)
+		}
+	}
+
+	// Every auth method is documented
+	authMethods := make(map[string]bool)
+	for _, method := range doc.Schema("ServerInfo").Properties["authMethod"].Enum {
+		authMethods[method] = true
+	}
+
+	for _, a := range []netshare.Auth{{}, {OIDC: true}, {Provider: auth.NewFile("")}, {Provider: &auth.LDAP{}}} {
+		if authMethods[a.Method()] == false {
+			t.Error("auth method is not documented:", a.Method())
+		}
+	}
+}
+
+func doTokenRequest(data *Data, method string, path string, body string, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	rw := httptest.NewRecorder()
+	data.Hand(rw, req)
+
+	return rw
+}
+
+func addTestToken(t *testing.T, data *Data, scopes ...string) (string, string) {
+	secret, hash, err := storage.NewSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id, _, err := data.DB.TokenAdd(storage.APIToken{Name: "test", Scopes: scopes, TokenHash: hash})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return id, secret
+}
+
+func TestAPIToken(t *testing.T) {
+	data := newTestData()
+	data.Auth.Provider = auth.NewFile("/nonexistent")
+
+	_, owner := addTestToken(t, data, storage.ScopeCreate, storage.ScopeDeleteOwn, storage.ScopeReadPrivate)
+	_, other := addTestToken(t, data, storage.ScopeCreate, storage.ScopeDeleteOwn, storage.ScopeReadPrivate)
+	_, readOnly := addTestToken(t, data, storage.ScopeReadPrivate)
+	_, admin := addTestToken(t, data, storage.ScopeAdmin)
+
+	// Unknown token
+	rw := doTokenRequest(data, "GET", "/api/v2/server", "", "wrong")
+	if rw.Code != http.StatusUnauthorized || readErrorCode(t, rw) != "unauthorized" {
+		t.Fatal("expected 401 but got", rw.Code)
+	}
+
+	// Token without "create" scope
+	rw = doTokenRequest(data, "POST", "/api/v2/pastes", `{"body":"Text"}`, readOnly)
+	if rw.Code != http.StatusForbidden {
+		t.Fatal("expected 403 but got", rw.Code, rw.Body.String())
+	}
+
+	// Token replaces the HTTP Basic authentication
+	rw = doTokenRequest(data, "POST", "/api/v2/pastes", `{"body":"Text","visibility":"private"}`, owner)
+	if rw.Code != http.StatusCreated {
+		t.Fatal("expected 201 but got", rw.Code, rw.Body.String())
+	}
+
+	var created newPasteAnswer
+	err := json.NewDecoder(rw.Body).Decode(&created)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Private paste can be read only with the token it was created with
+	rw = doTokenRequest(data, "GET", "/api/v2/pastes/"+created.ID, "", other)
+	if rw.Code != http.StatusNotFound {
+		t.Error("expected 404 but got", rw.Code)
+	}
+
+	rw = doTokenRequest(data, "GET", "/api/v2/pastes/"+created.ID, "", owner)
+	if rw.Code != http.StatusOK {
+		t.Error("expected 200 but got", rw.Code, rw.Body.String())
+	}
+
+	rw = doTokenRequest(data, "GET", "/api/v2/pastes/"+created.ID, "", admin)
+	if rw.Code != http.StatusOK {
+		t.Error("expected 200 but got", rw.Code, rw.Body.String())
+	}
+
+	// Delete without the delete token
+	rw = doTokenRequest(data, "DELETE", "/api/v2/pastes/"+created.ID, "", other)
+	if rw.Code != http.StatusForbidden {
+		t.Error("expected 403 but got", rw.Code)
+	}
+
+	rw = doTokenRequest(data, "DELETE", "/api/v2/pastes/"+created.ID, "", owner)
+	if rw.Code != http.StatusOK {
+		t.Error("expected 200 but got", rw.Code, rw.Body.String())
+	}
+}
+
+func TestBans(t *testing.T) {
+	data := newTestData()
+
+	_, admin := addTestToken(t, data, storage.ScopeAdmin)
+	_, creator := addTestToken(t, data, storage.ScopeCreate)
+
+	// Only administrator can manage bans
+	rw := doRequest(data, "GET", "/api/v2/bans", "")
+	if rw.Code != http.StatusUnauthorized {
+		t.Fatal("expected 401 but got", rw.Code)
+	}
+
+	rw = doTokenRequest(data, "POST", "/api/v2/bans", `{"range":"192.0.2.0/24"}`, creator)
+	if rw.Code != http.StatusForbidden || readErrorCode(t, rw) != "forbidden" {
+		t.Fatal("expected 403 but got", rw.Code)
+	}
+
+	// Bad range
+	rw = doTokenRequest(data, "POST", "/api/v2/bans", `{"range":"192.0.2.0/33"}`, admin)
+	if rw.Code != http.StatusBadRequest {
+		t.Fatal("expected 400 but got", rw.Code)
+	}
+
+	// Ban
+	rw = doTokenRequest(data, "POST", "/api/v2/bans", `{"range":"192.0.2.1","reason":"Spam","expiration":3600}`, admin)
+	if rw.Code != http.StatusCreated {
+		t.Fatal("expected 201 but got", rw.Code, rw.Body.String())
+	}
+
+	var ban storage.Ban
+	err := json.NewDecoder(rw.Body).Decode(&ban)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ban.ID == "" || ban.CIDR != "192.0.2.1/32" || ban.Reason != "Spam" || ban.ExpireTime == 0 {
+		t.Error("unexpected ban:", ban)
+	}
+
+	// Administrator is banned too, so other address is used
+	doRequestFrom := func(method string, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer "+admin)
+		req.RemoteAddr = "198.51.100.1:1234"
+
+		rw := httptest.NewRecorder()
+		data.Hand(rw, req)
+
+		return rw
+	}
+
+	rw = doRequestFrom("GET", "/api/v2/bans")
+	if rw.Code != http.StatusOK || strings.Contains(rw.Body.String(), ban.ID) == false {
+		t.Error("ban is not listed:", rw.Code, rw.Body.String())
+	}
+
+	entries, err := data.DB.AuditList(10, 0)
+	if err != nil || len(entries) != 1 || entries[0].Admin != "token:test" || entries[0].Action != storage.AuditBanAdd {
+		t.Error("unexpected audit log:", entries, err)
+	}
+
+	// Banned client (httptest uses 192.0.2.1)
+	rw = doTokenRequest(data, "GET", "/api/v2/server", "", creator)
+	if rw.Code != http.StatusForbidden {
+		t.Fatal("expected 403 but got", rw.Code)
+	}
+
+	var resp errorType
+	err = json.NewDecoder(rw.Body).Decode(&resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.Error.Code != "banned" || resp.Error.BanReason != "Spam" || resp.Error.BanExpireTime != ban.ExpireTime {
+		t.Error("unexpected error:", resp.Error)
+	}
+
+	// Unban
+	rw = doRequestFrom("DELETE", "/api/v2/bans/"+ban.ID)
+	if rw.Code != http.StatusOK {
+		t.Fatal("expected 200 but got", rw.Code, rw.Body.String())
+	}
+
+	rw = doTokenRequest(data, "GET", "/api/v2/server", "", creator)
+	if rw.Code != http.StatusOK {
+		t.Error("expected 200 but got", rw.Code)
+	}
+}
@@ -0,0 +1,57 @@
+// Copyright (C) 2021-2023 Leonid Maslakov.
+
+// This file is part of Lenpaste.
+
+// Lenpaste is free software: you can redistribute it
+// and/or modify it under the terms of the
+// GNU Affero Public License as published by the
+// Free Software Foundation, either version 3 of the License,
+// or (at your option) any later version.
+
+// Lenpaste is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+// or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero Public License for more details.
+
+// You should have received a copy of the GNU Affero Public License along with Lenpaste.
+// If not, see <https://www.gnu.org/licenses/>.
+
+// Package auth checks the user names and passwords sent using HTTP Basic authentication.
+package auth
+
+import (
+	"github.com/lcomrade/lenpaste/internal/lenpasswd"
+)
+
+// Authorization methods of the providers.
+const (
+	MethodLenPasswd = "lenpasswd"
+	MethodLDAP      = "ldap"
+)
+
+// Provider checks user names and passwords.
+type Provider interface {
+	// Method returns the name of the authorization method, for example MethodLDAP.
+	Method() string
+
+	// Check reports whether the user exists and the password is correct.
+	Check(user string, password string) (bool, error)
+}
+
+// File checks users with the LenPasswd file.
+// The file is cached by the lenpasswd package and read again when it changes.
+type File struct {
+	Path string
+}
+
+func NewFile(path string) *File {
+	return &File{Path: path}
+}
+
+func (f *File) Method() string {
+	return MethodLenPasswd
+}
+
+func (f *File) Check(user string, password string) (bool, error) {
+	return lenpasswd.LoadAndCheck(f.Path, user, password)
+}
@@ -0,0 +1,238 @@
+// Copyright (C) 2021-2023 Leonid Maslakov.
+
+// This file is part of Lenpaste.
+
+// Lenpaste is free software: you can redistribute it
+// and/or modify it under the terms of the
+// GNU Affero Public License as published by the
+// Free Software Foundation, either version 3 of the License,
+// or (at your option) any later version.
+
+// Lenpaste is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+// or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero Public License for more details.
+
+// You should have received a copy of the GNU Affero Public License along with Lenpaste.
+// If not, see <https://www.gnu.org/licenses/>.
+
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"errors"
+	"github.com/go-ldap/ldap/v3"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Timeout of the connection and of each LDAP request.
+const ldapTimeout = 10 * time.Second
+
+var (
+	ErrLDAPBadURL    = errors.New("auth: LDAP URL must start with ldap:// or ldaps://")
+	ErrLDAPBadUserDN = errors.New("auth: LDAP user DN template must contain one \"%s\"")
+	ErrLDAPBadFilter = errors.New("auth: invalid LDAP group filter")
+)
+
+// ldapConn is the part of the LDAP connection used by the provider.
+type ldapConn interface {
+	Bind(username string, password string) error
+	Search(req *ldap.SearchRequest) (*ldap.SearchResult, error)
+	Close() error
+}
+
+type ldapCacheEntry struct {
+	passwordMAC []byte
+	expireTime  time.Time
+}
+
+// LDAP checks users with the LDAP simple bind.
+type LDAP struct {
+	URL         string        // ldap://host:389 or ldaps://host:636
+	StartTLS    bool          // Use StartTLS with the ldap:// URL.
+	UserDN      string        // User DN template, "%s" is replaced with the escaped user name.
+	GroupFilter string        // Optional search filter the user entry must match after bind.
+	CacheTTL    time.Duration // Successful binds are cached for this time, 0 disables the cache.
+
+	dial func() (ldapConn, error)
+
+	cacheMu  sync.Mutex
+	cacheKey []byte // Key of the password HMAC, the cache does not keep passwords
+	cache    map[string]ldapCacheEntry
+}
+
+// NewLDAP returns the LDAP provider.
+// Group filter is checked against the user entry using the user's own bind,
+// for example "(memberOf=cn=lenpaste,ou=groups,dc=example,dc=org)".
+func NewLDAP(ldapURL string, userDN string, groupFilter string, startTLS bool, cacheTTL time.Duration) (*LDAP, error) {
+	if strings.HasPrefix(ldapURL, "ldap://") == false && strings.HasPrefix(ldapURL, "ldaps://") == false {
+		return nil, ErrLDAPBadURL
+	}
+
+	if strings.Count(userDN, "%s") != 1 {
+		return nil, ErrLDAPBadUserDN
+	}
+
+	if groupFilter != "" {
+		_, err := ldap.CompileFilter(groupFilter)
+		if err != nil {
+			return nil, ErrLDAPBadFilter
+		}
+	}
+
+	l := LDAP{
+		URL:         ldapURL,
+		StartTLS:    startTLS,
+		UserDN:      userDN,
+		GroupFilter: groupFilter,
+		CacheTTL:    cacheTTL,
+		cacheKey:    make([]byte, 32),
+		cache:       make(map[string]ldapCacheEntry),
+	}
+
+	_, err := rand.Read(l.cacheKey)
+	if err != nil {
+		return nil, err
+	}
+
+	l.dial = l.dialURL
+
+	return &l, nil
+}
+
+func (l *LDAP) dialURL() (ldapConn, error) {
+	conn, err := ldap.DialURL(l.URL, ldap.DialWithDialer(&net.Dialer{Timeout: ldapTimeout}))
+	if err != nil {
+		return nil, err
+	}
+
+	conn.SetTimeout(ldapTimeout)
+
+	if l.StartTLS {
+		u, err := url.Parse(l.URL)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+
+		err = conn.StartTLS(&tls.Config{ServerName: u.Hostname()})
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	return conn, nil
+}
+
+func (l *LDAP) Method() string {
+	return MethodLDAP
+}
+
+func (l *LDAP) Check(user string, password string) (bool, error) {
+	// Bind with an empty password is an unauthenticated bind, servers accept it for any DN
+	if user == "" || password == "" {
+		return false, nil
+	}
+
+	if l.cached(user, password) {
+		return true, nil
+	}
+
+	conn, err := l.dial()
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	userDN := strings.Replace(l.UserDN, "%s", ldap.EscapeDN(user), 1)
+
+	err = conn.Bind(userDN, password)
+	if err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	// Check group membership
+	if l.GroupFilter != "" {
+		result, err := conn.Search(ldap.NewSearchRequest(
+			userDN, ldap.ScopeBaseObject, ldap.NeverDerefAliases, 1, int(ldapTimeout/time.Second), false,
+			l.GroupFilter, []string{"dn"}, nil,
+		))
+		if err != nil {
+			if ldap.IsErrorWithCode(err, ldap.LDAPResultNoSuchObject) {
+				return false, nil
+			}
+
+			return false, err
+		}
+
+		if len(result.Entries) == 0 {
+			return false, nil
+		}
+	}
+
+	l.cacheAdd(user, password)
+
+	return true, nil
+}
+
+func (l *LDAP) passwordMAC(password string) []byte {
+	mac := hmac.New(sha256.New, l.cacheKey)
+	mac.Write([]byte(password))
+	return mac.Sum(nil)
+}
+
+// cached reports whether the user was successfully bound with the same password during the cache TTL.
+func (l *LDAP) cached(user string, password string) bool {
+	if l.CacheTTL <= 0 {
+		return false
+	}
+
+	l.cacheMu.Lock()
+	defer l.cacheMu.Unlock()
+
+	entry, exist := l.cache[user]
+	if exist == false {
+		return false
+	}
+
+	if time.Now().After(entry.expireTime) {
+		delete(l.cache, user)
+		return false
+	}
+
+	return hmac.Equal(entry.passwordMAC, l.passwordMAC(password))
+}
+
+func (l *LDAP) cacheAdd(user string, password string) {
+	if l.CacheTTL <= 0 {
+		return
+	}
+
+	l.cacheMu.Lock()
+	defer l.cacheMu.Unlock()
+
+	// Drop expired entries of users who do not come back
+	now := time.Now()
+	for name, entry := range l.cache {
+		if now.After(entry.expireTime) {
+			delete(l.cache, name)
+		}
+	}
+
+	l.cache[user] = ldapCacheEntry{
+		passwordMAC: l.passwordMAC(password),
+		expireTime:  now.Add(l.CacheTTL),
+	}
+}
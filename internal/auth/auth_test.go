@@ -0,0 +1,218 @@
+// Copyright (C) 2021-2023 Leonid Maslakov.
+
+// This file is part of Lenpaste.
+
+// Lenpaste is free software: you can redistribute it
+// and/or modify it under the terms of the
+// GNU Affero Public License as published by the
+// Free Software Foundation, either version 3 of the License,
+// or (at your option) any later version.
+
+// Lenpaste is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+// or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero Public License for more details.
+
+// You should have received a copy of the GNU Affero Public License along with Lenpaste.
+// If not, see <https://www.gnu.org/licenses/>.
+
+package auth
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+// fakeLDAP is the LDAP server with users in the "ou=people,dc=example,dc=org" and one group.
+type fakeLDAP struct {
+	passwords map[string]string // Passwords by DN
+	members   map[string]bool   // Group members by DN
+	binds     int
+}
+
+type fakeConn struct {
+	server  *fakeLDAP
+	boundDN string
+}
+
+func (c *fakeConn) Bind(username string, password string) error {
+	c.server.binds++
+
+	if c.server.passwords[username] == "" || c.server.passwords[username] != password {
+		return ldap.NewError(ldap.LDAPResultInvalidCredentials, nil)
+	}
+
+	c.boundDN = username
+	return nil
+}
+
+func (c *fakeConn) Search(req *ldap.SearchRequest) (*ldap.SearchResult, error) {
+	if req.BaseDN != c.boundDN || req.Scope != ldap.ScopeBaseObject {
+		return nil, ldap.NewError(ldap.LDAPResultInsufficientAccessRights, nil)
+	}
+
+	result := ldap.SearchResult{}
+	if c.server.members[req.BaseDN] {
+		result.Entries = append(result.Entries, ldap.NewEntry(req.BaseDN, nil))
+	}
+
+	return &result, nil
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func newTestLDAP(t *testing.T, groupFilter string, cacheTTL time.Duration) (*LDAP, *fakeLDAP) {
+	server := &fakeLDAP{
+		passwords: map[string]string{
+			"uid=alice,ou=people,dc=example,dc=org": "alice password",
+			"uid=bob,ou=people,dc=example,dc=org":   "bob password",
+			"uid=a\\,b,ou=people,dc=example,dc=org": "comma password",
+		},
+		members: map[string]bool{
+			"uid=alice,ou=people,dc=example,dc=org": true,
+		},
+	}
+
+	l, err := NewLDAP("ldap://ldap.example.org", "uid=%s,ou=people,dc=example,dc=org", groupFilter, false, cacheTTL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l.dial = func() (ldapConn, error) {
+		return &fakeConn{server: server}, nil
+	}
+
+	return l, server
+}
+
+func TestNewLDAP(t *testing.T) {
+	_, err := NewLDAP("http://ldap.example.org", "uid=%s,dc=example,dc=org", "", false, 0)
+	if err != ErrLDAPBadURL {
+		t.Error("expected ErrLDAPBadURL but got", err)
+	}
+
+	_, err = NewLDAP("ldaps://ldap.example.org", "dc=example,dc=org", "", false, 0)
+	if err != ErrLDAPBadUserDN {
+		t.Error("expected ErrLDAPBadUserDN but got", err)
+	}
+
+	_, err = NewLDAP("ldaps://ldap.example.org", "uid=%s,dc=example,dc=org", "(memberOf=", false, 0)
+	if err != ErrLDAPBadFilter {
+		t.Error("expected ErrLDAPBadFilter but got", err)
+	}
+}
+
+func TestLDAPCheck(t *testing.T) {
+	l, _ := newTestLDAP(t, "", 0)
+
+	tests := []struct {
+		user     string
+		password string
+		ok       bool
+	}{
+		{"alice", "alice password", true},
+		{"bob", "bob password", true},
+		{"a,b", "comma password", true},
+		{"alice", "wrong", false},
+		{"alice", "", false},
+		{"", "", false},
+		{"carol", "carol password", false},
+		// Injection into the DN must not change the bound entry
+		{"alice,ou=people,dc=example,dc=org", "alice password", false},
+	}
+
+	for _, test := range tests {
+		ok, err := l.Check(test.user, test.password)
+		if err != nil {
+			t.Fatal(test.user, err)
+		}
+
+		if ok != test.ok {
+			t.Errorf("%q %q: expected %v but got %v", test.user, test.password, test.ok, ok)
+		}
+	}
+}
+
+func TestLDAPGroupFilter(t *testing.T) {
+	l, _ := newTestLDAP(t, "(memberOf=cn=lenpaste,ou=groups,dc=example,dc=org)", 0)
+
+	ok, err := l.Check("alice", "alice password")
+	if err != nil || ok == false {
+		t.Error("group member is not allowed:", err)
+	}
+
+	ok, err = l.Check("bob", "bob password")
+	if err != nil || ok {
+		t.Error("user outside the group is allowed:", err)
+	}
+}
+
+func TestLDAPCache(t *testing.T) {
+	l, server := newTestLDAP(t, "", time.Hour)
+
+	for i := 0; i < 3; i++ {
+		ok, err := l.Check("alice", "alice password")
+		if err != nil || ok == false {
+			t.Fatal("expected successful check:", err)
+		}
+	}
+
+	if server.binds != 1 {
+		t.Error("expected 1 bind but got", server.binds)
+	}
+
+	// Other password is checked by the server
+	ok, _ := l.Check("alice", "wrong")
+	if ok || server.binds != 2 {
+		t.Error("wrong password is accepted from the cache, binds:", server.binds)
+	}
+
+	// Failed binds are not cached
+	l.Check("alice", "wrong")
+	if server.binds != 3 {
+		t.Error("expected 3 binds but got", server.binds)
+	}
+
+	// Changed password is checked again after the TTL
+	l.CacheTTL = time.Millisecond
+	l.cache = make(map[string]ldapCacheEntry)
+
+	l.Check("alice", "alice password")
+	time.Sleep(5 * time.Millisecond)
+	server.passwords["uid=alice,ou=people,dc=example,dc=org"] = "new password"
+
+	ok, _ = l.Check("alice", "alice password")
+	if ok {
+		t.Error("old password is accepted after the cache TTL")
+	}
+}
+
+func TestFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lenpasswd")
+
+	err := ioutil.WriteFile(path, []byte("alice:secret\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var p Provider = NewFile(path)
+	if p.Method() != MethodLenPasswd {
+		t.Error("unexpected method:", p.Method())
+	}
+
+	ok, err := p.Check("alice", "secret")
+	if err != nil || ok == false {
+		t.Error("expected successful check:", err)
+	}
+
+	ok, err = p.Check("alice", "wrong")
+	if err != nil || ok {
+		t.Error("wrong password is accepted:", err)
+	}
+}
@@ -22,6 +22,7 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -45,9 +46,20 @@ type variable struct {
 type CLI struct {
 	version string
 
+	command   string
+	argsUsage string
+	args      []string
+
+	commands []command
+
 	vars []variable
 }
 
+type command struct {
+	name  string
+	usage string
+}
+
 type FlagOptions struct {
 	Required bool
 	PreHook  func(string) (string, error)
@@ -61,6 +73,32 @@ func New(version string) *CLI {
 	}
 }
 
+// NewCommand creates a CLI for the "command" subcommand.
+// Unlike the main CLI it accepts positional arguments, argsUsage describes them in help.
+func NewCommand(version string, command string, argsUsage string) *CLI {
+	return &CLI{
+		version: version,
+
+		command:   command,
+		argsUsage: argsUsage,
+
+		vars: []variable{},
+	}
+}
+
+// AddCommand adds the subcommand to the help message.
+func (c *CLI) AddCommand(name string, usage string) {
+	c.commands = append(c.commands, command{
+		name:  name,
+		usage: usage,
+	})
+}
+
+// Args returns positional arguments after Parse.
+func (c *CLI) Args() []string {
+	return c.args
+}
+
 func (c *CLI) addVar(name string, value interface{}, defValue string, usage string, opts *FlagOptions) {
 	if name == "" {
 		panic("cli: add variable: variable name could not be empty")
@@ -210,7 +248,17 @@ func (c *CLI) printHelp() {
 	}
 
 	// Print help
-	fmt.Println("Usage:", os.Args[0], reqFlags+"[OPTION]...")
+	usage := os.Args[0]
+	if c.command != "" {
+		usage += " " + c.command
+	}
+
+	usage += " " + reqFlags + "[OPTION]..."
+	if c.argsUsage != "" {
+		usage += " " + c.argsUsage
+	}
+
+	fmt.Println("Usage:", usage)
 	fmt.Println("")
 
 	for _, v := range c.vars {
@@ -231,6 +279,27 @@ func (c *CLI) printHelp() {
 	fmt.Println("  -version   Display version and exit.")
 	fmt.Println("  -help      Display this help and exit.")
 
+	if len(c.commands) != 0 {
+		var maxNameSize int
+		for _, cmd := range c.commands {
+			if len(cmd.name) > maxNameSize {
+				maxNameSize = len(cmd.name)
+			}
+		}
+
+		fmt.Println()
+		fmt.Println("Commands:")
+
+		for _, cmd := range c.commands {
+			var spaces string
+			for i := 0; i < maxNameSize-len(cmd.name)+2; i++ {
+				spaces += " "
+			}
+
+			fmt.Println(" ", cmd.name, spaces, cmd.usage)
+		}
+	}
+
 	os.Exit(0)
 }
 
@@ -239,12 +308,18 @@ func (c *CLI) Parse() {
 	// Used to check if "required" flags are present.
 	readVars := make(map[string]struct{})
 
+	// Skip program name and subcommand name
+	osArgs := os.Args[1:]
+	if c.command != "" {
+		osArgs = os.Args[2:]
+	}
+
 	// Read variables from CLI flags
 	{
 		alreadyRead := make(map[string]struct{})
 
 		var varInProgress *variable
-		for _, arg := range os.Args[1:] {
+		for _, arg := range osArgs {
 			if varInProgress == nil {
 				switch arg {
 				case "-version":
@@ -254,9 +329,15 @@ func (c *CLI) Parse() {
 					c.printHelp()
 				}
 
+				// Positional argument
+				if c.argsUsage != "" && strings.HasPrefix(arg, "-") == false {
+					c.args = append(c.args, arg)
+					continue
+				}
+
 				_, exist := alreadyRead[arg]
 				if exist {
-					exitOnError("flag \"" + varInProgress.cliFlagName + "\" occurs twice")
+					exitOnError("flag \"" + arg + "\" occurs twice")
 				}
 
 				ok := false
@@ -264,7 +345,8 @@ func (c *CLI) Parse() {
 					if v.cliFlagName == arg {
 						switch v.value.(type) {
 						case *bool:
-							// pass
+							// Bool flag has no value
+							*v.value.(*bool) = true
 						default:
 							varInProgress = &v
 						}
@@ -44,6 +44,8 @@ func parseDuration(s string) (time.Duration, error) {
 		}
 
 		switch c {
+		case 's':
+			out += val
 		case 'm':
 			out += val * 60
 		case 'h':
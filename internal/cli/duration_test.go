@@ -25,6 +25,7 @@ import (
 
 func TestParseDuration(t *testing.T) {
 	testData := map[string]time.Duration{
+		"5s":    5 * time.Second,
 		"10m":   60 * 10 * time.Second,
 		"1h 1d": 60 * 60 * 25 * time.Second,
 		"1h1d": 60 * 60 * 25 * time.Second,
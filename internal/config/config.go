@@ -19,17 +19,41 @@
 package config
 
 import (
+	"errors"
 	"github.com/lcomrade/lenpaste/internal/logger"
 	"github.com/lcomrade/lenpaste/internal/netshare"
+	"github.com/lcomrade/lenpaste/internal/oidc"
+	"time"
 )
 
 const Software = "Lenpaste"
 
+// Who can create user accounts in the WEB interface.
+const (
+	RegistrationOpen   = "open"   // Anyone.
+	RegistrationAdmin  = "admin"  // Only administrators.
+	RegistrationClosed = "closed" // Nobody, only with "lenpaste user add" command.
+)
+
+var ErrOpenRegistration = errors.New("config: open registration can not be used when authorization is required to create pastes")
+
+// CheckRegistration returns ErrOpenRegistration if anyone could sign up
+// and create pastes without the required authorization, logged in users skip it.
+func CheckRegistration(registration string, accounts bool, a netshare.Auth) error {
+	if accounts && a.Required() && registration == RegistrationOpen {
+		return ErrOpenRegistration
+	}
+
+	return nil
+}
+
 type Config struct {
 	Log logger.Logger
 
-	RateLimitNew *netshare.RateLimitSystem
-	RateLimitGet *netshare.RateLimitSystem
+	RateLimitNew    *netshare.RateLimitSystem
+	RateLimitGet    *netshare.RateLimitSystem
+	RateLimitAuth   *netshare.RateLimitSystem
+	RateLimitSearch *netshare.RateLimitSystem
 
 	Version string
 
@@ -46,9 +70,19 @@ type Config struct {
 
 	RobotsDisallow bool
 
-	LenPasswdFile string
+	Auth netshare.Auth
+	OIDC *oidc.Provider
+
+	Accounts        bool
+	Registration    string
+	SessionLifetime time.Duration
+	ReportsAutoHide int
+
+	PublicURL      string
+	TCPIdleTimeout time.Duration
 
 	UiDefaultLifetime string
 	UiDefaultTheme    string
 	UiThemesDir       string
+	UiDisableHistory  bool
 }
@@ -0,0 +1,53 @@
+// Copyright (C) 2021-2023 Leonid Maslakov.
+
+// This file is part of Lenpaste.
+
+// Lenpaste is free software: you can redistribute it
+// and/or modify it under the terms of the
+// GNU Affero Public License as published by the
+// Free Software Foundation, either version 3 of the License,
+// or (at your option) any later version.
+
+// Lenpaste is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+// or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero Public License for more details.
+
+// You should have received a copy of the GNU Affero Public License along with Lenpaste.
+// If not, see <https://www.gnu.org/licenses/>.
+
+package config
+
+import (
+	"testing"
+
+	"github.com/lcomrade/lenpaste/internal/auth"
+	"github.com/lcomrade/lenpaste/internal/netshare"
+)
+
+func TestCheckRegistration(t *testing.T) {
+	lenpasswd := netshare.Auth{Provider: auth.NewFile("/data/lenpasswd")}
+	oidcOnly := netshare.Auth{OIDC: true}
+
+	tests := []struct {
+		name         string
+		registration string
+		accounts     bool
+		auth         netshare.Auth
+		expect       error
+	}{
+		{"no auth", RegistrationOpen, true, netshare.Auth{}, nil},
+		{"lenpasswd without accounts", RegistrationOpen, false, lenpasswd, nil},
+		{"lenpasswd with open registration", RegistrationOpen, true, lenpasswd, ErrOpenRegistration},
+		{"lenpasswd with admin registration", RegistrationAdmin, true, lenpasswd, nil},
+		{"OIDC only with open registration", RegistrationOpen, true, oidcOnly, ErrOpenRegistration},
+		{"OIDC only with closed registration", RegistrationClosed, true, oidcOnly, nil},
+	}
+
+	for _, test := range tests {
+		err := CheckRegistration(test.registration, test.accounts, test.auth)
+		if err != test.expect {
+			t.Error(test.name+":", "expected", test.expect, "but got", err)
+		}
+	}
+}
@@ -0,0 +1,339 @@
+// Copyright (C) 2021-2023 Leonid Maslakov.
+
+// This file is part of Lenpaste.
+
+// Lenpaste is free software: you can redistribute it
+// and/or modify it under the terms of the
+// GNU Affero Public License as published by the
+// Free Software Foundation, either version 3 of the License,
+// or (at your option) any later version.
+
+// Lenpaste is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+// or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero Public License for more details.
+
+// You should have received a copy of the GNU Affero Public License along with Lenpaste.
+// If not, see <https://www.gnu.org/licenses/>.
+
+// Package diff finds line differences between two texts.
+package diff
+
+import (
+	"strings"
+)
+
+const (
+	OpEqual  = "equal"
+	OpInsert = "insert"
+	OpDelete = "delete"
+)
+
+// MaxEditDistance limits the work done to find the shortest edit script.
+// If the texts differ more, the whole old text is shown as deleted and the new one as inserted.
+const MaxEditDistance = 2000
+
+type Line struct {
+	Op      string `json:"op"`
+	OldLine int    `json:"oldLine"` // 0 for inserted lines
+	NewLine int    `json:"newLine"` // 0 for deleted lines
+	Text    string `json:"text"`
+}
+
+type Hunk struct {
+	OldStart int    `json:"oldStart"`
+	OldLines int    `json:"oldLines"`
+	NewStart int    `json:"newStart"`
+	NewLines int    `json:"newLines"`
+	Lines    []Line `json:"lines"`
+}
+
+// SplitLines splits text into lines without line end characters.
+func SplitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+
+	text = strings.Replace(text, "\r\n", "\n", -1)
+	text = strings.Replace(text, "\r", "\n", -1)
+	text = strings.TrimSuffix(text, "\n")
+
+	return strings.Split(text, "\n")
+}
+
+// Lines returns the shortest edit script that turns the old lines into the new ones.
+// It uses the Myers algorithm.
+func Lines(oldLines []string, newLines []string) []Line {
+	// Replace lines with numbers so that they are compared faster
+	ids := make(map[string]int)
+	a := make([]int, len(oldLines))
+	b := make([]int, len(newLines))
+
+	for i, line := range oldLines {
+		id, ok := ids[line]
+		if ok == false {
+			id = len(ids)
+			ids[line] = id
+		}
+		a[i] = id
+	}
+
+	for i, line := range newLines {
+		id, ok := ids[line]
+		if ok == false {
+			id = len(ids)
+			ids[line] = id
+		}
+		b[i] = id
+	}
+
+	// Common prefix and suffix does not need the Myers algorithm
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	ops := shortestEdit(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])
+
+	// Build result
+	result := make([]Line, 0, len(a)+len(b))
+	oldPos, newPos := 0, 0
+
+	addEqual := func(count int) {
+		for i := 0; i < count; i++ {
+			result = append(result, Line{Op: OpEqual, OldLine: oldPos + 1, NewLine: newPos + 1, Text: oldLines[oldPos]})
+			oldPos++
+			newPos++
+		}
+	}
+
+	addEqual(prefix)
+
+	for _, op := range ops {
+		switch op {
+		case OpEqual:
+			addEqual(1)
+
+		case OpDelete:
+			result = append(result, Line{Op: OpDelete, OldLine: oldPos + 1, Text: oldLines[oldPos]})
+			oldPos++
+
+		case OpInsert:
+			result = append(result, Line{Op: OpInsert, NewLine: newPos + 1, Text: newLines[newPos]})
+			newPos++
+		}
+	}
+
+	addEqual(suffix)
+
+	return result
+}
+
+// vSnapshot is the state of the Myers algorithm before the step d.
+// It stores only the diagonals from -d-1 to d+1.
+type vSnapshot struct {
+	d int
+	v []int
+}
+
+func (s vSnapshot) get(k int) int {
+	return s.v[k+s.d+1]
+}
+
+// shortestEdit returns the list of operations (OpEqual, OpDelete and OpInsert) that turns a into b.
+func shortestEdit(a []int, b []int) []string {
+	n, m := len(a), len(b)
+	max := n + m
+
+	if max == 0 {
+		return nil
+	}
+
+	offset := max + 1
+	v := make([]int, 2*max+3)
+
+	var trace []vSnapshot
+	found := false
+
+	for d := 0; d <= max && d <= MaxEditDistance; d++ {
+		// Save state
+		snap := vSnapshot{d: d, v: make([]int, 2*d+3)}
+		copy(snap.v, v[offset-d-1:offset+d+2])
+		trace = append(trace, snap)
+
+		for k := -d; k <= d; k += 2 {
+			// Move down (insert) or right (delete)
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+
+			// Follow the diagonal (equal lines)
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+
+			v[offset+k] = x
+
+			if x >= n && y >= m {
+				found = true
+				break
+			}
+		}
+
+		if found {
+			break
+		}
+	}
+
+	// Texts are too different
+	if found == false {
+		ops := make([]string, 0, max)
+		for i := 0; i < n; i++ {
+			ops = append(ops, OpDelete)
+		}
+		for i := 0; i < m; i++ {
+			ops = append(ops, OpInsert)
+		}
+
+		return ops
+	}
+
+	// Backtrack
+	ops := make([]string, 0, max)
+	x, y := n, m
+
+	for d := len(trace) - 1; d >= 0; d-- {
+		snap := trace[d]
+		k := x - y
+
+		var prevK int
+		if k == -d || (k != d && snap.get(k-1) < snap.get(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+
+		prevX := snap.get(prevK)
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			ops = append(ops, OpEqual)
+			x--
+			y--
+		}
+
+		if d > 0 {
+			if x == prevX {
+				ops = append(ops, OpInsert)
+			} else {
+				ops = append(ops, OpDelete)
+			}
+		}
+
+		x, y = prevX, prevY
+	}
+
+	// Reverse
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+
+	return ops
+}
+
+// Hunks groups changed lines with the given number of context lines around them.
+// Returns nil if there are no changes.
+func Hunks(lines []Line, context int) []Hunk {
+	var hunks []Hunk
+
+	i := 0
+	for i < len(lines) {
+		// Find next change
+		if lines[i].Op == OpEqual {
+			i++
+			continue
+		}
+
+		start := i - context
+		if start < 0 {
+			start = 0
+		}
+
+		// Find the end of the hunk, changes closer than 2*context lines are merged
+		end := i
+		for j := i; j < len(lines); j++ {
+			if lines[j].Op != OpEqual {
+				end = j
+				continue
+			}
+
+			if j-end > 2*context {
+				break
+			}
+		}
+
+		end = end + context + 1
+		if end > len(lines) {
+			end = len(lines)
+		}
+
+		hunks = append(hunks, newHunk(lines, start, end))
+		i = end
+	}
+
+	return hunks
+}
+
+func newHunk(lines []Line, start int, end int) Hunk {
+	hunk := Hunk{Lines: lines[start:end]}
+
+	// Count lines
+	for _, line := range hunk.Lines {
+		if line.Op != OpInsert {
+			hunk.OldLines++
+			if hunk.OldStart == 0 {
+				hunk.OldStart = line.OldLine
+			}
+		}
+
+		if line.Op != OpDelete {
+			hunk.NewLines++
+			if hunk.NewStart == 0 {
+				hunk.NewStart = line.NewLine
+			}
+		}
+	}
+
+	// Like in the unified diff format, an empty range starts at the line before it
+	if hunk.OldLines == 0 {
+		hunk.OldStart = linesBefore(lines, start, OpInsert)
+	}
+
+	if hunk.NewLines == 0 {
+		hunk.NewStart = linesBefore(lines, start, OpDelete)
+	}
+
+	return hunk
+}
+
+// linesBefore counts old (skip = OpInsert) or new (skip = OpDelete) lines before the index.
+func linesBefore(lines []Line, index int, skip string) int {
+	count := 0
+	for _, line := range lines[:index] {
+		if line.Op != skip {
+			count++
+		}
+	}
+
+	return count
+}
@@ -0,0 +1,178 @@
+// Copyright (C) 2021-2023 Leonid Maslakov.
+
+// This file is part of Lenpaste.
+
+// Lenpaste is free software: you can redistribute it
+// and/or modify it under the terms of the
+// GNU Affero Public License as published by the
+// Free Software Foundation, either version 3 of the License,
+// or (at your option) any later version.
+
+// Lenpaste is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+// or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero Public License for more details.
+
+// You should have received a copy of the GNU Affero Public License along with Lenpaste.
+// If not, see <https://www.gnu.org/licenses/>.
+
+package diff
+
+import (
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// checkLines checks that the edit script turns the old lines into the new ones.
+func checkLines(t *testing.T, oldLines []string, newLines []string, lines []Line) {
+	var gotOld, gotNew []string
+
+	for _, line := range lines {
+		if line.Op != OpInsert {
+			if line.OldLine != len(gotOld)+1 {
+				t.Fatal("wrong old line number:", line)
+			}
+			gotOld = append(gotOld, line.Text)
+		}
+
+		if line.Op != OpDelete {
+			if line.NewLine != len(gotNew)+1 {
+				t.Fatal("wrong new line number:", line)
+			}
+			gotNew = append(gotNew, line.Text)
+		}
+	}
+
+	if strings.Join(gotOld, "\n") != strings.Join(oldLines, "\n") || strings.Join(gotNew, "\n") != strings.Join(newLines, "\n") {
+		t.Fatal("edit script does not match texts:", oldLines, newLines, lines)
+	}
+}
+
+// lcsLen returns the length of the longest common subsequence.
+func lcsLen(a []string, b []string) int {
+	dp := make([][]int, len(a)+1)
+	for i := range dp {
+		dp[i] = make([]int, len(b)+1)
+	}
+
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				dp[i][j] = dp[i+1][j+1] + 1
+			} else if dp[i+1][j] > dp[i][j+1] {
+				dp[i][j] = dp[i+1][j]
+			} else {
+				dp[i][j] = dp[i][j+1]
+			}
+		}
+	}
+
+	return dp[0][0]
+}
+
+func TestSplitLines(t *testing.T) {
+	testData := map[string][]string{
+		"":           nil,
+		"a":          {"a"},
+		"a\n":        {"a"},
+		"a\r\nb\r\n": {"a", "b"},
+		"a\rb":       {"a", "b"},
+		"a\n\nb":     {"a", "", "b"},
+	}
+
+	for input, expect := range testData {
+		if reflect.DeepEqual(SplitLines(input), expect) == false {
+			t.Error("input:", input, "expect:", expect, "got:", SplitLines(input))
+		}
+	}
+}
+
+func TestLines(t *testing.T) {
+	oldLines := SplitLines("a\nb\nc\na\nb\nb\na")
+	newLines := SplitLines("c\nb\na\nb\na\nc")
+
+	lines := Lines(oldLines, newLines)
+	checkLines(t, oldLines, newLines, lines)
+
+	// Example from the Myers paper, the shortest edit script has 5 changes
+	changes := 0
+	for _, line := range lines {
+		if line.Op != OpEqual {
+			changes++
+		}
+	}
+
+	if changes != 5 {
+		t.Error("expected 5 changes but got", changes)
+	}
+}
+
+func TestLinesRandom(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	alphabet := []string{"a", "b", "c", "d"}
+
+	for i := 0; i < 500; i++ {
+		oldLines := make([]string, r.Intn(20))
+		for j := range oldLines {
+			oldLines[j] = alphabet[r.Intn(len(alphabet))]
+		}
+
+		newLines := make([]string, r.Intn(20))
+		for j := range newLines {
+			newLines[j] = alphabet[r.Intn(len(alphabet))]
+		}
+
+		lines := Lines(oldLines, newLines)
+		checkLines(t, oldLines, newLines, lines)
+
+		// Edit script must be the shortest
+		equal := 0
+		for _, line := range lines {
+			if line.Op == OpEqual {
+				equal++
+			}
+		}
+
+		if equal != lcsLen(oldLines, newLines) {
+			t.Fatal("edit script is not the shortest:", oldLines, newLines)
+		}
+	}
+}
+
+func TestHunks(t *testing.T) {
+	var oldLines, newLines []string
+	for i := 0; i < 20; i++ {
+		oldLines = append(oldLines, string(rune('a'+i)))
+		newLines = append(newLines, string(rune('a'+i)))
+	}
+
+	// Change line 3 and insert a line after line 18
+	newLines[2] = "C"
+	newLines = append(newLines[:18], append([]string{"new"}, newLines[18:]...)...)
+
+	hunks := Hunks(Lines(oldLines, newLines), 3)
+	if len(hunks) != 2 {
+		t.Fatal("expected 2 hunks but got", len(hunks))
+	}
+
+	if hunks[0].OldStart != 1 || hunks[0].OldLines != 6 || hunks[0].NewStart != 1 || hunks[0].NewLines != 6 {
+		t.Error("unexpected first hunk:", hunks[0])
+	}
+
+	if hunks[1].OldStart != 16 || hunks[1].OldLines != 5 || hunks[1].NewStart != 16 || hunks[1].NewLines != 6 {
+		t.Error("unexpected second hunk:", hunks[1])
+	}
+
+	// No changes
+	if Hunks(Lines(oldLines, oldLines), 3) != nil {
+		t.Error("expected no hunks")
+	}
+
+	// Insert into the empty text
+	hunks = Hunks(Lines(nil, []string{"a"}), 3)
+	if len(hunks) != 1 || hunks[0].OldStart != 0 || hunks[0].OldLines != 0 || hunks[0].NewStart != 1 || hunks[0].NewLines != 1 {
+		t.Error("unexpected hunks:", hunks)
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright (C) 2021-2023 Leonid Maslakov.
+
+// This file is part of Lenpaste.
+
+// Lenpaste is free software: you can redistribute it
+// and/or modify it under the terms of the
+// GNU Affero Public License as published by the
+// Free Software Foundation, either version 3 of the License,
+// or (at your option) any later version.
+
+// Lenpaste is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+// or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero Public License for more details.
+
+// You should have received a copy of the GNU Affero Public License along with Lenpaste.
+// If not, see <https://www.gnu.org/licenses/>.
+
+package hastebin
+
+import (
+	chromaLexers "github.com/alecthomas/chroma/v2/lexers"
+	"github.com/lcomrade/lenpaste/internal/config"
+	"github.com/lcomrade/lenpaste/internal/logger"
+	"github.com/lcomrade/lenpaste/internal/netshare"
+	"github.com/lcomrade/lenpaste/internal/storage"
+	"net/http"
+	"strings"
+)
+
+// Data serves the hastebin compatible API:
+// POST /documents creates the paste and GET /documents/<key> returns it.
+// GET /raw/<key> is served by the raw package.
+type Data struct {
+	Log logger.Logger
+	DB  storage.Store
+
+	RateLimitNew *netshare.RateLimitSystem
+	RateLimitGet *netshare.RateLimitSystem
+
+	Lexers []string
+
+	Version string
+
+	TitleMaxLen int
+	BodyMaxLen  int
+	MaxLifeTime int64
+
+	Auth netshare.Auth
+}
+
+func Load(db storage.Store, cfg config.Config) *Data {
+	return &Data{
+		DB:           db,
+		Log:          cfg.Log,
+		RateLimitNew: cfg.RateLimitNew,
+		RateLimitGet: cfg.RateLimitGet,
+		Lexers:       chromaLexers.Names(false),
+		Version:      cfg.Version,
+		TitleMaxLen:  cfg.TitleMaxLen,
+		BodyMaxLen:   cfg.BodyMaxLen,
+		MaxLifeTime:  cfg.MaxLifeTime,
+		Auth:         cfg.Auth,
+	}
+}
+
+func (data *Data) Hand(rw http.ResponseWriter, req *http.Request) {
+	// Process request
+	var err error
+
+	rw.Header().Set("Server", config.Software+"/"+data.Version)
+
+	// Check API token
+	req, err = netshare.AuthRequest(req, data.DB)
+	if err == nil {
+		if req.URL.Path == "/documents" {
+			err = data.newHand(rw, req)
+
+		} else if strings.HasPrefix(req.URL.Path, "/documents/") {
+			err = data.getHand(rw, req, strings.TrimPrefix(req.URL.Path, "/documents/"))
+
+		} else {
+			err = netshare.ErrNotFound
+		}
+	}
+
+	// Log
+	if err == nil {
+		data.Log.HttpRequest(req, 200)
+
+	} else {
+		code, err := data.writeError(rw, req, err)
+		if err != nil {
+			data.Log.HttpError(req, err)
+		} else {
+			data.Log.HttpRequest(req, code)
+		}
+	}
+}
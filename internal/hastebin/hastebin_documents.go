@@ -0,0 +1,104 @@
+// Copyright (C) 2021-2023 Leonid Maslakov.
+
+// This file is part of Lenpaste.
+
+// Lenpaste is free software: you can redistribute it
+// and/or modify it under the terms of the
+// GNU Affero Public License as published by the
+// Free Software Foundation, either version 3 of the License,
+// or (at your option) any later version.
+
+// Lenpaste is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+// or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero Public License for more details.
+
+// You should have received a copy of the GNU Affero Public License along with Lenpaste.
+// If not, see <https://www.gnu.org/licenses/>.
+
+package hastebin
+
+import (
+	"encoding/json"
+	"github.com/lcomrade/lenpaste/internal/netshare"
+	"net/http"
+)
+
+type newAnswer struct {
+	Key string `json:"key"`
+}
+
+type getAnswer struct {
+	Key  string `json:"key"`
+	Data string `json:"data"`
+}
+
+// POST /documents
+func (data *Data) newHand(rw http.ResponseWriter, req *http.Request) error {
+	var err error
+
+	// Check auth
+	err = netshare.CheckCreateAuth(req, data.Auth)
+	if err != nil {
+		return err
+	}
+
+	// Check method
+	if req.Method != "POST" {
+		return netshare.ErrMethodNotAllowed
+	}
+
+	// Request body is the paste text
+	paste, err := netshare.PasteAddFromBody(req, data.DB, data.RateLimitNew, data.TitleMaxLen, data.BodyMaxLen, data.MaxLifeTime, data.Lexers)
+	if err != nil {
+		return err
+	}
+
+	// Return response
+	rw.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(rw).Encode(newAnswer{Key: paste.ID})
+}
+
+// GET /documents/<key>
+func (data *Data) getHand(rw http.ResponseWriter, req *http.Request, pasteID string) error {
+	// Check rate limit
+	err := data.RateLimitGet.CheckAndUse(netshare.GetClientAddr(req))
+	if err != nil {
+		return err
+	}
+
+	// Check method
+	if req.Method != "GET" {
+		return netshare.ErrMethodNotAllowed
+	}
+
+	// Get paste
+	paste, err := data.DB.PasteGet(pasteID)
+	if err != nil {
+		return err
+	}
+
+	// Private paste can be opened only by its owner
+	err = netshare.PasteCheckVisibility(req, paste)
+	if err != nil {
+		return err
+	}
+
+	// Check paste password
+	err = netshare.PasteCheckPassword(req, paste, data.RateLimitGet, netshare.PastePassword(req, true))
+	if err != nil {
+		return err
+	}
+
+	// If "one use" paste
+	if paste.OneUse == true {
+		err = data.DB.PasteDelete(pasteID)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Return response
+	rw.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(rw).Encode(getAnswer{Key: paste.ID, Data: paste.Body})
+}
@@ -0,0 +1,99 @@
+// Copyright (C) 2021-2023 Leonid Maslakov.
+
+// This file is part of Lenpaste.
+
+// Lenpaste is free software: you can redistribute it
+// and/or modify it under the terms of the
+// GNU Affero Public License as published by the
+// Free Software Foundation, either version 3 of the License,
+// or (at your option) any later version.
+
+// Lenpaste is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+// or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero Public License for more details.
+
+// You should have received a copy of the GNU Affero Public License along with Lenpaste.
+// If not, see <https://www.gnu.org/licenses/>.
+
+package hastebin
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/lcomrade/lenpaste/internal/netshare"
+	"github.com/lcomrade/lenpaste/internal/storage"
+	"net/http"
+	"strconv"
+)
+
+// Hastebin returns errors as {"message": "..."}.
+type errorType struct {
+	Message string `json:"message"`
+}
+
+func (data *Data) writeError(rw http.ResponseWriter, req *http.Request, e error) (int, error) {
+	var resp errorType
+	var code int
+
+	var eTmp429 *netshare.ErrTooManyRequests
+	var eTmpBan *netshare.ErrBanned
+
+	if e == netshare.ErrBadRequest {
+		code = 400
+		resp.Message = "Bad Request"
+
+	} else if e == netshare.ErrUnauthorized {
+		rw.Header().Add("WWW-Authenticate", "Basic")
+		code = 401
+		resp.Message = "Unauthorized"
+
+	} else if e == netshare.ErrPasswordRequired {
+		rw.Header().Add("WWW-Authenticate", "Basic")
+		code = 401
+		resp.Message = "Password Required"
+
+	} else if e == netshare.ErrForbidden {
+		code = 403
+		resp.Message = "Forbidden"
+
+	} else if errors.As(e, &eTmpBan) {
+		code = 403
+		resp.Message = netshare.BanMessage(eTmpBan)
+
+	} else if e == netshare.ErrWrongPassword {
+		code = 403
+		resp.Message = "Wrong Password"
+
+	} else if e == storage.ErrNotFoundID || e == netshare.ErrNotFound {
+		code = 404
+		resp.Message = "Document not found."
+
+	} else if e == netshare.ErrMethodNotAllowed {
+		code = 405
+		resp.Message = "Method Not Allowed"
+
+	} else if e == netshare.ErrPayloadTooLarge {
+		code = 413
+		resp.Message = "Document exceeds maximum length."
+
+	} else if errors.As(e, &eTmp429) {
+		code = 429
+		resp.Message = "Too Many Requests"
+		rw.Header().Set("Retry-After", strconv.FormatInt(eTmp429.RetryAfter, 10))
+
+	} else {
+		code = 500
+		resp.Message = "Error adding document."
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(code)
+
+	err := json.NewEncoder(rw).Encode(resp)
+	if err != nil {
+		return 500, err
+	}
+
+	return code, nil
+}
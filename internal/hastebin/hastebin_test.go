@@ -0,0 +1,97 @@
+// Copyright (C) 2021-2023 Leonid Maslakov.
+
+// This file is part of Lenpaste.
+
+// Lenpaste is free software: you can redistribute it
+// and/or modify it under the terms of the
+// GNU Affero Public License as published by the
+// Free Software Foundation, either version 3 of the License,
+// or (at your option) any later version.
+
+// Lenpaste is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+// or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero Public License for more details.
+
+// You should have received a copy of the GNU Affero Public License along with Lenpaste.
+// If not, see <https://www.gnu.org/licenses/>.
+
+package hastebin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/lcomrade/lenpaste/internal/config"
+	"github.com/lcomrade/lenpaste/internal/logger"
+	"github.com/lcomrade/lenpaste/internal/netshare"
+	"github.com/lcomrade/lenpaste/internal/storage"
+)
+
+func newTestData() *Data {
+	return Load(storage.NewMemory(), config.Config{
+		Log:          logger.New("2006/01/02 15:04:05"),
+		RateLimitNew: netshare.NewRateLimitSystem(0, 0, 0),
+		RateLimitGet: netshare.NewRateLimitSystem(0, 0, 0),
+		TitleMaxLen:  100,
+		BodyMaxLen:   20000,
+		MaxLifeTime:  -1,
+	})
+}
+
+func TestDocuments(t *testing.T) {
+	data := newTestData()
+
+	// Create document
+	req := httptest.NewRequest("POST", "/documents", strings.NewReader("Hello, world!"))
+	req.Header.Set("Content-Type", "text/plain")
+	rw := httptest.NewRecorder()
+	data.Hand(rw, req)
+
+	if rw.Code != http.StatusOK {
+		t.Fatal("expected 200 but got", rw.Code, rw.Body.String())
+	}
+
+	var created newAnswer
+	err := json.NewDecoder(rw.Body).Decode(&created)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Get document
+	req = httptest.NewRequest("GET", "/documents/"+created.Key, nil)
+	rw = httptest.NewRecorder()
+	data.Hand(rw, req)
+
+	if rw.Code != http.StatusOK {
+		t.Fatal("expected 200 but got", rw.Code, rw.Body.String())
+	}
+
+	var doc getAnswer
+	err = json.NewDecoder(rw.Body).Decode(&doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if doc.Key != created.Key || doc.Data != "Hello, world!" {
+		t.Error("unexpected document:", doc)
+	}
+
+	// Get unknown document
+	req = httptest.NewRequest("GET", "/documents/unknown", nil)
+	rw = httptest.NewRecorder()
+	data.Hand(rw, req)
+
+	var errResp errorType
+	err = json.NewDecoder(rw.Body).Decode(&errResp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rw.Code != http.StatusNotFound || errResp.Message == "" {
+		t.Error("expected 404 but got", rw.Code)
+	}
+}
@@ -25,10 +25,25 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
+// Data maps the user name to the password hash or to the plain password.
 type Data map[string]string
 
+type cacheEntry struct {
+	data    Data
+	modTime time.Time
+	size    int64
+}
+
+// Parsed files, see Load.
+var (
+	cacheMu sync.Mutex
+	cache   = make(map[string]cacheEntry)
+)
+
 func LoadFile(path string) (Data, error) {
 	// Open file
 	file, err := os.Open(path)
@@ -66,27 +81,74 @@ func LoadFile(path string) (Data, error) {
 			return nil, errors.New("lenpasswd: overriding user " + user + " in line " + strconv.Itoa(i))
 		}
 
+		if checkHashFormat(pass) == false {
+			return nil, errors.New("lenpasswd: unknown password hash format in line " + strconv.Itoa(i))
+		}
+
 		data[user] = pass
 	}
 
 	return data, nil
 }
 
+// Load returns the parsed file.
+// The file is kept in memory and parsed again only if its modification time or size changes.
+func Load(path string) (Data, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, errors.New("lenpasswd: " + err.Error())
+	}
+
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+
+	entry, exist := cache[path]
+	if exist && entry.modTime.Equal(info.ModTime()) && entry.size == info.Size() {
+		return entry.data, nil
+	}
+
+	data, err := LoadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	cache[path] = cacheEntry{data: data, modTime: info.ModTime(), size: info.Size()}
+
+	return data, nil
+}
+
+// Reload parses the file again even if its modification time has not changed.
+// It is called when the server receives SIGHUP.
+func Reload(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return errors.New("lenpasswd: " + err.Error())
+	}
+
+	data, err := LoadFile(path)
+	if err != nil {
+		return err
+	}
+
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+
+	cache[path] = cacheEntry{data: data, modTime: info.ModTime(), size: info.Size()}
+
+	return nil
+}
+
 func (data Data) Check(user string, pass string) bool {
 	truePass, exist := data[user]
 	if exist == false {
 		return false
 	}
 
-	if pass != truePass {
-		return false
-	}
-
-	return true
+	return checkPassword(pass, truePass)
 }
 
 func LoadAndCheck(path string, user string, pass string) (bool, error) {
-	data, err := LoadFile(path)
+	data, err := Load(path)
 	if err != nil {
 		return false, err
 	}
@@ -0,0 +1,128 @@
+// Copyright (C) 2021-2023 Leonid Maslakov.
+
+// This file is part of Lenpaste.
+
+// Lenpaste is free software: you can redistribute it
+// and/or modify it under the terms of the
+// GNU Affero Public License as published by the
+// Free Software Foundation, either version 3 of the License,
+// or (at your option) any later version.
+
+// Lenpaste is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+// or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero Public License for more details.
+
+// You should have received a copy of the GNU Affero Public License along with Lenpaste.
+// If not, see <https://www.gnu.org/licenses/>.
+
+package lenpasswd
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+var (
+	ErrBadUserName  = errors.New("lenpasswd: user name must not be empty or contain ':' and line breaks")
+	ErrUserNotFound = errors.New("lenpasswd: user not found")
+)
+
+// readLines reads the file lines. Not existing file has no lines.
+func readLines(path string) ([]string, os.FileMode, error) {
+	fileByte, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, 0600, nil
+		}
+
+		return nil, 0, errors.New("lenpasswd: " + err.Error())
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, 0, errors.New("lenpasswd: " + err.Error())
+	}
+
+	var lines []string
+	for _, line := range strings.Split(string(fileByte), "\n") {
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	return lines, info.Mode().Perm(), nil
+}
+
+// writeLines replaces the file, so the server never reads a partially written file.
+func writeLines(path string, lines []string, perm os.FileMode) error {
+	text := strings.Join(lines, "\n")
+	if text != "" {
+		text += "\n"
+	}
+
+	tmpPath := path + ".tmp"
+
+	err := ioutil.WriteFile(tmpPath, []byte(text), perm)
+	if err != nil {
+		return errors.New("lenpasswd: " + err.Error())
+	}
+
+	err = os.Rename(tmpPath, path)
+	if err != nil {
+		os.Remove(tmpPath)
+		return errors.New("lenpasswd: " + err.Error())
+	}
+
+	return nil
+}
+
+// SetUser adds the user to the file or replaces the password hash of the existing user.
+// The file is created if it does not exist.
+func SetUser(path string, user string, hash string) error {
+	if user == "" || strings.ContainsAny(user, ":\r\n") {
+		return ErrBadUserName
+	}
+
+	lines, perm, err := readLines(path)
+	if err != nil {
+		return err
+	}
+
+	found := false
+	for i, line := range lines {
+		if strings.HasPrefix(line, user+":") {
+			lines[i] = user + ":" + hash
+			found = true
+		}
+	}
+
+	if found == false {
+		lines = append(lines, user+":"+hash)
+	}
+
+	return writeLines(path, lines, perm)
+}
+
+// DelUser deletes the user from the file.
+func DelUser(path string, user string) error {
+	lines, perm, err := readLines(path)
+	if err != nil {
+		return err
+	}
+
+	var newLines []string
+	for _, line := range lines {
+		if strings.HasPrefix(line, user+":") == false {
+			newLines = append(newLines, line)
+		}
+	}
+
+	if len(newLines) == len(lines) {
+		return ErrUserNotFound
+	}
+
+	return writeLines(path, newLines, perm)
+}
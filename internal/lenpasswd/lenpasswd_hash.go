@@ -0,0 +1,142 @@
+// Copyright (C) 2021-2023 Leonid Maslakov.
+
+// This file is part of Lenpaste.
+
+// Lenpaste is free software: you can redistribute it
+// and/or modify it under the terms of the
+// GNU Affero Public License as published by the
+// Free Software Foundation, either version 3 of the License,
+// or (at your option) any later version.
+
+// Lenpaste is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+// or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero Public License for more details.
+
+// You should have received a copy of the GNU Affero Public License along with Lenpaste.
+// If not, see <https://www.gnu.org/licenses/>.
+
+package lenpasswd
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"github.com/lcomrade/lenpaste/internal/storage"
+	"golang.org/x/crypto/bcrypt"
+	"math/big"
+	"strings"
+)
+
+// Password hash algorithms supported by HashPassword.
+const (
+	AlgBcrypt   = "bcrypt"
+	AlgArgon2id = "argon2id"
+	AlgSHA256   = "sha256-crypt"
+	AlgSHA512   = "sha512-crypt"
+)
+
+var Algorithms = []string{AlgBcrypt, AlgArgon2id, AlgSHA256, AlgSHA512}
+
+var ErrUnknownAlgorithm = errors.New("lenpasswd: unknown password hash algorithm")
+
+// Password hash prefixes. They are the same as in the htpasswd and crypt(3) formats.
+// Password without a known prefix is stored as is, so plain passwords can not start with "$".
+var (
+	bcryptPrefixes   = []string{"$2a$", "$2b$", "$2y$"}
+	argon2idPrefix   = "$argon2id$"
+	shaCryptPrefixes = []string{"$5$", "$6$"}
+)
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checkHashFormat reports whether the password hash has a known format.
+func checkHashFormat(hash string) bool {
+	if strings.HasPrefix(hash, "$") == false {
+		return true
+	}
+
+	return hasAnyPrefix(hash, bcryptPrefixes) || strings.HasPrefix(hash, argon2idPrefix) || hasAnyPrefix(hash, shaCryptPrefixes)
+}
+
+// checkPassword compares the password with the hash from the file.
+// All comparisons are done in constant time.
+func checkPassword(password string, hash string) bool {
+	switch {
+	case hasAnyPrefix(hash, bcryptPrefixes):
+		return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+
+	case strings.HasPrefix(hash, argon2idPrefix):
+		ok, err := storage.CheckPassword(password, hash)
+		return err == nil && ok
+
+	case hasAnyPrefix(hash, shaCryptPrefixes):
+		otherHash, err := shaCrypt(password, hash)
+		if err != nil {
+			return false
+		}
+
+		return subtle.ConstantTimeCompare([]byte(hash), []byte(otherHash)) == 1
+
+	case strings.HasPrefix(hash, "$"):
+		return false
+	}
+
+	// Plain password
+	return subtle.ConstantTimeCompare([]byte(hash), []byte(password)) == 1
+}
+
+// HashPassword returns the password hash that can be written to the file.
+func HashPassword(password string, algorithm string) (string, error) {
+	switch algorithm {
+	case AlgBcrypt:
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+		if err != nil {
+			return "", errors.New("lenpasswd: " + err.Error())
+		}
+
+		return string(hash), nil
+
+	case AlgArgon2id:
+		return storage.HashPassword(password)
+
+	case AlgSHA256, AlgSHA512:
+		salt, err := genSalt(shaCryptSaltMaxLen)
+		if err != nil {
+			return "", err
+		}
+
+		prefix := "$5$"
+		if algorithm == AlgSHA512 {
+			prefix = "$6$"
+		}
+
+		return shaCrypt(password, prefix+salt)
+	}
+
+	return "", ErrUnknownAlgorithm
+}
+
+func genSalt(n int) (string, error) {
+	salt := make([]byte, n)
+	max := big.NewInt(int64(len(cryptAlphabet)))
+
+	for i := range salt {
+		v, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+
+		salt[i] = cryptAlphabet[v.Int64()]
+	}
+
+	return string(salt), nil
+}
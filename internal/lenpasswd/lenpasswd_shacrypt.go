@@ -0,0 +1,230 @@
+// Copyright (C) 2021-2023 Leonid Maslakov.
+
+// This file is part of Lenpaste.
+
+// Lenpaste is free software: you can redistribute it
+// and/or modify it under the terms of the
+// GNU Affero Public License as published by the
+// Free Software Foundation, either version 3 of the License,
+// or (at your option) any later version.
+
+// Lenpaste is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+// or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero Public License for more details.
+
+// You should have received a copy of the GNU Affero Public License along with Lenpaste.
+// If not, see <https://www.gnu.org/licenses/>.
+
+package lenpasswd
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"hash"
+	"strconv"
+	"strings"
+)
+
+// SHA-crypt ("$5$" and "$6$" hashes of the crypt(3) function).
+// See: https://www.akkadia.org/drepper/SHA-crypt.txt
+const (
+	shaCryptRoundsDefault = 5000
+	shaCryptRoundsMin     = 1000
+	shaCryptRoundsMax     = 999999999
+	shaCryptSaltMaxLen    = 16
+)
+
+const cryptAlphabet = "./0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+var errBadShaCrypt = errors.New("lenpasswd: invalid SHA-crypt hash")
+
+// Order of the digest bytes in the encoded SHA-crypt hash.
+var (
+	shaCrypt256Order = [][3]int{
+		{0, 10, 20}, {21, 1, 11}, {12, 22, 2}, {3, 13, 23}, {24, 4, 14},
+		{15, 25, 5}, {6, 16, 26}, {27, 7, 17}, {18, 28, 8}, {9, 19, 29},
+	}
+	shaCrypt512Order = [][3]int{
+		{0, 21, 42}, {22, 43, 1}, {44, 2, 23}, {3, 24, 45}, {25, 46, 4},
+		{47, 5, 26}, {6, 27, 48}, {28, 49, 7}, {50, 8, 29}, {9, 30, 51},
+		{31, 52, 10}, {53, 11, 32}, {12, 33, 54}, {34, 55, 13}, {56, 14, 35},
+		{15, 36, 57}, {37, 58, 16}, {59, 17, 38}, {18, 39, 60}, {40, 61, 19},
+		{62, 20, 41},
+	}
+)
+
+// shaCrypt computes the SHA-crypt hash of the password.
+// setting is the hash prefix: "$5$" or "$6$", optional "rounds=N$" and the salt.
+// The rest of the setting (for example, the old hash) is ignored.
+func shaCrypt(password string, setting string) (string, error) {
+	var newHash func() hash.Hash
+	var order [][3]int
+	var prefix string
+
+	switch {
+	case strings.HasPrefix(setting, "$5$"):
+		newHash, order, prefix = sha256.New, shaCrypt256Order, "$5$"
+	case strings.HasPrefix(setting, "$6$"):
+		newHash, order, prefix = sha512.New, shaCrypt512Order, "$6$"
+	default:
+		return "", errBadShaCrypt
+	}
+
+	setting = setting[len(prefix):]
+
+	// Read rounds
+	rounds := shaCryptRoundsDefault
+	customRounds := false
+
+	if strings.HasPrefix(setting, "rounds=") {
+		end := strings.IndexByte(setting, '$')
+		if end == -1 {
+			return "", errBadShaCrypt
+		}
+
+		n, err := strconv.ParseUint(setting[len("rounds="):end], 10, 32)
+		if err != nil {
+			return "", errBadShaCrypt
+		}
+
+		rounds = int(n)
+		if rounds < shaCryptRoundsMin {
+			rounds = shaCryptRoundsMin
+		}
+		if rounds > shaCryptRoundsMax {
+			rounds = shaCryptRoundsMax
+		}
+
+		customRounds = true
+		setting = setting[end+1:]
+	}
+
+	// Read salt
+	salt := setting
+	if end := strings.IndexByte(salt, '$'); end != -1 {
+		salt = salt[:end]
+	}
+
+	if len(salt) > shaCryptSaltMaxLen {
+		salt = salt[:shaCryptSaltMaxLen]
+	}
+
+	pass := []byte(password)
+	saltB := []byte(salt)
+
+	// Digest B
+	h := newHash()
+	h.Write(pass)
+	h.Write(saltB)
+	h.Write(pass)
+	digestB := h.Sum(nil)
+
+	// Digest A
+	h = newHash()
+	h.Write(pass)
+	h.Write(saltB)
+
+	for n := len(pass); n > 0; n -= len(digestB) {
+		if n > len(digestB) {
+			h.Write(digestB)
+		} else {
+			h.Write(digestB[:n])
+		}
+	}
+
+	for n := len(pass); n > 0; n >>= 1 {
+		if n&1 != 0 {
+			h.Write(digestB)
+		} else {
+			h.Write(pass)
+		}
+	}
+
+	digestA := h.Sum(nil)
+
+	// P sequence
+	h = newHash()
+	for i := 0; i < len(pass); i++ {
+		h.Write(pass)
+	}
+	pSeq := repeatBytes(h.Sum(nil), len(pass))
+
+	// S sequence
+	h = newHash()
+	for i := 0; i < 16+int(digestA[0]); i++ {
+		h.Write(saltB)
+	}
+	sSeq := repeatBytes(h.Sum(nil), len(saltB))
+
+	// Rounds
+	digestC := digestA
+	for i := 0; i < rounds; i++ {
+		h = newHash()
+
+		if i&1 != 0 {
+			h.Write(pSeq)
+		} else {
+			h.Write(digestC)
+		}
+
+		if i%3 != 0 {
+			h.Write(sSeq)
+		}
+
+		if i%7 != 0 {
+			h.Write(pSeq)
+		}
+
+		if i&1 != 0 {
+			h.Write(digestC)
+		} else {
+			h.Write(pSeq)
+		}
+
+		digestC = h.Sum(nil)
+	}
+
+	// Encode result
+	var out strings.Builder
+	out.WriteString(prefix)
+	if customRounds {
+		out.WriteString("rounds=" + strconv.Itoa(rounds) + "$")
+	}
+	out.WriteString(salt)
+	out.WriteByte('$')
+
+	for _, o := range order {
+		encodeCrypt64(&out, uint(digestC[o[0]])<<16|uint(digestC[o[1]])<<8|uint(digestC[o[2]]), 4)
+	}
+
+	if len(digestC) == sha256.Size {
+		encodeCrypt64(&out, uint(digestC[31])<<8|uint(digestC[30]), 3)
+	} else {
+		encodeCrypt64(&out, uint(digestC[63]), 2)
+	}
+
+	return out.String(), nil
+}
+
+// repeatBytes repeats b to get n bytes.
+func repeatBytes(b []byte, n int) []byte {
+	out := make([]byte, 0, n)
+	for len(out) < n {
+		if n-len(out) > len(b) {
+			out = append(out, b...)
+		} else {
+			out = append(out, b[:n-len(out)]...)
+		}
+	}
+
+	return out
+}
+
+func encodeCrypt64(out *strings.Builder, v uint, n int) {
+	for i := 0; i < n; i++ {
+		out.WriteByte(cryptAlphabet[v&0x3f])
+		v >>= 6
+	}
+}
@@ -0,0 +1,207 @@
+// Copyright (C) 2021-2023 Leonid Maslakov.
+
+// This file is part of Lenpaste.
+
+// Lenpaste is free software: you can redistribute it
+// and/or modify it under the terms of the
+// GNU Affero Public License as published by the
+// Free Software Foundation, either version 3 of the License,
+// or (at your option) any later version.
+
+// Lenpaste is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+// or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero Public License for more details.
+
+// You should have received a copy of the GNU Affero Public License along with Lenpaste.
+// If not, see <https://www.gnu.org/licenses/>.
+
+package lenpasswd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestShaCrypt(t *testing.T) {
+	// Test vectors from the SHA-crypt specification
+	tests := []struct {
+		setting string
+		result  string
+	}{
+		{"$5$saltstring", "$5$saltstring$5B8vYYiY.CVt1RlTTf8KbXBH3hsxY/GNooZaBBGWEc5"},
+		{"$6$saltstring", "$6$saltstring$svn8UoSVapNtMuq1ukKS4tPQd8iKwSMHWjl/O817G3uBnIFNjnQJuesI68u4OTLiBFdcbYEdFCoEOfaS35inz1"},
+		{"$5$rounds=10000$saltstringsaltstring", "$5$rounds=10000$saltstringsaltst$3xv.VbSHBb41AL9AvLeujZkZRBAwqFMz2.opqey6IcA"},
+		{"$6$rounds=10000$saltstringsaltstring", "$6$rounds=10000$saltstringsaltst$OW1/O6BYHV6BcXZu8QVeXbDWra3Oeqh0sbHbbMCVNSnCM/UrjmM0Dp8vOuZeHBy/YTBmSK6H9qs/y3RnOaw5v."},
+	}
+
+	for _, test := range tests {
+		result, err := shaCrypt("Hello world!", test.setting)
+		if err != nil {
+			t.Fatal(test.setting, err)
+		}
+
+		if result != test.result {
+			t.Errorf("%s: expected %q but got %q", test.setting, test.result, result)
+		}
+	}
+}
+
+func TestCheckPassword(t *testing.T) {
+	for _, alg := range Algorithms {
+		hash, err := HashPassword("secret", alg)
+		if err != nil {
+			t.Fatal(alg, err)
+		}
+
+		if checkHashFormat(hash) == false {
+			t.Error(alg, "unknown hash format:", hash)
+		}
+
+		if checkPassword("secret", hash) == false {
+			t.Error(alg, "right password is rejected")
+		}
+
+		if checkPassword("wrong", hash) == true {
+			t.Error(alg, "wrong password is accepted")
+		}
+	}
+
+	_, err := HashPassword("secret", "md5")
+	if err != ErrUnknownAlgorithm {
+		t.Error("expected ErrUnknownAlgorithm but got", err)
+	}
+
+	// Plain password
+	if checkPassword("secret", "secret") == false || checkPassword("secre", "secret") == true {
+		t.Error("plain password check failed")
+	}
+
+	// Unknown hash must not be compared as a plain password
+	if checkHashFormat("$1$salt$hash") == true || checkPassword("$1$salt$hash", "$1$salt$hash") == true {
+		t.Error("unknown hash format is accepted")
+	}
+}
+
+func TestLoadCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lenpasswd")
+
+	err := ioutil.WriteFile(path, []byte("admin:first\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ok, err := LoadAndCheck(path, "admin", "first")
+	if err != nil || ok == false {
+		t.Fatal("expected successful check but got", ok, err)
+	}
+
+	// Same size and modification time, the cached file is used
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = ioutil.WriteFile(path, []byte("admin:other\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = os.Chtimes(path, info.ModTime(), info.ModTime())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ok, _ = LoadAndCheck(path, "admin", "first")
+	if ok == false {
+		t.Error("expected cached file")
+	}
+
+	// Reload
+	err = Reload(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ok, _ = LoadAndCheck(path, "admin", "other")
+	if ok == false {
+		t.Error("file is not reloaded")
+	}
+
+	// Modification time changes
+	modTime := info.ModTime().Add(time.Second)
+	err = ioutil.WriteFile(path, []byte("admin:third\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = os.Chtimes(path, modTime, modTime)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ok, _ = LoadAndCheck(path, "admin", "third")
+	if ok == false {
+		t.Error("file is not reloaded after modification")
+	}
+}
+
+func TestSetDelUser(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lenpasswd")
+
+	hash, err := HashPassword("secret", AlgSHA512)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = SetUser(path, "alice", hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = SetUser(path, "bob", "plain")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = SetUser(path, "bob", "changed")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = SetUser(path, "bad:name", "plain")
+	if err != ErrBadUserName {
+		t.Error("expected ErrBadUserName but got", err)
+	}
+
+	data, err := LoadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(data) != 2 || data.Check("alice", "secret") == false || data.Check("bob", "changed") == false {
+		t.Error("unexpected file content:", data)
+	}
+
+	err = DelUser(path, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = DelUser(path, "alice")
+	if err != ErrUserNotFound {
+		t.Error("expected ErrUserNotFound but got", err)
+	}
+
+	data, err = LoadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(data) != 1 || data.Check("alice", "secret") == true {
+		t.Error("unexpected file content:", data)
+	}
+}
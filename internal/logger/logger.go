@@ -21,6 +21,7 @@ package logger
 import (
 	"fmt"
 	"github.com/lcomrade/lenpaste/internal/netshare"
+	"net"
 	"net/http"
 	"os"
 	"runtime"
@@ -67,3 +68,11 @@ func (cfg Logger) HttpRequest(req *http.Request, code int) {
 func (cfg Logger) HttpError(req *http.Request, e error) {
 	fmt.Fprintln(os.Stderr, time.Now().Format(cfg.TimeFormat), "[ERROR]  ", netshare.GetClientAddr(req).String(), req.Method, 500, req.URL.Path, "(User-Agent: "+req.UserAgent()+")", "Error:", getTrace(), e.Error())
 }
+
+func (cfg Logger) TcpRequest(clientAddr net.IP, code int) {
+	fmt.Fprintln(os.Stdout, time.Now().Format(cfg.TimeFormat), "[REQUEST]", clientAddr.String(), "TCP", code)
+}
+
+func (cfg Logger) TcpError(clientAddr net.IP, e error) {
+	fmt.Fprintln(os.Stderr, time.Now().Format(cfg.TimeFormat), "[ERROR]  ", clientAddr.String(), "TCP", 500, "Error:", getTrace(), e.Error())
+}
@@ -24,11 +24,17 @@ import (
 
 const (
 	MaxLengthAuthorAll = 100 // Max length or paste author name, email and URL.
+	MaxLengthPassword  = 256 // Max length of paste password.
+	MaxLengthFileName  = 128 // Max length of file name in the multi-file paste.
+	MaxFiles           = 20  // Max number of files in the multi-file paste.
 )
 
 var (
 	ErrBadRequest       = errors.New("Bad Request")        // 400
 	ErrUnauthorized     = errors.New("Unauthorized")       // 401
+	ErrPasswordRequired = errors.New("Password Required")  // 401
+	ErrForbidden        = errors.New("Forbidden")          // 403
+	ErrWrongPassword    = errors.New("Wrong Password")     // 403
 	ErrNotFound         = errors.New("Not Found")          // 404
 	ErrMethodNotAllowed = errors.New("Method Not Allowed") // 405
 	ErrPayloadTooLarge  = errors.New("Payload Too Large")  // 413
@@ -51,3 +57,23 @@ func ErrTooManyRequestsNew(retryAfter int64) *ErrTooManyRequests {
 		RetryAfter: retryAfter,
 	}
 }
+
+// ErrBanned is returned if the client address is banned by the administrator.
+// It is the 403 error, the reason and the expiration time are shown to the client.
+type ErrBanned struct {
+	s          string
+	Reason     string
+	ExpireTime int64 // 0 = never expires
+}
+
+func (e *ErrBanned) Error() string {
+	return e.s
+}
+
+func ErrBannedNew(reason string, expireTime int64) *ErrBanned {
+	return &ErrBanned{
+		s:          "Banned",
+		Reason:     reason,
+		ExpireTime: expireTime,
+	}
+}
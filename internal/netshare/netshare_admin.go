@@ -0,0 +1,176 @@
+// Copyright (C) 2021-2023 Leonid Maslakov.
+
+// This file is part of Lenpaste.
+
+// Lenpaste is free software: you can redistribute it
+// and/or modify it under the terms of the
+// GNU Affero Public License as published by the
+// Free Software Foundation, either version 3 of the License,
+// or (at your option) any later version.
+
+// Lenpaste is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+// or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero Public License for more details.
+
+// You should have received a copy of the GNU Affero Public License along with Lenpaste.
+// If not, see <https://www.gnu.org/licenses/>.
+
+package netshare
+
+import (
+	"github.com/lcomrade/lenpaste/internal/storage"
+	"net/http"
+)
+
+const AuditPerPage = 50
+
+// RequestAdmin returns the name of the administrator who sent the request.
+// It is the name of the logged in user or "token:" and the name of the API token with the "admin" scope.
+// ErrUnauthorized is returned if nobody is logged in and ErrForbidden if the user is not an administrator.
+func RequestAdmin(req *http.Request) (string, error) {
+	user, exist := RequestUser(req)
+	if exist {
+		if user.Admin == false {
+			return "", ErrForbidden
+		}
+
+		return user.Name, nil
+	}
+
+	token, exist := RequestToken(req)
+	if exist {
+		if token.HasScope(storage.ScopeAdmin) == false {
+			return "", ErrForbidden
+		}
+
+		return "token:" + token.Name, nil
+	}
+
+	return "", ErrUnauthorized
+}
+
+// audit writes the action of the administrator to the audit log.
+func audit(db storage.Store, admin string, action string, target string, details string) error {
+	return db.AuditAdd(storage.AuditEntry{
+		Admin:   admin,
+		Action:  action,
+		Target:  target,
+		Details: details,
+	})
+}
+
+// AdminPasteGet returns the paste with all its metadata.
+// Hidden pastes are also returned, one use pastes are not deleted when the administrator opens them.
+func AdminPasteGet(req *http.Request, db storage.Store, pasteID string) (storage.Paste, error) {
+	admin, err := RequestAdmin(req)
+	if err != nil {
+		return storage.Paste{}, err
+	}
+
+	paste, err := db.PasteGetAny(pasteID)
+	if err != nil {
+		return storage.Paste{}, err
+	}
+
+	err = audit(db, admin, storage.AuditPasteView, paste.ID, "")
+	if err != nil {
+		return storage.Paste{}, err
+	}
+
+	return paste, nil
+}
+
+// AdminPasteDelete deletes any paste.
+func AdminPasteDelete(req *http.Request, db storage.Store, pasteID string) error {
+	admin, err := RequestAdmin(req)
+	if err != nil {
+		return err
+	}
+
+	return adminPasteDelete(db, admin, pasteID)
+}
+
+func adminPasteDelete(db storage.Store, admin string, pasteID string) error {
+	paste, err := db.PasteGetAny(pasteID)
+	if err != nil {
+		return err
+	}
+
+	err = db.PasteDelete(paste.ID)
+	if err != nil {
+		return err
+	}
+
+	return audit(db, admin, storage.AuditPasteDelete, paste.ID, "title: "+paste.Title+", creator IP: "+paste.CreatorIP)
+}
+
+// AdminBanAdd bans the IP address or CIDR range, see BanAdd.
+func AdminBanAdd(req *http.Request, db storage.Store, ipRange string, reason string, lifeTime int64, pasteID string) (storage.Ban, error) {
+	admin, err := RequestAdmin(req)
+	if err != nil {
+		return storage.Ban{}, err
+	}
+
+	ban, err := BanAdd(db, admin, ipRange, reason, lifeTime, pasteID)
+	if err == storage.ErrBanExists {
+		return storage.Ban{}, ErrBadRequest
+	}
+
+	return ban, err
+}
+
+// AdminBanDelete removes the ban, see BanDelete.
+func AdminBanDelete(req *http.Request, db storage.Store, banID string) error {
+	admin, err := RequestAdmin(req)
+	if err != nil {
+		return err
+	}
+
+	return BanDelete(db, admin, banID)
+}
+
+// AdminBanList returns the active bans.
+func AdminBanList(req *http.Request, db storage.Store) ([]storage.Ban, error) {
+	_, err := RequestAdmin(req)
+	if err != nil {
+		return nil, err
+	}
+
+	return db.BanList()
+}
+
+// AuditPage is the page of the audit log.
+type AuditPage struct {
+	Page     int // Starts from 1
+	NextPage bool
+	Entries  []storage.AuditEntry
+}
+
+// AuditList reads the "page" parameter from the URL query and returns the page of the audit log.
+func AuditList(req *http.Request, db storage.Store) (AuditPage, error) {
+	_, err := RequestAdmin(req)
+	if err != nil {
+		return AuditPage{}, err
+	}
+
+	var result AuditPage
+
+	result.Page, err = readPage(req, AuditPerPage)
+	if err != nil {
+		return AuditPage{}, err
+	}
+
+	// Read one more entry to know if there is a next page
+	result.Entries, err = db.AuditList(AuditPerPage+1, (result.Page-1)*AuditPerPage)
+	if err != nil {
+		return AuditPage{}, err
+	}
+
+	if len(result.Entries) > AuditPerPage {
+		result.NextPage = true
+		result.Entries = result.Entries[:AuditPerPage]
+	}
+
+	return result, nil
+}
@@ -0,0 +1,103 @@
+// Copyright (C) 2021-2023 Leonid Maslakov.
+
+// This file is part of Lenpaste.
+
+// Lenpaste is free software: you can redistribute it
+// and/or modify it under the terms of the
+// GNU Affero Public License as published by the
+// Free Software Foundation, either version 3 of the License,
+// or (at your option) any later version.
+
+// Lenpaste is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+// or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero Public License for more details.
+
+// You should have received a copy of the GNU Affero Public License along with Lenpaste.
+// If not, see <https://www.gnu.org/licenses/>.
+
+package netshare
+
+import (
+	"github.com/lcomrade/lenpaste/internal/auth"
+	"github.com/lcomrade/lenpaste/internal/storage"
+	"net/http"
+)
+
+// Authorization methods reported by the server info API.
+// The methods of the Basic authentication providers are defined in the auth package.
+const (
+	AuthMethodNone = "none"
+	AuthMethodOIDC = "oidc"
+)
+
+// AuthMethods are all values that Auth.Method can return.
+var AuthMethods = []string{AuthMethodNone, auth.MethodLenPasswd, auth.MethodLDAP, AuthMethodOIDC}
+
+// Auth describes the authorization required to create pastes.
+type Auth struct {
+	Provider auth.Provider // Checks the name and password sent using HTTP Basic authentication, nil if not used.
+	OIDC     bool          // Users log in with OpenID Connect.
+}
+
+// Method returns the active authorization method.
+// OpenID Connect takes precedence, the Basic authentication can still be used by scripts.
+func (a Auth) Method() string {
+	if a.OIDC {
+		return AuthMethodOIDC
+	}
+
+	if a.Provider != nil {
+		return a.Provider.Method()
+	}
+
+	return AuthMethodNone
+}
+
+// Required reports whether authorization is required to create pastes.
+func (a Auth) Required() bool {
+	return a.Method() != AuthMethodNone
+}
+
+// CheckCreateAuth checks that the request is allowed to create pastes.
+// API token must have the "create" scope, logged in users are always allowed.
+// Otherwise, if the auth provider is set, the user name and password must be sent using HTTP Basic authentication.
+func CheckCreateAuth(req *http.Request, a Auth) error {
+	_, userExist := RequestUser(req)
+	if userExist {
+		return nil
+	}
+
+	token, exist := RequestToken(req)
+	if exist {
+		if token.HasScope(storage.ScopeCreate) == false {
+			return ErrForbidden
+		}
+
+		return nil
+	}
+
+	if a.Required() == false {
+		return nil
+	}
+
+	if a.Provider == nil {
+		return ErrUnauthorized
+	}
+
+	user, pass, authExist := req.BasicAuth()
+	if authExist == false {
+		return ErrUnauthorized
+	}
+
+	authOk, err := a.Provider.Check(user, pass)
+	if err != nil {
+		return err
+	}
+
+	if authOk == false {
+		return ErrUnauthorized
+	}
+
+	return nil
+}
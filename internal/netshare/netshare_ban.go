@@ -0,0 +1,255 @@
+// Copyright (C) 2021-2023 Leonid Maslakov.
+
+// This file is part of Lenpaste.
+
+// Lenpaste is free software: you can redistribute it
+// and/or modify it under the terms of the
+// GNU Affero Public License as published by the
+// Free Software Foundation, either version 3 of the License,
+// or (at your option) any later version.
+
+// Lenpaste is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+// or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero Public License for more details.
+
+// You should have received a copy of the GNU Affero Public License along with Lenpaste.
+// If not, see <https://www.gnu.org/licenses/>.
+
+package netshare
+
+import (
+	"github.com/lcomrade/lenpaste/internal/storage"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const MaxLengthBanReason = 500 // Max length of the ban reason.
+
+// BanCacheTTL is how long the parsed ban list is kept in memory.
+// Bans changed by another process (for example by the "lenpaste ban" command) are applied after this time.
+const BanCacheTTL = 10 * time.Second
+
+type bannedRange struct {
+	storage.Ban
+	ipNet *net.IPNet
+}
+
+// banCache keeps the active bans of the last used storage, so requests do not query it every time.
+type banCache struct {
+	sync.RWMutex
+
+	db         storage.Store
+	ranges     []bannedRange
+	updateTime time.Time
+}
+
+var bans banCache
+
+func (c *banCache) fresh(db storage.Store) bool {
+	return c.db == db && time.Since(c.updateTime) < BanCacheTTL
+}
+
+// get returns the cached bans and reads them from the storage if the cache is outdated.
+func (c *banCache) get(db storage.Store) ([]bannedRange, error) {
+	c.RLock()
+	if c.fresh(db) {
+		ranges := c.ranges
+		c.RUnlock()
+		return ranges, nil
+	}
+	c.RUnlock()
+
+	c.Lock()
+	defer c.Unlock()
+
+	// Another request could read the bans while we were waiting
+	if c.fresh(db) {
+		return c.ranges, nil
+	}
+
+	list, err := db.BanList()
+	if err != nil {
+		return nil, err
+	}
+
+	ranges := make([]bannedRange, 0, len(list))
+	for _, ban := range list {
+		_, ipNet, err := net.ParseCIDR(ban.CIDR)
+		if err != nil {
+			continue
+		}
+
+		ranges = append(ranges, bannedRange{Ban: ban, ipNet: ipNet})
+	}
+
+	c.db = db
+	c.ranges = ranges
+	c.updateTime = time.Now()
+
+	return ranges, nil
+}
+
+// reset makes the next check read the bans from the storage.
+func (c *banCache) reset() {
+	c.Lock()
+	c.db = nil
+	c.ranges = nil
+	c.Unlock()
+}
+
+// ParseBanRange parses the IP address or CIDR range and returns it in CIDR notation.
+// Single address becomes /32 (IPv4) or /128 (IPv6) range.
+func ParseBanRange(s string) (string, error) {
+	s = strings.TrimSpace(s)
+
+	if strings.Contains(s, "/") {
+		_, ipNet, err := net.ParseCIDR(s)
+		if err != nil {
+			return "", ErrBadRequest
+		}
+
+		return ipNet.String(), nil
+	}
+
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return "", ErrBadRequest
+	}
+
+	if ip4 := ip.To4(); ip4 != nil {
+		return (&net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)}).String(), nil
+	}
+
+	return (&net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}).String(), nil
+}
+
+// BanAdd bans the IP address or CIDR range on behalf of the administrator and writes it to the audit log.
+// Lifetime is in seconds, 0 means that the ban never expires.
+// If the range is banned because of the paste, its ID is written to the audit log.
+func BanAdd(db storage.Store, admin string, ipRange string, reason string, lifeTime int64, pasteID string) (storage.Ban, error) {
+	var err error
+
+	if lifeTime < 0 {
+		return storage.Ban{}, ErrBadRequest
+	}
+
+	ban := storage.Ban{
+		Reason: strings.TrimSpace(reason),
+	}
+
+	if len([]rune(ban.Reason)) > MaxLengthBanReason {
+		return storage.Ban{}, ErrBadRequest
+	}
+
+	ban.CIDR, err = ParseBanRange(ipRange)
+	if err != nil {
+		return storage.Ban{}, err
+	}
+
+	if lifeTime != 0 {
+		ban.ExpireTime = time.Now().Unix() + lifeTime
+	}
+
+	ban.ID, err = db.BanAdd(ban)
+	if err != nil {
+		return storage.Ban{}, err
+	}
+
+	bans.reset()
+
+	// Audit log
+	var details []string
+	if pasteID != "" {
+		details = append(details, "paste: "+pasteID)
+	}
+
+	if ban.Reason != "" {
+		details = append(details, "reason: "+ban.Reason)
+	}
+
+	if ban.ExpireTime != 0 {
+		details = append(details, "expires: "+time.Unix(ban.ExpireTime, 0).UTC().Format(time.RFC3339))
+	}
+
+	err = audit(db, admin, storage.AuditBanAdd, ban.CIDR, strings.Join(details, ", "))
+	if err != nil {
+		return storage.Ban{}, err
+	}
+
+	return ban, nil
+}
+
+// BanDelete removes the active ban on behalf of the administrator and writes it to the audit log.
+func BanDelete(db storage.Store, admin string, banID string) error {
+	list, err := db.BanList()
+	if err != nil {
+		return err
+	}
+
+	for _, ban := range list {
+		if ban.ID == banID {
+			err = db.BanDelete(ban.ID)
+			if err != nil {
+				return err
+			}
+
+			bans.reset()
+
+			return audit(db, admin, storage.AuditBanDelete, ban.CIDR, "")
+		}
+	}
+
+	return ErrNotFound
+}
+
+// CheckBan returns ErrBanned if the client address is in one of the active banned ranges.
+// The ban list is read from the storage at most once per BanCacheTTL.
+// Unknown address is never banned.
+func CheckBan(db storage.Store, clientAddr net.IP) error {
+	if clientAddr == nil {
+		return nil
+	}
+
+	ranges, err := bans.get(db)
+	if err != nil {
+		return err
+	}
+
+	timeNow := time.Now().Unix()
+
+	for _, ban := range ranges {
+		if ban.Expired(timeNow) {
+			continue
+		}
+
+		if ban.ipNet.Contains(clientAddr) {
+			return ErrBannedNew(ban.Reason, ban.ExpireTime)
+		}
+	}
+
+	return nil
+}
+
+// CheckBanRequest returns ErrBanned if the client that sent the request is banned.
+func CheckBanRequest(req *http.Request, db storage.Store) error {
+	return CheckBan(db, GetClientAddr(req))
+}
+
+// BanMessage returns the plain text 403 error for the banned client.
+func BanMessage(e *ErrBanned) string {
+	msg := "403 Forbidden: your IP address is banned"
+
+	if e.Reason != "" {
+		msg += "\nReason: " + e.Reason
+	}
+
+	if e.ExpireTime != 0 {
+		msg += "\nExpires: " + time.Unix(e.ExpireTime, 0).UTC().Format(time.RFC3339)
+	}
+
+	return msg
+}
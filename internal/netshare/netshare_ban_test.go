@@ -0,0 +1,83 @@
+// Copyright (C) 2021-2023 Leonid Maslakov.
+
+// This file is part of Lenpaste.
+
+// Lenpaste is free software: you can redistribute it
+// and/or modify it under the terms of the
+// GNU Affero Public License as published by the
+// Free Software Foundation, either version 3 of the License,
+// or (at your option) any later version.
+
+// Lenpaste is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+// or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero Public License for more details.
+
+// You should have received a copy of the GNU Affero Public License along with Lenpaste.
+// If not, see <https://www.gnu.org/licenses/>.
+
+package netshare
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/lcomrade/lenpaste/internal/storage"
+)
+
+// countingStore counts the ban list queries.
+type countingStore struct {
+	storage.Store
+	banListCalls int
+}
+
+func (s *countingStore) BanList() ([]storage.Ban, error) {
+	s.banListCalls++
+	return s.Store.BanList()
+}
+
+func TestCheckBanCache(t *testing.T) {
+	db := &countingStore{Store: storage.NewMemory()}
+	clientAddr := net.ParseIP("192.0.2.1")
+
+	checkBanned := func(expect bool) {
+		t.Helper()
+
+		var banned *ErrBanned
+		err := CheckBan(db, clientAddr)
+		if errors.As(err, &banned) != expect {
+			t.Fatal("expected banned", expect, "but got", err)
+		}
+	}
+
+	// Storage is queried only once
+	for i := 0; i < 3; i++ {
+		checkBanned(false)
+	}
+
+	if db.banListCalls != 1 {
+		t.Error("expected 1 ban list query but got", db.banListCalls)
+	}
+
+	// New ban is applied at once
+	ban, err := BanAdd(db, "test", "192.0.2.0/24", "spam", 0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkBanned(true)
+	checkBanned(true)
+
+	// Removed ban is applied at once
+	err = BanDelete(db, "test", ban.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkBanned(false)
+
+	if db.banListCalls != 4 {
+		t.Error("expected 4 ban list queries but got", db.banListCalls)
+	}
+}
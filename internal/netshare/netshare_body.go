@@ -0,0 +1,130 @@
+// Copyright (C) 2021-2023 Leonid Maslakov.
+
+// This file is part of Lenpaste.
+
+// Lenpaste is free software: you can redistribute it
+// and/or modify it under the terms of the
+// GNU Affero Public License as published by the
+// Free Software Foundation, either version 3 of the License,
+// or (at your option) any later version.
+
+// Lenpaste is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+// or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero Public License for more details.
+
+// You should have received a copy of the GNU Affero Public License along with Lenpaste.
+// If not, see <https://www.gnu.org/licenses/>.
+
+package netshare
+
+import (
+	"bufio"
+	"github.com/lcomrade/lenpaste/internal/storage"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+)
+
+// bodyParams are the paste parameters that can be set when the paste body is sent as is.
+// The value is read from the query parameter or, if it is empty, from the X-Paste-* header.
+var bodyParams = map[string]string{
+	"title":      "X-Paste-Title",
+	"syntax":     "X-Paste-Syntax",
+	"lineEnd":    "X-Paste-Line-End",
+	"expiration": "X-Paste-Expiration",
+	"oneUse":     "X-Paste-One-Use",
+	"visibility": "X-Paste-Visibility",
+	"password":   "X-Paste-Password",
+}
+
+// ReadBody reads the text from r until EOF.
+// The text is read rune by rune, so no more than bodyMaxLen runes are kept in memory.
+// If bodyMaxLen is not positive the length is not limited.
+func ReadBody(r io.Reader, bodyMaxLen int) (string, error) {
+	reader := bufio.NewReader(r)
+
+	var body strings.Builder
+	var length int
+
+	for {
+		char, size, err := reader.ReadRune()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return "", err
+		}
+
+		// Paste is a text
+		if char == utf8.RuneError && size == 1 {
+			return "", ErrBadRequest
+		}
+
+		length = length + 1
+		if length > bodyMaxLen && bodyMaxLen > 0 {
+			return "", ErrPayloadTooLarge
+		}
+
+		body.WriteRune(char)
+	}
+
+	return body.String(), nil
+}
+
+// PasteAddFromBody creates the paste from the request body sent as is (for example by "curl -T").
+// Other paste parameters are read from the query or headers, see bodyParams.
+func PasteAddFromBody(req *http.Request, db storage.Store, rateSys *RateLimitSystem, titleMaxLen int, bodyMaxLen int, maxLifeTime int64, lexerNames []string) (CreatedPaste, error) {
+	// Check HTTP method
+	if req.Method != "PUT" && req.Method != "POST" {
+		return CreatedPaste{}, ErrMethodNotAllowed
+	}
+
+	// Check rate limit
+	err := rateSys.CheckAndUse(GetClientAddr(req))
+	if err != nil {
+		return CreatedPaste{}, err
+	}
+
+	// UTF-8 rune is never longer than 4 bytes
+	if bodyMaxLen > 0 && req.ContentLength > int64(bodyMaxLen)*utf8.UTFMax {
+		return CreatedPaste{}, ErrPayloadTooLarge
+	}
+
+	// Read body
+	body, err := ReadBody(req.Body, bodyMaxLen)
+	if err != nil {
+		return CreatedPaste{}, err
+	}
+
+	// Prepare form
+	query := req.URL.Query()
+
+	form := url.Values{}
+	form.Set("body", body)
+
+	for name, header := range bodyParams {
+		value := query.Get(name)
+		if value == "" {
+			value = req.Header.Get(header)
+		}
+
+		form.Set(name, value)
+	}
+
+	return pasteAdd(form, requestOwner(req), db, titleMaxLen, bodyMaxLen, maxLifeTime, lexerNames)
+}
+
+// PasteAddFromText creates the paste from the text received outside of HTTP (for example by the TCP listener).
+// All paste parameters have default values.
+// The rate limit must be checked by the caller before the text is read.
+func PasteAddFromText(text string, clientAddr net.IP, db storage.Store, titleMaxLen int, bodyMaxLen int, maxLifeTime int64, lexerNames []string) (CreatedPaste, error) {
+	form := url.Values{}
+	form.Set("body", text)
+
+	return pasteAdd(form, pasteOwner{Addr: clientAddr}, db, titleMaxLen, bodyMaxLen, maxLifeTime, lexerNames)
+}
@@ -0,0 +1,64 @@
+// Copyright (C) 2021-2023 Leonid Maslakov.
+
+// This file is part of Lenpaste.
+
+// Lenpaste is free software: you can redistribute it
+// and/or modify it under the terms of the
+// GNU Affero Public License as published by the
+// Free Software Foundation, either version 3 of the License,
+// or (at your option) any later version.
+
+// Lenpaste is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+// or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero Public License for more details.
+
+// You should have received a copy of the GNU Affero Public License along with Lenpaste.
+// If not, see <https://www.gnu.org/licenses/>.
+
+package netshare
+
+import (
+	"encoding/base64"
+	"unicode/utf8"
+)
+
+// Encrypted paste body is the base64 (standard encoding with padding) of the AES-GCM nonce
+// followed by the ciphertext and the authentication tag. The key is never sent to the server,
+// it is kept in the URL fragment.
+const (
+	EncryptedNonceSize = 12 // AES-GCM nonce size in bytes.
+	EncryptedTagSize   = 16 // AES-GCM authentication tag size in bytes.
+)
+
+// EncryptedBodyMaxLen returns the max length of the encrypted paste body
+// if the plain text is limited to bodyMaxLen characters.
+// Returns bodyMaxLen as is if the length is not limited.
+func EncryptedBodyMaxLen(bodyMaxLen int) int {
+	if bodyMaxLen <= 0 {
+		return bodyMaxLen
+	}
+
+	// Every character can take up to 4 bytes in UTF-8
+	return base64.StdEncoding.EncodedLen(bodyMaxLen*utf8.UTFMax + EncryptedNonceSize + EncryptedTagSize)
+}
+
+// checkEncryptedBody checks that the encrypted paste body looks like the AES-GCM ciphertext.
+// The server can not check more than that.
+func checkEncryptedBody(body string, bodyMaxLen int) error {
+	maxLen := EncryptedBodyMaxLen(bodyMaxLen)
+	if len(body) > maxLen && maxLen > 0 {
+		return ErrPayloadTooLarge
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(body)
+	if err != nil {
+		return ErrBadRequest
+	}
+
+	if len(raw) <= EncryptedNonceSize+EncryptedTagSize {
+		return ErrBadRequest
+	}
+
+	return nil
+}
@@ -19,55 +19,291 @@
 package netshare
 
 import (
+	"errors"
 	"net"
 	"net/http"
 	"strings"
 )
 
+// DefaultTrustedProxies is the loopback, so the reverse proxy on the same host works without configuration.
+const DefaultTrustedProxies = "127.0.0.0/8,::1/128"
+
+// trustedProxies is the list of reverse proxies whose forwarded headers are honored.
+// It is set once on startup, see SetTrustedProxies.
+var trustedProxies []*net.IPNet
+
+// ParseTrustedProxies parses the comma separated list of IP addresses and CIDR ranges.
+// Empty list trusts nobody.
+func ParseTrustedProxies(s string) ([]*net.IPNet, error) {
+	var result []*net.IPNet
+
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		cidr, err := ParseBanRange(part)
+		if err != nil {
+			return nil, errors.New("invalid trusted proxy \"" + part + "\"")
+		}
+
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, ipNet)
+	}
+
+	return result, nil
+}
+
+// SetTrustedProxies sets the reverse proxies whose X-Real-IP, X-Forwarded-* and Forwarded headers are honored.
+// Headers sent by other clients are ignored.
+func SetTrustedProxies(proxies []*net.IPNet) {
+	trustedProxies = proxies
+}
+
+func isTrustedProxy(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+
+	for _, ipNet := range trustedProxies {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// peerAddr returns the address of the direct peer of the connection.
+func peerAddr(req *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return net.ParseIP(req.RemoteAddr)
+	}
+
+	return net.ParseIP(host)
+}
+
+// fromTrustedProxy reports whether the forwarded headers of the request can be used.
+func fromTrustedProxy(req *http.Request) bool {
+	return isTrustedProxy(peerAddr(req))
+}
+
+// forwardedElement is one element of the RFC 7239 Forwarded header, added by one proxy.
+type forwardedElement struct {
+	For   net.IP // Nil if the node is "unknown", obfuscated or invalid
+	Host  string
+	Proto string
+}
+
+// parseForwardedNode parses the "for" parameter of the Forwarded header.
+// It can be an IPv4 address or an IPv6 address in brackets, both with an optional port.
+func parseForwardedNode(node string) net.IP {
+	if strings.HasPrefix(node, "[") {
+		end := strings.Index(node, "]")
+		if end < 0 {
+			return nil
+		}
+
+		return net.ParseIP(node[1:end])
+	}
+
+	host, _, err := net.SplitHostPort(node)
+	if err == nil {
+		node = host
+	}
+
+	return net.ParseIP(node)
+}
+
+// parseForwarded returns the elements of all Forwarded headers of the request from left to right.
+// Unknown parameters and pairs without a value are skipped.
+func parseForwarded(req *http.Request) []forwardedElement {
+	var result []forwardedElement
+
+	for _, header := range req.Header.Values("Forwarded") {
+		for _, element := range strings.Split(header, ",") {
+			var fwd forwardedElement
+
+			for _, pair := range strings.Split(element, ";") {
+				kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+				if len(kv) != 2 {
+					continue
+				}
+
+				value := strings.Trim(kv[1], "\"")
+
+				switch strings.ToLower(kv[0]) {
+				case "for":
+					fwd.For = parseForwardedNode(value)
+				case "host":
+					fwd.Host = value
+				case "proto":
+					fwd.Proto = strings.ToLower(value)
+				}
+			}
+
+			result = append(result, fwd)
+		}
+	}
+
+	return result
+}
+
+// splitHeaderList returns the items of the comma separated header from all its lines.
+func splitHeaderList(req *http.Request, name string) []string {
+	var result []string
+
+	for _, header := range req.Header.Values(name) {
+		for _, item := range strings.Split(header, ",") {
+			result = append(result, strings.TrimSpace(item))
+		}
+	}
+
+	return result
+}
+
+// clientHop walks the forwarded addresses from right to left skipping the trusted proxies
+// and returns the index of the client address. Only the nearest proxy is known to be trusted,
+// so the addresses to the left of the first invalid or untrusted one can be spoofed.
+// If all addresses are trusted, the leftmost one is the client.
+// len(addrs) is returned if the address added by the direct peer is invalid.
+func clientHop(addrs []net.IP) int {
+	for i := len(addrs) - 1; i >= 0; i-- {
+		if addrs[i] == nil {
+			return i + 1
+		}
+
+		if isTrustedProxy(addrs[i]) == false {
+			return i
+		}
+	}
+
+	return 0
+}
+
+// lastHeaderItem returns the rightmost item of the comma separated header, it is added by the nearest proxy.
+func lastHeaderItem(req *http.Request, name string) string {
+	items := splitHeaderList(req, name)
+	if len(items) == 0 {
+		return ""
+	}
+
+	return items[len(items)-1]
+}
+
+// clientForwarded returns the Forwarded element added by the proxy the client connected to.
+func clientForwarded(req *http.Request) (forwardedElement, bool) {
+	elements := parseForwarded(req)
+	if len(elements) == 0 {
+		return forwardedElement{}, false
+	}
+
+	addrs := make([]net.IP, len(elements))
+	for i, fwd := range elements {
+		addrs[i] = fwd.For
+	}
+
+	i := clientHop(addrs)
+	if i == len(elements) {
+		return forwardedElement{}, false
+	}
+
+	return elements[i], true
+}
+
+// GetHost returns the host requested by the client.
+// Forwarded and X-Forwarded-Host headers are used only if the request came from a trusted proxy.
 func GetHost(req *http.Request) string {
-	// Read header
-	xHost := req.Header.Get("X-Forwarded-Host")
+	if fromTrustedProxy(req) {
+		fwd, ok := clientForwarded(req)
+		if ok && fwd.Host != "" {
+			return fwd.Host
+		}
 
-	// Check
-	if xHost != "" {
-		return xHost
+		xHost := lastHeaderItem(req, "X-Forwarded-Host")
+		if xHost != "" {
+			return xHost
+		}
 	}
 
 	return req.Host
 }
 
+// GetProtocol returns the protocol used by the client: "http" or "https".
+// Forwarded and X-Forwarded-Proto headers are used only if the request came from a trusted proxy.
 func GetProtocol(req *http.Request) string {
-	// X-Forwarded-Proto
-	xProto := req.Header.Get("X-Forwarded-Proto")
+	if fromTrustedProxy(req) {
+		fwd, ok := clientForwarded(req)
+		if ok && fwd.Proto != "" {
+			return fwd.Proto
+		}
 
-	if xProto != "" {
-		return xProto
+		xProto := strings.ToLower(lastHeaderItem(req, "X-Forwarded-Proto"))
+		if xProto != "" {
+			return xProto
+		}
 	}
 
-	// Else real protocol
-	return req.URL.Scheme
+	// Else real protocol, URL of the server request usually does not contain it
+	if req.URL.Scheme != "" {
+		return req.URL.Scheme
+	}
+
+	if req.TLS != nil {
+		return "https"
+	}
+
+	return "http"
 }
 
+// GetClientAddr returns the IP address of the client.
+// If the request came from a trusted proxy, the address is read from the Forwarded,
+// X-Forwarded-For or X-Real-IP header. Forwarded chains are walked from right to left
+// and the first address that is not a trusted proxy is the client.
 func GetClientAddr(req *http.Request) net.IP {
-	// X-Real-IP
-	xReal := req.Header.Get("X-Real-IP")
-	if xReal != "" {
-		return net.ParseIP(xReal)
+	peer := peerAddr(req)
+	if isTrustedProxy(peer) == false {
+		return peer
+	}
+
+	// Forwarded
+	if len(req.Header.Values("Forwarded")) != 0 {
+		fwd, ok := clientForwarded(req)
+		if ok {
+			return fwd.For
+		}
+
+		return peer
 	}
 
 	// X-Forwarded-For
-	xFor := req.Header.Get("X-Forwarded-For")
-	xFor = strings.Split(xFor, ",")[0]
+	items := splitHeaderList(req, "X-Forwarded-For")
+	if len(items) != 0 {
+		addrs := make([]net.IP, len(items))
+		for i, item := range items {
+			addrs[i] = net.ParseIP(item)
+		}
 
-	if xFor != "" {
-		return net.ParseIP(xFor)
+		i := clientHop(addrs)
+		if i < len(addrs) {
+			return addrs[i]
+		}
+
+		return peer
 	}
 
-	// Else use real client address
-	host, _, err := net.SplitHostPort(req.RemoteAddr)
-	if err != nil {
-		return nil
+	// X-Real-IP
+	xReal := net.ParseIP(strings.TrimSpace(req.Header.Get("X-Real-IP")))
+	if xReal != nil {
+		return xReal
 	}
 
-	return net.ParseIP(host)
+	// Else use real client address
+	return peer
 }
@@ -0,0 +1,142 @@
+// Copyright (C) 2021-2023 Leonid Maslakov.
+
+// This file is part of Lenpaste.
+
+// Lenpaste is free software: you can redistribute it
+// and/or modify it under the terms of the
+// GNU Affero Public License as published by the
+// Free Software Foundation, either version 3 of the License,
+// or (at your option) any later version.
+
+// Lenpaste is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+// or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero Public License for more details.
+
+// You should have received a copy of the GNU Affero Public License along with Lenpaste.
+// If not, see <https://www.gnu.org/licenses/>.
+
+package netshare
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func setTestProxies(t *testing.T, s string) {
+	proxies, err := ParseTrustedProxies(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	SetTrustedProxies(proxies)
+	t.Cleanup(func() {
+		SetTrustedProxies(nil)
+	})
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	proxies, err := ParseTrustedProxies(" 10.0.0.1, 172.16.0.0/12,,::1 ")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(proxies) != 3 || proxies[0].String() != "10.0.0.1/32" || proxies[1].String() != "172.16.0.0/12" || proxies[2].String() != "::1/128" {
+		t.Error("unexpected proxies:", proxies)
+	}
+
+	proxies, err = ParseTrustedProxies("")
+	if err != nil || len(proxies) != 0 {
+		t.Error("unexpected result of empty list:", proxies, err)
+	}
+
+	_, err = ParseTrustedProxies("10.0.0.1,localhost")
+	if err == nil {
+		t.Error("expected error")
+	}
+}
+
+func TestGetClientAddr(t *testing.T) {
+	setTestProxies(t, "10.0.0.0/8,2001:db8::1")
+
+	testData := []struct {
+		remoteAddr string
+		headers    map[string]string
+		expect     string
+	}{
+		// Untrusted peer can not spoof the address
+		{"192.0.2.1:1234", map[string]string{"X-Real-IP": "198.51.100.1"}, "192.0.2.1"},
+		{"192.0.2.1:1234", map[string]string{"X-Forwarded-For": "198.51.100.1"}, "192.0.2.1"},
+		{"192.0.2.1:1234", map[string]string{"Forwarded": "for=198.51.100.1"}, "192.0.2.1"},
+		// Trusted proxy
+		{"10.0.0.1:1234", nil, "10.0.0.1"},
+		{"10.0.0.1:1234", map[string]string{"X-Real-IP": "198.51.100.1"}, "198.51.100.1"},
+		{"10.0.0.1:1234", map[string]string{"X-Forwarded-For": "198.51.100.1"}, "198.51.100.1"},
+		{"[2001:db8::1]:1234", map[string]string{"X-Forwarded-For": "2001:db8::2"}, "2001:db8::2"},
+		// Client sends the fake address, the proxy appends the real one
+		{"10.0.0.1:1234", map[string]string{"X-Forwarded-For": "203.0.113.1, 198.51.100.1"}, "198.51.100.1"},
+		{"10.0.0.1:1234", map[string]string{"X-Forwarded-For": "203.0.113.1, 198.51.100.1, 10.0.0.2"}, "198.51.100.1"},
+		{"10.0.0.1:1234", map[string]string{"X-Forwarded-For": "10.0.0.3, 10.0.0.2"}, "10.0.0.3"},
+		{"10.0.0.1:1234", map[string]string{"X-Forwarded-For": "198.51.100.1, garbage"}, "10.0.0.1"},
+		{"10.0.0.1:1234", map[string]string{"X-Forwarded-For": "garbage, 198.51.100.1"}, "198.51.100.1"},
+		// X-Real-IP is used only without the forwarded chain
+		{"10.0.0.1:1234", map[string]string{"X-Forwarded-For": "198.51.100.1", "X-Real-IP": "203.0.113.1"}, "198.51.100.1"},
+		// RFC 7239
+		{"10.0.0.1:1234", map[string]string{"Forwarded": "for=198.51.100.1;proto=https"}, "198.51.100.1"},
+		{"10.0.0.1:1234", map[string]string{"Forwarded": `for=203.0.113.1, For="[2001:db8:cafe::17]:4711"`}, "2001:db8:cafe::17"},
+		{"10.0.0.1:1234", map[string]string{"Forwarded": `for=203.0.113.1, for="198.51.100.1:80", for=10.0.0.2`}, "198.51.100.1"},
+		{"10.0.0.1:1234", map[string]string{"Forwarded": "for=unknown"}, "10.0.0.1"},
+		{"10.0.0.1:1234", map[string]string{"Forwarded": "for=198.51.100.1", "X-Forwarded-For": "203.0.113.1"}, "198.51.100.1"},
+	}
+
+	for i, test := range testData {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = test.remoteAddr
+		for key, value := range test.headers {
+			req.Header.Set(key, value)
+		}
+
+		result := GetClientAddr(req)
+		if result == nil || result.String() != test.expect {
+			t.Errorf("%d: expected %s but got %s", i, test.expect, result)
+		}
+	}
+}
+
+func TestGetHostAndProtocol(t *testing.T) {
+	setTestProxies(t, "10.0.0.0/8")
+
+	testData := []struct {
+		remoteAddr  string
+		headers     map[string]string
+		expectHost  string
+		expectProto string
+	}{
+		{"192.0.2.1:1234", map[string]string{"X-Forwarded-Host": "evil.example", "X-Forwarded-Proto": "https"}, "example.org", "http"},
+		{"192.0.2.1:1234", map[string]string{"Forwarded": "host=evil.example;proto=https"}, "example.org", "http"},
+		{"10.0.0.1:1234", map[string]string{"X-Forwarded-Host": "paste.example", "X-Forwarded-Proto": "https"}, "paste.example", "https"},
+		{"10.0.0.1:1234", map[string]string{"X-Forwarded-Host": "evil.example, paste.example", "X-Forwarded-Proto": "HTTPS"}, "paste.example", "https"},
+		{"10.0.0.1:1234", map[string]string{"Forwarded": `for=198.51.100.1;host="paste.example";proto=https`}, "paste.example", "https"},
+		// Element added by the proxy the client connected to
+		{"10.0.0.1:1234", map[string]string{"Forwarded": "for=203.0.113.1;host=evil.example;proto=http, for=198.51.100.1;host=paste.example;proto=https, for=10.0.0.2;host=internal;proto=http"}, "paste.example", "https"},
+		{"10.0.0.1:1234", map[string]string{"Forwarded": "for=198.51.100.1", "X-Forwarded-Proto": "https"}, "example.org", "https"},
+	}
+
+	for i, test := range testData {
+		req := httptest.NewRequest("GET", "http://example.org/", nil)
+		req.RemoteAddr = test.remoteAddr
+		for key, value := range test.headers {
+			req.Header.Set(key, value)
+		}
+
+		host := GetHost(req)
+		if host != test.expectHost {
+			t.Errorf("%d: expected host %s but got %s", i, test.expectHost, host)
+		}
+
+		proto := GetProtocol(req)
+		if proto != test.expectProto {
+			t.Errorf("%d: expected protocol %s but got %s", i, test.expectProto, proto)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright (C) 2021-2023 Leonid Maslakov.
+
+// This file is part of Lenpaste.
+
+// Lenpaste is free software: you can redistribute it
+// and/or modify it under the terms of the
+// GNU Affero Public License as published by the
+// Free Software Foundation, either version 3 of the License,
+// or (at your option) any later version.
+
+// Lenpaste is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+// or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero Public License for more details.
+
+// You should have received a copy of the GNU Affero Public License along with Lenpaste.
+// If not, see <https://www.gnu.org/licenses/>.
+
+package netshare
+
+import (
+	"github.com/lcomrade/lenpaste/internal/storage"
+	"net/http"
+	"strconv"
+)
+
+const ListPerPage = 20
+
+const maxInt = int(^uint(0) >> 1)
+
+type ListPage struct {
+	Page     int // Starts from 1
+	NextPage bool
+	Pastes   []storage.Paste
+}
+
+// readPage reads the page number from the "page" parameter of the URL query.
+// Offset of the page with perPage items must fit into int.
+func readPage(req *http.Request, perPage int) (int, error) {
+	pageStr := req.URL.Query().Get("page")
+	if pageStr == "" {
+		return 1, nil
+	}
+
+	page, err := strconv.Atoi(pageStr)
+	if err != nil || page < 1 || page > maxInt/perPage {
+		return 0, ErrBadRequest
+	}
+
+	return page, nil
+}
+
+// PasteList reads the "page" parameter from the URL query and returns the page of the recent public pastes.
+func PasteList(req *http.Request, db storage.Store, rateSys *RateLimitSystem) (ListPage, error) {
+	// Check rate limit
+	err := rateSys.CheckAndUse(GetClientAddr(req))
+	if err != nil {
+		return ListPage{}, err
+	}
+
+	var result ListPage
+
+	result.Page, err = readPage(req, ListPerPage)
+	if err != nil {
+		return ListPage{}, err
+	}
+
+	// Read one more paste to know if there is a next page
+	result.Pastes, err = db.PasteList(ListPerPage+1, (result.Page-1)*ListPerPage)
+	if err != nil {
+		return ListPage{}, err
+	}
+
+	if len(result.Pastes) > ListPerPage {
+		result.NextPage = true
+		result.Pastes = result.Pastes[:ListPerPage]
+	}
+
+	return result, nil
+}
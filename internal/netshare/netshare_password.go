@@ -0,0 +1,133 @@
+// Copyright (C) 2021-2023 Leonid Maslakov.
+
+// This file is part of Lenpaste.
+
+// Lenpaste is free software: you can redistribute it
+// and/or modify it under the terms of the
+// GNU Affero Public License as published by the
+// Free Software Foundation, either version 3 of the License,
+// or (at your option) any later version.
+
+// Lenpaste is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+// or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero Public License for more details.
+
+// You should have received a copy of the GNU Affero Public License along with Lenpaste.
+// If not, see <https://www.gnu.org/licenses/>.
+
+package netshare
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/lcomrade/lenpaste/internal/storage"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PasteAccessLifetime is how long the access cookie keeps access to the password protected paste.
+const PasteAccessLifetime = 24 * time.Hour
+
+// pasteAccessKey signs the access cookies.
+// It is generated on start, so the restart of the server resets all access cookies.
+var pasteAccessKey = newPasteAccessKey()
+
+func newPasteAccessKey() []byte {
+	key := make([]byte, 32)
+
+	_, err := rand.Read(key)
+	if err != nil {
+		panic("netshare: failed to generate paste access key: " + err.Error())
+	}
+
+	return key
+}
+
+// PasteAccessCookie returns the name of the cookie that keeps access to the password protected paste.
+func PasteAccessCookie(pasteID string) string {
+	return "pasteAccess_" + pasteID
+}
+
+func pasteAccessMAC(paste storage.Paste, expireTime string) string {
+	mac := hmac.New(sha256.New, pasteAccessKey)
+	mac.Write([]byte(paste.ID + ":" + paste.PasswordHash + ":" + expireTime))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// PasteAccessToken returns the value of the access cookie that expires at expireTime (Unix time).
+// It is signed with the server key and becomes invalid if the paste password is changed.
+func PasteAccessToken(paste storage.Paste, expireTime int64) string {
+	expireStr := strconv.FormatInt(expireTime, 10)
+	return expireStr + "." + pasteAccessMAC(paste, expireStr)
+}
+
+// checkPasteAccessToken checks the signature and the expiration time of the access cookie value.
+func checkPasteAccessToken(paste storage.Paste, token string) bool {
+	parts := strings.SplitN(token, ".", 2)
+	if len(parts) != 2 {
+		return false
+	}
+
+	expireTime, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil || expireTime <= time.Now().Unix() {
+		return false
+	}
+
+	return hmac.Equal([]byte(parts[1]), []byte(pasteAccessMAC(paste, parts[0])))
+}
+
+// PastePassword reads the paste password from the "password" form field
+// or, if allowBasicAuth is true, from the HTTP Basic authentication (the user name is ignored).
+func PastePassword(req *http.Request, allowBasicAuth bool) string {
+	req.ParseForm()
+
+	password := req.Form.Get("password")
+	if password == "" && allowBasicAuth {
+		_, password, _ = req.BasicAuth()
+	}
+
+	return password
+}
+
+// PasteCheckPassword checks access to the password protected paste.
+// Access is granted by the right password or by the access cookie.
+// Every password attempt is counted against the rate limit system before the password is hashed.
+func PasteCheckPassword(req *http.Request, paste storage.Paste, rateSys *RateLimitSystem, password string) error {
+	// Paste is not protected
+	if paste.PasswordHash == "" {
+		return nil
+	}
+
+	// Check access cookie
+	cookie, err := req.Cookie(PasteAccessCookie(paste.ID))
+	if err == nil && checkPasteAccessToken(paste, cookie.Value) {
+		return nil
+	}
+
+	// Check password
+	if password == "" {
+		return ErrPasswordRequired
+	}
+
+	err = rateSys.CheckAndUse(GetClientAddr(req))
+	if err != nil {
+		return err
+	}
+
+	ok, err := storage.CheckPassword(password, paste.PasswordHash)
+	if err != nil {
+		return err
+	}
+
+	if ok == false {
+		return ErrWrongPassword
+	}
+
+	return nil
+}
@@ -0,0 +1,109 @@
+// Copyright (C) 2021-2023 Leonid Maslakov.
+
+// This file is part of Lenpaste.
+
+// Lenpaste is free software: you can redistribute it
+// and/or modify it under the terms of the
+// GNU Affero Public License as published by the
+// Free Software Foundation, either version 3 of the License,
+// or (at your option) any later version.
+
+// Lenpaste is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+// or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero Public License for more details.
+
+// You should have received a copy of the GNU Affero Public License along with Lenpaste.
+// If not, see <https://www.gnu.org/licenses/>.
+
+package netshare
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lcomrade/lenpaste/internal/storage"
+)
+
+func TestPasteCheckPasswordRateLimit(t *testing.T) {
+	hash, err := storage.HashPassword("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	paste := storage.Paste{ID: "paste", PasswordHash: hash}
+	rateSys := NewRateLimitSystem(1, 0, 0)
+	req := httptest.NewRequest("GET", "/paste", nil)
+
+	err = PasteCheckPassword(req, paste, rateSys, "")
+	if err != ErrPasswordRequired {
+		t.Fatal("expected ErrPasswordRequired but got", err)
+	}
+
+	err = PasteCheckPassword(req, paste, rateSys, "wrong")
+	if err != ErrWrongPassword {
+		t.Fatal("expected ErrWrongPassword but got", err)
+	}
+
+	// Right password does not help after the limit
+	var tooMany *ErrTooManyRequests
+	err = PasteCheckPassword(req, paste, rateSys, "secret")
+	if errors.As(err, &tooMany) == false {
+		t.Error("expected ErrTooManyRequests but got", err)
+	}
+
+	// Access cookie is not limited
+	req.AddCookie(&http.Cookie{Name: PasteAccessCookie(paste.ID), Value: PasteAccessToken(paste, time.Now().Add(time.Hour).Unix())})
+
+	err = PasteCheckPassword(req, paste, rateSys, "")
+	if err != nil {
+		t.Error("expected access with cookie but got", err)
+	}
+}
+
+func TestPasteAccessToken(t *testing.T) {
+	paste := storage.Paste{ID: "paste", PasswordHash: "hash"}
+	expireTime := time.Now().Add(time.Hour).Unix()
+
+	token := PasteAccessToken(paste, expireTime)
+	if checkPasteAccessToken(paste, token) == false {
+		t.Error("valid token is rejected:", token)
+	}
+
+	// Expired token
+	if checkPasteAccessToken(paste, PasteAccessToken(paste, time.Now().Unix()-1)) {
+		t.Error("expired token is accepted")
+	}
+
+	// Changed expiration time
+	forged := strconv.FormatInt(expireTime+3600, 10) + token[strings.Index(token, "."):]
+	if checkPasteAccessToken(paste, forged) {
+		t.Error("forged token is accepted:", forged)
+	}
+
+	// Changed password
+	if checkPasteAccessToken(storage.Paste{ID: "paste", PasswordHash: "new hash"}, token) {
+		t.Error("token is accepted after the password change")
+	}
+
+	// Token signed with another key, the same as after the restart
+	oldKey := pasteAccessKey
+	pasteAccessKey = newPasteAccessKey()
+	defer func() { pasteAccessKey = oldKey }()
+
+	if checkPasteAccessToken(paste, token) {
+		t.Error("token signed with another key is accepted")
+	}
+
+	// Malformed tokens
+	for _, token := range []string{"", ".", "abc", "abc." + token} {
+		if checkPasteAccessToken(paste, token) {
+			t.Error("malformed token is accepted:", token)
+		}
+	}
+}
@@ -22,36 +22,39 @@ import (
 	"github.com/lcomrade/lenpaste/internal/lineend"
 	"github.com/lcomrade/lenpaste/internal/storage"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 	"time"
 	"unicode/utf8"
 )
 
-func PasteAddFromForm(req *http.Request, db storage.DB, rateSys *RateLimitSystem, titleMaxLen int, bodyMaxLen int, maxLifeTime int64, lexerNames []string) (string, int64, int64, error) {
-	// Check HTTP method
-	if req.Method != "POST" {
-		return "", 0, 0, ErrMethodNotAllowed
-	}
-
-	// Check rate limit
-	err := rateSys.CheckAndUse(GetClientAddr(req))
-	if err != nil {
-		return "", 0, 0, err
-	}
+// CreatedPaste is returned after the paste is created.
+// DeleteToken and EditToken are known only at this moment, the DB stores only their hashes.
+type CreatedPaste struct {
+	ID          string
+	CreateTime  int64
+	DeleteTime  int64
+	Visibility  string
+	DeleteToken string
+	EditToken   string
+}
 
-	// Read form
-	req.ParseForm()
+// EditedPaste is returned after the new paste revision is created.
+type EditedPaste struct {
+	ID       string
+	Revision int
+	EditTime int64
+}
 
-	paste := storage.Paste{
-		Title:       req.PostForm.Get("title"),
-		Body:        req.PostForm.Get("body"),
-		Syntax:      req.PostForm.Get("syntax"),
-		DeleteTime:  0,
-		OneUse:      false,
-		Author:      req.PostForm.Get("author"),
-		AuthorEmail: req.PostForm.Get("authorEmail"),
-		AuthorURL:   req.PostForm.Get("authorURL"),
+// readPasteText reads and checks paste title, body and syntax from the form.
+// It is used both when creating and editing pastes.
+// If encrypted is true, the body must be encrypted on the client side, see checkEncryptedBody.
+func readPasteText(form url.Values, encrypted bool, titleMaxLen int, bodyMaxLen int, lexerNames []string) (storage.PasteRevision, error) {
+	paste := storage.PasteRevision{
+		Title:  form.Get("title"),
+		Body:   form.Get("body"),
+		Syntax: form.Get("syntax"),
 	}
 
 	// Remove new line from title
@@ -61,95 +64,489 @@ func PasteAddFromForm(req *http.Request, db storage.DB, rateSys *RateLimitSystem
 
 	// Check title
 	if utf8.RuneCountInString(paste.Title) > titleMaxLen && titleMaxLen >= 0 {
-		return "", 0, 0, ErrPayloadTooLarge
+		return paste, ErrPayloadTooLarge
 	}
 
 	// Check paste body
 	if paste.Body == "" {
-		return "", 0, 0, ErrBadRequest
+		return paste, ErrBadRequest
+	}
+
+	if encrypted {
+		// Line ends of the encrypted text are changed by the client
+		err := checkEncryptedBody(paste.Body, bodyMaxLen)
+		if err != nil {
+			return paste, err
+		}
+
+	} else {
+		if utf8.RuneCountInString(paste.Body) > bodyMaxLen && bodyMaxLen > 0 {
+			return paste, ErrPayloadTooLarge
+		}
+
+		// Change paste body lines end
+		var err error
+		paste.Body, err = changeLineEnd(paste.Body, form.Get("lineEnd"))
+		if err != nil {
+			return paste, err
+		}
+	}
+
+	// Check syntax
+	var err error
+	paste.Syntax, err = checkSyntax(paste.Syntax, lexerNames)
+	if err != nil {
+		return paste, err
 	}
 
-	if utf8.RuneCountInString(paste.Body) > bodyMaxLen && bodyMaxLen > 0 {
-		return "", 0, 0, ErrPayloadTooLarge
+	return paste, nil
+}
+
+// readPasteFiles reads additional files of the multi-file paste from the form.
+// The first file is the paste body itself. Returns nil if there are no additional files.
+// Total length of all files must not be more than bodyMaxLen.
+func readPasteFiles(form url.Values, text storage.PasteRevision, bodyMaxLen int, lexerNames []string) ([]storage.PasteFile, error) {
+	names := form["extraFileName"]
+	bodies := form["extraFileBody"]
+	syntaxes := form["extraFileSyntax"]
+
+	if len(bodies) == 0 {
+		return nil, nil
+	}
+
+	if len(names) != len(bodies) || len(syntaxes) != len(bodies) || len(bodies) >= MaxFiles {
+		return nil, ErrBadRequest
+	}
+
+	files := []storage.PasteFile{{
+		Name:   form.Get("fileName"),
+		Body:   text.Body,
+		Syntax: text.Syntax,
+	}}
+
+	totalLen := utf8.RuneCountInString(text.Body)
+
+	for i := range bodies {
+		file := storage.PasteFile{
+			Name:   names[i],
+			Body:   bodies[i],
+			Syntax: syntaxes[i],
+		}
+
+		if file.Body == "" {
+			return nil, ErrBadRequest
+		}
+
+		totalLen = totalLen + utf8.RuneCountInString(file.Body)
+		if totalLen > bodyMaxLen && bodyMaxLen > 0 {
+			return nil, ErrPayloadTooLarge
+		}
+
+		var err error
+		file.Body, err = changeLineEnd(file.Body, form.Get("lineEnd"))
+		if err != nil {
+			return nil, err
+		}
+
+		file.Syntax, err = checkSyntax(file.Syntax, lexerNames)
+		if err != nil {
+			return nil, err
+		}
+
+		files = append(files, file)
+	}
+
+	// Check file names
+	for i := range files {
+		if files[i].Name == "" {
+			files[i].Name = "file" + strconv.Itoa(i+1)
+		}
+
+		if utf8.RuneCountInString(files[i].Name) > MaxLengthFileName {
+			return nil, ErrPayloadTooLarge
+		}
+
+		if files[i].Name == "." || files[i].Name == ".." || strings.ContainsAny(files[i].Name, "/\\\r\n\t") {
+			return nil, ErrBadRequest
+		}
+
+		for j := 0; j < i; j++ {
+			if files[i].Name == files[j].Name {
+				return nil, ErrBadRequest
+			}
+		}
 	}
 
-	// Change paste body lines end
-	switch req.PostForm.Get("lineEnd") {
+	return files, nil
+}
+
+// LineEnds are the values of the "lineEnd" form field, they can also be in lower case.
+var LineEnds = []string{"LF", "CRLF", "CR"}
+
+func changeLineEnd(text string, lineEnd string) (string, error) {
+	switch lineEnd {
 	case "", "LF", "lf":
-		paste.Body = lineend.UnknownToUnix(paste.Body)
+		return lineend.UnknownToUnix(text), nil
 
 	case "CRLF", "crlf":
-		paste.Body = lineend.UnknownToDos(paste.Body)
+		return lineend.UnknownToDos(text), nil
 
 	case "CR", "cr":
-		paste.Body = lineend.UnknownToOldMac(paste.Body)
-
-	default:
-		return "", 0, 0, ErrBadRequest
+		return lineend.UnknownToOldMac(text), nil
 	}
 
-	// Check syntax
-	if paste.Syntax == "" {
-		paste.Syntax = "plaintext"
+	return text, ErrBadRequest
+}
+
+func checkSyntax(syntax string, lexerNames []string) (string, error) {
+	if syntax == "" {
+		return "plaintext", nil
 	}
 
-	syntaxOk := false
 	for _, name := range lexerNames {
-		if name == paste.Syntax {
-			syntaxOk = true
-			break
+		if name == syntax {
+			return syntax, nil
 		}
 	}
 
-	if syntaxOk == false {
-		return "", 0, 0, ErrBadRequest
+	return syntax, ErrBadRequest
+}
+
+func PasteAddFromForm(req *http.Request, db storage.Store, rateSys *RateLimitSystem, titleMaxLen int, bodyMaxLen int, maxLifeTime int64, lexerNames []string) (CreatedPaste, error) {
+	// Check HTTP method
+	if req.Method != "POST" {
+		return CreatedPaste{}, ErrMethodNotAllowed
+	}
+
+	// Check rate limit
+	err := rateSys.CheckAndUse(GetClientAddr(req))
+	if err != nil {
+		return CreatedPaste{}, err
 	}
 
-	// Get delete time
-	expirStr := req.PostForm.Get("expiration")
-	if expirStr != "" {
-		// Convert string to int
-		expir, err := strconv.ParseInt(expirStr, 10, 64)
+	// Read form
+	req.ParseForm()
+
+	// Client can fork only the paste it can read
+	forkOf := req.PostForm.Get("forkOf")
+	if forkOf != "" {
+		_, err = PasteGetForFork(req, db, rateSys, forkOf)
 		if err != nil {
-			return "", 0, 0, ErrBadRequest
+			return CreatedPaste{}, err
 		}
+	}
 
-		// Check limits
-		if maxLifeTime > 0 {
-			if expir > maxLifeTime || expir <= 0 {
-				return "", 0, 0, ErrBadRequest
-			}
-		}
+	return pasteAdd(req.PostForm, requestOwner(req), db, titleMaxLen, bodyMaxLen, maxLifeTime, lexerNames)
+}
 
-		// Save if ok
-		if expir > 0 {
-			paste.DeleteTime = time.Now().Unix() + expir
+// readDeleteTime reads the paste lifetime in seconds from the "expiration" form field
+// and returns the paste delete time. 0 means the paste never expires.
+func readDeleteTime(form url.Values, maxLifeTime int64) (int64, error) {
+	expirStr := form.Get("expiration")
+	if expirStr == "" {
+		return 0, nil
+	}
+
+	// Convert string to int
+	expir, err := strconv.ParseInt(expirStr, 10, 64)
+	if err != nil {
+		return 0, ErrBadRequest
+	}
+
+	// Check limits
+	if maxLifeTime > 0 {
+		if expir > maxLifeTime || expir <= 0 {
+			return 0, ErrBadRequest
 		}
 	}
 
+	if expir > 0 {
+		return time.Now().Unix() + expir, nil
+	}
+
+	return 0, nil
+}
+
+// pasteAdd creates the paste from the form.
+// The HTTP method, rate limit and access to the original paste of the fork must be checked by the caller.
+func pasteAdd(form url.Values, owner pasteOwner, db storage.Store, titleMaxLen int, bodyMaxLen int, maxLifeTime int64, lexerNames []string) (CreatedPaste, error) {
+	// Check ban list
+	err := CheckBan(db, owner.Addr)
+	if err != nil {
+		return CreatedPaste{}, err
+	}
+
+	encrypted := form.Get("encrypted") == "true"
+
+	text, err := readPasteText(form, encrypted, titleMaxLen, bodyMaxLen, lexerNames)
+	if err != nil {
+		return CreatedPaste{}, err
+	}
+
+	files, err := readPasteFiles(form, text, bodyMaxLen, lexerNames)
+	if err != nil {
+		return CreatedPaste{}, err
+	}
+
+	// Server can not split the encrypted text into files
+	if encrypted && len(files) != 0 {
+		return CreatedPaste{}, ErrBadRequest
+	}
+
+	paste := storage.Paste{
+		Title:       text.Title,
+		Body:        text.Body,
+		Syntax:      text.Syntax,
+		Encrypted:   encrypted,
+		Files:       files,
+		DeleteTime:  0,
+		OneUse:      false,
+		Author:      form.Get("author"),
+		AuthorEmail: form.Get("authorEmail"),
+		AuthorURL:   form.Get("authorURL"),
+		TokenID:     owner.TokenID,
+		UserID:      owner.UserID,
+	}
+
+	if owner.Addr != nil {
+		paste.CreatorIP = owner.Addr.String()
+	}
+
+	// Get delete time
+	paste.DeleteTime, err = readDeleteTime(form, maxLifeTime)
+	if err != nil {
+		return CreatedPaste{}, err
+	}
+
 	// Get "one use" parameter
-	if req.PostForm.Get("oneUse") == "true" {
+	if form.Get("oneUse") == "true" {
 		paste.OneUse = true
 	}
 
 	// Check author name, email and URL length.
 	if utf8.RuneCountInString(paste.Author) > MaxLengthAuthorAll {
-		return "", 0, 0, ErrPayloadTooLarge
+		return CreatedPaste{}, ErrPayloadTooLarge
 	}
 
 	if utf8.RuneCountInString(paste.AuthorEmail) > MaxLengthAuthorAll {
-		return "", 0, 0, ErrPayloadTooLarge
+		return CreatedPaste{}, ErrPayloadTooLarge
 	}
 
 	if utf8.RuneCountInString(paste.AuthorURL) > MaxLengthAuthorAll {
-		return "", 0, 0, ErrPayloadTooLarge
+		return CreatedPaste{}, ErrPayloadTooLarge
+	}
+
+	paste.ForkedFrom = form.Get("forkOf")
+
+	// Hash paste password
+	password := form.Get("password")
+	if utf8.RuneCountInString(password) > MaxLengthPassword {
+		return CreatedPaste{}, ErrPayloadTooLarge
+	}
+
+	if password != "" {
+		paste.PasswordHash, err = storage.HashPassword(password)
+		if err != nil {
+			return CreatedPaste{}, err
+		}
+	}
+
+	// Get visibility.
+	// Public paste is listed and shown in the search results, so it can not be secret.
+	paste.Visibility, err = readVisibility(form)
+	if err != nil {
+		return CreatedPaste{}, err
+	}
+
+	if paste.Visibility == storage.VisibilityPublic {
+		if paste.OneUse || paste.Encrypted || paste.PasswordHash != "" {
+			return CreatedPaste{}, ErrBadRequest
+		}
+	}
+
+	// Generate delete and edit tokens
+	var result CreatedPaste
+
+	result.DeleteToken, paste.DeleteTokenHash, err = storage.NewSecret()
+	if err != nil {
+		return CreatedPaste{}, err
+	}
+
+	result.EditToken, paste.EditTokenHash, err = storage.NewSecret()
+	if err != nil {
+		return CreatedPaste{}, err
 	}
 
 	// Create paste
-	pasteID, createTime, deleteTime, err := db.PasteAdd(paste)
+	result.ID, result.CreateTime, result.DeleteTime, err = db.PasteAdd(paste)
+	if err != nil {
+		return CreatedPaste{}, err
+	}
+
+	result.Visibility = paste.Visibility
+
+	return result, nil
+}
+
+// PasteEditFromForm publishes a new paste revision if editToken matches the paste edit token.
+func PasteEditFromForm(req *http.Request, db storage.Store, rateSys *RateLimitSystem, pasteID string, editToken string, titleMaxLen int, bodyMaxLen int, lexerNames []string) (EditedPaste, error) {
+	// Check HTTP method
+	if req.Method != "POST" {
+		return EditedPaste{}, ErrMethodNotAllowed
+	}
+
+	// Check rate limit
+	err := rateSys.CheckAndUse(GetClientAddr(req))
+	if err != nil {
+		return EditedPaste{}, err
+	}
+
+	// Read form
+	req.ParseForm()
+
+	// Check edit token
+	paste, err := db.PasteGet(pasteID)
+	if err != nil {
+		return EditedPaste{}, err
+	}
+
+	if storage.CheckSecret(editToken, paste.EditTokenHash) == false {
+		return EditedPaste{}, ErrForbidden
+	}
+
+	// "One use" pastes are deleted after the first view, there is no point in editing them
+	if paste.OneUse == true {
+		return EditedPaste{}, ErrBadRequest
+	}
+
+	// Revisions keep only one file
+	if len(paste.Files) != 0 {
+		return EditedPaste{}, ErrBadRequest
+	}
+
+	// Check new text, revisions of the encrypted paste are encrypted too
+	text, err := readPasteText(req.PostForm, paste.Encrypted, titleMaxLen, bodyMaxLen, lexerNames)
+	if err != nil {
+		return EditedPaste{}, err
+	}
+
+	// Save new revision
+	result := EditedPaste{ID: pasteID}
+
+	result.Revision, result.EditTime, err = db.PasteEdit(pasteID, text)
+	if err != nil {
+		return EditedPaste{}, err
+	}
+
+	return result, nil
+}
+
+// PasteDeleteByToken deletes the paste if the token matches its delete token.
+// The delete token is not needed if the API token of the request has the "delete-own" scope for the paste
+// or if the paste belongs to the logged in user.
+func PasteDeleteByToken(req *http.Request, db storage.Store, pasteID string, token string) error {
+	paste, err := db.PasteGet(pasteID)
+	if err != nil {
+		return err
+	}
+
+	if tokenAllows(req, paste, storage.ScopeDeleteOwn) == false && userOwns(req, paste) == false && storage.CheckSecret(token, paste.DeleteTokenHash) == false {
+		return ErrForbidden
+	}
+
+	return db.PasteDelete(pasteID)
+}
+
+// PasteGetRevision returns the paste revision specified by the "rev" parameter value.
+// If revStr is empty, the latest revision is returned.
+func PasteGetRevision(db storage.Store, pasteID string, revStr string) (storage.Paste, error) {
+	if revStr == "" {
+		return db.PasteGet(pasteID)
+	}
+
+	rev, err := strconv.Atoi(revStr)
+	if err != nil {
+		return storage.Paste{}, ErrBadRequest
+	}
+
+	return db.PasteGetRevision(pasteID, rev)
+}
+
+// PasteGetForDiff returns two paste revisions that should be compared.
+// "One use" pastes can not be compared because they are deleted only after the user confirms viewing.
+// Encrypted pastes can not be compared because the server does not know their text.
+func PasteGetForDiff(req *http.Request, db storage.Store, rateSys *RateLimitSystem, idA string, revA string, passwordA string, idB string, revB string, passwordB string) (storage.Paste, storage.Paste, error) {
+	pasteA, err := PasteGetRevision(db, idA, revA)
+	if err != nil {
+		return storage.Paste{}, storage.Paste{}, err
+	}
+
+	pasteB, err := PasteGetRevision(db, idB, revB)
+	if err != nil {
+		return storage.Paste{}, storage.Paste{}, err
+	}
+
+	if pasteA.OneUse || pasteB.OneUse || pasteA.Encrypted || pasteB.Encrypted {
+		return storage.Paste{}, storage.Paste{}, ErrBadRequest
+	}
+
+	// Check access
+	err = PasteCheckVisibility(req, pasteA)
+	if err != nil {
+		return storage.Paste{}, storage.Paste{}, err
+	}
+
+	err = PasteCheckVisibility(req, pasteB)
+	if err != nil {
+		return storage.Paste{}, storage.Paste{}, err
+	}
+
+	err = PasteCheckPassword(req, pasteA, rateSys, passwordA)
+	if err != nil {
+		return storage.Paste{}, storage.Paste{}, err
+	}
+
+	err = PasteCheckPassword(req, pasteB, rateSys, passwordB)
+	if err != nil {
+		return storage.Paste{}, storage.Paste{}, err
+	}
+
+	return pasteA, pasteB, nil
+}
+
+// PasteGetFile returns the file of the multi-file paste by its name.
+func PasteGetFile(paste storage.Paste, name string) (storage.PasteFile, error) {
+	for _, file := range paste.Files {
+		if file.Name == name {
+			return file, nil
+		}
+	}
+
+	return storage.PasteFile{}, ErrNotFound
+}
+
+// PasteGetForFork returns the paste whose text should be copied to the new paste.
+// "One use" and encrypted pastes can not be forked because the server can not show their text.
+func PasteGetForFork(req *http.Request, db storage.Store, rateSys *RateLimitSystem, pasteID string) (storage.Paste, error) {
+	paste, err := db.PasteGet(pasteID)
+	if err != nil {
+		return storage.Paste{}, err
+	}
+
+	if paste.OneUse || paste.Encrypted {
+		return storage.Paste{}, ErrBadRequest
+	}
+
+	err = PasteCheckVisibility(req, paste)
+	if err != nil {
+		return storage.Paste{}, err
+	}
+
+	// Password protected paste must be unlocked on its page first
+	err = PasteCheckPassword(req, paste, rateSys, "")
 	if err != nil {
-		return pasteID, createTime, deleteTime, err
+		return storage.Paste{}, err
 	}
 
-	return pasteID, createTime, deleteTime, nil
+	return paste, nil
 }
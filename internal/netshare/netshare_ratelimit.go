@@ -19,120 +19,258 @@
 package netshare
 
 import (
+	"container/list"
 	"net"
 	"sync"
 	"time"
 )
 
+// Client list is split into independently locked shards,
+// so requests from different clients rarely wait for each other.
+const rateLimitShards = 16
+
+// RateLimitOptions are shared by the rate limits of all route classes.
+type RateLimitOptions struct {
+	IPv4Prefix int // Clients from one IPv4 network share the limit, 32 means one address
+	IPv6Prefix int // Clients from one IPv6 network share the limit, one /64 network usually belongs to one client
+	MaxClients int // Least recently seen clients are forgotten when there are more of them
+}
+
+var DefaultRateLimitOptions = RateLimitOptions{
+	IPv4Prefix: 32,
+	IPv6Prefix: 64,
+	MaxClients: 100000,
+}
+
+// RateLimitWindow allows up to Limit requests from one client during any Period.
+type RateLimitWindow struct {
+	Period time.Duration
+	Limit  uint // 0 = no limit
+}
+
+// RateLimitSystem limits the requests of one route class, for example paste creation.
+// Every window is a sliding window counter, so the client can not send twice the limit
+// around the boundary of the fixed windows.
 type RateLimitSystem struct {
-	per5Min  *RateLimit
-	per15Min *RateLimit
-	per1Hour *RateLimit
+	windows []RateLimitWindow
+
+	ipv4Mask net.IPMask
+	ipv6Mask net.IPMask
+
+	shards [rateLimitShards]rateLimitShard
+
+	now func() time.Time // Replaced in tests
+}
+
+// rateLimitKey is the IPv6 or IPv4-mapped network of the client.
+type rateLimitKey [16]byte
+
+type rateLimitShard struct {
+	sync.Mutex
+
+	maxClients int
+	clients    map[rateLimitKey]*list.Element
+	lru        *list.List // Most recently seen client is at the front
+}
+
+type rateLimitClient struct {
+	key      rateLimitKey
+	counters []rateLimitCounter // One for every window
+}
+
+// rateLimitCounter counts requests in the current and the previous fixed windows.
+// Requests of the previous window are counted in proportion to its part that is still inside the sliding window.
+type rateLimitCounter struct {
+	window int64 // Number of the current fixed window since the Unix epoch
+	curr   uint
+	prev   uint
 }
 
+// NewRateLimitSystem returns the rate limit with 5 minutes, 15 minutes and 1 hour windows and default options.
+// 0 disables the window.
 func NewRateLimitSystem(per5Min, per15Min, per1Hour uint) *RateLimitSystem {
-	return &RateLimitSystem{
-		per5Min:  NewRateLimit(5*60, per5Min),
-		per15Min: NewRateLimit(15*60, per15Min),
-		per1Hour: NewRateLimit(60*60, per1Hour),
-	}
+	return NewRateLimitWindows(DefaultRateLimitOptions, []RateLimitWindow{
+		{Period: 5 * time.Minute, Limit: per5Min},
+		{Period: 15 * time.Minute, Limit: per15Min},
+		{Period: time.Hour, Limit: per1Hour},
+	})
 }
 
-func (rateSys *RateLimitSystem) CheckAndUse(ip net.IP) error {
-	var tmp int64
+// NewRateLimitWindows returns the rate limit with any windows.
+// Prefixes out of the 0-32 (IPv4) and 0-128 (IPv6) ranges are replaced with the full address length.
+func NewRateLimitWindows(opts RateLimitOptions, windows []RateLimitWindow) *RateLimitSystem {
+	rateSys := &RateLimitSystem{
+		ipv4Mask: net.CIDRMask(opts.IPv4Prefix, 32),
+		ipv6Mask: net.CIDRMask(opts.IPv6Prefix, 128),
+		now:      time.Now,
+	}
 
-	tmp = rateSys.per5Min.CheckAndUse(ip)
-	if tmp != 0 {
-		return ErrTooManyRequestsNew(tmp)
+	if rateSys.ipv4Mask == nil {
+		rateSys.ipv4Mask = net.CIDRMask(32, 32)
 	}
 
-	tmp = rateSys.per15Min.CheckAndUse(ip)
-	if tmp != 0 {
-		return ErrTooManyRequestsNew(tmp)
+	if rateSys.ipv6Mask == nil {
+		rateSys.ipv6Mask = net.CIDRMask(128, 128)
 	}
 
-	tmp = rateSys.per1Hour.CheckAndUse(ip)
-	if tmp != 0 {
-		return ErrTooManyRequestsNew(tmp)
+	for _, window := range windows {
+		if window.Limit != 0 && window.Period > 0 {
+			rateSys.windows = append(rateSys.windows, window)
+		}
 	}
 
-	return nil
+	maxClients := (opts.MaxClients + rateLimitShards - 1) / rateLimitShards
+	if maxClients < 1 {
+		maxClients = 1
+	}
+
+	for i := range rateSys.shards {
+		rateSys.shards[i] = rateLimitShard{
+			maxClients: maxClients,
+			clients:    make(map[rateLimitKey]*list.Element),
+			lru:        list.New(),
+		}
+	}
+
+	return rateSys
 }
 
-type RateLimit struct {
-	sync.RWMutex
+// key returns the network of the client without memory allocation.
+// IPv4 addresses are stored in the IPv4-mapped form, so they never match IPv6 networks.
+// All invalid addresses are one client.
+func (rateSys *RateLimitSystem) key(ip net.IP) rateLimitKey {
+	var key rateLimitKey
 
-	limitPeriod int  // N - Rate limit period (in seconds)
-	limitCount  uint // X - Max request count per N seconds period
+	if ip4 := ip.To4(); ip4 != nil {
+		key[10], key[11] = 0xff, 0xff
+		for i := range ip4 {
+			key[12+i] = ip4[i] & rateSys.ipv4Mask[i]
+		}
 
-	list map[string]rateLimitIP // Rate limit bucket
+	} else if ip6 := ip.To16(); ip6 != nil {
+		for i := range ip6 {
+			key[i] = ip6[i] & rateSys.ipv6Mask[i]
+		}
+	}
+
+	return key
 }
 
-type rateLimitIP struct {
-	UseTime  int64 // Fist IP use time
-	UseCount uint  // Requests count by IP
+// shard returns the part of the client list for the key, FNV-1a hash is used.
+func (rateSys *RateLimitSystem) shard(key rateLimitKey) *rateLimitShard {
+	hash := uint32(2166136261)
+	for _, b := range key {
+		hash ^= uint32(b)
+		hash *= 16777619
+	}
+
+	return &rateSys.shards[hash%rateLimitShards]
 }
 
-func NewRateLimit(rateLimitPeriod int, limitCount uint) *RateLimit {
-	rateLimit := &RateLimit{
-		limitPeriod: rateLimitPeriod,
-		limitCount:  limitCount,
-		list:        make(map[string]rateLimitIP),
+// CheckAndUse counts the request of the client.
+// If any window is exceeded, the request is not counted and ErrTooManyRequests is returned.
+func (rateSys *RateLimitSystem) CheckAndUse(ip net.IP) error {
+	// If rate limit not need
+	if len(rateSys.windows) == 0 {
+		return nil
 	}
 
-	go rateLimit.runWorker()
+	key := rateSys.key(ip)
+	shard := rateSys.shard(key)
+	timeNow := rateSys.now().UnixNano()
+
+	shard.Lock()
+	defer shard.Unlock()
+
+	client := shard.get(key, len(rateSys.windows))
+
+	// Check all windows
+	var retryAfter int64
+	for i, window := range rateSys.windows {
+		wait := client.counters[i].check(window, timeNow)
+		if wait > retryAfter {
+			retryAfter = wait
+		}
+	}
+
+	if retryAfter != 0 {
+		return ErrTooManyRequestsNew(retryAfter)
+	}
+
+	// Use
+	for i := range client.counters {
+		client.counters[i].curr++
+	}
 
-	return rateLimit
+	return nil
 }
 
-func (rateLimit *RateLimit) runWorker() {
-	for {
-		time.Sleep(time.Duration(rateLimit.limitPeriod) * time.Second)
+// get returns the client and marks it as recently seen.
+// If the shard is full, the least recently seen client is replaced.
+func (shard *rateLimitShard) get(key rateLimitKey, windows int) *rateLimitClient {
+	elem, exist := shard.clients[key]
+	if exist {
+		shard.lru.MoveToFront(elem)
+		return elem.Value.(*rateLimitClient)
+	}
 
-		timeNow := time.Now().Unix()
-		rateLimit.Lock()
+	if shard.lru.Len() >= shard.maxClients {
+		elem = shard.lru.Back()
+		client := elem.Value.(*rateLimitClient)
+		delete(shard.clients, client.key)
 
-		for ipStr, data := range rateLimit.list {
-			if data.UseTime+int64(rateLimit.limitPeriod) <= timeNow {
-				delete(rateLimit.list, ipStr)
-			}
+		client.key = key
+		for i := range client.counters {
+			client.counters[i] = rateLimitCounter{}
 		}
 
-		rateLimit.Unlock()
+		shard.clients[key] = elem
+		shard.lru.MoveToFront(elem)
+
+		return client
 	}
-}
 
-func (rateLimit *RateLimit) CheckAndUse(ip net.IP) int64 {
-	// If rate limit not need
-	if rateLimit.limitCount == 0 {
-		return 0
+	client := &rateLimitClient{
+		key:      key,
+		counters: make([]rateLimitCounter, windows),
 	}
 
-	// Lock
-	rateLimit.Lock()
-	defer rateLimit.Unlock()
+	shard.clients[key] = shard.lru.PushFront(client)
+
+	return client
+}
 
-	ipStr := ip.String()
-	timeNow := time.Now().Unix()
+// check moves the counter to the current fixed window and returns
+// the number of seconds to wait before the next request or 0 if the request is allowed.
+func (counter *rateLimitCounter) check(window RateLimitWindow, timeNow int64) int64 {
+	period := int64(window.Period)
+	current := timeNow / period
 
-	// If last use time out
-	if rateLimit.list[ipStr].UseTime+int64(rateLimit.limitPeriod) <= timeNow {
-		rateLimit.list[ipStr] = rateLimitIP{
-			UseTime:  timeNow,
-			UseCount: 1,
-		}
+	switch current {
+	case counter.window:
+	case counter.window + 1:
+		counter.prev, counter.curr = counter.curr, 0
+	default:
+		counter.prev, counter.curr = 0, 0
+	}
+
+	counter.window = current
 
+	// Time left until the end of the current fixed window
+	left := (current+1)*period - timeNow
+
+	used := float64(counter.prev)*float64(left)/float64(period) + float64(counter.curr)
+	if used < float64(window.Limit) {
 		return 0
+	}
 
-		// Else
-	} else {
-		if rateLimit.list[ipStr].UseCount < rateLimit.limitCount {
-			tmp := rateLimit.list[ipStr]
-			tmp.UseCount = tmp.UseCount + 1
-			rateLimit.list[ipStr] = tmp
-			return 0
-		}
+	// Current window is full, in the next one its requests are gone
+	// from the sliding window right after the start.
+	// Else wait until enough requests of the previous window are gone.
+	wait := left
+	if counter.curr < window.Limit {
+		wait = left - int64(float64(window.Limit-counter.curr)*float64(period)/float64(counter.prev))
 	}
 
-	return rateLimit.list[ipStr].UseTime + int64(rateLimit.limitPeriod) - timeNow
+	return wait/int64(time.Second) + 1
 }
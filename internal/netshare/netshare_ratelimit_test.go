@@ -0,0 +1,239 @@
+// Copyright (C) 2021-2023 Leonid Maslakov.
+
+// This file is part of Lenpaste.
+
+// Lenpaste is free software: you can redistribute it
+// and/or modify it under the terms of the
+// GNU Affero Public License as published by the
+// Free Software Foundation, either version 3 of the License,
+// or (at your option) any later version.
+
+// Lenpaste is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+// or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero Public License for more details.
+
+// You should have received a copy of the GNU Affero Public License along with Lenpaste.
+// If not, see <https://www.gnu.org/licenses/>.
+
+package netshare
+
+import (
+	"errors"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// testClock is the manually moved time of the rate limit.
+type testClock struct {
+	t time.Time
+}
+
+func (clock *testClock) now() time.Time {
+	return clock.t
+}
+
+func newTestRateLimit(opts RateLimitOptions, windows ...RateLimitWindow) (*RateLimitSystem, *testClock) {
+	// Start of the fixed window, so the test controls the boundary
+	clock := &testClock{t: time.Unix(3600*1000, 0)}
+
+	rateSys := NewRateLimitWindows(opts, windows)
+	rateSys.now = clock.now
+
+	return rateSys, clock
+}
+
+func useN(t *testing.T, rateSys *RateLimitSystem, ip string, n int) {
+	for i := 0; i < n; i++ {
+		err := rateSys.CheckAndUse(net.ParseIP(ip))
+		if err != nil {
+			t.Fatal("request", i+1, "from", ip, "is limited:", err)
+		}
+	}
+}
+
+func retryAfter(t *testing.T, err error) int64 {
+	var e *ErrTooManyRequests
+	if errors.As(err, &e) == false {
+		t.Fatal("expected ErrTooManyRequests but got", err)
+	}
+
+	return e.RetryAfter
+}
+
+func TestRateLimitDisabled(t *testing.T) {
+	rateSys := NewRateLimitSystem(0, 0, 0)
+	useN(t, rateSys, "192.0.2.1", 1000)
+}
+
+func TestRateLimitSlidingWindow(t *testing.T) {
+	rateSys, clock := newTestRateLimit(DefaultRateLimitOptions, RateLimitWindow{Period: time.Minute, Limit: 10})
+
+	// Burst at the end of the fixed window
+	clock.t = clock.t.Add(50 * time.Second)
+	useN(t, rateSys, "192.0.2.1", 10)
+
+	// Full window is counted until its end
+	err := rateSys.CheckAndUse(net.ParseIP("192.0.2.1"))
+	if retryAfter(t, err) != 11 {
+		t.Error("expected retry after 11 seconds but got", retryAfter(t, err))
+	}
+
+	// 5 seconds of the new window: 10*55/60 requests of the previous one are still counted,
+	// so only one request is allowed where the fixed window allows the second burst
+	clock.t = clock.t.Add(15 * time.Second)
+	useN(t, rateSys, "192.0.2.1", 1)
+
+	// The next one is allowed when 10*(55-X)/60 + 1 < 10
+	err = rateSys.CheckAndUse(net.ParseIP("192.0.2.1"))
+	if retryAfter(t, err) != 2 {
+		t.Error("expected retry after 2 seconds but got", retryAfter(t, err))
+	}
+
+	clock.t = clock.t.Add(2 * time.Second)
+	useN(t, rateSys, "192.0.2.1", 1)
+
+	err = rateSys.CheckAndUse(net.ParseIP("192.0.2.1"))
+	if err == nil {
+		t.Error("expected rate limit")
+	}
+
+	// Rejected requests are not counted, so the full limit is available two windows later
+	clock.t = clock.t.Add(2 * time.Minute)
+	useN(t, rateSys, "192.0.2.1", 10)
+}
+
+func TestRateLimitWindows(t *testing.T) {
+	rateSys, clock := newTestRateLimit(DefaultRateLimitOptions,
+		RateLimitWindow{Period: time.Minute, Limit: 5},
+		RateLimitWindow{Period: time.Hour, Limit: 8},
+	)
+
+	useN(t, rateSys, "192.0.2.1", 5)
+
+	clock.t = clock.t.Add(2 * time.Minute)
+	useN(t, rateSys, "192.0.2.1", 3)
+
+	// Hourly limit is exceeded
+	clock.t = clock.t.Add(2 * time.Minute)
+	err := rateSys.CheckAndUse(net.ParseIP("192.0.2.1"))
+	if retryAfter(t, err) < 60 {
+		t.Error("expected retry after the hourly window but got", retryAfter(t, err))
+	}
+}
+
+func TestRateLimitPrefix(t *testing.T) {
+	rateSys, _ := newTestRateLimit(
+		RateLimitOptions{IPv4Prefix: 24, IPv6Prefix: 64, MaxClients: 100},
+		RateLimitWindow{Period: time.Minute, Limit: 2},
+	)
+
+	// One IPv6 /64 network is one client
+	useN(t, rateSys, "2001:db8:0:1::1", 1)
+	useN(t, rateSys, "2001:db8:0:1:ffff::2", 1)
+
+	err := rateSys.CheckAndUse(net.ParseIP("2001:db8:0:1::3"))
+	if err == nil {
+		t.Error("IPv6 /64 network is not limited")
+	}
+
+	useN(t, rateSys, "2001:db8:0:2::1", 2)
+
+	// IPv4 /24 network is one client
+	useN(t, rateSys, "192.0.2.1", 1)
+	useN(t, rateSys, "192.0.2.200", 1)
+
+	err = rateSys.CheckAndUse(net.ParseIP("192.0.2.3"))
+	if err == nil {
+		t.Error("IPv4 /24 network is not limited")
+	}
+
+	useN(t, rateSys, "192.0.3.1", 2)
+
+	// IPv4-mapped IPv6 address is the same IPv4 client
+	err = rateSys.CheckAndUse(net.ParseIP("::ffff:192.0.2.4"))
+	if err == nil {
+		t.Error("IPv4-mapped address is not limited")
+	}
+}
+
+func TestRateLimitEviction(t *testing.T) {
+	rateSys, _ := newTestRateLimit(
+		RateLimitOptions{IPv4Prefix: 32, IPv6Prefix: 128, MaxClients: rateLimitShards},
+		RateLimitWindow{Period: time.Minute, Limit: 1},
+	)
+
+	for i := 0; i < 1000; i++ {
+		useN(t, rateSys, "10.0."+strconv.Itoa(i/256)+"."+strconv.Itoa(i%256), 1)
+	}
+
+	for i := range rateSys.shards {
+		shard := &rateSys.shards[i]
+		if shard.lru.Len() > shard.maxClients || len(shard.clients) != shard.lru.Len() {
+			t.Fatal("shard", i, "has", shard.lru.Len(), "clients, max is", shard.maxClients)
+		}
+	}
+
+	// The most recently seen client is remembered
+	err := rateSys.CheckAndUse(net.ParseIP("10.0.3.231"))
+	if err == nil {
+		t.Error("recently seen client is forgotten")
+	}
+}
+
+func BenchmarkCheckAndUse(b *testing.B) {
+	rateSys := NewRateLimitSystem(uint(b.N)+1, uint(b.N)+1, uint(b.N)+1)
+	ip := net.ParseIP("192.0.2.1")
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		rateSys.CheckAndUse(ip)
+	}
+}
+
+// All goroutines use the same client, so they compete for one lock.
+func BenchmarkCheckAndUseContentionOneClient(b *testing.B) {
+	rateSys := NewRateLimitSystem(15, 30, 40)
+	ip := net.ParseIP("192.0.2.1")
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			rateSys.CheckAndUse(ip)
+		}
+	})
+}
+
+// Every request comes from a different client, so the least recently seen clients are evicted.
+func BenchmarkCheckAndUseContentionManyClients(b *testing.B) {
+	rateSys := NewRateLimitWindows(
+		RateLimitOptions{IPv4Prefix: 32, IPv6Prefix: 64, MaxClients: 10000},
+		[]RateLimitWindow{
+			{Period: 5 * time.Minute, Limit: 15},
+			{Period: 15 * time.Minute, Limit: 30},
+			{Period: time.Hour, Limit: 40},
+		},
+	)
+
+	ips := make([]net.IP, 100000)
+	for i := range ips {
+		ips[i] = net.IPv4(10, byte(i>>16), byte(i>>8), byte(i))
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			rateSys.CheckAndUse(ips[i%len(ips)])
+			i += 7
+		}
+	})
+}
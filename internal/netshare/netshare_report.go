@@ -0,0 +1,214 @@
+// Copyright (C) 2021-2023 Leonid Maslakov.
+
+// This file is part of Lenpaste.
+
+// Lenpaste is free software: you can redistribute it
+// and/or modify it under the terms of the
+// GNU Affero Public License as published by the
+// Free Software Foundation, either version 3 of the License,
+// or (at your option) any later version.
+
+// Lenpaste is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+// or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero Public License for more details.
+
+// You should have received a copy of the GNU Affero Public License along with Lenpaste.
+// If not, see <https://www.gnu.org/licenses/>.
+
+package netshare
+
+import (
+	"github.com/lcomrade/lenpaste/internal/storage"
+	"net/http"
+	"unicode/utf8"
+)
+
+const (
+	MaxLengthReportComment = 1000 // Max length of the report comment.
+	ReportsPerPage         = 50
+)
+
+// Reasons of the abuse report.
+var ReportReasons = []string{"illegal", "personal-data", "malware", "spam", "copyright", "other"}
+
+// ReportAddFromForm reports the paste with the "reason" and "comment" form fields.
+// Repeated reports from the same address are ignored.
+// If autoHide is more than 0, the paste is hidden when it is reported from this number of different addresses.
+func ReportAddFromForm(req *http.Request, db storage.Store, rateSys *RateLimitSystem, pasteID string, autoHide int) error {
+	// Check HTTP method
+	if req.Method != "POST" {
+		return ErrMethodNotAllowed
+	}
+
+	// Check rate limit
+	clientAddr := GetClientAddr(req)
+
+	err := rateSys.CheckAndUse(clientAddr)
+	if err != nil {
+		return err
+	}
+
+	// Read form
+	req.ParseForm()
+
+	report := storage.Report{
+		PasteID: pasteID,
+		Reason:  req.PostForm.Get("reason"),
+		Comment: req.PostForm.Get("comment"),
+	}
+
+	if clientAddr != nil {
+		report.ReporterIP = clientAddr.String()
+	}
+
+	known := false
+	for _, reason := range ReportReasons {
+		if report.Reason == reason {
+			known = true
+			break
+		}
+	}
+
+	if known == false {
+		return ErrBadRequest
+	}
+
+	if utf8.RuneCountInString(report.Comment) > MaxLengthReportComment {
+		return ErrPayloadTooLarge
+	}
+
+	// Check paste, private paste can be reported only by the client that can read it
+	paste, err := db.PasteGet(pasteID)
+	if err != nil {
+		return err
+	}
+
+	err = PasteCheckVisibility(req, paste)
+	if err != nil {
+		return ErrNotFound
+	}
+
+	// Add report
+	_, err = db.ReportAdd(report)
+	if err != nil {
+		if err == storage.ErrReportExists {
+			return nil
+		}
+
+		return err
+	}
+
+	return checkAutoHide(db, pasteID, autoHide)
+}
+
+// checkAutoHide hides the paste if it is reported from autoHide different addresses and shows it otherwise.
+func checkAutoHide(db storage.Store, pasteID string, autoHide int) error {
+	if autoHide <= 0 {
+		return nil
+	}
+
+	reporters, err := db.ReportReporters(pasteID)
+	if err != nil {
+		return err
+	}
+
+	return db.PasteSetHidden(pasteID, reporters >= autoHide)
+}
+
+// ReportPage is the page of the abuse reports queue.
+type ReportPage struct {
+	Page     int // Starts from 1
+	NextPage bool
+	Reports  []storage.Report
+}
+
+// ReportList reads the "page" parameter from the URL query and returns the page of the reports queue from oldest to newest.
+func ReportList(req *http.Request, db storage.Store) (ReportPage, error) {
+	_, err := RequestAdmin(req)
+	if err != nil {
+		return ReportPage{}, err
+	}
+
+	var result ReportPage
+
+	result.Page, err = readPage(req, ReportsPerPage)
+	if err != nil {
+		return ReportPage{}, err
+	}
+
+	// Read one more report to know if there is a next page
+	result.Reports, err = db.ReportList(ReportsPerPage+1, (result.Page-1)*ReportsPerPage)
+	if err != nil {
+		return ReportPage{}, err
+	}
+
+	if len(result.Reports) > ReportsPerPage {
+		result.NextPage = true
+		result.Reports = result.Reports[:ReportsPerPage]
+	}
+
+	return result, nil
+}
+
+// ReportResolve closes the report with the action:
+//   - "delete" deletes the paste and all its reports;
+//   - "dismiss" deletes only the report, the paste is shown again if it has not enough other reports;
+//   - "ban" bans the paste creator IP address and deletes the paste.
+func ReportResolve(req *http.Request, db storage.Store, reportID string, action string, autoHide int) error {
+	admin, err := RequestAdmin(req)
+	if err != nil {
+		return err
+	}
+
+	report, err := db.ReportGet(reportID)
+	if err != nil {
+		if err == storage.ErrNotFoundReport {
+			return ErrNotFound
+		}
+
+		return err
+	}
+
+	switch action {
+	case "delete":
+		return adminPasteDelete(db, admin, report.PasteID)
+
+	case "dismiss":
+		err = db.ReportDelete(report.ID)
+		if err != nil {
+			return err
+		}
+
+		err = audit(db, admin, storage.AuditReportDismiss, report.PasteID, "reason: "+report.Reason+", reporter IP: "+report.ReporterIP)
+		if err != nil {
+			return err
+		}
+
+		err = checkAutoHide(db, report.PasteID, autoHide)
+		if err != nil && err != storage.ErrNotFoundID {
+			return err
+		}
+
+		return nil
+
+	case "ban":
+		paste, err := db.PasteGetAny(report.PasteID)
+		if err != nil {
+			return err
+		}
+
+		if paste.CreatorIP == "" {
+			return ErrBadRequest
+		}
+
+		_, err = BanAdd(db, admin, paste.CreatorIP, "", 0, paste.ID)
+		if err != nil && err != storage.ErrBanExists {
+			return err
+		}
+
+		return adminPasteDelete(db, admin, paste.ID)
+	}
+
+	return ErrBadRequest
+}
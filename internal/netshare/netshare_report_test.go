@@ -0,0 +1,68 @@
+// Copyright (C) 2021-2023 Leonid Maslakov.
+
+// This file is part of Lenpaste.
+
+// Lenpaste is free software: you can redistribute it
+// and/or modify it under the terms of the
+// GNU Affero Public License as published by the
+// Free Software Foundation, either version 3 of the License,
+// or (at your option) any later version.
+
+// Lenpaste is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+// or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero Public License for more details.
+
+// You should have received a copy of the GNU Affero Public License along with Lenpaste.
+// If not, see <https://www.gnu.org/licenses/>.
+
+package netshare
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/lcomrade/lenpaste/internal/storage"
+)
+
+func TestReportPrivate(t *testing.T) {
+	db := storage.NewMemory()
+	rateSys := NewRateLimitSystem(0, 0, 0)
+
+	report := func(pasteID string) error {
+		form := url.Values{"reason": {"spam"}}
+		req := httptest.NewRequest("POST", "/report/"+pasteID, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		return ReportAddFromForm(req, db, rateSys, pasteID, 1)
+	}
+
+	for _, visibility := range []string{storage.VisibilityPublic, storage.VisibilityPrivate} {
+		pasteID, _, _, err := db.PasteAdd(storage.Paste{Body: "Text", Syntax: "plaintext", Visibility: visibility})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = report(pasteID)
+		if visibility == storage.VisibilityPrivate {
+			if err != ErrNotFound {
+				t.Error("expected ErrNotFound but got", err)
+			}
+
+		} else if err != nil {
+			t.Error("expected report but got", err)
+		}
+	}
+
+	// Private paste must not be reported by outsiders
+	reports, err := db.ReportList(10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(reports) != 1 {
+		t.Error("expected 1 report but got", reports)
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright (C) 2021-2023 Leonid Maslakov.
+
+// This file is part of Lenpaste.
+
+// Lenpaste is free software: you can redistribute it
+// and/or modify it under the terms of the
+// GNU Affero Public License as published by the
+// Free Software Foundation, either version 3 of the License,
+// or (at your option) any later version.
+
+// Lenpaste is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+// or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero Public License for more details.
+
+// You should have received a copy of the GNU Affero Public License along with Lenpaste.
+// If not, see <https://www.gnu.org/licenses/>.
+
+package netshare
+
+import (
+	"github.com/lcomrade/lenpaste/internal/storage"
+	"html"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	MaxLengthSearchQuery = 256
+	SearchPerPage        = 20
+)
+
+type SearchPage struct {
+	Query    string
+	Page     int // Starts from 1
+	NextPage bool
+	Results  []storage.SearchResult
+}
+
+// PasteSearch reads the "q" and "page" parameters from the URL query and searches the public pastes.
+func PasteSearch(req *http.Request, db storage.Store, rateSys *RateLimitSystem) (SearchPage, error) {
+	// Check rate limit
+	err := rateSys.CheckAndUse(GetClientAddr(req))
+	if err != nil {
+		return SearchPage{}, err
+	}
+
+	// Read query
+	query := req.URL.Query()
+
+	result := SearchPage{
+		Query: strings.TrimSpace(query.Get("q")),
+		Page:  1,
+	}
+
+	if utf8.RuneCountInString(result.Query) > MaxLengthSearchQuery {
+		return SearchPage{}, ErrPayloadTooLarge
+	}
+
+	result.Page, err = readPage(req, SearchPerPage)
+	if err != nil {
+		return SearchPage{}, err
+	}
+
+	if result.Query == "" {
+		return result, nil
+	}
+
+	// Search one more paste to know if there is a next page
+	result.Results, err = db.PasteSearch(result.Query, SearchPerPage+1, (result.Page-1)*SearchPerPage)
+	if err != nil {
+		return SearchPage{}, err
+	}
+
+	if len(result.Results) > SearchPerPage {
+		result.NextPage = true
+		result.Results = result.Results[:SearchPerPage]
+	}
+
+	return result, nil
+}
+
+// SearchSnippetHTML escapes the search result snippet and encloses matched words in the <mark> tag.
+// Unpaired marks (the paste text itself can contain such characters) are dropped.
+func SearchSnippetHTML(snippet string) string {
+	var result strings.Builder
+
+	for _, part := range strings.SplitAfter(snippet, storage.SnippetMatchEnd) {
+		text := strings.TrimSuffix(part, storage.SnippetMatchEnd)
+		closed := len(text) != len(part)
+
+		i := strings.LastIndex(text, storage.SnippetMatchStart)
+		if i == -1 || closed == false {
+			result.WriteString(html.EscapeString(strings.ReplaceAll(text, storage.SnippetMatchStart, "")))
+			continue
+		}
+
+		result.WriteString(html.EscapeString(strings.ReplaceAll(text[:i], storage.SnippetMatchStart, "")))
+		result.WriteString("<mark>")
+		result.WriteString(html.EscapeString(text[i+len(storage.SnippetMatchStart):]))
+		result.WriteString("</mark>")
+	}
+
+	return result.String()
+}
@@ -0,0 +1,96 @@
+// Copyright (C) 2021-2023 Leonid Maslakov.
+
+// This file is part of Lenpaste.
+
+// Lenpaste is free software: you can redistribute it
+// and/or modify it under the terms of the
+// GNU Affero Public License as published by the
+// Free Software Foundation, either version 3 of the License,
+// or (at your option) any later version.
+
+// Lenpaste is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+// or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero Public License for more details.
+
+// You should have received a copy of the GNU Affero Public License along with Lenpaste.
+// If not, see <https://www.gnu.org/licenses/>.
+
+package netshare
+
+import (
+	"context"
+	"github.com/lcomrade/lenpaste/internal/storage"
+	"net/http"
+	"strings"
+)
+
+type tokenContextKey struct{}
+
+// bearerToken returns the token from the "Authorization: Bearer" header.
+func bearerToken(req *http.Request) (string, bool) {
+	auth := req.Header.Get("Authorization")
+
+	const prefix = "Bearer "
+	if len(auth) < len(prefix) || strings.EqualFold(auth[:len(prefix)], prefix) == false {
+		return "", false
+	}
+
+	return strings.TrimSpace(auth[len(prefix):]), true
+}
+
+// AuthRequest checks the API token sent in the "Authorization: Bearer" header
+// and returns the request that carries the token in its context, see RequestToken.
+// Requests without the bearer token are returned as is.
+// Unknown and expired tokens are rejected with ErrUnauthorized.
+func AuthRequest(req *http.Request, db storage.Store) (*http.Request, error) {
+	secret, exist := bearerToken(req)
+	if exist == false {
+		return req, nil
+	}
+
+	if secret == "" {
+		return req, ErrUnauthorized
+	}
+
+	token, err := db.TokenGetByHash(storage.HashSecret(secret))
+	if err != nil {
+		if err == storage.ErrNotFoundToken {
+			return req, ErrUnauthorized
+		}
+
+		return req, err
+	}
+
+	if token.Expired() {
+		return req, ErrUnauthorized
+	}
+
+	err = db.TokenUse(token.ID)
+	if err != nil {
+		return req, err
+	}
+
+	return req.WithContext(context.WithValue(req.Context(), tokenContextKey{}, token)), nil
+}
+
+// RequestToken returns the API token checked by AuthRequest.
+func RequestToken(req *http.Request) (storage.APIToken, bool) {
+	token, ok := req.Context().Value(tokenContextKey{}).(storage.APIToken)
+	return token, ok
+}
+
+// tokenAllows reports whether the API token of the request gives the scope for the paste.
+// Admin token has access to all pastes, other tokens only to the pastes created with them.
+func tokenAllows(req *http.Request, paste storage.Paste, scope string) bool {
+	token, exist := RequestToken(req)
+	if exist == false || token.HasScope(scope) == false {
+		return false
+	}
+
+	if token.HasScope(storage.ScopeAdmin) {
+		return true
+	}
+
+	return paste.TokenID != "" && paste.TokenID == token.ID
+}
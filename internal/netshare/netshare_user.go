@@ -0,0 +1,332 @@
+// Copyright (C) 2021-2023 Leonid Maslakov.
+
+// This file is part of Lenpaste.
+
+// Lenpaste is free software: you can redistribute it
+// and/or modify it under the terms of the
+// GNU Affero Public License as published by the
+// Free Software Foundation, either version 3 of the License,
+// or (at your option) any later version.
+
+// Lenpaste is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+// or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero Public License for more details.
+
+// You should have received a copy of the GNU Affero Public License along with Lenpaste.
+// If not, see <https://www.gnu.org/licenses/>.
+
+package netshare
+
+import (
+	"context"
+	"github.com/lcomrade/lenpaste/internal/storage"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	MaxLengthUserName     = 32  // Max length of the user name.
+	MinLengthUserPassword = 8   // Min length of the user password.
+	MaxLengthUserPassword = 256 // Max length of the user password.
+)
+
+type userContextKey struct{}
+
+// Hash that is checked when the user does not exist,
+// so the login time does not show whether the user exists.
+var (
+	dummyHashOnce sync.Once
+	dummyHash     string
+)
+
+// WithUser returns the request that carries the logged in user, see RequestUser.
+func WithUser(req *http.Request, user storage.User) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), userContextKey{}, user))
+}
+
+// RequestUser returns the logged in user set by WithUser.
+func RequestUser(req *http.Request) (storage.User, bool) {
+	user, ok := req.Context().Value(userContextKey{}).(storage.User)
+	return user, ok
+}
+
+// userOwns reports whether the paste was created by the logged in user.
+func userOwns(req *http.Request, paste storage.Paste) bool {
+	user, exist := RequestUser(req)
+	return exist && paste.UserID != "" && paste.UserID == user.ID
+}
+
+// pasteOwner is the API token, the user account and the client address the paste is created with.
+type pasteOwner struct {
+	TokenID string
+	UserID  string
+	Addr    net.IP
+}
+
+func requestOwner(req *http.Request) pasteOwner {
+	token, _ := RequestToken(req)
+	user, _ := RequestUser(req)
+
+	return pasteOwner{
+		TokenID: token.ID,
+		UserID:  user.ID,
+		Addr:    GetClientAddr(req),
+	}
+}
+
+// checkUserName checks that the user name contains only latin letters, digits and "-", "_", "." characters.
+func checkUserName(name string) error {
+	if name == "" || utf8.RuneCountInString(name) > MaxLengthUserName {
+		return ErrBadRequest
+	}
+
+	for _, c := range name {
+		if ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9') || c == '-' || c == '_' || c == '.' {
+			continue
+		}
+
+		return ErrBadRequest
+	}
+
+	return nil
+}
+
+// UserAdd checks the user name and password and creates the user.
+func UserAdd(db storage.Store, name string, password string, admin bool) (storage.User, error) {
+	err := checkUserName(name)
+	if err != nil {
+		return storage.User{}, err
+	}
+
+	passwordLen := utf8.RuneCountInString(password)
+	if passwordLen < MinLengthUserPassword {
+		return storage.User{}, ErrBadRequest
+	}
+
+	if passwordLen > MaxLengthUserPassword {
+		return storage.User{}, ErrPayloadTooLarge
+	}
+
+	user := storage.User{
+		Name:  name,
+		Admin: admin,
+	}
+
+	user.PasswordHash, err = storage.HashPassword(password)
+	if err != nil {
+		return storage.User{}, err
+	}
+
+	user.ID, err = db.UserAdd(user)
+	if err != nil {
+		return storage.User{}, err
+	}
+
+	return user, nil
+}
+
+// UserAddFromForm creates the user from the "name" and "password" form fields.
+func UserAddFromForm(req *http.Request, db storage.Store, rateSys *RateLimitSystem) (storage.User, error) {
+	// Check HTTP method
+	if req.Method != "POST" {
+		return storage.User{}, ErrMethodNotAllowed
+	}
+
+	// Check rate limit
+	err := rateSys.CheckAndUse(GetClientAddr(req))
+	if err != nil {
+		return storage.User{}, err
+	}
+
+	req.ParseForm()
+
+	return UserAdd(db, req.PostForm.Get("name"), req.PostForm.Get("password"), false)
+}
+
+// UserLoginFromForm checks the "name" and "password" form fields.
+// Every attempt is counted against the rate limit system before the password is checked.
+func UserLoginFromForm(req *http.Request, db storage.Store, rateSys *RateLimitSystem) (storage.User, error) {
+	// Check HTTP method
+	if req.Method != "POST" {
+		return storage.User{}, ErrMethodNotAllowed
+	}
+
+	// Check rate limit
+	err := rateSys.CheckAndUse(GetClientAddr(req))
+	if err != nil {
+		return storage.User{}, err
+	}
+
+	req.ParseForm()
+
+	password := req.PostForm.Get("password")
+	if utf8.RuneCountInString(password) > MaxLengthUserPassword {
+		return storage.User{}, ErrPayloadTooLarge
+	}
+
+	user, err := db.UserGetByName(req.PostForm.Get("name"))
+	if err != nil && err != storage.ErrNotFoundUser {
+		return storage.User{}, err
+	}
+
+	// Users created with OpenID Connect have no password
+	ok := false
+	if err == nil && user.PasswordHash != "" {
+		ok, err = storage.CheckPassword(password, user.PasswordHash)
+		if err != nil {
+			return storage.User{}, err
+		}
+
+	} else {
+		dummyHashOnce.Do(func() {
+			dummyHash, _ = storage.HashPassword("")
+		})
+
+		storage.CheckPassword(password, dummyHash)
+	}
+
+	if ok == false {
+		return storage.User{}, ErrWrongPassword
+	}
+
+	return user, nil
+}
+
+// OIDCUser returns the user with the OpenID Connect subject and creates it on the first login.
+// The email is used as the name of the new user, local user names can not contain "@", so they never match.
+func OIDCUser(db storage.Store, subject string, email string) (storage.User, error) {
+	if subject == "" || email == "" {
+		return storage.User{}, ErrForbidden
+	}
+
+	user, err := db.UserGetByOIDCSubject(subject)
+	if err == nil {
+		if user.Email != email {
+			err = db.UserSetEmail(user.ID, email)
+			if err != nil {
+				return storage.User{}, err
+			}
+
+			user.Email = email
+		}
+
+		return user, nil
+	}
+
+	if err != storage.ErrNotFoundUser {
+		return storage.User{}, err
+	}
+
+	user = storage.User{
+		Name:        email,
+		Email:       email,
+		OIDCSubject: subject,
+	}
+
+	user.ID, err = db.UserAdd(user)
+	if err != nil {
+		if err == storage.ErrUserExists {
+			return storage.User{}, ErrForbidden
+		}
+
+		return storage.User{}, err
+	}
+
+	return user, nil
+}
+
+// SessionAdd creates a new session of the user.
+// Returns the session ID that must be given to the user and the session expiration time.
+func SessionAdd(db storage.Store, userID string, lifetime time.Duration) (string, int64, error) {
+	sessionID, sessionHash, err := storage.NewSecret()
+	if err != nil {
+		return "", 0, err
+	}
+
+	expireTime := time.Now().Add(lifetime).Unix()
+
+	err = db.SessionAdd(storage.Session{
+		IDHash:     sessionHash,
+		UserID:     userID,
+		ExpireTime: expireTime,
+	})
+	if err != nil {
+		return "", 0, err
+	}
+
+	return sessionID, expireTime, nil
+}
+
+// SessionUser returns the user of the session.
+func SessionUser(db storage.Store, sessionID string) (storage.User, error) {
+	session, err := db.SessionGet(storage.HashSecret(sessionID))
+	if err != nil {
+		return storage.User{}, err
+	}
+
+	return db.UserGet(session.UserID)
+}
+
+// PasteListByUser reads the "page" parameter from the URL query and returns the page of the logged in user pastes.
+func PasteListByUser(req *http.Request, db storage.Store) (ListPage, error) {
+	user, exist := RequestUser(req)
+	if exist == false {
+		return ListPage{}, ErrUnauthorized
+	}
+
+	var result ListPage
+	var err error
+
+	result.Page, err = readPage(req, ListPerPage)
+	if err != nil {
+		return ListPage{}, err
+	}
+
+	// Read one more paste to know if there is a next page
+	result.Pastes, err = db.PasteListByUser(user.ID, ListPerPage+1, (result.Page-1)*ListPerPage)
+	if err != nil {
+		return ListPage{}, err
+	}
+
+	if len(result.Pastes) > ListPerPage {
+		result.NextPage = true
+		result.Pastes = result.Pastes[:ListPerPage]
+	}
+
+	return result, nil
+}
+
+// PasteExtendFromForm sets the new lifetime of the logged in user paste from the "expiration" form field.
+func PasteExtendFromForm(req *http.Request, db storage.Store, pasteID string, maxLifeTime int64) (int64, error) {
+	// Check HTTP method
+	if req.Method != "POST" {
+		return 0, ErrMethodNotAllowed
+	}
+
+	req.ParseForm()
+
+	paste, err := db.PasteGet(pasteID)
+	if err != nil {
+		return 0, err
+	}
+
+	if userOwns(req, paste) == false {
+		return 0, ErrForbidden
+	}
+
+	deleteTime, err := readDeleteTime(req.PostForm, maxLifeTime)
+	if err != nil {
+		return 0, err
+	}
+
+	err = db.PasteSetDeleteTime(pasteID, deleteTime)
+	if err != nil {
+		return 0, err
+	}
+
+	return deleteTime, nil
+}
@@ -0,0 +1,66 @@
+// Copyright (C) 2021-2023 Leonid Maslakov.
+
+// This file is part of Lenpaste.
+
+// Lenpaste is free software: you can redistribute it
+// and/or modify it under the terms of the
+// GNU Affero Public License as published by the
+// Free Software Foundation, either version 3 of the License,
+// or (at your option) any later version.
+
+// Lenpaste is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+// or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero Public License for more details.
+
+// You should have received a copy of the GNU Affero Public License along with Lenpaste.
+// If not, see <https://www.gnu.org/licenses/>.
+
+package netshare
+
+import (
+	"errors"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/lcomrade/lenpaste/internal/storage"
+)
+
+func TestUserLoginRateLimit(t *testing.T) {
+	db := storage.NewMemory()
+
+	_, err := UserAdd(db, "alice", "password1", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rateSys := NewRateLimitSystem(2, 0, 0)
+
+	login := func(password string) error {
+		form := url.Values{"name": {"alice"}, "password": {password}}
+		req := httptest.NewRequest("POST", "/login", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		_, err := UserLoginFromForm(req, db, rateSys)
+		return err
+	}
+
+	err = login("password1")
+	if err != nil {
+		t.Fatal("expected login but got", err)
+	}
+
+	err = login("wrong")
+	if err != ErrWrongPassword {
+		t.Fatal("expected ErrWrongPassword but got", err)
+	}
+
+	// Right password does not help after the limit
+	var tooMany *ErrTooManyRequests
+	err = login("password1")
+	if errors.As(err, &tooMany) == false {
+		t.Error("expected ErrTooManyRequests but got", err)
+	}
+}
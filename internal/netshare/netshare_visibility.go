@@ -0,0 +1,81 @@
+// Copyright (C) 2021-2023 Leonid Maslakov.
+
+// This file is part of Lenpaste.
+
+// Lenpaste is free software: you can redistribute it
+// and/or modify it under the terms of the
+// GNU Affero Public License as published by the
+// Free Software Foundation, either version 3 of the License,
+// or (at your option) any later version.
+
+// Lenpaste is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+// or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero Public License for more details.
+
+// You should have received a copy of the GNU Affero Public License along with Lenpaste.
+// If not, see <https://www.gnu.org/licenses/>.
+
+package netshare
+
+import (
+	"crypto/subtle"
+	"github.com/lcomrade/lenpaste/internal/storage"
+	"net/http"
+	"net/url"
+)
+
+// PasteOwnerCookie returns the name of the cookie that gives access to the private paste.
+// The web interface sets it when the paste is created.
+func PasteOwnerCookie(pasteID string) string {
+	return "pasteOwner_" + pasteID
+}
+
+// PasteOwnerToken returns the value of the owner cookie.
+// It is derived from the edit token hash, so only the owner can get it.
+func PasteOwnerToken(pasteID string, editTokenHash string) string {
+	return storage.HashSecret(pasteID + ":owner:" + editTokenHash)
+}
+
+// readVisibility reads the paste visibility from the "visibility" form field.
+func readVisibility(form url.Values) (string, error) {
+	visibility := form.Get("visibility")
+
+	switch visibility {
+	case "":
+		return storage.VisibilityUnlisted, nil
+	case storage.VisibilityPrivate, storage.VisibilityUnlisted, storage.VisibilityPublic:
+		return visibility, nil
+	}
+
+	return "", ErrBadRequest
+}
+
+// PasteCheckVisibility checks access to the private paste.
+// Access is granted by the owner cookie, by the paste edit token in the "editToken" parameter,
+// by the API token with the "read-private" scope or to the logged in user who created the paste.
+// Private paste looks like a nonexistent one for everyone else.
+func PasteCheckVisibility(req *http.Request, paste storage.Paste) error {
+	if paste.Visibility != storage.VisibilityPrivate {
+		return nil
+	}
+
+	// Check owner cookie
+	cookie, err := req.Cookie(PasteOwnerCookie(paste.ID))
+	if err == nil && subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(PasteOwnerToken(paste.ID, paste.EditTokenHash))) == 1 {
+		return nil
+	}
+
+	// Check API token
+	if tokenAllows(req, paste, storage.ScopeReadPrivate) || userOwns(req, paste) {
+		return nil
+	}
+
+	// Check edit token
+	req.ParseForm()
+	if storage.CheckSecret(req.Form.Get("editToken"), paste.EditTokenHash) {
+		return nil
+	}
+
+	return ErrNotFound
+}
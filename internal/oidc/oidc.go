@@ -0,0 +1,213 @@
+// Copyright (C) 2021-2023 Leonid Maslakov.
+
+// This file is part of Lenpaste.
+
+// Lenpaste is free software: you can redistribute it
+// and/or modify it under the terms of the
+// GNU Affero Public License as published by the
+// Free Software Foundation, either version 3 of the License,
+// or (at your option) any later version.
+
+// Lenpaste is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+// or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero Public License for more details.
+
+// You should have received a copy of the GNU Affero Public License along with Lenpaste.
+// If not, see <https://www.gnu.org/licenses/>.
+
+// Package oidc implements the OpenID Connect authorization code flow with PKCE.
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Scopes requested from the identity provider.
+const Scopes = "openid email profile"
+
+var (
+	ErrBadIssuer     = errors.New("oidc: issuer in the discovery document does not match")
+	ErrBadDiscovery  = errors.New("oidc: discovery document does not contain required endpoints")
+	ErrNoIDToken     = errors.New("oidc: token response does not contain ID token")
+	ErrInvalidToken  = errors.New("oidc: invalid ID token")
+	ErrBadSignature  = errors.New("oidc: invalid ID token signature")
+	ErrUnknownKey    = errors.New("oidc: unknown ID token signing key")
+	ErrTokenExpired  = errors.New("oidc: ID token expired")
+	ErrBadAudience   = errors.New("oidc: ID token is issued for another client")
+	ErrNonceMismatch = errors.New("oidc: ID token nonce does not match")
+)
+
+// Provider is the OpenID Connect identity provider.
+type Provider struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+
+	AuthEndpoint  string
+	TokenEndpoint string
+	JWKSURI       string
+
+	client *http.Client
+
+	keysMu        sync.Mutex
+	keys          map[string]interface{} // Public keys by the key ID
+	keysFetchTime time.Time
+}
+
+type discoveryDoc struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type tokenResponse struct {
+	IDToken          string `json:"id_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// Discover reads the provider endpoints from the "/.well-known/openid-configuration" document of the issuer.
+func Discover(issuer string, clientID string, clientSecret string) (*Provider, error) {
+	p := Provider{
+		Issuer:       strings.TrimSuffix(issuer, "/"),
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		client:       &http.Client{Timeout: 10 * time.Second},
+	}
+
+	var doc discoveryDoc
+	err := p.getJSON(p.Issuer+"/.well-known/openid-configuration", &doc)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.TrimSuffix(doc.Issuer, "/") != p.Issuer {
+		return nil, ErrBadIssuer
+	}
+
+	if doc.AuthorizationEndpoint == "" || doc.TokenEndpoint == "" || doc.JWKSURI == "" {
+		return nil, ErrBadDiscovery
+	}
+
+	// Issuer is compared with the "iss" claim as is
+	p.Issuer = doc.Issuer
+	p.AuthEndpoint = doc.AuthorizationEndpoint
+	p.TokenEndpoint = doc.TokenEndpoint
+	p.JWKSURI = doc.JWKSURI
+
+	return &p, nil
+}
+
+func (p *Provider) getJSON(u string, v interface{}) error {
+	resp, err := p.client.Get(u)
+	if err != nil {
+		return errors.New("oidc: " + err.Error())
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return errors.New("oidc: GET " + u + ": " + resp.Status)
+	}
+
+	err = json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v)
+	if err != nil {
+		return errors.New("oidc: GET " + u + ": " + err.Error())
+	}
+
+	return nil
+}
+
+// NewRandom returns a random URL safe string that can be used as the state, nonce or PKCE code verifier.
+func NewRandom() (string, error) {
+	b := make([]byte, 32)
+
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// CodeChallenge returns the S256 PKCE code challenge of the code verifier.
+func CodeChallenge(verifier string) string {
+	hash := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(hash[:])
+}
+
+// AuthURL returns the URL the user is redirected to for login.
+func (p *Provider) AuthURL(redirectURL string, state string, nonce string, codeVerifier string) string {
+	query := url.Values{}
+	query.Set("response_type", "code")
+	query.Set("client_id", p.ClientID)
+	query.Set("redirect_uri", redirectURL)
+	query.Set("scope", Scopes)
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", CodeChallenge(codeVerifier))
+	query.Set("code_challenge_method", "S256")
+
+	sep := "?"
+	if strings.Contains(p.AuthEndpoint, "?") {
+		sep = "&"
+	}
+
+	return p.AuthEndpoint + sep + query.Encode()
+}
+
+// Exchange exchanges the authorization code for the ID token and returns its verified claims.
+func (p *Provider) Exchange(code string, codeVerifier string, redirectURL string, nonce string) (Claims, error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", redirectURL)
+	form.Set("code_verifier", codeVerifier)
+	form.Set("client_id", p.ClientID)
+
+	req, err := http.NewRequest("POST", p.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return Claims{}, err
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	// client_secret_basic, see RFC 6749 section 2.3.1
+	if p.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.ClientID), url.QueryEscape(p.ClientSecret))
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return Claims{}, errors.New("oidc: " + err.Error())
+	}
+	defer resp.Body.Close()
+
+	var token tokenResponse
+	err = json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&token)
+	if err != nil {
+		return Claims{}, errors.New("oidc: token response: " + resp.Status)
+	}
+
+	if token.Error != "" {
+		return Claims{}, errors.New("oidc: token response: " + token.Error + " " + token.ErrorDescription)
+	}
+
+	if token.IDToken == "" {
+		return Claims{}, ErrNoIDToken
+	}
+
+	return p.Verify(token.IDToken, nonce)
+}
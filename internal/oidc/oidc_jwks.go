@@ -0,0 +1,159 @@
+// Copyright (C) 2021-2023 Leonid Maslakov.
+
+// This file is part of Lenpaste.
+
+// Lenpaste is free software: you can redistribute it
+// and/or modify it under the terms of the
+// GNU Affero Public License as published by the
+// Free Software Foundation, either version 3 of the License,
+// or (at your option) any later version.
+
+// Lenpaste is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+// or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero Public License for more details.
+
+// You should have received a copy of the GNU Affero Public License along with Lenpaste.
+// If not, see <https://www.gnu.org/licenses/>.
+
+package oidc
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"math/big"
+	"time"
+)
+
+// Keys are fetched again if the token is signed with an unknown key,
+// but not more often than this period.
+const keysRefetchPeriod = time.Minute
+
+type jwks struct {
+	Keys []jwk `json:"keys"`
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// key returns the public key with the key ID.
+// Empty key ID matches the key if the provider has only one key.
+func (p *Provider) key(kid string) (interface{}, error) {
+	p.keysMu.Lock()
+	defer p.keysMu.Unlock()
+
+	key, ok := p.findKey(kid)
+	if ok {
+		return key, nil
+	}
+
+	// Provider could rotate keys
+	if time.Since(p.keysFetchTime) < keysRefetchPeriod {
+		return nil, ErrUnknownKey
+	}
+
+	err := p.fetchKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	key, ok = p.findKey(kid)
+	if ok == false {
+		return nil, ErrUnknownKey
+	}
+
+	return key, nil
+}
+
+func (p *Provider) findKey(kid string) (interface{}, bool) {
+	if kid == "" && len(p.keys) == 1 {
+		for _, key := range p.keys {
+			return key, true
+		}
+	}
+
+	key, ok := p.keys[kid]
+	return key, ok
+}
+
+func (p *Provider) fetchKeys() error {
+	p.keysFetchTime = time.Now()
+
+	var set jwks
+	err := p.getJSON(p.JWKSURI, &set)
+	if err != nil {
+		return err
+	}
+
+	keys := make(map[string]interface{})
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+
+		key, ok := k.publicKey()
+		if ok {
+			keys[k.Kid] = key
+		}
+	}
+
+	p.keys = keys
+
+	return nil
+}
+
+func decodeBigInt(s string) (*big.Int, bool) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(b) == 0 {
+		return nil, false
+	}
+
+	return new(big.Int).SetBytes(b), true
+}
+
+// publicKey returns the RSA or EC public key. Other key types are not supported.
+func (k jwk) publicKey() (interface{}, bool) {
+	switch k.Kty {
+	case "RSA":
+		n, okN := decodeBigInt(k.N)
+		e, okE := decodeBigInt(k.E)
+		if okN == false || okE == false || e.IsInt64() == false {
+			return nil, false
+		}
+
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, true
+
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, false
+		}
+
+		x, okX := decodeBigInt(k.X)
+		y, okY := decodeBigInt(k.Y)
+		if okX == false || okY == false || curve.IsOnCurve(x, y) == false {
+			return nil, false
+		}
+
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, true
+	}
+
+	return nil, false
+}
@@ -0,0 +1,285 @@
+// Copyright (C) 2021-2023 Leonid Maslakov.
+
+// This file is part of Lenpaste.
+
+// Lenpaste is free software: you can redistribute it
+// and/or modify it under the terms of the
+// GNU Affero Public License as published by the
+// Free Software Foundation, either version 3 of the License,
+// or (at your option) any later version.
+
+// Lenpaste is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+// or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero Public License for more details.
+
+// You should have received a copy of the GNU Affero Public License along with Lenpaste.
+// If not, see <https://www.gnu.org/licenses/>.
+
+package oidc
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// mockIssuer is a minimal identity provider that issues ID tokens for the single authorization code.
+type mockIssuer struct {
+	t      *testing.T
+	server *httptest.Server
+
+	rsaKey *rsa.PrivateKey
+	ecKey  *ecdsa.PrivateKey
+
+	code          string
+	codeChallenge string
+	claims        map[string]interface{}
+}
+
+func newMockIssuer(t *testing.T) *mockIssuer {
+	var err error
+	m := mockIssuer{t: t, code: "test-code"}
+
+	m.rsaKey, err = rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m.ecKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(rw http.ResponseWriter, req *http.Request) {
+		json.NewEncoder(rw).Encode(map[string]string{
+			"issuer":                 m.server.URL,
+			"authorization_endpoint": m.server.URL + "/authorize",
+			"token_endpoint":         m.server.URL + "/token",
+			"jwks_uri":               m.server.URL + "/jwks",
+		})
+	})
+
+	mux.HandleFunc("/jwks", func(rw http.ResponseWriter, req *http.Request) {
+		b64 := base64.RawURLEncoding.EncodeToString
+		json.NewEncoder(rw).Encode(map[string]interface{}{
+			"keys": []map[string]string{
+				{
+					"kty": "RSA", "kid": "rsa", "use": "sig",
+					"n": b64(m.rsaKey.N.Bytes()),
+					"e": b64(big.NewInt(int64(m.rsaKey.E)).Bytes()),
+				},
+				{
+					"kty": "EC", "kid": "ec", "use": "sig", "crv": "P-256",
+					"x": b64(m.ecKey.X.FillBytes(make([]byte, 32))),
+					"y": b64(m.ecKey.Y.FillBytes(make([]byte, 32))),
+				},
+			},
+		})
+	})
+
+	mux.HandleFunc("/token", func(rw http.ResponseWriter, req *http.Request) {
+		req.ParseForm()
+
+		// Client ID and secret are form encoded, see RFC 6749 section 2.3.1
+		id, secret, _ := req.BasicAuth()
+		id, _ = url.QueryUnescape(id)
+		secret, _ = url.QueryUnescape(secret)
+
+		if req.PostForm.Get("grant_type") != "authorization_code" || req.PostForm.Get("code") != m.code ||
+			CodeChallenge(req.PostForm.Get("code_verifier")) != m.codeChallenge ||
+			id != "lenpaste" || secret != "client secret" {
+			rw.WriteHeader(400)
+			json.NewEncoder(rw).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+
+		json.NewEncoder(rw).Encode(map[string]string{
+			"access_token": "access",
+			"token_type":   "Bearer",
+			"id_token":     m.sign("RS256", "rsa", m.claims),
+		})
+	})
+
+	m.server = httptest.NewServer(mux)
+	t.Cleanup(m.server.Close)
+
+	return &m
+}
+
+// validClaims returns the claims of the valid ID token.
+func (m *mockIssuer) validClaims(nonce string) map[string]interface{} {
+	return map[string]interface{}{
+		"iss":            m.server.URL,
+		"sub":            "248289761001",
+		"aud":            "lenpaste",
+		"exp":            time.Now().Add(time.Minute).Unix(),
+		"iat":            time.Now().Unix(),
+		"nonce":          nonce,
+		"email":          "alice@example.org",
+		"email_verified": true,
+	}
+}
+
+func (m *mockIssuer) sign(alg string, kid string, claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	hash := crypto.SHA256.New()
+	hash.Write([]byte(signed))
+
+	var sig []byte
+	var err error
+
+	switch alg {
+	case "RS256":
+		sig, err = rsa.SignPKCS1v15(rand.Reader, m.rsaKey, crypto.SHA256, hash.Sum(nil))
+
+	case "ES256":
+		var r, s *big.Int
+		r, s, err = ecdsa.Sign(rand.Reader, m.ecKey, hash.Sum(nil))
+		sig = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	}
+
+	if err != nil {
+		m.t.Fatal(err)
+	}
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func TestAuthorizationCodeFlow(t *testing.T) {
+	m := newMockIssuer(t)
+
+	p, err := Discover(m.server.URL+"/", "lenpaste", "client secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if p.TokenEndpoint != m.server.URL+"/token" {
+		t.Fatal("unexpected token endpoint:", p.TokenEndpoint)
+	}
+
+	state, err := NewRandom()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nonce, _ := NewRandom()
+	verifier, _ := NewRandom()
+	redirectURL := "https://paste.example.org/login/oidc/callback"
+
+	// User is redirected to the identity provider
+	authURL, err := url.Parse(p.AuthURL(redirectURL, state, nonce, verifier))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	query := authURL.Query()
+	if authURL.Path != "/authorize" || query.Get("state") != state || query.Get("nonce") != nonce ||
+		query.Get("code_challenge_method") != "S256" || query.Get("redirect_uri") != redirectURL ||
+		strings.Contains(query.Get("scope"), "email") == false {
+		t.Fatal("unexpected auth URL:", authURL)
+	}
+
+	m.codeChallenge = query.Get("code_challenge")
+	m.claims = m.validClaims(nonce)
+
+	// Wrong PKCE code verifier
+	_, err = p.Exchange(m.code, "wrong verifier", redirectURL, nonce)
+	if err == nil || strings.Contains(err.Error(), "invalid_grant") == false {
+		t.Error("expected invalid_grant error but got", err)
+	}
+
+	// Valid code
+	claims, err := p.Exchange(m.code, verifier, redirectURL, nonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if claims.Subject != "248289761001" || claims.VerifiedEmail() != "alice@example.org" {
+		t.Error("unexpected claims:", claims)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	m := newMockIssuer(t)
+
+	p, err := Discover(m.server.URL, "lenpaste", "client secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Valid tokens
+	_, err = p.Verify(m.sign("RS256", "rsa", m.validClaims("nonce")), "nonce")
+	if err != nil {
+		t.Error("RS256:", err)
+	}
+
+	_, err = p.Verify(m.sign("ES256", "ec", m.validClaims("nonce")), "nonce")
+	if err != nil {
+		t.Error("ES256:", err)
+	}
+
+	// Invalid tokens
+	tests := []struct {
+		name   string
+		modify func(claims map[string]interface{})
+		kid    string
+		expect error
+	}{
+		{"wrong issuer", func(c map[string]interface{}) { c["iss"] = "https://evil.example.org" }, "rsa", ErrInvalidToken},
+		{"wrong audience", func(c map[string]interface{}) { c["aud"] = "other" }, "rsa", ErrBadAudience},
+		{"wrong azp", func(c map[string]interface{}) { c["aud"] = []string{"lenpaste", "other"}; c["azp"] = "other" }, "rsa", ErrBadAudience},
+		{"expired", func(c map[string]interface{}) { c["exp"] = time.Now().Add(-time.Hour).Unix() }, "rsa", ErrTokenExpired},
+		{"wrong nonce", func(c map[string]interface{}) { c["nonce"] = "other" }, "rsa", ErrNonceMismatch},
+		{"unknown key", func(c map[string]interface{}) {}, "unknown", ErrUnknownKey},
+	}
+
+	for _, test := range tests {
+		claims := m.validClaims("nonce")
+		test.modify(claims)
+
+		_, err = p.Verify(m.sign("RS256", test.kid, claims), "nonce")
+		if err != test.expect {
+			t.Errorf("%s: expected %v but got %v", test.name, test.expect, err)
+		}
+	}
+
+	// RSA signature checked with the EC key
+	rawToken := m.sign("RS256", "rsa", m.validClaims("nonce"))
+	parts := strings.Split(rawToken, ".")
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","kid":"ec"}`))
+
+	_, err = p.Verify(header+"."+parts[1]+"."+parts[2], "nonce")
+	if err != ErrBadSignature {
+		t.Error("expected ErrBadSignature but got", err)
+	}
+
+	// Modified payload
+	payload, _ := json.Marshal(m.validClaims("other"))
+	_, err = p.Verify(parts[0]+"."+base64.RawURLEncoding.EncodeToString(payload)+"."+parts[2], "other")
+	if err != ErrBadSignature {
+		t.Error("expected ErrBadSignature but got", err)
+	}
+
+	// Unsigned token
+	none := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`))
+	_, err = p.Verify(none+"."+parts[1]+".", "nonce")
+	if err != ErrInvalidToken {
+		t.Error("expected ErrInvalidToken but got", err)
+	}
+}
@@ -0,0 +1,220 @@
+// Copyright (C) 2021-2023 Leonid Maslakov.
+
+// This file is part of Lenpaste.
+
+// Lenpaste is free software: you can redistribute it
+// and/or modify it under the terms of the
+// GNU Affero Public License as published by the
+// Free Software Foundation, either version 3 of the License,
+// or (at your option) any later version.
+
+// Lenpaste is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+// or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero Public License for more details.
+
+// You should have received a copy of the GNU Affero Public License along with Lenpaste.
+// If not, see <https://www.gnu.org/licenses/>.
+
+package oidc
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// Allowed clock difference between Lenpaste and the identity provider.
+const clockSkew = 60 // 1 minute
+
+// Claims are the ID token claims used by Lenpaste.
+type Claims struct {
+	Issuer          string   `json:"iss"`
+	Subject         string   `json:"sub"`
+	Audience        audience `json:"aud"`
+	AuthorizedParty string   `json:"azp"`
+	ExpireTime      int64    `json:"exp"`
+	IssuedAt        int64    `json:"iat"`
+	Nonce           string   `json:"nonce"`
+	Email           string   `json:"email"`
+	EmailVerified   *bool    `json:"email_verified"`
+	Name            string   `json:"name"`
+}
+
+// VerifiedEmail returns the email of the user.
+// It is empty if the identity provider marked the email as not verified.
+func (c Claims) VerifiedEmail() string {
+	if c.EmailVerified != nil && *c.EmailVerified == false {
+		return ""
+	}
+
+	return c.Email
+}
+
+// audience is the "aud" claim which can be a string or an array of strings.
+type audience []string
+
+func (a *audience) UnmarshalJSON(b []byte) error {
+	var s string
+	if json.Unmarshal(b, &s) == nil {
+		*a = audience{s}
+		return nil
+	}
+
+	var list []string
+	err := json.Unmarshal(b, &list)
+	if err != nil {
+		return err
+	}
+
+	*a = list
+	return nil
+}
+
+func (a audience) contains(s string) bool {
+	for _, v := range a {
+		if v == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// Signature algorithms of the ID token.
+var jwtAlgs = map[string]crypto.Hash{
+	"RS256": crypto.SHA256,
+	"RS384": crypto.SHA384,
+	"RS512": crypto.SHA512,
+	"ES256": crypto.SHA256,
+	"ES384": crypto.SHA384,
+	"ES512": crypto.SHA512,
+}
+
+func decodeSegment(s string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return ErrInvalidToken
+	}
+
+	err = json.Unmarshal(b, v)
+	if err != nil {
+		return ErrInvalidToken
+	}
+
+	return nil
+}
+
+// Verify checks the ID token signature, issuer, audience, expiration time and nonce.
+func (p *Provider) Verify(rawToken string, nonce string) (Claims, error) {
+	parts := strings.Split(rawToken, ".")
+	if len(parts) != 3 {
+		return Claims{}, ErrInvalidToken
+	}
+
+	// Check signature
+	var header jwtHeader
+	err := decodeSegment(parts[0], &header)
+	if err != nil {
+		return Claims{}, err
+	}
+
+	hashAlg, ok := jwtAlgs[header.Alg]
+	if ok == false {
+		return Claims{}, ErrInvalidToken
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return Claims{}, ErrInvalidToken
+	}
+
+	key, err := p.key(header.Kid)
+	if err != nil {
+		return Claims{}, err
+	}
+
+	hash := hashAlg.New()
+	hash.Write([]byte(parts[0] + "." + parts[1]))
+
+	err = verifySignature(header.Alg, key, hashAlg, hash.Sum(nil), sig)
+	if err != nil {
+		return Claims{}, err
+	}
+
+	// Check claims
+	var claims Claims
+	err = decodeSegment(parts[1], &claims)
+	if err != nil {
+		return Claims{}, err
+	}
+
+	if claims.Issuer != p.Issuer || claims.Subject == "" {
+		return Claims{}, ErrInvalidToken
+	}
+
+	if claims.Audience.contains(p.ClientID) == false {
+		return Claims{}, ErrBadAudience
+	}
+
+	if len(claims.Audience) > 1 && claims.AuthorizedParty != "" && claims.AuthorizedParty != p.ClientID {
+		return Claims{}, ErrBadAudience
+	}
+
+	now := time.Now().Unix()
+	if claims.ExpireTime+clockSkew < now || claims.IssuedAt-clockSkew > now {
+		return Claims{}, ErrTokenExpired
+	}
+
+	if claims.Nonce != nonce {
+		return Claims{}, ErrNonceMismatch
+	}
+
+	return claims, nil
+}
+
+func verifySignature(alg string, key interface{}, hashAlg crypto.Hash, hashed []byte, sig []byte) error {
+	switch alg[:2] {
+	case "RS":
+		pub, ok := key.(*rsa.PublicKey)
+		if ok == false {
+			return ErrBadSignature
+		}
+
+		if rsa.VerifyPKCS1v15(pub, hashAlg, hashed, sig) != nil {
+			return ErrBadSignature
+		}
+
+	case "ES":
+		pub, ok := key.(*ecdsa.PublicKey)
+		if ok == false {
+			return ErrBadSignature
+		}
+
+		// Signature is R and S of the key size each
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		if len(sig) != 2*size {
+			return ErrBadSignature
+		}
+
+		r := new(big.Int).SetBytes(sig[:size])
+		s := new(big.Int).SetBytes(sig[size:])
+		if ecdsa.Verify(pub, hashed, r, s) == false {
+			return ErrBadSignature
+		}
+	}
+
+	return nil
+}
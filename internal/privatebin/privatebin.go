@@ -0,0 +1,97 @@
+// Copyright (C) 2021-2023 Leonid Maslakov.
+
+// This file is part of Lenpaste.
+
+// Lenpaste is free software: you can redistribute it
+// and/or modify it under the terms of the
+// GNU Affero Public License as published by the
+// Free Software Foundation, either version 3 of the License,
+// or (at your option) any later version.
+
+// Lenpaste is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+// or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero Public License for more details.
+
+// You should have received a copy of the GNU Affero Public License along with Lenpaste.
+// If not, see <https://www.gnu.org/licenses/>.
+
+package privatebin
+
+import (
+	"github.com/lcomrade/lenpaste/internal/config"
+	"github.com/lcomrade/lenpaste/internal/logger"
+	"github.com/lcomrade/lenpaste/internal/netshare"
+	"github.com/lcomrade/lenpaste/internal/storage"
+	"net/http"
+)
+
+// Data serves the JSON API of PrivateBin (format version 2).
+// Pastes are encrypted by the client, the server keeps them as encrypted Lenpaste pastes.
+// Discussions are not supported.
+type Data struct {
+	Log logger.Logger
+	DB  storage.Store
+
+	RateLimitNew *netshare.RateLimitSystem
+	RateLimitGet *netshare.RateLimitSystem
+
+	Version string
+
+	BodyMaxLen  int
+	MaxLifeTime int64
+
+	Auth netshare.Auth
+}
+
+func Load(db storage.Store, cfg config.Config) *Data {
+	return &Data{
+		DB:           db,
+		Log:          cfg.Log,
+		RateLimitNew: cfg.RateLimitNew,
+		RateLimitGet: cfg.RateLimitGet,
+		Version:      cfg.Version,
+		BodyMaxLen:   cfg.BodyMaxLen,
+		MaxLifeTime:  cfg.MaxLifeTime,
+		Auth:         cfg.Auth,
+	}
+}
+
+// IsRequest reports whether the request is sent to the PrivateBin JSON API.
+// PrivateBin clients send all API requests to / with the X-Requested-With header.
+func IsRequest(req *http.Request) bool {
+	return req.URL.Path == "/" && req.Header.Get("X-Requested-With") == "JSONHttpRequest"
+}
+
+func (data *Data) Hand(rw http.ResponseWriter, req *http.Request) {
+	// Process request
+	var err error
+
+	rw.Header().Set("Server", config.Software+"/"+data.Version)
+
+	// Check API token
+	req, err = netshare.AuthRequest(req, data.DB)
+	if err == nil {
+		switch req.Method {
+		case "GET":
+			err = data.getHand(rw, req)
+		case "POST":
+			err = data.postHand(rw, req)
+		default:
+			err = netshare.ErrMethodNotAllowed
+		}
+	}
+
+	// Log
+	if err == nil {
+		data.Log.HttpRequest(req, 200)
+
+	} else {
+		code, err := data.writeError(rw, req, err)
+		if err != nil {
+			data.Log.HttpError(req, err)
+		} else {
+			data.Log.HttpRequest(req, code)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright (C) 2021-2023 Leonid Maslakov.
+
+// This file is part of Lenpaste.
+
+// Lenpaste is free software: you can redistribute it
+// and/or modify it under the terms of the
+// GNU Affero Public License as published by the
+// Free Software Foundation, either version 3 of the License,
+// or (at your option) any later version.
+
+// Lenpaste is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+// or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero Public License for more details.
+
+// You should have received a copy of the GNU Affero Public License along with Lenpaste.
+// If not, see <https://www.gnu.org/licenses/>.
+
+package privatebin
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/lcomrade/lenpaste/internal/netshare"
+	"github.com/lcomrade/lenpaste/internal/storage"
+	"net/http"
+	"strconv"
+)
+
+var (
+	errWrongDeleteToken    = errors.New("privatebin: wrong delete token")
+	errDiscussionsDisabled = errors.New("privatebin: discussions are not supported")
+)
+
+// PrivateBin returns errors with the 200 status code, the status field of the answer is 1.
+type errorType struct {
+	Status  int    `json:"status"`
+	Message string `json:"message"`
+}
+
+func (data *Data) writeError(rw http.ResponseWriter, req *http.Request, e error) (int, error) {
+	resp := errorType{Status: 1}
+
+	var eTmp429 *netshare.ErrTooManyRequests
+	var eTmpBan *netshare.ErrBanned
+
+	if e == netshare.ErrBadRequest {
+		resp.Message = "Invalid data."
+
+	} else if e == netshare.ErrUnauthorized {
+		rw.Header().Add("WWW-Authenticate", "Basic")
+		resp.Message = "Unauthorized."
+
+	} else if e == netshare.ErrForbidden {
+		resp.Message = "Forbidden."
+
+	} else if errors.As(e, &eTmpBan) {
+		resp.Message = netshare.BanMessage(eTmpBan)
+
+	} else if e == storage.ErrNotFoundID || e == netshare.ErrNotFound {
+		resp.Message = "Paste does not exist, has expired or has been deleted."
+
+	} else if e == errWrongDeleteToken {
+		resp.Message = "Wrong deletion token. Paste was not deleted."
+
+	} else if e == errDiscussionsDisabled {
+		resp.Message = "Discussions are not supported by this server."
+
+	} else if e == netshare.ErrMethodNotAllowed {
+		resp.Message = "Method Not Allowed."
+
+	} else if e == netshare.ErrPayloadTooLarge {
+		resp.Message = "Paste is limited to " + strconv.Itoa(netshare.EncryptedBodyMaxLen(data.BodyMaxLen)) + " bytes of encrypted data."
+
+	} else if errors.As(e, &eTmp429) {
+		resp.Message = "Please wait " + strconv.FormatInt(eTmp429.RetryAfter, 10) + " seconds between each post."
+		rw.Header().Set("Retry-After", strconv.FormatInt(eTmp429.RetryAfter, 10))
+
+	} else {
+		resp.Message = "Error saving paste. Sorry."
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+
+	err := json.NewEncoder(rw).Encode(resp)
+	if err != nil {
+		return 500, err
+	}
+
+	return 200, nil
+}
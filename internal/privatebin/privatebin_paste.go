@@ -0,0 +1,354 @@
+// Copyright (C) 2021-2023 Leonid Maslakov.
+
+// This file is part of Lenpaste.
+
+// Lenpaste is free software: you can redistribute it
+// and/or modify it under the terms of the
+// GNU Affero Public License as published by the
+// Free Software Foundation, either version 3 of the License,
+// or (at your option) any later version.
+
+// Lenpaste is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+// or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero Public License for more details.
+
+// You should have received a copy of the GNU Affero Public License along with Lenpaste.
+// If not, see <https://www.gnu.org/licenses/>.
+
+package privatebin
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"github.com/lcomrade/lenpaste/internal/netshare"
+	"github.com/lcomrade/lenpaste/internal/storage"
+	"io"
+	"net/http"
+	"time"
+)
+
+const (
+	formatVersion   = 2         // Supported PrivateBin paste format
+	defaultExpire   = "1week"   // PrivateBin default expiration
+	maxRequestExtra = 64 * 1024 // Max length of the request without the ciphertext
+)
+
+// expireValues are the PrivateBin expiration options in seconds, 0 means never.
+var expireValues = map[string]int64{
+	"5min":   5 * 60,
+	"10min":  10 * 60,
+	"1hour":  60 * 60,
+	"1day":   24 * 60 * 60,
+	"1week":  7 * 24 * 60 * 60,
+	"1month": 30 * 24 * 60 * 60,
+	"1year":  365 * 24 * 60 * 60,
+	"never":  0,
+}
+
+type request struct {
+	V     int             `json:"v"`
+	AData json.RawMessage `json:"adata"`
+	CT    string          `json:"ct"`
+	Meta  struct {
+		Expire string `json:"expire"`
+	} `json:"meta"`
+
+	PasteID     string `json:"pasteid"`
+	ParentID    string `json:"parentid"`
+	DeleteToken string `json:"deletetoken"`
+}
+
+// storedPaste is saved to the paste body.
+type storedPaste struct {
+	V     int             `json:"v"`
+	AData json.RawMessage `json:"adata"`
+	CT    string          `json:"ct"`
+}
+
+type createAnswer struct {
+	Status      int    `json:"status"`
+	ID          string `json:"id"`
+	URL         string `json:"url"`
+	DeleteToken string `json:"deletetoken"`
+}
+
+type pasteMeta struct {
+	Created    int64 `json:"created"`
+	TimeToLive int64 `json:"time_to_live,omitempty"`
+}
+
+type pasteAnswer struct {
+	Status        int             `json:"status"`
+	ID            string          `json:"id"`
+	URL           string          `json:"url"`
+	V             int             `json:"v"`
+	AData         json.RawMessage `json:"adata"`
+	CT            string          `json:"ct"`
+	Meta          pasteMeta       `json:"meta"`
+	Comments      []struct{}      `json:"comments"`
+	CommentCount  int             `json:"comment_count"`
+	CommentOffset int             `json:"comment_offset"`
+	Context       string          `json:"@context"`
+}
+
+type deleteAnswer struct {
+	Status int    `json:"status"`
+	ID     string `json:"id"`
+}
+
+// GET /?<pasteid> or /?pasteid=<pasteid>[&deletetoken=<token>]
+func (data *Data) getHand(rw http.ResponseWriter, req *http.Request) error {
+	query := req.URL.Query()
+
+	pasteID := query.Get("pasteid")
+	if pasteID == "" {
+		pasteID = req.URL.RawQuery
+	}
+
+	if pasteID == "" {
+		return netshare.ErrBadRequest
+	}
+
+	deleteToken := query.Get("deletetoken")
+	if deleteToken != "" {
+		return data.delete(rw, req, pasteID, deleteToken)
+	}
+
+	// Check rate limit
+	err := data.RateLimitGet.CheckAndUse(netshare.GetClientAddr(req))
+	if err != nil {
+		return err
+	}
+
+	// Get paste
+	paste, err := data.DB.PasteGet(pasteID)
+	if err != nil {
+		return err
+	}
+
+	// Only pastes created by PrivateBin clients can be read
+	stored, err := readStoredPaste(paste)
+	if err != nil {
+		return err
+	}
+
+	// "Burn after reading" paste
+	if paste.OneUse == true {
+		err = data.DB.PasteDelete(pasteID)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Return response
+	answer := pasteAnswer{
+		ID:       paste.ID,
+		URL:      "/?" + paste.ID,
+		V:        stored.V,
+		AData:    stored.AData,
+		CT:       stored.CT,
+		Meta:     pasteMeta{Created: paste.CreateTime},
+		Comments: []struct{}{},
+		Context:  "?jsonld=paste",
+	}
+
+	if paste.DeleteTime > 0 {
+		answer.Meta.TimeToLive = paste.DeleteTime - time.Now().Unix()
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(rw).Encode(answer)
+}
+
+// POST /
+func (data *Data) postHand(rw http.ResponseWriter, req *http.Request) error {
+	// Read request
+	maxLen := int64(-1)
+	body := io.Reader(req.Body)
+
+	if data.BodyMaxLen > 0 {
+		maxLen = int64(netshare.EncryptedBodyMaxLen(data.BodyMaxLen)) + maxRequestExtra
+		body = io.LimitReader(req.Body, maxLen+1)
+	}
+
+	raw, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+
+	if maxLen >= 0 && int64(len(raw)) > maxLen {
+		return netshare.ErrPayloadTooLarge
+	}
+
+	var pbReq request
+	err = json.Unmarshal(raw, &pbReq)
+	if err != nil {
+		return netshare.ErrBadRequest
+	}
+
+	// Delete paste
+	if pbReq.PasteID != "" && pbReq.DeleteToken != "" {
+		return data.delete(rw, req, pbReq.PasteID, pbReq.DeleteToken)
+	}
+
+	// Comments
+	if pbReq.PasteID != "" || pbReq.ParentID != "" {
+		return errDiscussionsDisabled
+	}
+
+	return data.create(rw, req, pbReq)
+}
+
+func (data *Data) create(rw http.ResponseWriter, req *http.Request, pbReq request) error {
+	var err error
+
+	// Check auth
+	err = netshare.CheckCreateAuth(req, data.Auth)
+	if err != nil {
+		return err
+	}
+
+	// Check rate limit and ban list
+	clientAddr := netshare.GetClientAddr(req)
+
+	err = data.RateLimitNew.CheckAndUse(clientAddr)
+	if err != nil {
+		return err
+	}
+
+	err = netshare.CheckBan(data.DB, clientAddr)
+	if err != nil {
+		return err
+	}
+
+	// Check paste
+	if pbReq.V != formatVersion || pbReq.CT == "" {
+		return netshare.ErrBadRequest
+	}
+
+	maxLen := netshare.EncryptedBodyMaxLen(data.BodyMaxLen)
+	if len(pbReq.CT) > maxLen && maxLen > 0 {
+		return netshare.ErrPayloadTooLarge
+	}
+
+	_, err = base64.StdEncoding.DecodeString(pbReq.CT)
+	if err != nil {
+		return netshare.ErrBadRequest
+	}
+
+	// adata is [cipher parameters, format, open discussion, burn after reading]
+	var adata []json.RawMessage
+	err = json.Unmarshal(pbReq.AData, &adata)
+	if err != nil || len(adata) != 4 {
+		return netshare.ErrBadRequest
+	}
+
+	var burnAfterReading int
+	err = json.Unmarshal(adata[3], &burnAfterReading)
+	if err != nil {
+		return netshare.ErrBadRequest
+	}
+
+	// Get delete time
+	if pbReq.Meta.Expire == "" {
+		pbReq.Meta.Expire = defaultExpire
+	}
+
+	expire, ok := expireValues[pbReq.Meta.Expire]
+	if ok == false {
+		return netshare.ErrBadRequest
+	}
+
+	if data.MaxLifeTime > 0 && (expire <= 0 || expire > data.MaxLifeTime) {
+		return netshare.ErrBadRequest
+	}
+
+	// Prepare paste
+	body, err := json.Marshal(storedPaste{V: pbReq.V, AData: pbReq.AData, CT: pbReq.CT})
+	if err != nil {
+		return err
+	}
+
+	// Paste created with the API token can be deleted with it
+	token, _ := netshare.RequestToken(req)
+
+	paste := storage.Paste{
+		Body:       string(body),
+		Syntax:     "plaintext",
+		Encrypted:  true,
+		OneUse:     burnAfterReading == 1,
+		Visibility: storage.VisibilityUnlisted,
+		TokenID:    token.ID,
+	}
+
+	if clientAddr != nil {
+		paste.CreatorIP = clientAddr.String()
+	}
+
+	if expire > 0 {
+		paste.DeleteTime = time.Now().Unix() + expire
+	}
+
+	var answer createAnswer
+
+	answer.DeleteToken, paste.DeleteTokenHash, err = storage.NewSecret()
+	if err != nil {
+		return err
+	}
+
+	// Edit token is never shown, PrivateBin pastes can not be edited
+	_, paste.EditTokenHash, err = storage.NewSecret()
+	if err != nil {
+		return err
+	}
+
+	// Create paste
+	answer.ID, _, _, err = data.DB.PasteAdd(paste)
+	if err != nil {
+		return err
+	}
+
+	answer.URL = "/?" + answer.ID
+
+	rw.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(rw).Encode(answer)
+}
+
+func (data *Data) delete(rw http.ResponseWriter, req *http.Request, pasteID string, deleteToken string) error {
+	// Check rate limit
+	err := data.RateLimitNew.CheckAndUse(netshare.GetClientAddr(req))
+	if err != nil {
+		return err
+	}
+
+	// Delete paste
+	err = netshare.PasteDeleteByToken(req, data.DB, pasteID, deleteToken)
+	if err != nil {
+		if err == netshare.ErrForbidden {
+			return errWrongDeleteToken
+		}
+
+		return err
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(rw).Encode(deleteAnswer{ID: pasteID})
+}
+
+// readStoredPaste reads the PrivateBin paste from the paste body.
+// Other pastes look like nonexistent ones.
+func readStoredPaste(paste storage.Paste) (storedPaste, error) {
+	var stored storedPaste
+
+	if paste.Encrypted == false {
+		return stored, netshare.ErrNotFound
+	}
+
+	err := json.Unmarshal([]byte(paste.Body), &stored)
+	if err != nil || stored.V != formatVersion || stored.CT == "" {
+		return stored, netshare.ErrNotFound
+	}
+
+	return stored, nil
+}
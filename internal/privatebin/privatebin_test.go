@@ -0,0 +1,172 @@
+// Copyright (C) 2021-2023 Leonid Maslakov.
+
+// This file is part of Lenpaste.
+
+// Lenpaste is free software: you can redistribute it
+// and/or modify it under the terms of the
+// GNU Affero Public License as published by the
+// Free Software Foundation, either version 3 of the License,
+// or (at your option) any later version.
+
+// Lenpaste is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+// or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero Public License for more details.
+
+// You should have received a copy of the GNU Affero Public License along with Lenpaste.
+// If not, see <https://www.gnu.org/licenses/>.
+
+package privatebin
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/lcomrade/lenpaste/internal/config"
+	"github.com/lcomrade/lenpaste/internal/logger"
+	"github.com/lcomrade/lenpaste/internal/netshare"
+	"github.com/lcomrade/lenpaste/internal/storage"
+)
+
+const testAData = `[["c2FsdA==","aXY=",100000,256,128,"aes","gcm","zlib"],"plaintext",0,%d]`
+
+func newTestData() *Data {
+	return Load(storage.NewMemory(), config.Config{
+		Log:          logger.New("2006/01/02 15:04:05"),
+		RateLimitNew: netshare.NewRateLimitSystem(0, 0, 0),
+		RateLimitGet: netshare.NewRateLimitSystem(0, 0, 0),
+		BodyMaxLen:   20000,
+		MaxLifeTime:  -1,
+	})
+}
+
+func doRequest(t *testing.T, data *Data, method string, target string, body string, v interface{}) {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("X-Requested-With", "JSONHttpRequest")
+
+	if IsRequest(req) == false {
+		t.Fatal("request is not detected")
+	}
+
+	rw := httptest.NewRecorder()
+	data.Hand(rw, req)
+
+	if rw.Code != 200 {
+		t.Fatal("expected 200 but got", rw.Code)
+	}
+
+	err := json.NewDecoder(rw.Body).Decode(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCreateReadDelete(t *testing.T) {
+	data := newTestData()
+
+	// Create paste
+	adata := strings.Replace(testAData, "%d", "0", 1)
+
+	var created createAnswer
+	doRequest(t, data, "POST", "/", `{"v":2,"adata":`+adata+`,"ct":"Y2lwaGVydGV4dA==","meta":{"expire":"1day"}}`, &created)
+
+	if created.Status != 0 || created.ID == "" || created.URL != "/?"+created.ID || created.DeleteToken == "" {
+		t.Fatal("unexpected answer:", created)
+	}
+
+	// Read paste
+	var paste pasteAnswer
+	doRequest(t, data, "GET", "/?"+created.ID, "", &paste)
+
+	if paste.Status != 0 || paste.CT != "Y2lwaGVydGV4dA==" || paste.V != 2 || paste.Meta.TimeToLive <= 0 {
+		t.Error("unexpected paste:", paste)
+	}
+
+	var gotAData, expAData interface{}
+	json.Unmarshal(paste.AData, &gotAData)
+	json.Unmarshal([]byte(adata), &expAData)
+	if string(mustMarshal(gotAData)) != string(mustMarshal(expAData)) {
+		t.Error("adata is changed:", string(paste.AData))
+	}
+
+	// Delete with wrong token
+	var errResp errorType
+	doRequest(t, data, "POST", "/", `{"pasteid":"`+created.ID+`","deletetoken":"wrong"}`, &errResp)
+	if errResp.Status != 1 {
+		t.Fatal("paste is deleted with wrong token")
+	}
+
+	// Delete paste
+	var deleted deleteAnswer
+	doRequest(t, data, "POST", "/", `{"pasteid":"`+created.ID+`","deletetoken":"`+created.DeleteToken+`"}`, &deleted)
+	if deleted.Status != 0 || deleted.ID != created.ID {
+		t.Fatal("unexpected answer:", deleted)
+	}
+
+	errResp = errorType{}
+	doRequest(t, data, "GET", "/?pasteid="+created.ID, "", &errResp)
+	if errResp.Status != 1 {
+		t.Error("paste is not deleted")
+	}
+}
+
+func TestBurnAfterReading(t *testing.T) {
+	data := newTestData()
+
+	var created createAnswer
+	doRequest(t, data, "POST", "/", `{"v":2,"adata":`+strings.Replace(testAData, "%d", "1", 1)+`,"ct":"Y2lwaGVydGV4dA==","meta":{"expire":"never"}}`, &created)
+
+	var paste pasteAnswer
+	doRequest(t, data, "GET", "/?"+created.ID, "", &paste)
+	if paste.Status != 0 {
+		t.Fatal("unexpected paste:", paste)
+	}
+
+	var errResp errorType
+	doRequest(t, data, "GET", "/?"+created.ID, "", &errResp)
+	if errResp.Status != 1 {
+		t.Error("paste is not deleted after reading")
+	}
+}
+
+func TestInvalid(t *testing.T) {
+	data := newTestData()
+
+	for _, body := range []string{
+		`{"v":1,"adata":` + strings.Replace(testAData, "%d", "0", 1) + `,"ct":"Y2lwaGVydGV4dA=="}`,
+		`{"v":2,"adata":[],"ct":"Y2lwaGVydGV4dA=="}`,
+		`{"v":2,"adata":` + strings.Replace(testAData, "%d", "0", 1) + `,"ct":"not base64"}`,
+		`{"v":2,"adata":` + strings.Replace(testAData, "%d", "0", 1) + `,"ct":"Y2lwaGVydGV4dA==","meta":{"expire":"1century"}}`,
+		`{"v":2,"pasteid":"abc","parentid":"abc","adata":[],"ct":"Y2lwaGVydGV4dA=="}`,
+		`not json`,
+	} {
+		var errResp errorType
+		doRequest(t, data, "POST", "/", body, &errResp)
+		if errResp.Status != 1 || errResp.Message == "" {
+			t.Error("invalid request is accepted:", body)
+		}
+	}
+
+	// Lenpaste pastes can not be read
+	pasteID, _, _, err := data.DB.PasteAdd(storage.Paste{Body: "text", Syntax: "plaintext"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var errResp errorType
+	doRequest(t, data, "GET", "/?"+pasteID, "", &errResp)
+	if errResp.Status != 1 {
+		t.Error("Lenpaste paste is returned")
+	}
+}
+
+func mustMarshal(v interface{}) []byte {
+	b, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+
+	return b
+}
@@ -19,6 +19,7 @@
 package raw
 
 import (
+	chromaLexers "github.com/alecthomas/chroma/v2/lexers"
 	"github.com/lcomrade/lenpaste/internal/config"
 	"github.com/lcomrade/lenpaste/internal/logger"
 	"github.com/lcomrade/lenpaste/internal/netshare"
@@ -27,27 +28,57 @@ import (
 )
 
 type Data struct {
-	DB  storage.DB
+	DB  storage.Store
 	Log logger.Logger
 
+	RateLimitNew *netshare.RateLimitSystem
 	RateLimitGet *netshare.RateLimitSystem
 
+	Lexers []string
+
 	Version string
+
+	TitleMaxLen int
+	BodyMaxLen  int
+	MaxLifeTime int64
+
+	Auth netshare.Auth
 }
 
-func Load(db storage.DB, cfg config.Config) *Data {
+func Load(db storage.Store, cfg config.Config) *Data {
 	return &Data{
 		DB:           db,
 		Log:          cfg.Log,
+		RateLimitNew: cfg.RateLimitNew,
 		RateLimitGet: cfg.RateLimitGet,
+		Lexers:       chromaLexers.Names(false),
 		Version:      cfg.Version,
+		TitleMaxLen:  cfg.TitleMaxLen,
+		BodyMaxLen:   cfg.BodyMaxLen,
+		MaxLifeTime:  cfg.MaxLifeTime,
+		Auth:         cfg.Auth,
 	}
 }
 
 func (data *Data) Hand(rw http.ResponseWriter, req *http.Request) {
 	rw.Header().Set("Server", config.Software+"/"+data.Version)
 
-	err := data.rawHand(rw, req)
+	var err error
+
+	// Check API token and ban list
+	req, err = netshare.AuthRequest(req, data.DB)
+	if err == nil {
+		err = netshare.CheckBanRequest(req, data.DB)
+	}
+
+	if err == nil {
+		switch req.URL.Path {
+		case "/", "/raw":
+			err = data.uploadHand(rw, req)
+		default:
+			err = data.rawHand(rw, req)
+		}
+	}
 
 	if err == nil {
 		data.Log.HttpRequest(req, 200)
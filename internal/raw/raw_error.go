@@ -33,11 +33,46 @@ func (data *Data) writeError(rw http.ResponseWriter, req *http.Request, e error)
 
 	// Dectect error
 	var eTmp429 *netshare.ErrTooManyRequests
+	var eTmpBan *netshare.ErrBanned
 
-	if e == storage.ErrNotFoundID && e == netshare.ErrNotFound {
+	if e == netshare.ErrBadRequest {
+		errCode = 400
+		errText = "400 Bad Request"
+
+	} else if e == netshare.ErrUnauthorized {
+		rw.Header().Add("WWW-Authenticate", "Basic")
+		errCode = 401
+		errText = "401 Unauthorized"
+
+	} else if e == netshare.ErrPasswordRequired {
+		rw.Header().Add("WWW-Authenticate", "Basic")
+		errCode = 401
+		errText = "401 Password Required"
+
+	} else if e == netshare.ErrForbidden {
+		errCode = 403
+		errText = "403 Forbidden"
+
+	} else if e == netshare.ErrWrongPassword {
+		errCode = 403
+		errText = "403 Wrong Password"
+
+	} else if errors.As(e, &eTmpBan) {
+		errCode = 403
+		errText = netshare.BanMessage(eTmpBan)
+
+	} else if e == storage.ErrNotFoundID || e == storage.ErrNotFoundRevision || e == netshare.ErrNotFound {
 		errCode = 404
 		errText = "404 Not Found"
 
+	} else if e == netshare.ErrMethodNotAllowed {
+		errCode = 405
+		errText = "405 Method Not Allowed"
+
+	} else if e == netshare.ErrPayloadTooLarge {
+		errCode = 413
+		errText = "413 Payload Too Large"
+
 	} else if errors.As(e, &eTmp429) {
 		errCode = 429
 		errText = "429 Too Many Requests"
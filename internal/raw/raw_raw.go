@@ -22,9 +22,10 @@ import (
 	"github.com/lcomrade/lenpaste/internal/netshare"
 	"io"
 	"net/http"
+	"strings"
 )
 
-// Pattern: /raw/
+// Pattern: /raw/<id> or /raw/<id>/<file name>
 func (data *Data) rawHand(rw http.ResponseWriter, req *http.Request) error {
 	// Check rate limit
 	err := data.RateLimitGet.CheckAndUse(netshare.GetClientAddr(req))
@@ -35,11 +36,41 @@ func (data *Data) rawHand(rw http.ResponseWriter, req *http.Request) error {
 	// Read DB
 	pasteID := string([]rune(req.URL.Path)[5:])
 
-	paste, err := data.DB.PasteGet(pasteID)
+	fileName := ""
+	if i := strings.Index(pasteID, "/"); i >= 0 {
+		fileName = pasteID[i+1:]
+		pasteID = pasteID[:i]
+	}
+
+	paste, err := netshare.PasteGetRevision(data.DB, pasteID, req.URL.Query().Get("rev"))
 	if err != nil {
 		return err
 	}
 
+	// Private paste can be opened only by its owner
+	err = netshare.PasteCheckVisibility(req, paste)
+	if err != nil {
+		return err
+	}
+
+	// Check paste password
+	err = netshare.PasteCheckPassword(req, paste, data.RateLimitGet, netshare.PastePassword(req, true))
+	if err != nil {
+		return err
+	}
+
+	// Get file of the multi-file paste
+	body := paste.Body
+
+	if fileName != "" {
+		file, err := netshare.PasteGetFile(paste, fileName)
+		if err != nil {
+			return err
+		}
+
+		body = file.Body
+	}
+
 	// If "one use" paste
 	if paste.OneUse == true {
 		// Delete paste
@@ -52,7 +83,7 @@ func (data *Data) rawHand(rw http.ResponseWriter, req *http.Request) error {
 	// Write result
 	rw.Header().Set("Content-Type", "text/plain; charset=utf-8")
 
-	_, err = io.WriteString(rw, paste.Body)
+	_, err = io.WriteString(rw, body)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,145 @@
+// Copyright (C) 2021-2023 Leonid Maslakov.
+
+// This file is part of Lenpaste.
+
+// Lenpaste is free software: you can redistribute it
+// and/or modify it under the terms of the
+// GNU Affero Public License as published by the
+// Free Software Foundation, either version 3 of the License,
+// or (at your option) any later version.
+
+// Lenpaste is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+// or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero Public License for more details.
+
+// You should have received a copy of the GNU Affero Public License along with Lenpaste.
+// If not, see <https://www.gnu.org/licenses/>.
+
+package raw
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/lcomrade/lenpaste/internal/config"
+	"github.com/lcomrade/lenpaste/internal/logger"
+	"github.com/lcomrade/lenpaste/internal/netshare"
+	"github.com/lcomrade/lenpaste/internal/storage"
+)
+
+func newTestData() *Data {
+	return Load(storage.NewMemory(), config.Config{
+		Log:          logger.New("2006/01/02 15:04:05"),
+		RateLimitNew: netshare.NewRateLimitSystem(0, 0, 0),
+		RateLimitGet: netshare.NewRateLimitSystem(0, 0, 0),
+		TitleMaxLen:  100,
+		BodyMaxLen:   20,
+		MaxLifeTime:  -1,
+	})
+}
+
+func TestUpload(t *testing.T) {
+	data := newTestData()
+
+	// PUT /
+	req := httptest.NewRequest("PUT", "http://example.org/?title=Log&syntax=Go", strings.NewReader("line 1\r\nline 2\r\n"))
+	req.Header.Set("X-Paste-Expiration", "3600")
+	rw := httptest.NewRecorder()
+	data.Hand(rw, req)
+
+	if rw.Code != http.StatusOK {
+		t.Fatal("expected 200 but got", rw.Code, rw.Body.String())
+	}
+
+	pasteURL := strings.TrimSpace(rw.Body.String())
+	if strings.HasPrefix(pasteURL, "http://example.org/") == false || rw.Header().Get("X-Delete-Token") == "" {
+		t.Fatal("unexpected answer:", rw.Body.String())
+	}
+
+	paste, err := data.DB.PasteGet(strings.TrimPrefix(pasteURL, "http://example.org/"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if paste.Title != "Log" || paste.Syntax != "Go" || paste.Body != "line 1\nline 2\n" || paste.DeleteTime == 0 {
+		t.Errorf("unexpected paste: %+v", paste)
+	}
+
+	// POST /raw and get it back
+	req = httptest.NewRequest("POST", "http://example.org/raw", strings.NewReader("Привет"))
+	rw = httptest.NewRecorder()
+	data.Hand(rw, req)
+
+	if rw.Code != http.StatusOK {
+		t.Fatal("expected 200 but got", rw.Code, rw.Body.String())
+	}
+
+	pasteID := strings.TrimPrefix(strings.TrimSpace(rw.Body.String()), "http://example.org/")
+
+	req = httptest.NewRequest("GET", "/raw/"+pasteID, nil)
+	rw = httptest.NewRecorder()
+	data.Hand(rw, req)
+
+	if rw.Body.String() != "Привет" {
+		t.Error("unexpected body:", rw.Body.String())
+	}
+}
+
+func TestUploadErrors(t *testing.T) {
+	data := newTestData()
+
+	tests := []struct {
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{"PUT", "/", "", 400},
+		{"PUT", "/", "\xff\xfe", 400},
+		{"PUT", "/?syntax=unknown", "text", 400},
+		{"PUT", "/", strings.Repeat("a", 21), 413},
+		{"PUT", "/", strings.Repeat("Ж", 20), 200},
+		{"GET", "/raw", "", 405},
+		{"PUT", "/raw", "text", 405},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(test.method, test.path, strings.NewReader(test.body))
+		rw := httptest.NewRecorder()
+		data.Hand(rw, req)
+
+		if rw.Code != test.status {
+			t.Error(test.method, test.path, "expected", test.status, "but got", rw.Code)
+		}
+	}
+}
+
+func TestBan(t *testing.T) {
+	data := newTestData()
+
+	_, err := data.DB.BanAdd(storage.Ban{CIDR: "192.0.2.0/24", Reason: "Spam"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// httptest uses 192.0.2.1
+	req := httptest.NewRequest("POST", "http://example.org/raw", strings.NewReader("Text"))
+	rw := httptest.NewRecorder()
+	data.Hand(rw, req)
+
+	if rw.Code != http.StatusForbidden || strings.Contains(rw.Body.String(), "Reason: Spam") == false {
+		t.Error("expected 403 with the ban reason but got", rw.Code, rw.Body.String())
+	}
+
+	req = httptest.NewRequest("POST", "http://example.org/raw", strings.NewReader("Text"))
+	req.RemoteAddr = "198.51.100.1:1234"
+	rw = httptest.NewRecorder()
+	data.Hand(rw, req)
+
+	if rw.Code != http.StatusOK {
+		t.Error("expected 200 but got", rw.Code, rw.Body.String())
+	}
+}
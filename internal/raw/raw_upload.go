@@ -0,0 +1,60 @@
+// Copyright (C) 2021-2023 Leonid Maslakov.
+
+// This file is part of Lenpaste.
+
+// Lenpaste is free software: you can redistribute it
+// and/or modify it under the terms of the
+// GNU Affero Public License as published by the
+// Free Software Foundation, either version 3 of the License,
+// or (at your option) any later version.
+
+// Lenpaste is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+// or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero Public License for more details.
+
+// You should have received a copy of the GNU Affero Public License along with Lenpaste.
+// If not, see <https://www.gnu.org/licenses/>.
+
+package raw
+
+import (
+	"github.com/lcomrade/lenpaste/internal/netshare"
+	"io"
+	"net/http"
+)
+
+// Pattern: PUT / or POST /raw
+// The request body is the paste text, the response is the paste URL.
+func (data *Data) uploadHand(rw http.ResponseWriter, req *http.Request) error {
+	var err error
+
+	// Check auth
+	err = netshare.CheckCreateAuth(req, data.Auth)
+	if err != nil {
+		return err
+	}
+
+	// Check method
+	if (req.URL.Path == "/" && req.Method != "PUT") || (req.URL.Path == "/raw" && req.Method != "POST") {
+		return netshare.ErrMethodNotAllowed
+	}
+
+	// Create paste
+	paste, err := netshare.PasteAddFromBody(req, data.DB, data.RateLimitNew, data.TitleMaxLen, data.BodyMaxLen, data.MaxLifeTime, data.Lexers)
+	if err != nil {
+		return err
+	}
+
+	// Write result
+	rw.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	rw.Header().Set("X-Delete-Token", paste.DeleteToken)
+	rw.Header().Set("X-Edit-Token", paste.EditToken)
+
+	_, err = io.WriteString(rw, netshare.GetProtocol(req)+"://"+netshare.GetHost(req)+"/"+paste.ID+"\n")
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -20,9 +20,29 @@ package storage
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/argon2"
 	"math/big"
+	"strings"
 )
 
+// Argon2id parameters for the paste passwords.
+// They can be changed at any time, the old hashes store their own parameters.
+const (
+	argon2Time    = 2
+	argon2Memory  = 19 * 1024 // KiB
+	argon2Threads = 1
+	argon2SaltLen = 16
+	argon2KeyLen  = 32
+)
+
+var ErrBadPasswordHash = errors.New("db: unknown password hash format")
+
 func genTokenCrypto(tokenLen int) (string, error) {
 	// Generate token
 	var chars = []rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789")
@@ -42,3 +62,85 @@ func genTokenCrypto(tokenLen int) (string, error) {
 
 	return token, nil
 }
+
+// NewSecret generates a random secret token (for example, a paste delete token).
+// Returns the token that must be given to the user and its hash that must be stored in the DB.
+func NewSecret() (string, string, error) {
+	secret, err := genTokenCrypto(32)
+	if err != nil {
+		return "", "", err
+	}
+
+	return secret, HashSecret(secret), nil
+}
+
+// HashSecret returns hex encoded SHA-256 of the secret token.
+// Secret tokens are long and random, so a slow hash function is not needed.
+func HashSecret(secret string) string {
+	hash := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(hash[:])
+}
+
+// CheckSecret compares the secret token with the hash from the DB in constant time.
+func CheckSecret(secret string, hash string) bool {
+	if secret == "" || hash == "" {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(HashSecret(secret)), []byte(hash)) == 1
+}
+
+// HashPassword returns the Argon2id hash of the password in the PHC string format:
+// $argon2id$v=19$m=19456,t=2,p=1$SALT$HASH
+func HashPassword(password string) (string, error) {
+	salt := make([]byte, argon2SaltLen)
+	_, err := rand.Read(salt)
+	if err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, argon2Time, argon2Memory, argon2Threads, argon2KeyLen)
+
+	return fmt.Sprintf(
+		"$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, argon2Memory, argon2Time, argon2Threads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// CheckPassword compares the password with the hash created by HashPassword.
+func CheckPassword(password string, hash string) (bool, error) {
+	// Parse hash
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[0] != "" || parts[1] != "argon2id" {
+		return false, ErrBadPasswordHash
+	}
+
+	var version int
+	_, err := fmt.Sscanf(parts[2], "v=%d", &version)
+	if err != nil || version != argon2.Version {
+		return false, ErrBadPasswordHash
+	}
+
+	var memory, time uint32
+	var threads uint8
+	_, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &time, &threads)
+	if err != nil {
+		return false, ErrBadPasswordHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false, ErrBadPasswordHash
+	}
+
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return false, ErrBadPasswordHash
+	}
+
+	// Compare
+	otherKey := argon2.IDKey([]byte(password), salt, time, memory, threads, uint32(len(key)))
+
+	return subtle.ConstantTimeCompare(key, otherKey) == 1, nil
+}
@@ -0,0 +1,68 @@
+// Copyright (C) 2021-2023 Leonid Maslakov.
+
+// This file is part of Lenpaste.
+
+// Lenpaste is free software: you can redistribute it
+// and/or modify it under the terms of the
+// GNU Affero Public License as published by the
+// Free Software Foundation, either version 3 of the License,
+// or (at your option) any later version.
+
+// Lenpaste is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+// or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero Public License for more details.
+
+// You should have received a copy of the GNU Affero Public License along with Lenpaste.
+// If not, see <https://www.gnu.org/licenses/>.
+
+package storage
+
+import (
+	"testing"
+)
+
+func TestSecret(t *testing.T) {
+	secret, hash, err := NewSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if CheckSecret(secret, hash) == false {
+		t.Error("secret does not match its hash")
+	}
+
+	if CheckSecret(secret+"x", hash) || CheckSecret("", hash) || CheckSecret(secret, "") {
+		t.Error("wrong secret matches the hash")
+	}
+}
+
+func TestPassword(t *testing.T) {
+	hash, err := HashPassword("my password")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ok, err := CheckPassword("my password", hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ok == false {
+		t.Error("password does not match its hash")
+	}
+
+	ok, err = CheckPassword("my passwor", hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ok == true {
+		t.Error("wrong password matches the hash")
+	}
+
+	_, err = CheckPassword("my password", "$2y$10$abcdef")
+	if err != ErrBadPasswordHash {
+		t.Error("expected ErrBadPasswordHash but got", err)
+	}
+}
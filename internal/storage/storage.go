@@ -26,17 +26,85 @@ import (
 )
 
 var (
-	ErrNotFoundID = errors.New("db: could not find ID")
+	ErrNotFoundID       = errors.New("db: could not find ID")
+	ErrNotFoundRevision = errors.New("db: could not find revision")
+	ErrBadOffset        = errors.New("db: offset can not be negative")
 )
 
+// Store is a paste storage backend.
+// It is implemented by DB (SQLite3 and PostgreSQL) and Memory.
+type Store interface {
+	PasteAdd(paste Paste) (string, int64, int64, error)
+	PasteDelete(id string) error
+	PasteGet(id string) (Paste, error)
+	PasteGetAny(id string) (Paste, error)
+	PasteGetRevision(id string, revision int) (Paste, error)
+	PasteRevisions(id string) ([]PasteRevision, error)
+	PasteEdit(id string, rev PasteRevision) (int, int64, error)
+	PasteDeleteExpired() (int64, error)
+	PasteSearch(query string, limit int, offset int) ([]SearchResult, error)
+	PasteList(limit int, offset int) ([]Paste, error)
+	PasteListByUser(userID string, limit int, offset int) ([]Paste, error)
+	PasteSetDeleteTime(id string, deleteTime int64) error
+	PasteSetHidden(id string, hidden bool) error
+
+	TokenAdd(token APIToken) (string, int64, error)
+	TokenGetByHash(tokenHash string) (APIToken, error)
+	TokenList() ([]APIToken, error)
+	TokenDelete(id string) error
+	TokenUse(id string) error
+
+	UserAdd(user User) (string, error)
+	UserGet(id string) (User, error)
+	UserGetByName(name string) (User, error)
+	UserGetByOIDCSubject(subject string) (User, error)
+	UserSetEmail(id string, email string) error
+	UserList() ([]User, error)
+	UserDelete(id string) error
+
+	SessionAdd(session Session) error
+	SessionGet(idHash string) (Session, error)
+	SessionDelete(idHash string) error
+	SessionDeleteExpired() (int64, error)
+
+	BanAdd(ban Ban) (string, error)
+	BanList() ([]Ban, error)
+	BanDelete(id string) error
+	BanDeleteExpired() (int64, error)
+
+	ReportAdd(report Report) (string, error)
+	ReportGet(id string) (Report, error)
+	ReportList(limit int, offset int) ([]Report, error)
+	ReportDelete(id string) error
+	ReportReporters(pasteID string) (int, error)
+
+	AuditAdd(entry AuditEntry) error
+	AuditList(limit int, offset int) ([]AuditEntry, error)
+
+	Stats() (Stats, error)
+}
+
+// Open opens the storage backend by driver name.
+// The "memory" driver keeps all pastes in RAM and ignores dataSourceName.
+func Open(driverName string, dataSourceName string, maxOpenConns int, maxIdleConns int) (Store, error) {
+	if driverName == "memory" {
+		return NewMemory(), nil
+	}
+
+	return NewPool(driverName, dataSourceName, maxOpenConns, maxIdleConns)
+}
+
 type DB struct {
-	pool *sql.DB
+	pool   *sql.DB
+	driver string
 }
 
 func NewPool(driverName string, dataSourceName string, maxOpenConns int, maxIdleConns int) (DB, error) {
 	var db DB
 	var err error
 
+	db.driver = driverName
+
 	db.pool, err = sql.Open(driverName, dataSourceName)
 	if err != nil {
 		return db, err
@@ -53,6 +121,11 @@ func (db DB) Close() error {
 }
 
 func InitDB(driverName string, dataSourceName string) error {
+	// In-memory storage does not have a schema
+	if driverName == "memory" {
+		return nil
+	}
+
 	// Open DB
 	db, err := NewPool(driverName, dataSourceName, 1, 0)
 	if err != nil {
@@ -60,56 +133,11 @@ func InitDB(driverName string, dataSourceName string) error {
 	}
 	defer db.Close()
 
-	// Create tables
-	_, err = db.pool.Exec(`
-		CREATE TABLE IF NOT EXISTS pastes (
-			id          TEXT    PRIMARY KEY,
-			title       TEXT    NOT NULL,
-			body        TEXT    NOT NULL,
-			syntax      TEXT    NOT NULL,
-			create_time INTEGER NOT NULL,
-			delete_time INTEGER NOT NULL,
-			one_use     BOOL    NOT NULL
-		);
-	`)
+	// Apply all pending migrations
+	_, err = db.MigrateUp(0)
 	if err != nil {
 		return err
 	}
 
-	// Crutch for SQLite3
-	if driverName == "sqlite3" {
-		_, err = db.pool.Exec(`ALTER TABLE pastes ADD COLUMN author       TEXT NOT NULL DEFAULT ''`)
-		if err != nil {
-			if err.Error() != "duplicate column name: author" {
-				return err
-			}
-		}
-
-		_, err = db.pool.Exec(`ALTER TABLE pastes ADD COLUMN author_email TEXT NOT NULL DEFAULT ''`)
-		if err != nil {
-			if err.Error() != "duplicate column name: author_email" {
-				return err
-			}
-		}
-
-		_, err = db.pool.Exec(`ALTER TABLE pastes ADD COLUMN author_url TEXT NOT NULL DEFAULT ''`)
-		if err != nil {
-			if err.Error() != "duplicate column name: author_url" {
-				return err
-			}
-		}
-
-		// Normal SQL for all other DBs
-	} else {
-		_, err = db.pool.Exec(`
-			ALTER TABLE pastes ADD COLUMN IF NOT EXISTS author       TEXT NOT NULL DEFAULT '';
-			ALTER TABLE pastes ADD COLUMN IF NOT EXISTS author_email TEXT NOT NULL DEFAULT '';
-			ALTER TABLE pastes ADD COLUMN IF NOT EXISTS author_url   TEXT NOT NULL DEFAULT '';
-		`)
-		if err != nil {
-			return err
-		}
-	}
-
 	return nil
 }
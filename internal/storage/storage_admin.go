@@ -0,0 +1,402 @@
+// Copyright (C) 2021-2023 Leonid Maslakov.
+
+// This file is part of Lenpaste.
+
+// Lenpaste is free software: you can redistribute it
+// and/or modify it under the terms of the
+// GNU Affero Public License as published by the
+// Free Software Foundation, either version 3 of the License,
+// or (at your option) any later version.
+
+// Lenpaste is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+// or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero Public License for more details.
+
+// You should have received a copy of the GNU Affero Public License along with Lenpaste.
+// If not, see <https://www.gnu.org/licenses/>.
+
+package storage
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+// Admin actions written to the audit log.
+const (
+	AuditPasteView     = "paste-view"     // Paste metadata and creator IP are shown
+	AuditPasteDelete   = "paste-delete"   // Paste is deleted
+	AuditBanAdd        = "ban-add"        // IP address or CIDR range is banned
+	AuditBanDelete     = "ban-delete"     // Ban is removed
+	AuditReportDismiss = "report-dismiss" // Report is dismissed without changes
+)
+
+var (
+	ErrNotFoundBan = errors.New("db: could not find ban")
+	ErrBanExists   = errors.New("db: ban already exists")
+)
+
+// Ban forbids clients from the IP range to use the server.
+type Ban struct {
+	ID         string `json:"id"`         // Ignored when creating
+	CIDR       string `json:"cidr"`       // Single address is stored as /32 or /128 range
+	Reason     string `json:"reason"`     // Shown to the banned client
+	CreateTime int64  `json:"createTime"` // Ignored when creating
+	ExpireTime int64  `json:"expireTime"` // 0 = never expires
+}
+
+// Expired reports whether the ban is no longer active.
+func (ban Ban) Expired(timeNow int64) bool {
+	return ban.ExpireTime != 0 && ban.ExpireTime <= timeNow
+}
+
+// AuditEntry is one action of the administrator.
+type AuditEntry struct {
+	ID      int64  `json:"id"`   // Ignored when creating, grows with every new entry
+	Time    int64  `json:"time"` // Ignored when creating
+	Admin   string `json:"admin"`
+	Action  string `json:"action"` // One of Audit* constants
+	Target  string `json:"target"` // Paste ID or CIDR range
+	Details string `json:"details"`
+}
+
+// Stats is the number of objects stored on the instance.
+// Expired pastes that are not deleted yet are not counted.
+type Stats struct {
+	Pastes        int64 `json:"pastes"`
+	PublicPastes  int64 `json:"publicPastes"`
+	PastesLastDay int64 `json:"pastesLastDay"` // Created in the last 24 hours
+	Users         int64 `json:"users"`
+	APITokens     int64 `json:"apiTokens"`
+	Bans          int64 `json:"bans"`
+	Reports       int64 `json:"reports"`
+}
+
+func (db DB) BanAdd(ban Ban) (string, error) {
+	var err error
+
+	ban.ID, err = genTokenCrypto(8)
+	if err != nil {
+		return "", err
+	}
+
+	ban.CreateTime = time.Now().Unix()
+
+	// Expired ban of the same range is replaced
+	_, err = db.pool.Exec(
+		`DELETE FROM bans WHERE cidr = $1 AND expire_time != 0 AND expire_time <= $2`,
+		ban.CIDR, ban.CreateTime,
+	)
+	if err != nil {
+		return "", err
+	}
+
+	// Check range
+	var count int
+	err = db.pool.QueryRow(`SELECT COUNT(*) FROM bans WHERE cidr = $1`, ban.CIDR).Scan(&count)
+	if err != nil {
+		return "", err
+	}
+
+	if count != 0 {
+		return "", ErrBanExists
+	}
+
+	_, err = db.pool.Exec(
+		`INSERT INTO bans (id, cidr, reason, create_time, expire_time) VALUES ($1, $2, $3, $4, $5)`,
+		ban.ID, ban.CIDR, ban.Reason, ban.CreateTime, ban.ExpireTime,
+	)
+	if err != nil {
+		return "", err
+	}
+
+	return ban.ID, nil
+}
+
+// BanList returns the active bans from oldest to newest.
+func (db DB) BanList() ([]Ban, error) {
+	rows, err := db.pool.Query(
+		`SELECT id, cidr, reason, create_time, expire_time FROM bans
+		WHERE expire_time = 0 OR expire_time > $1 ORDER BY create_time, id`,
+		time.Now().Unix(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var bans []Ban
+	for rows.Next() {
+		var ban Ban
+
+		err = rows.Scan(&ban.ID, &ban.CIDR, &ban.Reason, &ban.CreateTime, &ban.ExpireTime)
+		if err != nil {
+			return nil, err
+		}
+
+		bans = append(bans, ban)
+	}
+
+	return bans, rows.Err()
+}
+
+func (db DB) BanDelete(id string) error {
+	result, err := db.pool.Exec(`DELETE FROM bans WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrNotFoundBan
+	}
+
+	return nil
+}
+
+func (db DB) BanDeleteExpired() (int64, error) {
+	result, err := db.pool.Exec(
+		`DELETE FROM bans WHERE expire_time != 0 AND expire_time <= $1`,
+		time.Now().Unix(),
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+func (db DB) AuditAdd(entry AuditEntry) error {
+	entry.Time = time.Now().Unix()
+
+	_, err := db.pool.Exec(
+		`INSERT INTO audit_log (time, admin, action, target, details) VALUES ($1, $2, $3, $4, $5)`,
+		entry.Time, entry.Admin, entry.Action, entry.Target, entry.Details,
+	)
+	return err
+}
+
+// AuditList returns the audit log entries from newest to oldest.
+func (db DB) AuditList(limit int, offset int) ([]AuditEntry, error) {
+	if offset < 0 {
+		return nil, ErrBadOffset
+	}
+
+	rows, err := db.pool.Query(
+		`SELECT id, time, admin, action, target, details FROM audit_log ORDER BY id DESC LIMIT $1 OFFSET $2`,
+		limit, offset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []AuditEntry
+	for rows.Next() {
+		var entry AuditEntry
+
+		err = rows.Scan(&entry.ID, &entry.Time, &entry.Admin, &entry.Action, &entry.Target, &entry.Details)
+		if err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
+
+func (db DB) Stats() (Stats, error) {
+	var stats Stats
+
+	timeNow := time.Now().Unix()
+
+	queries := []struct {
+		dest  *int64
+		query string
+		args  []interface{}
+	}{
+		{&stats.Pastes, `SELECT COUNT(*) FROM pastes WHERE delete_time = 0 OR delete_time > $1`, []interface{}{timeNow}},
+		{&stats.PublicPastes, `SELECT COUNT(*) FROM pastes WHERE (delete_time = 0 OR delete_time > $1) AND visibility = $2`, []interface{}{timeNow, VisibilityPublic}},
+		{&stats.PastesLastDay, `SELECT COUNT(*) FROM pastes WHERE (delete_time = 0 OR delete_time > $1) AND create_time > $2`, []interface{}{timeNow, timeNow - 24*60*60}},
+		{&stats.Users, `SELECT COUNT(*) FROM users`, nil},
+		{&stats.APITokens, `SELECT COUNT(*) FROM api_tokens`, nil},
+		{&stats.Bans, `SELECT COUNT(*) FROM bans WHERE expire_time = 0 OR expire_time > $1`, []interface{}{timeNow}},
+		{&stats.Reports, `SELECT COUNT(*) FROM reports`, nil},
+	}
+
+	for _, q := range queries {
+		err := db.pool.QueryRow(q.query, q.args...).Scan(q.dest)
+		if err != nil {
+			return stats, err
+		}
+	}
+
+	return stats, nil
+}
+
+func (m *Memory) BanAdd(ban Ban) (string, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	timeNow := time.Now().Unix()
+
+	for id, b := range m.bans {
+		if b.CIDR == ban.CIDR {
+			// Expired ban of the same range is replaced
+			if b.Expired(timeNow) {
+				delete(m.bans, id)
+				continue
+			}
+
+			return "", ErrBanExists
+		}
+	}
+
+	// Generate unique ID
+	for {
+		var err error
+		ban.ID, err = genTokenCrypto(8)
+		if err != nil {
+			return "", err
+		}
+
+		_, exist := m.bans[ban.ID]
+		if exist == false {
+			break
+		}
+	}
+
+	ban.CreateTime = timeNow
+	m.bans[ban.ID] = ban
+
+	return ban.ID, nil
+}
+
+func (m *Memory) BanList() ([]Ban, error) {
+	m.RLock()
+	defer m.RUnlock()
+
+	timeNow := time.Now().Unix()
+
+	var bans []Ban
+	for _, ban := range m.bans {
+		if ban.Expired(timeNow) == false {
+			bans = append(bans, ban)
+		}
+	}
+
+	sort.Slice(bans, func(i, j int) bool {
+		if bans[i].CreateTime != bans[j].CreateTime {
+			return bans[i].CreateTime < bans[j].CreateTime
+		}
+
+		return bans[i].ID < bans[j].ID
+	})
+
+	return bans, nil
+}
+
+func (m *Memory) BanDelete(id string) error {
+	m.Lock()
+	defer m.Unlock()
+
+	_, exist := m.bans[id]
+	if exist == false {
+		return ErrNotFoundBan
+	}
+
+	delete(m.bans, id)
+
+	return nil
+}
+
+func (m *Memory) BanDeleteExpired() (int64, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	timeNow := time.Now().Unix()
+
+	var count int64
+	for id, ban := range m.bans {
+		if ban.Expired(timeNow) {
+			delete(m.bans, id)
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+func (m *Memory) AuditAdd(entry AuditEntry) error {
+	m.Lock()
+	defer m.Unlock()
+
+	entry.ID = int64(len(m.audit)) + 1
+	entry.Time = time.Now().Unix()
+
+	m.audit = append(m.audit, entry)
+
+	return nil
+}
+
+func (m *Memory) AuditList(limit int, offset int) ([]AuditEntry, error) {
+	if offset < 0 {
+		return nil, ErrBadOffset
+	}
+
+	m.RLock()
+	defer m.RUnlock()
+
+	// Entries are added in ID order
+	var entries []AuditEntry
+	for i := len(m.audit) - 1 - offset; i >= 0 && len(entries) < limit; i-- {
+		entries = append(entries, m.audit[i])
+	}
+
+	return entries, nil
+}
+
+func (m *Memory) Stats() (Stats, error) {
+	m.RLock()
+	defer m.RUnlock()
+
+	var stats Stats
+
+	timeNow := time.Now().Unix()
+
+	for _, paste := range m.pastes {
+		if paste.DeleteTime != 0 && paste.DeleteTime <= timeNow {
+			continue
+		}
+
+		stats.Pastes++
+
+		if paste.Visibility == VisibilityPublic {
+			stats.PublicPastes++
+		}
+
+		if paste.CreateTime > timeNow-24*60*60 {
+			stats.PastesLastDay++
+		}
+	}
+
+	stats.Users = int64(len(m.users))
+	stats.APITokens = int64(len(m.tokens))
+
+	for _, ban := range m.bans {
+		if ban.Expired(timeNow) == false {
+			stats.Bans++
+		}
+	}
+
+	stats.Reports = int64(len(m.reports))
+
+	return stats, nil
+}
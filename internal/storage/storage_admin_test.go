@@ -0,0 +1,185 @@
+// Copyright (C) 2021-2023 Leonid Maslakov.
+
+// This file is part of Lenpaste.
+
+// Lenpaste is free software: you can redistribute it
+// and/or modify it under the terms of the
+// GNU Affero Public License as published by the
+// Free Software Foundation, either version 3 of the License,
+// or (at your option) any later version.
+
+// Lenpaste is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+// or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero Public License for more details.
+
+// You should have received a copy of the GNU Affero Public License along with Lenpaste.
+// If not, see <https://www.gnu.org/licenses/>.
+
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBans(t *testing.T) {
+	for name, db := range testStores(t) {
+		id, err := db.BanAdd(Ban{CIDR: "192.0.2.0/24"})
+		if err != nil {
+			t.Fatal(name, err)
+		}
+
+		_, err = db.BanAdd(Ban{CIDR: "192.0.2.0/24"})
+		if err != ErrBanExists {
+			t.Error(name, "expected ErrBanExists but got", err)
+		}
+
+		_, err = db.BanAdd(Ban{CIDR: "2001:db8::/32"})
+		if err != nil {
+			t.Fatal(name, err)
+		}
+
+		bans, err := db.BanList()
+		if err != nil {
+			t.Fatal(name, err)
+		}
+
+		if len(bans) != 2 || bans[0].CreateTime == 0 {
+			t.Error(name, "unexpected bans:", bans)
+		}
+
+		err = db.BanDelete(id)
+		if err != nil {
+			t.Fatal(name, err)
+		}
+
+		err = db.BanDelete(id)
+		if err != ErrNotFoundBan {
+			t.Error(name, "expected ErrNotFoundBan but got", err)
+		}
+
+		bans, err = db.BanList()
+		if err != nil || len(bans) != 1 || bans[0].CIDR != "2001:db8::/32" {
+			t.Error(name, "unexpected bans:", bans, err)
+		}
+	}
+}
+
+func TestBanExpire(t *testing.T) {
+	for name, db := range testStores(t) {
+		timeNow := time.Now().Unix()
+
+		_, err := db.BanAdd(Ban{CIDR: "192.0.2.0/24", Reason: "Spam", ExpireTime: timeNow - 10})
+		if err != nil {
+			t.Fatal(name, err)
+		}
+
+		_, err = db.BanAdd(Ban{CIDR: "198.51.100.0/24", ExpireTime: timeNow + 3600})
+		if err != nil {
+			t.Fatal(name, err)
+		}
+
+		bans, err := db.BanList()
+		if err != nil || len(bans) != 1 || bans[0].CIDR != "198.51.100.0/24" {
+			t.Error(name, "expired ban is listed:", bans, err)
+		}
+
+		// Expired ban is replaced by the new one
+		_, err = db.BanAdd(Ban{CIDR: "192.0.2.0/24", Reason: "Spam again"})
+		if err != nil {
+			t.Fatal(name, err)
+		}
+
+		bans, err = db.BanList()
+		if err != nil || len(bans) != 2 {
+			t.Fatal(name, "unexpected bans:", bans, err)
+		}
+
+		for _, ban := range bans {
+			if ban.CIDR == "192.0.2.0/24" && (ban.Reason != "Spam again" || ban.ExpireTime != 0) {
+				t.Error(name, "expired ban is not replaced:", ban)
+			}
+		}
+
+		count, err := db.BanDeleteExpired()
+		if err != nil || count != 0 {
+			t.Error(name, "unexpected expired bans count:", count, err)
+		}
+
+		_, err = db.BanAdd(Ban{CIDR: "203.0.113.0/24", ExpireTime: timeNow - 1})
+		if err != nil {
+			t.Fatal(name, err)
+		}
+
+		count, err = db.BanDeleteExpired()
+		if err != nil || count != 1 {
+			t.Error(name, "unexpected expired bans count:", count, err)
+		}
+	}
+}
+
+func TestAuditLog(t *testing.T) {
+	for name, db := range testStores(t) {
+		for _, target := range []string{"first", "second", "third"} {
+			err := db.AuditAdd(AuditEntry{Admin: "root", Action: AuditPasteDelete, Target: target})
+			if err != nil {
+				t.Fatal(name, err)
+			}
+		}
+
+		entries, err := db.AuditList(2, 0)
+		if err != nil {
+			t.Fatal(name, err)
+		}
+
+		if len(entries) != 2 || entries[0].Target != "third" || entries[1].Target != "second" || entries[0].ID <= entries[1].ID || entries[0].Time == 0 {
+			t.Fatal(name, "unexpected audit log:", entries)
+		}
+
+		entries, err = db.AuditList(10, 2)
+		if err != nil || len(entries) != 1 || entries[0].Target != "first" {
+			t.Error(name, "unexpected audit log page:", entries, err)
+		}
+	}
+}
+
+func TestStats(t *testing.T) {
+	for name, db := range testStores(t) {
+		id, _, _, err := db.PasteAdd(Paste{Body: "Public", Syntax: "plaintext", Visibility: VisibilityPublic, CreatorIP: "192.0.2.1"})
+		if err != nil {
+			t.Fatal(name, err)
+		}
+
+		_, _, _, err = db.PasteAdd(Paste{Body: "Unlisted", Syntax: "plaintext"})
+		if err != nil {
+			t.Fatal(name, err)
+		}
+
+		_, err = db.UserAdd(User{Name: "root", Admin: true})
+		if err != nil {
+			t.Fatal(name, err)
+		}
+
+		_, err = db.BanAdd(Ban{CIDR: "192.0.2.0/24"})
+		if err != nil {
+			t.Fatal(name, err)
+		}
+
+		stats, err := db.Stats()
+		if err != nil {
+			t.Fatal(name, err)
+		}
+
+		expect := Stats{Pastes: 2, PublicPastes: 1, PastesLastDay: 2, Users: 1, APITokens: 0, Bans: 1}
+		if stats != expect {
+			t.Error(name, "expected", expect, "but got", stats)
+		}
+
+		// Creator IP is stored
+		paste, err := db.PasteGet(id)
+		if err != nil || paste.CreatorIP != "192.0.2.1" {
+			t.Error(name, "unexpected creator IP:", paste.CreatorIP, err)
+		}
+	}
+}
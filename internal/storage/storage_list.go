@@ -0,0 +1,190 @@
+// Copyright (C) 2021-2023 Leonid Maslakov.
+
+// This file is part of Lenpaste.
+
+// Lenpaste is free software: you can redistribute it
+// and/or modify it under the terms of the
+// GNU Affero Public License as published by the
+// Free Software Foundation, either version 3 of the License,
+// or (at your option) any later version.
+
+// Lenpaste is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+// or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero Public License for more details.
+
+// You should have received a copy of the GNU Affero Public License along with Lenpaste.
+// If not, see <https://www.gnu.org/licenses/>.
+
+package storage
+
+import (
+	"sort"
+	"time"
+)
+
+// PasteList returns public pastes from newest to oldest.
+// Files and secret hashes of the pastes are not read.
+func (db DB) PasteList(limit int, offset int) ([]Paste, error) {
+	if offset < 0 {
+		return nil, ErrBadOffset
+	}
+
+	rows, err := db.pool.Query(
+		`SELECT id, title, body, syntax, create_time, delete_time, one_use, author, author_email, author_url, revision, edit_time, encrypted, forked_from, visibility
+		FROM pastes WHERE visibility = 'public' AND hidden = FALSE AND (delete_time = 0 OR delete_time > $1)
+		ORDER BY create_time DESC, id LIMIT $2 OFFSET $3`,
+		time.Now().Unix(), limit, offset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var pastes []Paste
+	for rows.Next() {
+		var paste Paste
+
+		err = rows.Scan(&paste.ID, &paste.Title, &paste.Body, &paste.Syntax, &paste.CreateTime, &paste.DeleteTime, &paste.OneUse, &paste.Author, &paste.AuthorEmail, &paste.AuthorURL, &paste.Revision, &paste.EditTime, &paste.Encrypted, &paste.ForkedFrom, &paste.Visibility)
+		if err != nil {
+			return nil, err
+		}
+
+		pastes = append(pastes, paste)
+	}
+
+	return pastes, rows.Err()
+}
+
+// PasteList returns public pastes from newest to oldest.
+func (m *Memory) PasteList(limit int, offset int) ([]Paste, error) {
+	m.RLock()
+	defer m.RUnlock()
+
+	timeNow := time.Now().Unix()
+
+	var pastes []Paste
+	for _, paste := range m.pastes {
+		if paste.Visibility != VisibilityPublic || paste.Hidden {
+			continue
+		}
+
+		if paste.DeleteTime < timeNow && paste.DeleteTime > 0 {
+			continue
+		}
+
+		paste.Files = nil
+		paste.DeleteTokenHash = ""
+		paste.EditTokenHash = ""
+		paste.PasswordHash = ""
+
+		pastes = append(pastes, paste)
+	}
+
+	sort.Slice(pastes, func(i, j int) bool {
+		if pastes[i].CreateTime != pastes[j].CreateTime {
+			return pastes[i].CreateTime > pastes[j].CreateTime
+		}
+
+		return pastes[i].ID < pastes[j].ID
+	})
+
+	// Make page
+	if offset < 0 {
+		return nil, ErrBadOffset
+	}
+
+	if offset >= len(pastes) {
+		return nil, nil
+	}
+
+	pastes = pastes[offset:]
+	if len(pastes) > limit {
+		pastes = pastes[:limit]
+	}
+
+	return pastes, nil
+}
+
+// PasteListByUser returns all pastes of the user from newest to oldest.
+// Files and secret hashes of the pastes are not read.
+func (db DB) PasteListByUser(userID string, limit int, offset int) ([]Paste, error) {
+	if offset < 0 {
+		return nil, ErrBadOffset
+	}
+
+	rows, err := db.pool.Query(
+		`SELECT id, title, body, syntax, create_time, delete_time, one_use, author, author_email, author_url, revision, edit_time, encrypted, forked_from, visibility
+		FROM pastes WHERE user_id = $1 AND (delete_time = 0 OR delete_time > $2)
+		ORDER BY create_time DESC, id LIMIT $3 OFFSET $4`,
+		userID, time.Now().Unix(), limit, offset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var pastes []Paste
+	for rows.Next() {
+		paste := Paste{UserID: userID}
+
+		err = rows.Scan(&paste.ID, &paste.Title, &paste.Body, &paste.Syntax, &paste.CreateTime, &paste.DeleteTime, &paste.OneUse, &paste.Author, &paste.AuthorEmail, &paste.AuthorURL, &paste.Revision, &paste.EditTime, &paste.Encrypted, &paste.ForkedFrom, &paste.Visibility)
+		if err != nil {
+			return nil, err
+		}
+
+		pastes = append(pastes, paste)
+	}
+
+	return pastes, rows.Err()
+}
+
+// PasteListByUser returns all pastes of the user from newest to oldest.
+func (m *Memory) PasteListByUser(userID string, limit int, offset int) ([]Paste, error) {
+	m.RLock()
+	defer m.RUnlock()
+
+	timeNow := time.Now().Unix()
+
+	var pastes []Paste
+	for _, paste := range m.pastes {
+		if paste.UserID != userID || userID == "" {
+			continue
+		}
+
+		if paste.DeleteTime < timeNow && paste.DeleteTime > 0 {
+			continue
+		}
+
+		paste.Files = nil
+		paste.DeleteTokenHash = ""
+		paste.EditTokenHash = ""
+		paste.PasswordHash = ""
+
+		pastes = append(pastes, paste)
+	}
+
+	sort.Slice(pastes, func(i, j int) bool {
+		if pastes[i].CreateTime != pastes[j].CreateTime {
+			return pastes[i].CreateTime > pastes[j].CreateTime
+		}
+
+		return pastes[i].ID < pastes[j].ID
+	})
+
+	// Make page
+	if offset < 0 {
+		return nil, ErrBadOffset
+	}
+
+	if offset >= len(pastes) {
+		return nil, nil
+	}
+
+	pastes = pastes[offset:]
+	if len(pastes) > limit {
+		pastes = pastes[:limit]
+	}
+
+	return pastes, nil
+}
@@ -0,0 +1,98 @@
+// Copyright (C) 2021-2023 Leonid Maslakov.
+
+// This file is part of Lenpaste.
+
+// Lenpaste is free software: you can redistribute it
+// and/or modify it under the terms of the
+// GNU Affero Public License as published by the
+// Free Software Foundation, either version 3 of the License,
+// or (at your option) any later version.
+
+// Lenpaste is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+// or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero Public License for more details.
+
+// You should have received a copy of the GNU Affero Public License along with Lenpaste.
+// If not, see <https://www.gnu.org/licenses/>.
+
+package storage
+
+import (
+	"testing"
+)
+
+func TestPasteList(t *testing.T) {
+	for name, db := range testStores(t) {
+		// Create pastes
+		pastes := []Paste{
+			{Title: "Public 1", Body: "Text", Syntax: "plaintext", Visibility: VisibilityPublic},
+			{Title: "Unlisted", Body: "Text", Syntax: "plaintext"},
+			{Title: "Private", Body: "Text", Syntax: "plaintext", Visibility: VisibilityPrivate},
+			{Title: "Public 2", Body: "Text", Syntax: "plaintext", Visibility: VisibilityPublic},
+		}
+
+		for _, paste := range pastes {
+			id, _, _, err := db.PasteAdd(paste)
+			if err != nil {
+				t.Fatal(name, err)
+			}
+
+			paste, err = db.PasteGet(id)
+			if err != nil {
+				t.Fatal(name, err)
+			}
+
+			if paste.Visibility == "" {
+				t.Error(name, "visibility is not set:", paste)
+			}
+		}
+
+		// List
+		list, err := db.PasteList(10, 0)
+		if err != nil {
+			t.Fatal(name, err)
+		}
+
+		if len(list) != 2 {
+			t.Fatal(name, "expected 2 pastes but got", list)
+		}
+
+		for _, paste := range list {
+			if paste.Visibility != VisibilityPublic {
+				t.Error(name, "unexpected paste:", paste)
+			}
+		}
+
+		// Pagination
+		list, err = db.PasteList(1, 1)
+		if err != nil {
+			t.Fatal(name, err)
+		}
+
+		if len(list) != 1 {
+			t.Error(name, "expected 1 paste on the second page but got", list)
+		}
+
+		// Negative offset
+		_, err = db.PasteList(1, -20)
+		if err != ErrBadOffset {
+			t.Error(name, "expected ErrBadOffset but got", err)
+		}
+
+		_, err = db.PasteListByUser("user", 1, -20)
+		if err != ErrBadOffset {
+			t.Error(name, "expected ErrBadOffset but got", err)
+		}
+
+		_, err = db.ReportList(1, -20)
+		if err != ErrBadOffset {
+			t.Error(name, "expected ErrBadOffset but got", err)
+		}
+
+		_, err = db.AuditList(1, -20)
+		if err != ErrBadOffset {
+			t.Error(name, "expected ErrBadOffset but got", err)
+		}
+	}
+}
@@ -0,0 +1,289 @@
+// Copyright (C) 2021-2023 Leonid Maslakov.
+
+// This file is part of Lenpaste.
+
+// Lenpaste is free software: you can redistribute it
+// and/or modify it under the terms of the
+// GNU Affero Public License as published by the
+// Free Software Foundation, either version 3 of the License,
+// or (at your option) any later version.
+
+// Lenpaste is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+// or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero Public License for more details.
+
+// You should have received a copy of the GNU Affero Public License along with Lenpaste.
+// If not, see <https://www.gnu.org/licenses/>.
+
+package storage
+
+import (
+	"sync"
+	"time"
+)
+
+// Memory is a Store that keeps all data in RAM.
+// It is useful for tests and ephemeral instances, all pastes, API tokens, user accounts and bans are lost on restart.
+type Memory struct {
+	sync.RWMutex
+
+	pastes    map[string]Paste
+	revisions map[string][]PasteRevision // Sorted from oldest to newest
+	tokens    map[string]APIToken
+	users     map[string]User
+	sessions  map[string]Session // Key is the session ID hash
+	bans      map[string]Ban
+	reports   map[string]Report
+	audit     []AuditEntry // Sorted from oldest to newest
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		pastes:    make(map[string]Paste),
+		revisions: make(map[string][]PasteRevision),
+		tokens:    make(map[string]APIToken),
+		users:     make(map[string]User),
+		sessions:  make(map[string]Session),
+		bans:      make(map[string]Ban),
+		reports:   make(map[string]Report),
+	}
+}
+
+func (m *Memory) PasteAdd(paste Paste) (string, int64, int64, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	// Generate unique ID
+	for {
+		var err error
+		paste.ID, err = genTokenCrypto(8)
+		if err != nil {
+			return paste.ID, paste.CreateTime, paste.DeleteTime, err
+		}
+
+		_, exist := m.pastes[paste.ID]
+		if exist == false {
+			break
+		}
+	}
+
+	// Set paste create time
+	paste.CreateTime = time.Now().Unix()
+
+	// Check delete time
+	if paste.DeleteTime < 0 {
+		paste.DeleteTime = 0
+	}
+
+	if paste.Visibility == "" {
+		paste.Visibility = VisibilityUnlisted
+	}
+
+	paste.Revision = 1
+	paste.EditTime = 0
+
+	// Files must not be changed by the caller
+	if len(paste.Files) != 0 {
+		paste.Files = append([]PasteFile(nil), paste.Files...)
+	}
+
+	// Add
+	m.pastes[paste.ID] = paste
+	m.revisions[paste.ID] = []PasteRevision{{
+		Revision:   1,
+		Title:      paste.Title,
+		Body:       paste.Body,
+		Syntax:     paste.Syntax,
+		CreateTime: paste.CreateTime,
+	}}
+
+	return paste.ID, paste.CreateTime, paste.DeleteTime, nil
+}
+
+func (m *Memory) PasteDelete(id string) error {
+	m.Lock()
+	defer m.Unlock()
+
+	_, exist := m.pastes[id]
+	if exist == false {
+		return ErrNotFoundID
+	}
+
+	delete(m.pastes, id)
+	delete(m.revisions, id)
+	m.deleteReports(id)
+
+	return nil
+}
+
+// get returns the paste and deletes it if it is expired. Hidden pastes are not found.
+// The caller must hold the lock.
+func (m *Memory) get(id string) (Paste, error) {
+	paste, err := m.getAny(id)
+	if err != nil {
+		return paste, err
+	}
+
+	if paste.Hidden {
+		return Paste{}, ErrNotFoundID
+	}
+
+	return paste, nil
+}
+
+// getAny is like get but also returns hidden pastes.
+func (m *Memory) getAny(id string) (Paste, error) {
+	paste, exist := m.pastes[id]
+	if exist == false {
+		return Paste{}, ErrNotFoundID
+	}
+
+	// Check paste expiration
+	if paste.DeleteTime < time.Now().Unix() && paste.DeleteTime > 0 {
+		delete(m.pastes, id)
+		delete(m.revisions, id)
+		m.deleteReports(id)
+		return Paste{}, ErrNotFoundID
+	}
+
+	return paste, nil
+}
+
+func (m *Memory) PasteGet(id string) (Paste, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	return m.get(id)
+}
+
+func (m *Memory) PasteGetAny(id string) (Paste, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	return m.getAny(id)
+}
+
+func (m *Memory) PasteGetRevision(id string, revision int) (Paste, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	paste, err := m.get(id)
+	if err != nil {
+		return paste, err
+	}
+
+	if revision < 1 || revision > paste.Revision {
+		return Paste{}, ErrNotFoundRevision
+	}
+
+	rev := m.revisions[id][revision-1]
+
+	paste.Title = rev.Title
+	paste.Body = rev.Body
+	paste.Syntax = rev.Syntax
+	paste.Revision = rev.Revision
+	paste.EditTime = 0
+	if revision > 1 {
+		paste.EditTime = rev.CreateTime
+	}
+
+	return paste, nil
+}
+
+func (m *Memory) PasteRevisions(id string) ([]PasteRevision, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	_, err := m.get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	revisions := m.revisions[id]
+
+	result := make([]PasteRevision, len(revisions))
+	for i, rev := range revisions {
+		result[len(revisions)-1-i] = rev
+	}
+
+	return result, nil
+}
+
+func (m *Memory) PasteEdit(id string, rev PasteRevision) (int, int64, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	paste, err := m.get(id)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	rev.Revision = paste.Revision + 1
+	rev.CreateTime = time.Now().Unix()
+
+	paste.Title = rev.Title
+	paste.Body = rev.Body
+	paste.Syntax = rev.Syntax
+	paste.Revision = rev.Revision
+	paste.EditTime = rev.CreateTime
+
+	m.pastes[id] = paste
+	m.revisions[id] = append(m.revisions[id], rev)
+
+	return rev.Revision, rev.CreateTime, nil
+}
+
+// PasteSetDeleteTime changes the paste expiration time, 0 means the paste never expires.
+func (m *Memory) PasteSetDeleteTime(id string, deleteTime int64) error {
+	m.Lock()
+	defer m.Unlock()
+
+	paste, err := m.get(id)
+	if err != nil {
+		return err
+	}
+
+	if deleteTime < 0 {
+		deleteTime = 0
+	}
+
+	paste.DeleteTime = deleteTime
+	m.pastes[id] = paste
+
+	return nil
+}
+
+func (m *Memory) PasteSetHidden(id string, hidden bool) error {
+	m.Lock()
+	defer m.Unlock()
+
+	paste, err := m.getAny(id)
+	if err != nil {
+		return err
+	}
+
+	paste.Hidden = hidden
+	m.pastes[id] = paste
+
+	return nil
+}
+
+func (m *Memory) PasteDeleteExpired() (int64, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	var count int64
+	timeNow := time.Now().Unix()
+
+	for id, paste := range m.pastes {
+		if paste.DeleteTime < timeNow && paste.DeleteTime > 0 {
+			delete(m.pastes, id)
+			delete(m.revisions, id)
+			m.deleteReports(id)
+			count++
+		}
+	}
+
+	return count, nil
+}
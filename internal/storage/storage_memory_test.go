@@ -0,0 +1,87 @@
+// Copyright (C) 2021-2023 Leonid Maslakov.
+
+// This file is part of Lenpaste.
+
+// Lenpaste is free software: you can redistribute it
+// and/or modify it under the terms of the
+// GNU Affero Public License as published by the
+// Free Software Foundation, either version 3 of the License,
+// or (at your option) any later version.
+
+// Lenpaste is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+// or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero Public License for more details.
+
+// You should have received a copy of the GNU Affero Public License along with Lenpaste.
+// If not, see <https://www.gnu.org/licenses/>.
+
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMemoryPaste(t *testing.T) {
+	var db Store = NewMemory()
+
+	// Add and get
+	id, _, _, err := db.PasteAdd(Paste{Title: "Title", Body: "Body", Syntax: "plaintext"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	paste, err := db.PasteGet(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if paste.ID != id || paste.Title != "Title" || paste.Body != "Body" {
+		t.Error("unexpected paste:", paste)
+	}
+
+	// Delete
+	err = db.PasteDelete(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = db.PasteGet(id)
+	if err != ErrNotFoundID {
+		t.Error("expected ErrNotFoundID but got", err)
+	}
+
+	err = db.PasteDelete(id)
+	if err != ErrNotFoundID {
+		t.Error("expected ErrNotFoundID but got", err)
+	}
+}
+
+func TestMemoryPasteDeleteExpired(t *testing.T) {
+	var db Store = NewMemory()
+
+	_, _, _, err := db.PasteAdd(Paste{Body: "expired", DeleteTime: time.Now().Unix() - 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id, _, _, err := db.PasteAdd(Paste{Body: "never"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	count, err := db.PasteDeleteExpired()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if count != 1 {
+		t.Error("expected 1 deleted paste but got", count)
+	}
+
+	_, err = db.PasteGet(id)
+	if err != nil {
+		t.Error(err)
+	}
+}
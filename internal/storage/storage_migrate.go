@@ -0,0 +1,721 @@
+// Copyright (C) 2021-2023 Leonid Maslakov.
+
+// This file is part of Lenpaste.
+
+// Lenpaste is free software: you can redistribute it
+// and/or modify it under the terms of the
+// GNU Affero Public License as published by the
+// Free Software Foundation, either version 3 of the License,
+// or (at your option) any later version.
+
+// Lenpaste is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+// or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero Public License for more details.
+
+// You should have received a copy of the GNU Affero Public License along with Lenpaste.
+// If not, see <https://www.gnu.org/licenses/>.
+
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrUnknownMigration = errors.New("db: unknown schema version")
+)
+
+type migration struct {
+	version int
+	name    string
+	up      func(tx *sql.Tx, driverName string) error
+	down    func(tx *sql.Tx, driverName string) error
+}
+
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	Applied   bool
+	ApplyTime int64
+}
+
+// Migrations must be sorted by version and never change after release.
+// Add a new migration to the end of the list instead.
+var migrations = []migration{
+	{
+		version: 1,
+		name:    "create pastes table",
+		up: func(tx *sql.Tx, driverName string) error {
+			return execAll(tx,
+				`CREATE TABLE IF NOT EXISTS pastes (
+					id          TEXT    PRIMARY KEY,
+					title       TEXT    NOT NULL,
+					body        TEXT    NOT NULL,
+					syntax      TEXT    NOT NULL,
+					create_time INTEGER NOT NULL,
+					delete_time INTEGER NOT NULL,
+					one_use     BOOL    NOT NULL
+				)`,
+			)
+		},
+		down: func(tx *sql.Tx, driverName string) error {
+			return execAll(tx, `DROP TABLE pastes`)
+		},
+	},
+	{
+		version: 2,
+		name:    "add paste author",
+		up: func(tx *sql.Tx, driverName string) error {
+			// Databases created before the migrations were introduced may already have these columns.
+			for _, column := range []string{"author", "author_email", "author_url"} {
+				err := addColumnIfNotExists(tx, driverName, "pastes", column, "TEXT NOT NULL DEFAULT ''")
+				if err != nil {
+					return err
+				}
+			}
+
+			return nil
+		},
+		down: func(tx *sql.Tx, driverName string) error {
+			return execAll(tx,
+				`ALTER TABLE pastes DROP COLUMN author`,
+				`ALTER TABLE pastes DROP COLUMN author_email`,
+				`ALTER TABLE pastes DROP COLUMN author_url`,
+			)
+		},
+	},
+	{
+		version: 3,
+		name:    "add paste delete token",
+		up: func(tx *sql.Tx, driverName string) error {
+			return execAll(tx, `ALTER TABLE pastes ADD COLUMN delete_token_hash TEXT NOT NULL DEFAULT ''`)
+		},
+		down: func(tx *sql.Tx, driverName string) error {
+			return execAll(tx, `ALTER TABLE pastes DROP COLUMN delete_token_hash`)
+		},
+	},
+	{
+		version: 4,
+		name:    "add paste revisions",
+		up: func(tx *sql.Tx, driverName string) error {
+			return execAll(tx,
+				`ALTER TABLE pastes ADD COLUMN revision INTEGER NOT NULL DEFAULT 1`,
+				`ALTER TABLE pastes ADD COLUMN edit_time INTEGER NOT NULL DEFAULT 0`,
+				`ALTER TABLE pastes ADD COLUMN edit_token_hash TEXT NOT NULL DEFAULT ''`,
+				`CREATE TABLE paste_revisions (
+					paste_id    TEXT    NOT NULL,
+					revision    INTEGER NOT NULL,
+					title       TEXT    NOT NULL,
+					body        TEXT    NOT NULL,
+					syntax      TEXT    NOT NULL,
+					create_time INTEGER NOT NULL,
+					PRIMARY KEY (paste_id, revision)
+				)`,
+				// Existing pastes become the first revision
+				`INSERT INTO paste_revisions (paste_id, revision, title, body, syntax, create_time)
+					SELECT id, 1, title, body, syntax, create_time FROM pastes`,
+			)
+		},
+		down: func(tx *sql.Tx, driverName string) error {
+			// Only the latest revision of each paste is kept
+			return execAll(tx,
+				`DROP TABLE paste_revisions`,
+				`ALTER TABLE pastes DROP COLUMN revision`,
+				`ALTER TABLE pastes DROP COLUMN edit_time`,
+				`ALTER TABLE pastes DROP COLUMN edit_token_hash`,
+			)
+		},
+	},
+	{
+		version: 5,
+		name:    "add paste password",
+		up: func(tx *sql.Tx, driverName string) error {
+			return execAll(tx, `ALTER TABLE pastes ADD COLUMN password_hash TEXT NOT NULL DEFAULT ''`)
+		},
+		down: func(tx *sql.Tx, driverName string) error {
+			return execAll(tx, `ALTER TABLE pastes DROP COLUMN password_hash`)
+		},
+	},
+	{
+		version: 6,
+		name:    "add encrypted pastes",
+		up: func(tx *sql.Tx, driverName string) error {
+			return execAll(tx, `ALTER TABLE pastes ADD COLUMN encrypted BOOL NOT NULL DEFAULT FALSE`)
+		},
+		down: func(tx *sql.Tx, driverName string) error {
+			return execAll(tx, `ALTER TABLE pastes DROP COLUMN encrypted`)
+		},
+	},
+	{
+		version: 7,
+		name:    "add multi-file pastes",
+		up: func(tx *sql.Tx, driverName string) error {
+			return execAll(tx, `CREATE TABLE paste_files (
+				paste_id TEXT    NOT NULL,
+				position INTEGER NOT NULL,
+				name     TEXT    NOT NULL,
+				body     TEXT    NOT NULL,
+				syntax   TEXT    NOT NULL,
+				PRIMARY KEY (paste_id, position)
+			)`)
+		},
+		down: func(tx *sql.Tx, driverName string) error {
+			// Only the first file of each paste is kept
+			return execAll(tx, `DROP TABLE paste_files`)
+		},
+	},
+	{
+		version: 8,
+		name:    "add paste forks",
+		up: func(tx *sql.Tx, driverName string) error {
+			return execAll(tx, `ALTER TABLE pastes ADD COLUMN forked_from TEXT NOT NULL DEFAULT ''`)
+		},
+		down: func(tx *sql.Tx, driverName string) error {
+			return execAll(tx, `ALTER TABLE pastes DROP COLUMN forked_from`)
+		},
+	},
+	{
+		version: 9,
+		name:    "add public pastes search",
+		up: func(tx *sql.Tx, driverName string) error {
+			err := execAll(tx, `ALTER TABLE pastes ADD COLUMN public BOOL NOT NULL DEFAULT FALSE`)
+			if err != nil {
+				return err
+			}
+
+			switch driverName {
+			case "sqlite3":
+				// FTS5 is available only if Lenpaste is built with the sqlite_fts5 tag.
+				// Otherwise the search falls back to LIKE, see DB.PasteSearch.
+				var fts5 bool
+				err = tx.QueryRow(`SELECT sqlite_compileoption_used('ENABLE_FTS5')`).Scan(&fts5)
+				if err != nil {
+					return err
+				}
+
+				if fts5 == false {
+					return nil
+				}
+
+				err = execAll(tx,
+					`CREATE VIRTUAL TABLE pastes_fts USING fts5(id UNINDEXED, title, body)`,
+					`CREATE TRIGGER pastes_fts_delete AFTER DELETE ON pastes BEGIN
+						DELETE FROM pastes_fts WHERE id = old.id;
+					END`,
+				)
+				if err != nil {
+					return err
+				}
+
+				return createSearchTriggers(tx, driverName, "new.public")
+
+			default:
+				return execAll(tx,
+					`ALTER TABLE pastes ADD COLUMN search TSVECTOR GENERATED ALWAYS AS (to_tsvector('simple', title || ' ' || body)) STORED`,
+					`CREATE INDEX pastes_search_idx ON pastes USING GIN (search)`,
+				)
+			}
+		},
+		down: func(tx *sql.Tx, driverName string) error {
+			switch driverName {
+			case "sqlite3":
+				err := execAll(tx,
+					`DROP TRIGGER IF EXISTS pastes_fts_insert`,
+					`DROP TRIGGER IF EXISTS pastes_fts_update`,
+					`DROP TRIGGER IF EXISTS pastes_fts_delete`,
+					`DROP TABLE IF EXISTS pastes_fts`,
+				)
+				if err != nil {
+					return err
+				}
+
+			default:
+				err := execAll(tx,
+					`DROP INDEX pastes_search_idx`,
+					`ALTER TABLE pastes DROP COLUMN search`,
+				)
+				if err != nil {
+					return err
+				}
+			}
+
+			return execAll(tx, `ALTER TABLE pastes DROP COLUMN public`)
+		},
+	},
+	{
+		version: 10,
+		name:    "add paste visibility",
+		up: func(tx *sql.Tx, driverName string) error {
+			// Search triggers depend on the old column
+			err := dropSearchTriggers(tx, driverName)
+			if err != nil {
+				return err
+			}
+
+			err = execAll(tx,
+				`ALTER TABLE pastes ADD COLUMN visibility TEXT NOT NULL DEFAULT 'unlisted'`,
+				`UPDATE pastes SET visibility = 'public' WHERE public`,
+				`ALTER TABLE pastes DROP COLUMN public`,
+			)
+			if err != nil {
+				return err
+			}
+
+			return createSearchTriggers(tx, driverName, "new.visibility = 'public'")
+		},
+		down: func(tx *sql.Tx, driverName string) error {
+			err := dropSearchTriggers(tx, driverName)
+			if err != nil {
+				return err
+			}
+
+			// Private pastes become unlisted
+			err = execAll(tx,
+				`ALTER TABLE pastes ADD COLUMN public BOOL NOT NULL DEFAULT FALSE`,
+				`UPDATE pastes SET public = TRUE WHERE visibility = 'public'`,
+				`ALTER TABLE pastes DROP COLUMN visibility`,
+			)
+			if err != nil {
+				return err
+			}
+
+			return createSearchTriggers(tx, driverName, "new.public")
+		},
+	},
+	{
+		version: 11,
+		name:    "add API tokens",
+		up: func(tx *sql.Tx, driverName string) error {
+			return execAll(tx,
+				`CREATE TABLE api_tokens (
+					id             TEXT    PRIMARY KEY,
+					name           TEXT    NOT NULL,
+					token_hash     TEXT    NOT NULL UNIQUE,
+					scopes         TEXT    NOT NULL,
+					create_time    BIGINT  NOT NULL,
+					expire_time    BIGINT  NOT NULL,
+					last_used_time BIGINT  NOT NULL DEFAULT 0
+				)`,
+				`ALTER TABLE pastes ADD COLUMN token_id TEXT NOT NULL DEFAULT ''`,
+			)
+		},
+		down: func(tx *sql.Tx, driverName string) error {
+			return execAll(tx,
+				`DROP TABLE api_tokens`,
+				`ALTER TABLE pastes DROP COLUMN token_id`,
+			)
+		},
+	},
+	{
+		version: 12,
+		name:    "add user accounts",
+		up: func(tx *sql.Tx, driverName string) error {
+			return execAll(tx,
+				`CREATE TABLE users (
+					id            TEXT    PRIMARY KEY,
+					name          TEXT    NOT NULL UNIQUE,
+					password_hash TEXT    NOT NULL,
+					admin         BOOL    NOT NULL DEFAULT FALSE,
+					create_time   BIGINT  NOT NULL
+				)`,
+				`CREATE TABLE sessions (
+					id_hash     TEXT    PRIMARY KEY,
+					user_id     TEXT    NOT NULL,
+					create_time BIGINT  NOT NULL,
+					expire_time BIGINT  NOT NULL
+				)`,
+				`ALTER TABLE pastes ADD COLUMN user_id TEXT NOT NULL DEFAULT ''`,
+			)
+		},
+		down: func(tx *sql.Tx, driverName string) error {
+			return execAll(tx,
+				`DROP TABLE sessions`,
+				`DROP TABLE users`,
+				`ALTER TABLE pastes DROP COLUMN user_id`,
+			)
+		},
+	},
+	{
+		version: 13,
+		name:    "add OpenID Connect users",
+		up: func(tx *sql.Tx, driverName string) error {
+			return execAll(tx,
+				`ALTER TABLE users ADD COLUMN email TEXT NOT NULL DEFAULT ''`,
+				`ALTER TABLE users ADD COLUMN oidc_subject TEXT NOT NULL DEFAULT ''`,
+			)
+		},
+		down: func(tx *sql.Tx, driverName string) error {
+			return execAll(tx,
+				`ALTER TABLE users DROP COLUMN oidc_subject`,
+				`ALTER TABLE users DROP COLUMN email`,
+			)
+		},
+	},
+	{
+		version: 14,
+		name:    "add admin panel",
+		up: func(tx *sql.Tx, driverName string) error {
+			// Audit log is sorted by ID, so it must grow
+			auditID := `BIGSERIAL PRIMARY KEY`
+			if driverName == "sqlite3" {
+				auditID = `INTEGER PRIMARY KEY AUTOINCREMENT`
+			}
+
+			return execAll(tx,
+				`ALTER TABLE pastes ADD COLUMN creator_ip TEXT NOT NULL DEFAULT ''`,
+				`CREATE TABLE bans (
+					id          TEXT    PRIMARY KEY,
+					cidr        TEXT    NOT NULL UNIQUE,
+					create_time BIGINT  NOT NULL
+				)`,
+				`CREATE TABLE audit_log (
+					id      `+auditID+`,
+					time    BIGINT  NOT NULL,
+					admin   TEXT    NOT NULL,
+					action  TEXT    NOT NULL,
+					target  TEXT    NOT NULL,
+					details TEXT    NOT NULL DEFAULT ''
+				)`,
+			)
+		},
+		down: func(tx *sql.Tx, driverName string) error {
+			return execAll(tx,
+				`DROP TABLE audit_log`,
+				`DROP TABLE bans`,
+				`ALTER TABLE pastes DROP COLUMN creator_ip`,
+			)
+		},
+	},
+	{
+		version: 15,
+		name:    "add abuse reports",
+		up: func(tx *sql.Tx, driverName string) error {
+			return execAll(tx,
+				`ALTER TABLE pastes ADD COLUMN hidden BOOL NOT NULL DEFAULT FALSE`,
+				`CREATE TABLE reports (
+					id          TEXT    PRIMARY KEY,
+					paste_id    TEXT    NOT NULL,
+					reason      TEXT    NOT NULL,
+					comment     TEXT    NOT NULL,
+					reporter_ip TEXT    NOT NULL,
+					create_time BIGINT  NOT NULL
+				)`,
+				`CREATE INDEX reports_paste_id ON reports (paste_id)`,
+			)
+		},
+		down: func(tx *sql.Tx, driverName string) error {
+			return execAll(tx,
+				`DROP TABLE reports`,
+				`ALTER TABLE pastes DROP COLUMN hidden`,
+			)
+		},
+	},
+	{
+		version: 16,
+		name:    "add ban reason and expiration",
+		up: func(tx *sql.Tx, driverName string) error {
+			return execAll(tx,
+				`ALTER TABLE bans ADD COLUMN reason TEXT NOT NULL DEFAULT ''`,
+				`ALTER TABLE bans ADD COLUMN expire_time BIGINT NOT NULL DEFAULT 0`,
+			)
+		},
+		down: func(tx *sql.Tx, driverName string) error {
+			return execAll(tx,
+				`ALTER TABLE bans DROP COLUMN expire_time`,
+				`ALTER TABLE bans DROP COLUMN reason`,
+			)
+		},
+	},
+}
+
+// createSearchTriggers creates SQLite3 triggers that keep only the public pastes in the FTS5 index.
+// It does nothing if the index does not exist.
+func createSearchTriggers(tx *sql.Tx, driverName string, publicExpr string) error {
+	if driverName != "sqlite3" {
+		return nil
+	}
+
+	var ftsExist int
+	err := tx.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'pastes_fts'`).Scan(&ftsExist)
+	if err != nil {
+		return err
+	}
+
+	if ftsExist == 0 {
+		return nil
+	}
+
+	return execAll(tx,
+		`CREATE TRIGGER pastes_fts_insert AFTER INSERT ON pastes WHEN `+publicExpr+` BEGIN
+			INSERT INTO pastes_fts (id, title, body) VALUES (new.id, new.title, new.body);
+		END`,
+		`CREATE TRIGGER pastes_fts_update AFTER UPDATE ON pastes BEGIN
+			DELETE FROM pastes_fts WHERE id = old.id;
+			INSERT INTO pastes_fts (id, title, body) SELECT new.id, new.title, new.body WHERE `+publicExpr+`;
+		END`,
+	)
+}
+
+func dropSearchTriggers(tx *sql.Tx, driverName string) error {
+	if driverName != "sqlite3" {
+		return nil
+	}
+
+	return execAll(tx,
+		`DROP TRIGGER IF EXISTS pastes_fts_insert`,
+		`DROP TRIGGER IF EXISTS pastes_fts_update`,
+	)
+}
+
+func execAll(tx *sql.Tx, queries ...string) error {
+	for _, query := range queries {
+		_, err := tx.Exec(query)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func columnExists(tx *sql.Tx, driverName string, table string, column string) (bool, error) {
+	var query string
+
+	switch driverName {
+	case "sqlite3":
+		query = `SELECT COUNT(*) FROM pragma_table_info($1) WHERE name = $2`
+	default:
+		query = `SELECT COUNT(*) FROM information_schema.columns WHERE table_schema = current_schema() AND table_name = $1 AND column_name = $2`
+	}
+
+	var count int
+	err := tx.QueryRow(query, table, column).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+
+	return count != 0, nil
+}
+
+func addColumnIfNotExists(tx *sql.Tx, driverName string, table string, column string, definition string) error {
+	exist, err := columnExists(tx, driverName, table, column)
+	if err != nil {
+		return err
+	}
+
+	if exist {
+		return nil
+	}
+
+	_, err = tx.Exec(`ALTER TABLE ` + table + ` ADD COLUMN ` + column + ` ` + definition)
+	return err
+}
+
+// LatestSchemaVersion returns the version of the last known migration.
+func LatestSchemaVersion() int {
+	return migrations[len(migrations)-1].version
+}
+
+func (db DB) initSchemaVersion() error {
+	_, err := db.pool.Exec(`
+		CREATE TABLE IF NOT EXISTS schema_version (
+			version    INTEGER PRIMARY KEY,
+			name       TEXT    NOT NULL,
+			apply_time INTEGER NOT NULL
+		);
+	`)
+	return err
+}
+
+func (db DB) appliedMigrations() (map[int]int64, error) {
+	err := db.initSchemaVersion()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.pool.Query(`SELECT version, apply_time FROM schema_version`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]int64)
+	for rows.Next() {
+		var version int
+		var applyTime int64
+
+		err = rows.Scan(&version, &applyTime)
+		if err != nil {
+			return nil, err
+		}
+
+		applied[version] = applyTime
+	}
+
+	return applied, rows.Err()
+}
+
+// SchemaVersion returns the version of the last applied migration or 0 if the DB is empty.
+func (db DB) SchemaVersion() (int, error) {
+	applied, err := db.appliedMigrations()
+	if err != nil {
+		return 0, err
+	}
+
+	version := 0
+	for v := range applied {
+		if v > version {
+			version = v
+		}
+	}
+
+	return version, nil
+}
+
+// MigrateStatus returns all known migrations and whether they are applied.
+func (db DB) MigrateStatus() ([]MigrationStatus, error) {
+	applied, err := db.appliedMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	var result []MigrationStatus
+	for _, m := range migrations {
+		applyTime, ok := applied[m.version]
+		result = append(result, MigrationStatus{
+			Version:   m.version,
+			Name:      m.name,
+			Applied:   ok,
+			ApplyTime: applyTime,
+		})
+	}
+
+	return result, nil
+}
+
+// runMigration applies or reverts one migration in a separate transaction.
+func (db DB) runMigration(m migration, up bool) (bool, error) {
+	tx, err := db.pool.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	// Do not let two Lenpaste instances migrate the same DB at once
+	if db.driver == "postgres" {
+		_, err = tx.Exec(`LOCK TABLE schema_version IN EXCLUSIVE MODE`)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	// Someone could have migrated the DB while we were waiting
+	var count int
+	err = tx.QueryRow(`SELECT COUNT(*) FROM schema_version WHERE version = $1`, m.version).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+
+	if up == (count != 0) {
+		return false, nil
+	}
+
+	// Migrate
+	if up {
+		err = m.up(tx, db.driver)
+		if err != nil {
+			return false, errors.New("db: migration " + strconv.Itoa(m.version) + " (" + m.name + "): " + err.Error())
+		}
+
+		_, err = tx.Exec(`INSERT INTO schema_version (version, name, apply_time) VALUES ($1, $2, $3)`, m.version, m.name, time.Now().Unix())
+		if err != nil {
+			return false, err
+		}
+
+	} else {
+		err = m.down(tx, db.driver)
+		if err != nil {
+			return false, errors.New("db: revert migration " + strconv.Itoa(m.version) + " (" + m.name + "): " + err.Error())
+		}
+
+		_, err = tx.Exec(`DELETE FROM schema_version WHERE version = $1`, m.version)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	return true, tx.Commit()
+}
+
+// MigrateUp applies all pending migrations up to and including the target version.
+// If target is 0 the DB is migrated to the latest version.
+// Returns the number of applied migrations.
+func (db DB) MigrateUp(target int) (int, error) {
+	if target == 0 {
+		target = LatestSchemaVersion()
+	}
+
+	if target < 0 || target > LatestSchemaVersion() {
+		return 0, ErrUnknownMigration
+	}
+
+	err := db.initSchemaVersion()
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, m := range migrations {
+		if m.version > target {
+			break
+		}
+
+		ok, err := db.runMigration(m, true)
+		if err != nil {
+			return count, err
+		}
+
+		if ok {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+// MigrateDown reverts all applied migrations newer than the target version.
+// If target is 0 the DB will be cleared completely.
+// Returns the number of reverted migrations.
+func (db DB) MigrateDown(target int) (int, error) {
+	if target < 0 || target > LatestSchemaVersion() {
+		return 0, ErrUnknownMigration
+	}
+
+	err := db.initSchemaVersion()
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for i := len(migrations) - 1; i >= 0; i-- {
+		m := migrations[i]
+		if m.version <= target {
+			break
+		}
+
+		ok, err := db.runMigration(m, false)
+		if err != nil {
+			return count, err
+		}
+
+		if ok {
+			count++
+		}
+	}
+
+	return count, nil
+}
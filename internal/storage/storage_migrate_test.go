@@ -0,0 +1,70 @@
+// Copyright (C) 2021-2023 Leonid Maslakov.
+
+// This file is part of Lenpaste.
+
+// Lenpaste is free software: you can redistribute it
+// and/or modify it under the terms of the
+// GNU Affero Public License as published by the
+// Free Software Foundation, either version 3 of the License,
+// or (at your option) any later version.
+
+// Lenpaste is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+// or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero Public License for more details.
+
+// You should have received a copy of the GNU Affero Public License along with Lenpaste.
+// If not, see <https://www.gnu.org/licenses/>.
+
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestMigrateUpDown(t *testing.T) {
+	db, err := NewPool("sqlite3", filepath.Join(t.TempDir(), "lenpaste.db"), 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// Apply all migrations twice
+	for i := 0; i < 2; i++ {
+		_, err = db.MigrateUp(0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		version, err := db.SchemaVersion()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if version != LatestSchemaVersion() {
+			t.Fatal("expected schema version", LatestSchemaVersion(), "but got", version)
+		}
+	}
+
+	// Revert and apply every migration one by one
+	for target := LatestSchemaVersion() - 1; target >= 0; target-- {
+		count, err := db.MigrateDown(target)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if count != 1 {
+			t.Fatal("expected 1 reverted migration but got", count, "(target:", target, ")")
+		}
+	}
+
+	count, err := db.MigrateUp(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if count != LatestSchemaVersion() {
+		t.Error("expected", LatestSchemaVersion(), "applied migrations but got", count)
+	}
+}
@@ -23,6 +23,19 @@ import (
 	"time"
 )
 
+// Paste visibility.
+// Unlisted paste can be opened by anyone who knows its ID.
+// Public paste is also listed on the recent pastes page and can be found by the search.
+// Private paste can be opened only by its owner.
+const (
+	VisibilityPrivate  = "private"
+	VisibilityUnlisted = "unlisted"
+	VisibilityPublic   = "public"
+)
+
+// Visibilities are all paste visibilities.
+var Visibilities = []string{VisibilityPrivate, VisibilityUnlisted, VisibilityPublic}
+
 type Paste struct {
 	ID         string `json:"id"` // Ignored when creating
 	Title      string `json:"title"`
@@ -31,10 +44,48 @@ type Paste struct {
 	DeleteTime int64  `json:"deleteTime"`
 	OneUse     bool   `json:"oneUse"`
 	Syntax     string `json:"syntax"`
+	Encrypted  bool   `json:"encrypted"`  // Body is encrypted on the client side, the server never sees the key
+	Visibility string `json:"visibility"` // VisibilityPrivate, VisibilityUnlisted or VisibilityPublic, empty means unlisted when creating
 
 	Author      string `json:"author"`
 	AuthorEmail string `json:"authorEmail"`
 	AuthorURL   string `json:"authorURL"`
+
+	Revision int   `json:"revision"` // Ignored when creating
+	EditTime int64 `json:"editTime"` // Ignored when creating, 0 if the paste has never been edited
+
+	ForkedFrom string `json:"forkedFrom"` // ID of the original paste or empty
+
+	DeleteTokenHash string `json:"-"` // SHA-256 of the owner delete token, see NewSecret
+	EditTokenHash   string `json:"-"` // SHA-256 of the owner edit token, see NewSecret
+	PasswordHash    string `json:"-"` // Argon2id hash of the paste password or empty, see HashPassword
+
+	TokenID string `json:"-"` // ID of the API token the paste was created with or empty
+	UserID  string `json:"-"` // ID of the user account the paste was created with or empty
+
+	CreatorIP string `json:"-"` // IP address of the client that created the paste, shown only to administrators
+	Hidden    bool   `json:"-"` // Paste is hidden because of abuse reports until the administrator checks it
+
+	// Files of the multi-file paste, empty for the usual paste.
+	// Body and Syntax of the paste are the same as of the first file.
+	Files []PasteFile `json:"files,omitempty"`
+}
+
+// PasteFile is one named file of the multi-file paste.
+type PasteFile struct {
+	Name   string `json:"name"`
+	Body   string `json:"body"`
+	Syntax string `json:"syntax"`
+}
+
+// PasteRevision is one version of the paste text.
+// The first revision is created together with the paste.
+type PasteRevision struct {
+	Revision   int    `json:"revision"` // Ignored when editing
+	Title      string `json:"title"`
+	Body       string `json:"body"`
+	Syntax     string `json:"syntax"`
+	CreateTime int64  `json:"createTime"` // Ignored when editing
 }
 
 func (db DB) PasteAdd(paste Paste) (string, int64, int64, error) {
@@ -54,21 +105,60 @@ func (db DB) PasteAdd(paste Paste) (string, int64, int64, error) {
 		paste.DeleteTime = 0
 	}
 
+	if paste.Visibility == "" {
+		paste.Visibility = VisibilityUnlisted
+	}
+
 	// Add
-	_, err = db.pool.Exec(
-		`INSERT INTO pastes (id, title, body, syntax, create_time, delete_time, one_use, author, author_email, author_url) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
-		paste.ID, paste.Title, paste.Body, paste.Syntax, paste.CreateTime, paste.DeleteTime, paste.OneUse, paste.Author, paste.AuthorEmail, paste.AuthorURL,
+	tx, err := db.pool.Begin()
+	if err != nil {
+		return paste.ID, paste.CreateTime, paste.DeleteTime, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(
+		`INSERT INTO pastes (id, title, body, syntax, create_time, delete_time, one_use, author, author_email, author_url, delete_token_hash, revision, edit_time, edit_token_hash, password_hash, encrypted, forked_from, visibility, token_id, user_id, creator_ip) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, 1, 0, $12, $13, $14, $15, $16, $17, $18, $19)`,
+		paste.ID, paste.Title, paste.Body, paste.Syntax, paste.CreateTime, paste.DeleteTime, paste.OneUse, paste.Author, paste.AuthorEmail, paste.AuthorURL, paste.DeleteTokenHash, paste.EditTokenHash, paste.PasswordHash, paste.Encrypted, paste.ForkedFrom, paste.Visibility, paste.TokenID, paste.UserID, paste.CreatorIP,
+	)
+	if err != nil {
+		return paste.ID, paste.CreateTime, paste.DeleteTime, err
+	}
+
+	_, err = tx.Exec(
+		`INSERT INTO paste_revisions (paste_id, revision, title, body, syntax, create_time) VALUES ($1, 1, $2, $3, $4, $5)`,
+		paste.ID, paste.Title, paste.Body, paste.Syntax, paste.CreateTime,
 	)
 	if err != nil {
 		return paste.ID, paste.CreateTime, paste.DeleteTime, err
 	}
 
+	for i, file := range paste.Files {
+		_, err = tx.Exec(
+			`INSERT INTO paste_files (paste_id, position, name, body, syntax) VALUES ($1, $2, $3, $4, $5)`,
+			paste.ID, i, file.Name, file.Body, file.Syntax,
+		)
+		if err != nil {
+			return paste.ID, paste.CreateTime, paste.DeleteTime, err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return paste.ID, paste.CreateTime, paste.DeleteTime, err
+	}
+
 	return paste.ID, paste.CreateTime, paste.DeleteTime, nil
 }
 
 func (db DB) PasteDelete(id string) error {
+	tx, err := db.pool.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
 	// Delete
-	result, err := db.pool.Exec(
+	result, err := tx.Exec(
 		`DELETE FROM pastes WHERE id = $1`,
 		id,
 	)
@@ -86,20 +176,60 @@ func (db DB) PasteDelete(id string) error {
 		return ErrNotFoundID
 	}
 
-	return nil
+	// Delete revisions and files
+	_, err = tx.Exec(
+		`DELETE FROM paste_revisions WHERE paste_id = $1`,
+		id,
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		`DELETE FROM paste_files WHERE paste_id = $1`,
+		id,
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		`DELETE FROM reports WHERE paste_id = $1`,
+		id,
+	)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
+// PasteGet returns the paste. Hidden pastes are not found, see PasteGetAny.
 func (db DB) PasteGet(id string) (Paste, error) {
+	paste, err := db.PasteGetAny(id)
+	if err != nil {
+		return paste, err
+	}
+
+	if paste.Hidden {
+		return Paste{}, ErrNotFoundID
+	}
+
+	return paste, nil
+}
+
+// PasteGetAny returns the paste even if it is hidden.
+func (db DB) PasteGetAny(id string) (Paste, error) {
 	var paste Paste
 
 	// Make query
 	row := db.pool.QueryRow(
-		`SELECT id, title, body, syntax, create_time, delete_time, one_use, author, author_email, author_url FROM pastes WHERE id = $1`,
+		`SELECT id, title, body, syntax, create_time, delete_time, one_use, author, author_email, author_url, delete_token_hash, revision, edit_time, edit_token_hash, password_hash, encrypted, forked_from, visibility, token_id, user_id, creator_ip, hidden FROM pastes WHERE id = $1`,
 		id,
 	)
 
 	// Read query
-	err := row.Scan(&paste.ID, &paste.Title, &paste.Body, &paste.Syntax, &paste.CreateTime, &paste.DeleteTime, &paste.OneUse, &paste.Author, &paste.AuthorEmail, &paste.AuthorURL)
+	err := row.Scan(&paste.ID, &paste.Title, &paste.Body, &paste.Syntax, &paste.CreateTime, &paste.DeleteTime, &paste.OneUse, &paste.Author, &paste.AuthorEmail, &paste.AuthorURL, &paste.DeleteTokenHash, &paste.Revision, &paste.EditTime, &paste.EditTokenHash, &paste.PasswordHash, &paste.Encrypted, &paste.ForkedFrom, &paste.Visibility, &paste.TokenID, &paste.UserID, &paste.CreatorIP, &paste.Hidden)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return paste, ErrNotFoundID
@@ -111,11 +241,8 @@ func (db DB) PasteGet(id string) (Paste, error) {
 	// Check paste expiration
 	if paste.DeleteTime < time.Now().Unix() && paste.DeleteTime > 0 {
 		// Delete expired paste
-		_, err = db.pool.Exec(
-			`DELETE FROM pastes WHERE id = $1`,
-			paste.ID,
-		)
-		if err != nil {
+		err = db.PasteDelete(paste.ID)
+		if err != nil && err != ErrNotFoundID {
 			return Paste{}, err
 		}
 
@@ -123,14 +250,251 @@ func (db DB) PasteGet(id string) (Paste, error) {
 		return Paste{}, ErrNotFoundID
 	}
 
+	// Read files
+	paste.Files, err = db.pasteFiles(paste.ID)
+	if err != nil {
+		return Paste{}, err
+	}
+
 	return paste, nil
 }
 
+func (db DB) pasteFiles(id string) ([]PasteFile, error) {
+	rows, err := db.pool.Query(
+		`SELECT name, body, syntax FROM paste_files WHERE paste_id = $1 ORDER BY position`,
+		id,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var files []PasteFile
+	for rows.Next() {
+		var file PasteFile
+
+		err = rows.Scan(&file.Name, &file.Body, &file.Syntax)
+		if err != nil {
+			return nil, err
+		}
+
+		files = append(files, file)
+	}
+
+	return files, rows.Err()
+}
+
+// PasteGetRevision returns the paste with the title, body and syntax of the given revision.
+func (db DB) PasteGetRevision(id string, revision int) (Paste, error) {
+	paste, err := db.PasteGet(id)
+	if err != nil {
+		return paste, err
+	}
+
+	// Latest revision is stored in the paste itself
+	if revision == paste.Revision {
+		return paste, nil
+	}
+
+	if revision < 1 || revision > paste.Revision {
+		return Paste{}, ErrNotFoundRevision
+	}
+
+	// Make query
+	row := db.pool.QueryRow(
+		`SELECT title, body, syntax, create_time FROM paste_revisions WHERE paste_id = $1 AND revision = $2`,
+		id, revision,
+	)
+
+	// Read query
+	var revTime int64
+	err = row.Scan(&paste.Title, &paste.Body, &paste.Syntax, &revTime)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return Paste{}, ErrNotFoundRevision
+		}
+
+		return Paste{}, err
+	}
+
+	paste.Revision = revision
+	paste.EditTime = 0
+	if revision > 1 {
+		paste.EditTime = revTime
+	}
+
+	return paste, nil
+}
+
+// PasteRevisions returns all paste revisions from newest to oldest.
+func (db DB) PasteRevisions(id string) ([]PasteRevision, error) {
+	// Check that paste exists and is not expired
+	_, err := db.PasteGet(id)
+	if err != nil {
+		return nil, err
+	}
+
+	// Make query
+	rows, err := db.pool.Query(
+		`SELECT revision, title, body, syntax, create_time FROM paste_revisions WHERE paste_id = $1 ORDER BY revision DESC`,
+		id,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Read query
+	var revisions []PasteRevision
+	for rows.Next() {
+		var rev PasteRevision
+
+		err = rows.Scan(&rev.Revision, &rev.Title, &rev.Body, &rev.Syntax, &rev.CreateTime)
+		if err != nil {
+			return nil, err
+		}
+
+		revisions = append(revisions, rev)
+	}
+
+	return revisions, rows.Err()
+}
+
+// PasteEdit adds a new revision to the paste and makes it the current one.
+// Returns the new revision number and its create time.
+func (db DB) PasteEdit(id string, rev PasteRevision) (int, int64, error) {
+	rev.CreateTime = time.Now().Unix()
+
+	tx, err := db.pool.Begin()
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback()
+
+	// Get current revision
+	var deleteTime int64
+	err = tx.QueryRow(
+		`SELECT revision, delete_time FROM pastes WHERE id = $1`,
+		id,
+	).Scan(&rev.Revision, &deleteTime)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, 0, ErrNotFoundID
+		}
+
+		return 0, 0, err
+	}
+
+	if deleteTime < rev.CreateTime && deleteTime > 0 {
+		return 0, 0, ErrNotFoundID
+	}
+
+	rev.Revision++
+
+	// Save revision
+	_, err = tx.Exec(
+		`INSERT INTO paste_revisions (paste_id, revision, title, body, syntax, create_time) VALUES ($1, $2, $3, $4, $5, $6)`,
+		id, rev.Revision, rev.Title, rev.Body, rev.Syntax, rev.CreateTime,
+	)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	_, err = tx.Exec(
+		`UPDATE pastes SET title = $1, body = $2, syntax = $3, revision = $4, edit_time = $5 WHERE id = $6`,
+		rev.Title, rev.Body, rev.Syntax, rev.Revision, rev.CreateTime, id,
+	)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return rev.Revision, rev.CreateTime, nil
+}
+
+// PasteSetDeleteTime changes the paste expiration time, 0 means the paste never expires.
+func (db DB) PasteSetDeleteTime(id string, deleteTime int64) error {
+	if deleteTime < 0 {
+		deleteTime = 0
+	}
+
+	// Expired paste can not be extended
+	_, err := db.PasteGet(id)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.pool.Exec(
+		`UPDATE pastes SET delete_time = $1 WHERE id = $2`,
+		deleteTime, id,
+	)
+	return err
+}
+
+// PasteSetHidden hides the paste or shows it again.
+func (db DB) PasteSetHidden(id string, hidden bool) error {
+	result, err := db.pool.Exec(
+		`UPDATE pastes SET hidden = $1 WHERE id = $2`,
+		hidden, id,
+	)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrNotFoundID
+	}
+
+	return nil
+}
+
 func (db DB) PasteDeleteExpired() (int64, error) {
+	tx, err := db.pool.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	timeNow := time.Now().Unix()
+
+	// Delete revisions and files
+	_, err = tx.Exec(
+		`DELETE FROM paste_revisions WHERE paste_id IN (SELECT id FROM pastes WHERE (delete_time < $1) AND (delete_time > 0))`,
+		timeNow,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.Exec(
+		`DELETE FROM paste_files WHERE paste_id IN (SELECT id FROM pastes WHERE (delete_time < $1) AND (delete_time > 0))`,
+		timeNow,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.Exec(
+		`DELETE FROM reports WHERE paste_id IN (SELECT id FROM pastes WHERE (delete_time < $1) AND (delete_time > 0))`,
+		timeNow,
+	)
+	if err != nil {
+		return 0, err
+	}
+
 	// Delete
-	result, err := db.pool.Exec(
+	result, err := tx.Exec(
 		`DELETE FROM pastes WHERE (delete_time < $1) AND (delete_time > 0)`,
-		time.Now().Unix(),
+		timeNow,
 	)
 	if err != nil {
 		return 0, err
@@ -142,5 +506,5 @@ func (db DB) PasteDeleteExpired() (int64, error) {
 		return rowsAffected, err
 	}
 
-	return rowsAffected, nil
+	return rowsAffected, tx.Commit()
 }
@@ -0,0 +1,173 @@
+// Copyright (C) 2021-2023 Leonid Maslakov.
+
+// This file is part of Lenpaste.
+
+// Lenpaste is free software: you can redistribute it
+// and/or modify it under the terms of the
+// GNU Affero Public License as published by the
+// Free Software Foundation, either version 3 of the License,
+// or (at your option) any later version.
+
+// Lenpaste is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+// or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero Public License for more details.
+
+// You should have received a copy of the GNU Affero Public License along with Lenpaste.
+// If not, see <https://www.gnu.org/licenses/>.
+
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// testStores returns all Store implementations that can be tested without external services.
+func testStores(t *testing.T) map[string]Store {
+	db, err := NewPool("sqlite3", filepath.Join(t.TempDir(), "lenpaste.db"), 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	_, err = db.MigrateUp(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return map[string]Store{
+		"memory":  NewMemory(),
+		"sqlite3": db,
+	}
+}
+
+func TestPasteRevisions(t *testing.T) {
+	for name, db := range testStores(t) {
+		// Create paste
+		id, _, _, err := db.PasteAdd(Paste{Title: "v1", Body: "Body 1", Syntax: "plaintext"})
+		if err != nil {
+			t.Fatal(name, err)
+		}
+
+		paste, err := db.PasteGet(id)
+		if err != nil {
+			t.Fatal(name, err)
+		}
+
+		if paste.Revision != 1 || paste.EditTime != 0 {
+			t.Error(name, "unexpected new paste:", paste)
+		}
+
+		// Edit paste
+		rev, editTime, err := db.PasteEdit(id, PasteRevision{Title: "v2", Body: "Body 2", Syntax: "go"})
+		if err != nil {
+			t.Fatal(name, err)
+		}
+
+		if rev != 2 || editTime == 0 {
+			t.Error(name, "unexpected revision", rev, editTime)
+		}
+
+		// Latest revision
+		paste, err = db.PasteGet(id)
+		if err != nil {
+			t.Fatal(name, err)
+		}
+
+		if paste.Title != "v2" || paste.Body != "Body 2" || paste.Syntax != "go" || paste.Revision != 2 || paste.EditTime != editTime {
+			t.Error(name, "unexpected edited paste:", paste)
+		}
+
+		// Old revision
+		paste, err = db.PasteGetRevision(id, 1)
+		if err != nil {
+			t.Fatal(name, err)
+		}
+
+		if paste.Title != "v1" || paste.Body != "Body 1" || paste.Syntax != "plaintext" || paste.Revision != 1 || paste.EditTime != 0 {
+			t.Error(name, "unexpected first revision:", paste)
+		}
+
+		_, err = db.PasteGetRevision(id, 3)
+		if err != ErrNotFoundRevision {
+			t.Error(name, "expected ErrNotFoundRevision but got", err)
+		}
+
+		// Revisions list
+		revisions, err := db.PasteRevisions(id)
+		if err != nil {
+			t.Fatal(name, err)
+		}
+
+		if len(revisions) != 2 || revisions[0].Revision != 2 || revisions[1].Revision != 1 {
+			t.Error(name, "unexpected revisions:", revisions)
+		}
+
+		// Delete paste with all revisions
+		err = db.PasteDelete(id)
+		if err != nil {
+			t.Fatal(name, err)
+		}
+
+		_, err = db.PasteRevisions(id)
+		if err != ErrNotFoundID {
+			t.Error(name, "expected ErrNotFoundID but got", err)
+		}
+
+		_, _, err = db.PasteEdit(id, PasteRevision{Body: "Body 3"})
+		if err != ErrNotFoundID {
+			t.Error(name, "expected ErrNotFoundID but got", err)
+		}
+	}
+}
+
+func TestPasteFiles(t *testing.T) {
+	for name, db := range testStores(t) {
+		files := []PasteFile{
+			{Name: "Dockerfile", Body: "FROM alpine", Syntax: "Docker"},
+			{Name: "entrypoint.sh", Body: "#!/bin/sh", Syntax: "Bash"},
+		}
+
+		// Create paste
+		id, _, _, err := db.PasteAdd(Paste{Body: files[0].Body, Syntax: files[0].Syntax, Files: files})
+		if err != nil {
+			t.Fatal(name, err)
+		}
+
+		paste, err := db.PasteGet(id)
+		if err != nil {
+			t.Fatal(name, err)
+		}
+
+		if len(paste.Files) != 2 || paste.Files[0] != files[0] || paste.Files[1] != files[1] {
+			t.Error(name, "unexpected files:", paste.Files)
+		}
+
+		// Usual paste has no files
+		id2, _, _, err := db.PasteAdd(Paste{Body: "Hello", Syntax: "plaintext"})
+		if err != nil {
+			t.Fatal(name, err)
+		}
+
+		paste, err = db.PasteGet(id2)
+		if err != nil {
+			t.Fatal(name, err)
+		}
+
+		if len(paste.Files) != 0 {
+			t.Error(name, "unexpected files:", paste.Files)
+		}
+
+		// Delete paste
+		err = db.PasteDelete(id)
+		if err != nil {
+			t.Fatal(name, err)
+		}
+
+		_, err = db.PasteGet(id)
+		if err != ErrNotFoundID {
+			t.Error(name, "expected ErrNotFoundID but got", err)
+		}
+	}
+}
@@ -0,0 +1,268 @@
+// Copyright (C) 2021-2023 Leonid Maslakov.
+
+// This file is part of Lenpaste.
+
+// Lenpaste is free software: you can redistribute it
+// and/or modify it under the terms of the
+// GNU Affero Public License as published by the
+// Free Software Foundation, either version 3 of the License,
+// or (at your option) any later version.
+
+// Lenpaste is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+// or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero Public License for more details.
+
+// You should have received a copy of the GNU Affero Public License along with Lenpaste.
+// If not, see <https://www.gnu.org/licenses/>.
+
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"sort"
+	"time"
+)
+
+var (
+	ErrNotFoundReport = errors.New("db: could not find report")
+	ErrReportExists   = errors.New("db: paste is already reported from this address")
+)
+
+// Report is the complaint about the paste content.
+// Reports of the paste are deleted together with it.
+type Report struct {
+	ID         string `json:"id"` // Ignored when creating
+	PasteID    string `json:"pasteID"`
+	Reason     string `json:"reason"`
+	Comment    string `json:"comment"`
+	ReporterIP string `json:"reporterIP"`
+	CreateTime int64  `json:"createTime"` // Ignored when creating
+}
+
+func (db DB) ReportAdd(report Report) (string, error) {
+	var err error
+
+	report.ID, err = genTokenCrypto(8)
+	if err != nil {
+		return "", err
+	}
+
+	report.CreateTime = time.Now().Unix()
+
+	// One address can report the paste only once
+	var count int
+	err = db.pool.QueryRow(
+		`SELECT COUNT(*) FROM reports WHERE paste_id = $1 AND reporter_ip = $2`,
+		report.PasteID, report.ReporterIP,
+	).Scan(&count)
+	if err != nil {
+		return "", err
+	}
+
+	if count != 0 {
+		return "", ErrReportExists
+	}
+
+	_, err = db.pool.Exec(
+		`INSERT INTO reports (id, paste_id, reason, comment, reporter_ip, create_time) VALUES ($1, $2, $3, $4, $5, $6)`,
+		report.ID, report.PasteID, report.Reason, report.Comment, report.ReporterIP, report.CreateTime,
+	)
+	if err != nil {
+		return "", err
+	}
+
+	return report.ID, nil
+}
+
+func (db DB) ReportGet(id string) (Report, error) {
+	var report Report
+
+	err := db.pool.QueryRow(
+		`SELECT id, paste_id, reason, comment, reporter_ip, create_time FROM reports WHERE id = $1`,
+		id,
+	).Scan(&report.ID, &report.PasteID, &report.Reason, &report.Comment, &report.ReporterIP, &report.CreateTime)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return Report{}, ErrNotFoundReport
+		}
+
+		return Report{}, err
+	}
+
+	return report, nil
+}
+
+// ReportList returns reports from oldest to newest.
+func (db DB) ReportList(limit int, offset int) ([]Report, error) {
+	if offset < 0 {
+		return nil, ErrBadOffset
+	}
+
+	rows, err := db.pool.Query(
+		`SELECT id, paste_id, reason, comment, reporter_ip, create_time FROM reports ORDER BY create_time, id LIMIT $1 OFFSET $2`,
+		limit, offset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reports []Report
+	for rows.Next() {
+		var report Report
+
+		err = rows.Scan(&report.ID, &report.PasteID, &report.Reason, &report.Comment, &report.ReporterIP, &report.CreateTime)
+		if err != nil {
+			return nil, err
+		}
+
+		reports = append(reports, report)
+	}
+
+	return reports, rows.Err()
+}
+
+func (db DB) ReportDelete(id string) error {
+	result, err := db.pool.Exec(`DELETE FROM reports WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrNotFoundReport
+	}
+
+	return nil
+}
+
+// ReportReporters returns the number of different addresses that reported the paste.
+func (db DB) ReportReporters(pasteID string) (int, error) {
+	var count int
+
+	err := db.pool.QueryRow(
+		`SELECT COUNT(DISTINCT reporter_ip) FROM reports WHERE paste_id = $1`,
+		pasteID,
+	).Scan(&count)
+
+	return count, err
+}
+
+func (m *Memory) ReportAdd(report Report) (string, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	for _, r := range m.reports {
+		if r.PasteID == report.PasteID && r.ReporterIP == report.ReporterIP {
+			return "", ErrReportExists
+		}
+	}
+
+	// Generate unique ID
+	for {
+		var err error
+		report.ID, err = genTokenCrypto(8)
+		if err != nil {
+			return "", err
+		}
+
+		_, exist := m.reports[report.ID]
+		if exist == false {
+			break
+		}
+	}
+
+	report.CreateTime = time.Now().Unix()
+	m.reports[report.ID] = report
+
+	return report.ID, nil
+}
+
+func (m *Memory) ReportGet(id string) (Report, error) {
+	m.RLock()
+	defer m.RUnlock()
+
+	report, exist := m.reports[id]
+	if exist == false {
+		return Report{}, ErrNotFoundReport
+	}
+
+	return report, nil
+}
+
+func (m *Memory) ReportList(limit int, offset int) ([]Report, error) {
+	m.RLock()
+	defer m.RUnlock()
+
+	var reports []Report
+	for _, report := range m.reports {
+		reports = append(reports, report)
+	}
+
+	sort.Slice(reports, func(i, j int) bool {
+		if reports[i].CreateTime != reports[j].CreateTime {
+			return reports[i].CreateTime < reports[j].CreateTime
+		}
+
+		return reports[i].ID < reports[j].ID
+	})
+
+	if offset < 0 {
+		return nil, ErrBadOffset
+	}
+
+	if offset >= len(reports) {
+		return nil, nil
+	}
+
+	reports = reports[offset:]
+	if len(reports) > limit {
+		reports = reports[:limit]
+	}
+
+	return reports, nil
+}
+
+func (m *Memory) ReportDelete(id string) error {
+	m.Lock()
+	defer m.Unlock()
+
+	_, exist := m.reports[id]
+	if exist == false {
+		return ErrNotFoundReport
+	}
+
+	delete(m.reports, id)
+
+	return nil
+}
+
+func (m *Memory) ReportReporters(pasteID string) (int, error) {
+	m.RLock()
+	defer m.RUnlock()
+
+	reporters := make(map[string]bool)
+	for _, report := range m.reports {
+		if report.PasteID == pasteID {
+			reporters[report.ReporterIP] = true
+		}
+	}
+
+	return len(reporters), nil
+}
+
+// deleteReports deletes all reports of the paste.
+// The caller must hold the lock.
+func (m *Memory) deleteReports(pasteID string) {
+	for id, report := range m.reports {
+		if report.PasteID == pasteID {
+			delete(m.reports, id)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+// Copyright (C) 2021-2023 Leonid Maslakov.
+
+// This file is part of Lenpaste.
+
+// Lenpaste is free software: you can redistribute it
+// and/or modify it under the terms of the
+// GNU Affero Public License as published by the
+// Free Software Foundation, either version 3 of the License,
+// or (at your option) any later version.
+
+// Lenpaste is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+// or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero Public License for more details.
+
+// You should have received a copy of the GNU Affero Public License along with Lenpaste.
+// If not, see <https://www.gnu.org/licenses/>.
+
+package storage
+
+import (
+	"testing"
+)
+
+func TestReports(t *testing.T) {
+	for name, db := range testStores(t) {
+		pasteID, _, _, err := db.PasteAdd(Paste{Body: "Leaked", Syntax: "plaintext", Visibility: VisibilityPublic})
+		if err != nil {
+			t.Fatal(name, err)
+		}
+
+		// Reports
+		id, err := db.ReportAdd(Report{PasteID: pasteID, Reason: "spam", ReporterIP: "192.0.2.1"})
+		if err != nil {
+			t.Fatal(name, err)
+		}
+
+		_, err = db.ReportAdd(Report{PasteID: pasteID, Reason: "other", ReporterIP: "192.0.2.1"})
+		if err != ErrReportExists {
+			t.Error(name, "expected ErrReportExists but got", err)
+		}
+
+		_, err = db.ReportAdd(Report{PasteID: pasteID, Reason: "illegal", Comment: "Comment", ReporterIP: "192.0.2.2"})
+		if err != nil {
+			t.Fatal(name, err)
+		}
+
+		count, err := db.ReportReporters(pasteID)
+		if err != nil || count != 2 {
+			t.Error(name, "expected 2 reporters but got", count, err)
+		}
+
+		report, err := db.ReportGet(id)
+		if err != nil || report.PasteID != pasteID || report.Reason != "spam" || report.CreateTime == 0 {
+			t.Error(name, "unexpected report:", report, err)
+		}
+
+		reports, err := db.ReportList(10, 0)
+		if err != nil || len(reports) != 2 {
+			t.Error(name, "unexpected reports:", reports, err)
+		}
+
+		err = db.ReportDelete(id)
+		if err != nil {
+			t.Fatal(name, err)
+		}
+
+		_, err = db.ReportGet(id)
+		if err != ErrNotFoundReport {
+			t.Error(name, "expected ErrNotFoundReport but got", err)
+		}
+
+		// Hidden paste
+		err = db.PasteSetHidden(pasteID, true)
+		if err != nil {
+			t.Fatal(name, err)
+		}
+
+		_, err = db.PasteGet(pasteID)
+		if err != ErrNotFoundID {
+			t.Error(name, "expected ErrNotFoundID for the hidden paste but got", err)
+		}
+
+		paste, err := db.PasteGetAny(pasteID)
+		if err != nil || paste.Hidden == false {
+			t.Error(name, "unexpected hidden paste:", paste, err)
+		}
+
+		pastes, err := db.PasteList(10, 0)
+		if err != nil || len(pastes) != 0 {
+			t.Error(name, "hidden paste is listed:", pastes, err)
+		}
+
+		results, err := db.PasteSearch("Leaked", 10, 0)
+		if err != nil || len(results) != 0 {
+			t.Error(name, "hidden paste is found:", results, err)
+		}
+
+		err = db.PasteSetHidden(pasteID, false)
+		if err != nil {
+			t.Fatal(name, err)
+		}
+
+		pastes, err = db.PasteList(10, 0)
+		if err != nil || len(pastes) != 1 {
+			t.Error(name, "shown paste is not listed:", pastes, err)
+		}
+
+		// Reports are deleted with the paste
+		err = db.PasteDelete(pasteID)
+		if err != nil {
+			t.Fatal(name, err)
+		}
+
+		reports, err = db.ReportList(10, 0)
+		if err != nil || len(reports) != 0 {
+			t.Error(name, "reports of the deleted paste are not deleted:", reports, err)
+		}
+	}
+}
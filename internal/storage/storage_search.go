@@ -0,0 +1,332 @@
+// Copyright (C) 2021-2023 Leonid Maslakov.
+
+// This file is part of Lenpaste.
+
+// Lenpaste is free software: you can redistribute it
+// and/or modify it under the terms of the
+// GNU Affero Public License as published by the
+// Free Software Foundation, either version 3 of the License,
+// or (at your option) any later version.
+
+// Lenpaste is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+// or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero Public License for more details.
+
+// You should have received a copy of the GNU Affero Public License along with Lenpaste.
+// If not, see <https://www.gnu.org/licenses/>.
+
+package storage
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Matched words in the SearchResult snippet are enclosed in these characters.
+// Paste text can contain them too, so the unpaired ones must be ignored, see netshare.SearchSnippetHTML.
+const (
+	SnippetMatchStart = "\x01"
+	SnippetMatchEnd   = "\x02"
+)
+
+const (
+	maxSearchTerms = 16
+	snippetLength  = 200 // In runes
+	snippetContext = 60  // Number of runes before the first match
+)
+
+// SearchResult is a public paste found by the search.
+type SearchResult struct {
+	ID         string `json:"id"`
+	Title      string `json:"title"`
+	Syntax     string `json:"syntax"`
+	CreateTime int64  `json:"createTime"`
+	Snippet    string `json:"snippet"` // Fragment of the paste body, see SnippetMatchStart
+}
+
+// searchTerms splits the search query into words.
+func searchTerms(query string) []string {
+	terms := strings.Fields(query)
+	if len(terms) > maxSearchTerms {
+		terms = terms[:maxSearchTerms]
+	}
+
+	return terms
+}
+
+func lowerRunes(text string) []rune {
+	runes := []rune(text)
+	for i, r := range runes {
+		runes[i] = unicode.ToLower(r)
+	}
+
+	return runes
+}
+
+func matchAt(text []rune, i int, term []rune) bool {
+	if i+len(term) > len(text) {
+		return false
+	}
+
+	for j, r := range term {
+		if text[i+j] != r {
+			return false
+		}
+	}
+
+	return true
+}
+
+// makeSnippet cuts a fragment around the first matched word from the text and marks all matched words in it.
+// It is used when the database can not make snippets itself.
+func makeSnippet(text string, terms []string) string {
+	runes := []rune(text)
+	lower := lowerRunes(text)
+
+	lowerTerms := make([][]rune, 0, len(terms))
+	for _, term := range terms {
+		lowerTerms = append(lowerTerms, lowerRunes(term))
+	}
+
+	// Find first match
+	first := -1
+	for i := range lower {
+		for _, term := range lowerTerms {
+			if matchAt(lower, i, term) {
+				first = i
+				break
+			}
+		}
+
+		if first != -1 {
+			break
+		}
+	}
+
+	start := 0
+	if first > snippetContext {
+		start = first - snippetContext
+	}
+
+	end := start + snippetLength
+	if end > len(runes) {
+		end = len(runes)
+	}
+
+	// Make snippet
+	var snippet strings.Builder
+
+	if start > 0 {
+		snippet.WriteString("...")
+	}
+
+	for i := start; i < end; {
+		matched := false
+		for _, term := range lowerTerms {
+			if len(term) != 0 && i+len(term) <= end && matchAt(lower, i, term) {
+				snippet.WriteString(SnippetMatchStart)
+				snippet.WriteString(string(runes[i : i+len(term)]))
+				snippet.WriteString(SnippetMatchEnd)
+				i += len(term)
+				matched = true
+				break
+			}
+		}
+
+		if matched == false {
+			snippet.WriteRune(runes[i])
+			i++
+		}
+	}
+
+	if end < len(runes) {
+		snippet.WriteString("...")
+	}
+
+	return snippet.String()
+}
+
+// PasteSearch returns public pastes that contain all words of the query.
+// SQLite3 uses the FTS5 index if Lenpaste is built with the sqlite_fts5 tag, PostgreSQL uses the tsvector index.
+func (db DB) PasteSearch(query string, limit int, offset int) ([]SearchResult, error) {
+	if offset < 0 {
+		return nil, ErrBadOffset
+	}
+
+	terms := searchTerms(query)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+
+	if db.driver != "sqlite3" {
+		return db.pasteSearchPostgres(terms, limit, offset)
+	}
+
+	// Check that FTS5 index exists
+	var ftsExist int
+	err := db.pool.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'pastes_fts'`).Scan(&ftsExist)
+	if err != nil {
+		return nil, err
+	}
+
+	if ftsExist == 0 {
+		return db.pasteSearchLike(terms, limit, offset)
+	}
+
+	return db.pasteSearchFTS5(terms, limit, offset)
+}
+
+func (db DB) pasteSearchFTS5(terms []string, limit int, offset int) ([]SearchResult, error) {
+	// Every word is quoted so that FTS5 query syntax can not be used
+	quoted := make([]string, 0, len(terms))
+	for _, term := range terms {
+		quoted = append(quoted, `"`+strings.ReplaceAll(term, `"`, `""`)+`"`)
+	}
+
+	return db.scanSearchResults(
+		`SELECT p.id, p.title, p.syntax, p.create_time, snippet(pastes_fts, 2, $1, $2, '...', 32)
+		FROM pastes_fts JOIN pastes p ON p.id = pastes_fts.id
+		WHERE pastes_fts MATCH $3 AND p.visibility = 'public' AND p.hidden = FALSE AND (p.delete_time = 0 OR p.delete_time > $4)
+		ORDER BY pastes_fts.rank LIMIT $5 OFFSET $6`,
+		SnippetMatchStart, SnippetMatchEnd, strings.Join(quoted, " "), time.Now().Unix(), limit, offset,
+	)
+}
+
+func (db DB) pasteSearchPostgres(terms []string, limit int, offset int) ([]SearchResult, error) {
+	headlineOptions := `StartSel="` + SnippetMatchStart + `", StopSel="` + SnippetMatchEnd + `", MinWords=15, MaxWords=35`
+
+	return db.scanSearchResults(
+		`SELECT id, title, syntax, create_time, ts_headline('simple', body, q, $1)
+		FROM pastes, plainto_tsquery('simple', $2) q
+		WHERE visibility = 'public' AND hidden = FALSE AND search @@ q AND (delete_time = 0 OR delete_time > $3)
+		ORDER BY ts_rank(search, q) DESC, create_time DESC LIMIT $4 OFFSET $5`,
+		headlineOptions, strings.Join(terms, " "), time.Now().Unix(), limit, offset,
+	)
+}
+
+// pasteSearchLike is a slow search for SQLite3 built without FTS5.
+func (db DB) pasteSearchLike(terms []string, limit int, offset int) ([]SearchResult, error) {
+	query := `SELECT id, title, syntax, create_time, body FROM pastes WHERE visibility = 'public' AND hidden = FALSE AND (delete_time = 0 OR delete_time > $1)`
+	args := []interface{}{time.Now().Unix()}
+
+	escaper := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	for _, term := range terms {
+		args = append(args, "%"+escaper.Replace(term)+"%")
+		n := "$" + strconv.Itoa(len(args))
+		query += ` AND (title LIKE ` + n + ` ESCAPE '\' OR body LIKE ` + n + ` ESCAPE '\')`
+	}
+
+	args = append(args, limit, offset)
+	query += ` ORDER BY create_time DESC LIMIT $` + strconv.Itoa(len(args)-1) + ` OFFSET $` + strconv.Itoa(len(args))
+
+	results, err := db.scanSearchResults(query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	// Body was read instead of snippet
+	for i := range results {
+		results[i].Snippet = makeSnippet(results[i].Snippet, terms)
+	}
+
+	return results, nil
+}
+
+func (db DB) scanSearchResults(query string, args ...interface{}) ([]SearchResult, error) {
+	rows, err := db.pool.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []SearchResult
+	for rows.Next() {
+		var result SearchResult
+
+		err = rows.Scan(&result.ID, &result.Title, &result.Syntax, &result.CreateTime, &result.Snippet)
+		if err != nil {
+			return nil, err
+		}
+
+		results = append(results, result)
+	}
+
+	return results, rows.Err()
+}
+
+// PasteSearch returns public pastes that contain all words of the query, newest first.
+func (m *Memory) PasteSearch(query string, limit int, offset int) ([]SearchResult, error) {
+	if offset < 0 {
+		return nil, ErrBadOffset
+	}
+
+	terms := searchTerms(query)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+
+	m.RLock()
+	defer m.RUnlock()
+
+	timeNow := time.Now().Unix()
+
+	var found []Paste
+	for _, paste := range m.pastes {
+		if paste.Visibility != VisibilityPublic || paste.Hidden {
+			continue
+		}
+
+		if paste.DeleteTime < timeNow && paste.DeleteTime > 0 {
+			continue
+		}
+
+		text := strings.ToLower(paste.Title + "\n" + paste.Body)
+
+		matched := true
+		for _, term := range terms {
+			if strings.Contains(text, strings.ToLower(term)) == false {
+				matched = false
+				break
+			}
+		}
+
+		if matched {
+			found = append(found, paste)
+		}
+	}
+
+	sort.Slice(found, func(i, j int) bool {
+		if found[i].CreateTime != found[j].CreateTime {
+			return found[i].CreateTime > found[j].CreateTime
+		}
+
+		return found[i].ID < found[j].ID
+	})
+
+	// Make page
+	if offset >= len(found) {
+		return nil, nil
+	}
+
+	found = found[offset:]
+	if len(found) > limit {
+		found = found[:limit]
+	}
+
+	results := make([]SearchResult, 0, len(found))
+	for _, paste := range found {
+		results = append(results, SearchResult{
+			ID:         paste.ID,
+			Title:      paste.Title,
+			Syntax:     paste.Syntax,
+			CreateTime: paste.CreateTime,
+			Snippet:    makeSnippet(paste.Body, terms),
+		})
+	}
+
+	return results, nil
+}
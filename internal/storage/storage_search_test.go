@@ -0,0 +1,115 @@
+// Copyright (C) 2021-2023 Leonid Maslakov.
+
+// This file is part of Lenpaste.
+
+// Lenpaste is free software: you can redistribute it
+// and/or modify it under the terms of the
+// GNU Affero Public License as published by the
+// Free Software Foundation, either version 3 of the License,
+// or (at your option) any later version.
+
+// Lenpaste is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+// or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero Public License for more details.
+
+// You should have received a copy of the GNU Affero Public License along with Lenpaste.
+// If not, see <https://www.gnu.org/licenses/>.
+
+package storage
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPasteSearch(t *testing.T) {
+	for name, db := range testStores(t) {
+		// Create pastes
+		pastes := []Paste{
+			{Title: "First", Body: "The quick brown fox jumps over the lazy dog.", Syntax: "plaintext", Visibility: VisibilityPublic},
+			{Title: "Second", Body: "A lazy cat sleeps all day.", Syntax: "plaintext", Visibility: VisibilityPublic},
+			{Title: "Private", Body: "Lazy private text.", Syntax: "plaintext"},
+			{Title: "Expired", Body: "Lazy expired text.", Syntax: "plaintext", Visibility: VisibilityPublic, DeleteTime: time.Now().Unix() + 1},
+		}
+
+		for _, paste := range pastes {
+			_, _, _, err := db.PasteAdd(paste)
+			if err != nil {
+				t.Fatal(name, err)
+			}
+		}
+
+		time.Sleep(2 * time.Second)
+
+		// Search
+		results, err := db.PasteSearch("lazy", 10, 0)
+		if err != nil {
+			t.Fatal(name, err)
+		}
+
+		if len(results) != 2 {
+			t.Fatal(name, "expected 2 results but got", results)
+		}
+
+		for _, result := range results {
+			if result.Title != "First" && result.Title != "Second" {
+				t.Error(name, "unexpected result:", result)
+			}
+
+			if strings.Contains(result.Snippet, SnippetMatchStart+"lazy"+SnippetMatchEnd) == false {
+				t.Error(name, "word is not marked in snippet:", result.Snippet)
+			}
+		}
+
+		// All words must match
+		results, err = db.PasteSearch("lazy fox", 10, 0)
+		if err != nil {
+			t.Fatal(name, err)
+		}
+
+		if len(results) != 1 || results[0].Title != "First" {
+			t.Error(name, "expected one result but got", results)
+		}
+
+		// Pagination
+		results, err = db.PasteSearch("lazy", 1, 1)
+		if err != nil {
+			t.Fatal(name, err)
+		}
+
+		if len(results) != 1 {
+			t.Error(name, "expected 1 result on the second page but got", results)
+		}
+
+		// Empty query
+		results, err = db.PasteSearch("  ", 10, 0)
+		if err != nil {
+			t.Fatal(name, err)
+		}
+
+		if len(results) != 0 {
+			t.Error(name, "expected no results but got", results)
+		}
+
+		// Negative offset
+		_, err = db.PasteSearch("text", 10, -20)
+		if err != ErrBadOffset {
+			t.Error(name, "expected ErrBadOffset but got", err)
+		}
+	}
+}
+
+func TestMakeSnippet(t *testing.T) {
+	text := strings.Repeat("a ", 100) + "Needle " + strings.Repeat("b ", 100)
+
+	snippet := makeSnippet(text, []string{"needle"})
+	if strings.HasPrefix(snippet, "...") == false || strings.HasSuffix(snippet, "...") == false {
+		t.Error("snippet is not cut:", snippet)
+	}
+
+	if strings.Contains(snippet, SnippetMatchStart+"Needle"+SnippetMatchEnd) == false {
+		t.Error("word is not marked in snippet:", snippet)
+	}
+}
@@ -0,0 +1,310 @@
+// Copyright (C) 2021-2023 Leonid Maslakov.
+
+// This file is part of Lenpaste.
+
+// Lenpaste is free software: you can redistribute it
+// and/or modify it under the terms of the
+// GNU Affero Public License as published by the
+// Free Software Foundation, either version 3 of the License,
+// or (at your option) any later version.
+
+// Lenpaste is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+// or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero Public License for more details.
+
+// You should have received a copy of the GNU Affero Public License along with Lenpaste.
+// If not, see <https://www.gnu.org/licenses/>.
+
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+)
+
+// API token scopes.
+// Admin token has all other scopes and can manage pastes created without it.
+const (
+	ScopeCreate      = "create"       // Create pastes
+	ScopeDeleteOwn   = "delete-own"   // Delete pastes created with this token without the delete token
+	ScopeReadPrivate = "read-private" // Read private pastes created with this token without the edit token
+	ScopeAdmin       = "admin"        // Delete and read any paste
+)
+
+var Scopes = []string{ScopeCreate, ScopeDeleteOwn, ScopeReadPrivate, ScopeAdmin}
+
+var (
+	ErrNotFoundToken = errors.New("db: could not find API token")
+	ErrUnknownScope  = errors.New("db: unknown API token scope")
+)
+
+// APIToken is a token that is sent in the "Authorization: Bearer" header.
+// The DB stores only the token hash, the token itself is shown only once when it is created.
+type APIToken struct {
+	ID           string   `json:"id"` // Ignored when creating
+	Name         string   `json:"name"`
+	Scopes       []string `json:"scopes"`
+	CreateTime   int64    `json:"createTime"`   // Ignored when creating
+	ExpireTime   int64    `json:"expireTime"`   // 0 if the token never expires
+	LastUsedTime int64    `json:"lastUsedTime"` // Ignored when creating, 0 if the token has never been used
+
+	TokenHash string `json:"-"` // SHA-256 of the token, see NewSecret
+}
+
+// HasScope reports whether the token has the scope.
+func (token APIToken) HasScope(scope string) bool {
+	for _, s := range token.Scopes {
+		if s == scope || s == ScopeAdmin {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Expired reports whether the token is expired.
+func (token APIToken) Expired() bool {
+	return token.ExpireTime > 0 && token.ExpireTime < time.Now().Unix()
+}
+
+// ParseScopes parses the comma separated list of scopes.
+func ParseScopes(s string) ([]string, error) {
+	var scopes []string
+
+	for _, scope := range strings.Split(s, ",") {
+		scope = strings.TrimSpace(scope)
+		if scope == "" {
+			continue
+		}
+
+		known := false
+		for _, v := range Scopes {
+			if scope == v {
+				known = true
+				break
+			}
+		}
+
+		if known == false {
+			return nil, ErrUnknownScope
+		}
+
+		scopes = append(scopes, scope)
+	}
+
+	if len(scopes) == 0 {
+		return nil, ErrUnknownScope
+	}
+
+	return scopes, nil
+}
+
+func (db DB) TokenAdd(token APIToken) (string, int64, error) {
+	var err error
+
+	// Generate ID
+	token.ID, err = genTokenCrypto(8)
+	if err != nil {
+		return "", 0, err
+	}
+
+	token.CreateTime = time.Now().Unix()
+
+	if token.ExpireTime < 0 {
+		token.ExpireTime = 0
+	}
+
+	_, err = db.pool.Exec(
+		`INSERT INTO api_tokens (id, name, token_hash, scopes, create_time, expire_time, last_used_time) VALUES ($1, $2, $3, $4, $5, $6, 0)`,
+		token.ID, token.Name, token.TokenHash, strings.Join(token.Scopes, ","), token.CreateTime, token.ExpireTime,
+	)
+	if err != nil {
+		return "", 0, err
+	}
+
+	return token.ID, token.CreateTime, nil
+}
+
+func scanToken(scan func(dest ...interface{}) error) (APIToken, error) {
+	var token APIToken
+	var scopes string
+
+	err := scan(&token.ID, &token.Name, &token.TokenHash, &scopes, &token.CreateTime, &token.ExpireTime, &token.LastUsedTime)
+	if err != nil {
+		return token, err
+	}
+
+	if scopes != "" {
+		token.Scopes = strings.Split(scopes, ",")
+	}
+
+	return token, nil
+}
+
+func (db DB) TokenGetByHash(tokenHash string) (APIToken, error) {
+	row := db.pool.QueryRow(
+		`SELECT id, name, token_hash, scopes, create_time, expire_time, last_used_time FROM api_tokens WHERE token_hash = $1`,
+		tokenHash,
+	)
+
+	token, err := scanToken(row.Scan)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return APIToken{}, ErrNotFoundToken
+		}
+
+		return APIToken{}, err
+	}
+
+	return token, nil
+}
+
+func (db DB) TokenList() ([]APIToken, error) {
+	rows, err := db.pool.Query(
+		`SELECT id, name, token_hash, scopes, create_time, expire_time, last_used_time FROM api_tokens ORDER BY create_time, id`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tokens []APIToken
+	for rows.Next() {
+		token, err := scanToken(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+
+		tokens = append(tokens, token)
+	}
+
+	return tokens, rows.Err()
+}
+
+func (db DB) TokenDelete(id string) error {
+	result, err := db.pool.Exec(
+		`DELETE FROM api_tokens WHERE id = $1`,
+		id,
+	)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrNotFoundToken
+	}
+
+	return nil
+}
+
+func (db DB) TokenUse(id string) error {
+	_, err := db.pool.Exec(
+		`UPDATE api_tokens SET last_used_time = $1 WHERE id = $2`,
+		time.Now().Unix(), id,
+	)
+	return err
+}
+
+func (m *Memory) TokenAdd(token APIToken) (string, int64, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	// Generate unique ID
+	for {
+		var err error
+		token.ID, err = genTokenCrypto(8)
+		if err != nil {
+			return "", 0, err
+		}
+
+		_, exist := m.tokens[token.ID]
+		if exist == false {
+			break
+		}
+	}
+
+	token.CreateTime = time.Now().Unix()
+	token.LastUsedTime = 0
+
+	if token.ExpireTime < 0 {
+		token.ExpireTime = 0
+	}
+
+	// Scopes must not be changed by the caller
+	token.Scopes = append([]string(nil), token.Scopes...)
+
+	m.tokens[token.ID] = token
+
+	return token.ID, token.CreateTime, nil
+}
+
+func (m *Memory) TokenGetByHash(tokenHash string) (APIToken, error) {
+	m.RLock()
+	defer m.RUnlock()
+
+	for _, token := range m.tokens {
+		if token.TokenHash == tokenHash {
+			return token, nil
+		}
+	}
+
+	return APIToken{}, ErrNotFoundToken
+}
+
+func (m *Memory) TokenList() ([]APIToken, error) {
+	m.RLock()
+	defer m.RUnlock()
+
+	var tokens []APIToken
+	for _, token := range m.tokens {
+		tokens = append(tokens, token)
+	}
+
+	sort.Slice(tokens, func(i, j int) bool {
+		if tokens[i].CreateTime != tokens[j].CreateTime {
+			return tokens[i].CreateTime < tokens[j].CreateTime
+		}
+
+		return tokens[i].ID < tokens[j].ID
+	})
+
+	return tokens, nil
+}
+
+func (m *Memory) TokenDelete(id string) error {
+	m.Lock()
+	defer m.Unlock()
+
+	_, exist := m.tokens[id]
+	if exist == false {
+		return ErrNotFoundToken
+	}
+
+	delete(m.tokens, id)
+
+	return nil
+}
+
+func (m *Memory) TokenUse(id string) error {
+	m.Lock()
+	defer m.Unlock()
+
+	token, exist := m.tokens[id]
+	if exist == false {
+		return ErrNotFoundToken
+	}
+
+	token.LastUsedTime = time.Now().Unix()
+	m.tokens[id] = token
+
+	return nil
+}
@@ -0,0 +1,127 @@
+// Copyright (C) 2021-2023 Leonid Maslakov.
+
+// This file is part of Lenpaste.
+
+// Lenpaste is free software: you can redistribute it
+// and/or modify it under the terms of the
+// GNU Affero Public License as published by the
+// Free Software Foundation, either version 3 of the License,
+// or (at your option) any later version.
+
+// Lenpaste is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+// or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero Public License for more details.
+
+// You should have received a copy of the GNU Affero Public License along with Lenpaste.
+// If not, see <https://www.gnu.org/licenses/>.
+
+package storage
+
+import (
+	"testing"
+)
+
+func TestParseScopes(t *testing.T) {
+	scopes, err := ParseScopes("create, delete-own")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(scopes) != 2 || scopes[0] != ScopeCreate || scopes[1] != ScopeDeleteOwn {
+		t.Error("unexpected scopes:", scopes)
+	}
+
+	for _, s := range []string{"", ",", "create,write"} {
+		_, err = ParseScopes(s)
+		if err != ErrUnknownScope {
+			t.Errorf("%q: expected ErrUnknownScope but got %v", s, err)
+		}
+	}
+
+	// Admin has all scopes
+	token := APIToken{Scopes: []string{ScopeAdmin}}
+	if token.HasScope(ScopeDeleteOwn) == false {
+		t.Error("admin token must have all scopes")
+	}
+
+	token = APIToken{Scopes: []string{ScopeCreate}}
+	if token.HasScope(ScopeReadPrivate) == true {
+		t.Error("unexpected read-private scope")
+	}
+}
+
+func TestTokens(t *testing.T) {
+	for name, db := range testStores(t) {
+		_, hash, err := NewSecret()
+		if err != nil {
+			t.Fatal(name, err)
+		}
+
+		// Add and get
+		id, _, err := db.TokenAdd(APIToken{Name: "CI", Scopes: []string{ScopeCreate, ScopeDeleteOwn}, TokenHash: hash})
+		if err != nil {
+			t.Fatal(name, err)
+		}
+
+		token, err := db.TokenGetByHash(hash)
+		if err != nil {
+			t.Fatal(name, err)
+		}
+
+		if token.ID != id || token.Name != "CI" || len(token.Scopes) != 2 || token.LastUsedTime != 0 || token.Expired() {
+			t.Error(name, "unexpected token:", token)
+		}
+
+		_, err = db.TokenGetByHash(HashSecret("wrong"))
+		if err != ErrNotFoundToken {
+			t.Error(name, "expected ErrNotFoundToken but got", err)
+		}
+
+		// Paste keeps the token ID
+		pasteID, _, _, err := db.PasteAdd(Paste{Body: "Body", Syntax: "plaintext", TokenID: id})
+		if err != nil {
+			t.Fatal(name, err)
+		}
+
+		paste, err := db.PasteGet(pasteID)
+		if err != nil {
+			t.Fatal(name, err)
+		}
+
+		if paste.TokenID != id {
+			t.Error(name, "expected paste token ID", id, "but got", paste.TokenID)
+		}
+
+		// Use
+		err = db.TokenUse(id)
+		if err != nil {
+			t.Fatal(name, err)
+		}
+
+		tokens, err := db.TokenList()
+		if err != nil {
+			t.Fatal(name, err)
+		}
+
+		if len(tokens) != 1 || tokens[0].ID != id || tokens[0].LastUsedTime == 0 {
+			t.Error(name, "unexpected token list:", tokens)
+		}
+
+		// Delete
+		err = db.TokenDelete(id)
+		if err != nil {
+			t.Fatal(name, err)
+		}
+
+		err = db.TokenDelete(id)
+		if err != ErrNotFoundToken {
+			t.Error(name, "expected ErrNotFoundToken but got", err)
+		}
+
+		_, err = db.TokenGetByHash(hash)
+		if err != ErrNotFoundToken {
+			t.Error(name, "expected ErrNotFoundToken but got", err)
+		}
+	}
+}
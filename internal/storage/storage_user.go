@@ -0,0 +1,463 @@
+// Copyright (C) 2021-2023 Leonid Maslakov.
+
+// This file is part of Lenpaste.
+
+// Lenpaste is free software: you can redistribute it
+// and/or modify it under the terms of the
+// GNU Affero Public License as published by the
+// Free Software Foundation, either version 3 of the License,
+// or (at your option) any later version.
+
+// Lenpaste is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+// or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero Public License for more details.
+
+// You should have received a copy of the GNU Affero Public License along with Lenpaste.
+// If not, see <https://www.gnu.org/licenses/>.
+
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	ErrNotFoundUser    = errors.New("db: could not find user")
+	ErrUserExists      = errors.New("db: user already exists")
+	ErrNotFoundSession = errors.New("db: could not find session")
+)
+
+// User is a user account of the web interface.
+type User struct {
+	ID           string `json:"id"` // Ignored when creating
+	Name         string `json:"name"`
+	Admin        bool   `json:"admin"`
+	CreateTime   int64  `json:"createTime"` // Ignored when creating
+	Email        string `json:"email"`
+	PasswordHash string `json:"-"` // Argon2id hash of the password, see HashPassword. Empty if the user can not log in with a password.
+	OIDCSubject  string `json:"-"` // Subject of the OpenID Connect user, empty for local users.
+}
+
+// Session is a login session of the user.
+// The session ID is kept only in the user cookie, the DB stores its hash.
+type Session struct {
+	IDHash     string // SHA-256 of the session ID, see NewSecret
+	UserID     string
+	CreateTime int64 // Ignored when creating
+	ExpireTime int64
+}
+
+func (db DB) UserAdd(user User) (string, error) {
+	var err error
+
+	user.ID, err = genTokenCrypto(8)
+	if err != nil {
+		return "", err
+	}
+
+	user.CreateTime = time.Now().Unix()
+
+	// Check name
+	_, err = db.UserGetByName(user.Name)
+	if err == nil {
+		return "", ErrUserExists
+	}
+
+	if err != ErrNotFoundUser {
+		return "", err
+	}
+
+	_, err = db.pool.Exec(
+		`INSERT INTO users (id, name, email, password_hash, oidc_subject, admin, create_time) VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		user.ID, user.Name, user.Email, user.PasswordHash, user.OIDCSubject, user.Admin, user.CreateTime,
+	)
+	if err != nil {
+		// Same name can be added by a parallel request
+		if strings.Contains(strings.ToLower(err.Error()), "unique") {
+			return "", ErrUserExists
+		}
+
+		return "", err
+	}
+
+	return user.ID, nil
+}
+
+func scanUser(scan func(dest ...interface{}) error) (User, error) {
+	var user User
+
+	err := scan(&user.ID, &user.Name, &user.Email, &user.PasswordHash, &user.OIDCSubject, &user.Admin, &user.CreateTime)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return User{}, ErrNotFoundUser
+		}
+
+		return User{}, err
+	}
+
+	return user, nil
+}
+
+func (db DB) UserGet(id string) (User, error) {
+	return scanUser(db.pool.QueryRow(
+		`SELECT id, name, email, password_hash, oidc_subject, admin, create_time FROM users WHERE id = $1`,
+		id,
+	).Scan)
+}
+
+func (db DB) UserGetByName(name string) (User, error) {
+	return scanUser(db.pool.QueryRow(
+		`SELECT id, name, email, password_hash, oidc_subject, admin, create_time FROM users WHERE name = $1`,
+		name,
+	).Scan)
+}
+
+func (db DB) UserGetByOIDCSubject(subject string) (User, error) {
+	if subject == "" {
+		return User{}, ErrNotFoundUser
+	}
+
+	return scanUser(db.pool.QueryRow(
+		`SELECT id, name, email, password_hash, oidc_subject, admin, create_time FROM users WHERE oidc_subject = $1`,
+		subject,
+	).Scan)
+}
+
+func (db DB) UserSetEmail(id string, email string) error {
+	result, err := db.pool.Exec(
+		`UPDATE users SET email = $1 WHERE id = $2`,
+		email, id,
+	)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrNotFoundUser
+	}
+
+	return nil
+}
+
+func (db DB) UserList() ([]User, error) {
+	rows, err := db.pool.Query(
+		`SELECT id, name, email, password_hash, oidc_subject, admin, create_time FROM users ORDER BY name`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []User
+	for rows.Next() {
+		user, err := scanUser(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+
+		users = append(users, user)
+	}
+
+	return users, rows.Err()
+}
+
+// UserDelete deletes the user and all user sessions.
+// Pastes of the user are not deleted.
+func (db DB) UserDelete(id string) error {
+	tx, err := db.pool.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(
+		`DELETE FROM users WHERE id = $1`,
+		id,
+	)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrNotFoundUser
+	}
+
+	_, err = tx.Exec(
+		`DELETE FROM sessions WHERE user_id = $1`,
+		id,
+	)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (db DB) SessionAdd(session Session) error {
+	_, err := db.pool.Exec(
+		`INSERT INTO sessions (id_hash, user_id, create_time, expire_time) VALUES ($1, $2, $3, $4)`,
+		session.IDHash, session.UserID, time.Now().Unix(), session.ExpireTime,
+	)
+	return err
+}
+
+// SessionGet returns the session. Expired sessions are deleted.
+func (db DB) SessionGet(idHash string) (Session, error) {
+	var session Session
+
+	err := db.pool.QueryRow(
+		`SELECT id_hash, user_id, create_time, expire_time FROM sessions WHERE id_hash = $1`,
+		idHash,
+	).Scan(&session.IDHash, &session.UserID, &session.CreateTime, &session.ExpireTime)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return Session{}, ErrNotFoundSession
+		}
+
+		return Session{}, err
+	}
+
+	if session.ExpireTime < time.Now().Unix() {
+		err = db.SessionDelete(idHash)
+		if err != nil && err != ErrNotFoundSession {
+			return Session{}, err
+		}
+
+		return Session{}, ErrNotFoundSession
+	}
+
+	return session, nil
+}
+
+func (db DB) SessionDelete(idHash string) error {
+	result, err := db.pool.Exec(
+		`DELETE FROM sessions WHERE id_hash = $1`,
+		idHash,
+	)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrNotFoundSession
+	}
+
+	return nil
+}
+
+func (db DB) SessionDeleteExpired() (int64, error) {
+	result, err := db.pool.Exec(
+		`DELETE FROM sessions WHERE expire_time < $1`,
+		time.Now().Unix(),
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+func (m *Memory) UserAdd(user User) (string, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	for _, u := range m.users {
+		if u.Name == user.Name {
+			return "", ErrUserExists
+		}
+	}
+
+	// Generate unique ID
+	for {
+		var err error
+		user.ID, err = genTokenCrypto(8)
+		if err != nil {
+			return "", err
+		}
+
+		_, exist := m.users[user.ID]
+		if exist == false {
+			break
+		}
+	}
+
+	user.CreateTime = time.Now().Unix()
+
+	m.users[user.ID] = user
+
+	return user.ID, nil
+}
+
+func (m *Memory) UserGet(id string) (User, error) {
+	m.RLock()
+	defer m.RUnlock()
+
+	user, exist := m.users[id]
+	if exist == false {
+		return User{}, ErrNotFoundUser
+	}
+
+	return user, nil
+}
+
+func (m *Memory) UserGetByName(name string) (User, error) {
+	m.RLock()
+	defer m.RUnlock()
+
+	for _, user := range m.users {
+		if user.Name == name {
+			return user, nil
+		}
+	}
+
+	return User{}, ErrNotFoundUser
+}
+
+func (m *Memory) UserGetByOIDCSubject(subject string) (User, error) {
+	m.RLock()
+	defer m.RUnlock()
+
+	if subject == "" {
+		return User{}, ErrNotFoundUser
+	}
+
+	for _, user := range m.users {
+		if user.OIDCSubject == subject {
+			return user, nil
+		}
+	}
+
+	return User{}, ErrNotFoundUser
+}
+
+func (m *Memory) UserSetEmail(id string, email string) error {
+	m.Lock()
+	defer m.Unlock()
+
+	user, exist := m.users[id]
+	if exist == false {
+		return ErrNotFoundUser
+	}
+
+	user.Email = email
+	m.users[id] = user
+
+	return nil
+}
+
+func (m *Memory) UserList() ([]User, error) {
+	m.RLock()
+	defer m.RUnlock()
+
+	var users []User
+	for _, user := range m.users {
+		users = append(users, user)
+	}
+
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].Name < users[j].Name
+	})
+
+	return users, nil
+}
+
+// UserDelete deletes the user and all user sessions.
+// Pastes of the user are not deleted.
+func (m *Memory) UserDelete(id string) error {
+	m.Lock()
+	defer m.Unlock()
+
+	_, exist := m.users[id]
+	if exist == false {
+		return ErrNotFoundUser
+	}
+
+	delete(m.users, id)
+
+	for idHash, session := range m.sessions {
+		if session.UserID == id {
+			delete(m.sessions, idHash)
+		}
+	}
+
+	return nil
+}
+
+func (m *Memory) SessionAdd(session Session) error {
+	m.Lock()
+	defer m.Unlock()
+
+	session.CreateTime = time.Now().Unix()
+	m.sessions[session.IDHash] = session
+
+	return nil
+}
+
+// SessionGet returns the session. Expired sessions are deleted.
+func (m *Memory) SessionGet(idHash string) (Session, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	session, exist := m.sessions[idHash]
+	if exist == false {
+		return Session{}, ErrNotFoundSession
+	}
+
+	if session.ExpireTime < time.Now().Unix() {
+		delete(m.sessions, idHash)
+		return Session{}, ErrNotFoundSession
+	}
+
+	return session, nil
+}
+
+func (m *Memory) SessionDelete(idHash string) error {
+	m.Lock()
+	defer m.Unlock()
+
+	_, exist := m.sessions[idHash]
+	if exist == false {
+		return ErrNotFoundSession
+	}
+
+	delete(m.sessions, idHash)
+
+	return nil
+}
+
+func (m *Memory) SessionDeleteExpired() (int64, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	timeNow := time.Now().Unix()
+
+	var count int64
+	for idHash, session := range m.sessions {
+		if session.ExpireTime < timeNow {
+			delete(m.sessions, idHash)
+			count++
+		}
+	}
+
+	return count, nil
+}
@@ -0,0 +1,169 @@
+// Copyright (C) 2021-2023 Leonid Maslakov.
+
+// This file is part of Lenpaste.
+
+// Lenpaste is free software: you can redistribute it
+// and/or modify it under the terms of the
+// GNU Affero Public License as published by the
+// Free Software Foundation, either version 3 of the License,
+// or (at your option) any later version.
+
+// Lenpaste is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+// or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero Public License for more details.
+
+// You should have received a copy of the GNU Affero Public License along with Lenpaste.
+// If not, see <https://www.gnu.org/licenses/>.
+
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestUsers(t *testing.T) {
+	for name, db := range testStores(t) {
+		// Add and get
+		id, err := db.UserAdd(User{Name: "alice", PasswordHash: "hash", Admin: true})
+		if err != nil {
+			t.Fatal(name, err)
+		}
+
+		_, err = db.UserAdd(User{Name: "alice", PasswordHash: "hash"})
+		if err != ErrUserExists {
+			t.Error(name, "expected ErrUserExists but got", err)
+		}
+
+		user, err := db.UserGetByName("alice")
+		if err != nil {
+			t.Fatal(name, err)
+		}
+
+		if user.ID != id || user.PasswordHash != "hash" || user.Admin == false || user.CreateTime == 0 {
+			t.Error(name, "unexpected user:", user)
+		}
+
+		user, err = db.UserGet(id)
+		if err != nil || user.Name != "alice" {
+			t.Error(name, "unexpected user:", user, err)
+		}
+
+		_, err = db.UserGetByName("bob")
+		if err != ErrNotFoundUser {
+			t.Error(name, "expected ErrNotFoundUser but got", err)
+		}
+
+		// Sessions
+		_, hash, err := NewSecret()
+		if err != nil {
+			t.Fatal(name, err)
+		}
+
+		err = db.SessionAdd(Session{IDHash: hash, UserID: id, ExpireTime: time.Now().Unix() + 60})
+		if err != nil {
+			t.Fatal(name, err)
+		}
+
+		session, err := db.SessionGet(hash)
+		if err != nil || session.UserID != id {
+			t.Error(name, "unexpected session:", session, err)
+		}
+
+		_, expiredHash, err := NewSecret()
+		if err != nil {
+			t.Fatal(name, err)
+		}
+
+		err = db.SessionAdd(Session{IDHash: expiredHash, UserID: id, ExpireTime: time.Now().Unix() - 60})
+		if err != nil {
+			t.Fatal(name, err)
+		}
+
+		count, err := db.SessionDeleteExpired()
+		if err != nil || count != 1 {
+			t.Error(name, "expected 1 deleted session but got", count, err)
+		}
+
+		// User pastes
+		pasteID, _, _, err := db.PasteAdd(Paste{Body: "Body", Syntax: "plaintext", UserID: id, Visibility: VisibilityPrivate, DeleteTime: time.Now().Unix() + 60})
+		if err != nil {
+			t.Fatal(name, err)
+		}
+
+		_, _, _, err = db.PasteAdd(Paste{Body: "Other", Syntax: "plaintext"})
+		if err != nil {
+			t.Fatal(name, err)
+		}
+
+		pastes, err := db.PasteListByUser(id, 10, 0)
+		if err != nil {
+			t.Fatal(name, err)
+		}
+
+		if len(pastes) != 1 || pastes[0].ID != pasteID || pastes[0].UserID != id {
+			t.Error(name, "unexpected user pastes:", pastes)
+		}
+
+		err = db.PasteSetDeleteTime(pasteID, 0)
+		if err != nil {
+			t.Fatal(name, err)
+		}
+
+		paste, err := db.PasteGet(pasteID)
+		if err != nil || paste.DeleteTime != 0 {
+			t.Error(name, "unexpected paste delete time:", paste.DeleteTime, err)
+		}
+
+		// Delete user with sessions
+		err = db.UserDelete(id)
+		if err != nil {
+			t.Fatal(name, err)
+		}
+
+		_, err = db.SessionGet(hash)
+		if err != ErrNotFoundSession {
+			t.Error(name, "expected ErrNotFoundSession but got", err)
+		}
+
+		err = db.UserDelete(id)
+		if err != ErrNotFoundUser {
+			t.Error(name, "expected ErrNotFoundUser but got", err)
+		}
+	}
+}
+
+func TestOIDCUsers(t *testing.T) {
+	for name, db := range testStores(t) {
+		id, err := db.UserAdd(User{Name: "alice@example.org", Email: "alice@example.org", OIDCSubject: "248289761001"})
+		if err != nil {
+			t.Fatal(name, err)
+		}
+
+		user, err := db.UserGetByOIDCSubject("248289761001")
+		if err != nil || user.ID != id || user.Email != "alice@example.org" || user.PasswordHash != "" {
+			t.Error(name, "unexpected user:", user, err)
+		}
+
+		_, err = db.UserGetByOIDCSubject("")
+		if err != ErrNotFoundUser {
+			t.Error(name, "expected ErrNotFoundUser but got", err)
+		}
+
+		err = db.UserSetEmail(id, "alice@example.com")
+		if err != nil {
+			t.Fatal(name, err)
+		}
+
+		user, err = db.UserGet(id)
+		if err != nil || user.Email != "alice@example.com" {
+			t.Error(name, "unexpected user:", user, err)
+		}
+
+		err = db.UserSetEmail("unknown", "bob@example.org")
+		if err != ErrNotFoundUser {
+			t.Error(name, "expected ErrNotFoundUser but got", err)
+		}
+	}
+}
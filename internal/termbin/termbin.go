@@ -0,0 +1,188 @@
+// Copyright (C) 2021-2023 Leonid Maslakov.
+
+// This file is part of Lenpaste.
+
+// Lenpaste is free software: you can redistribute it
+// and/or modify it under the terms of the
+// GNU Affero Public License as published by the
+// Free Software Foundation, either version 3 of the License,
+// or (at your option) any later version.
+
+// Lenpaste is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+// or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero Public License for more details.
+
+// You should have received a copy of the GNU Affero Public License along with Lenpaste.
+// If not, see <https://www.gnu.org/licenses/>.
+
+package termbin
+
+import (
+	chromaLexers "github.com/alecthomas/chroma/v2/lexers"
+	"github.com/lcomrade/lenpaste/internal/config"
+	"github.com/lcomrade/lenpaste/internal/logger"
+	"github.com/lcomrade/lenpaste/internal/netshare"
+	"github.com/lcomrade/lenpaste/internal/storage"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+const (
+	connMaxTime  = 5 * time.Minute // Connection is closed after this time even if the client is still sending data
+	drainMaxSize = 64 * 1024       // Max size of the unread data that is discarded before closing the connection
+)
+
+type Data struct {
+	DB  storage.Store
+	Log logger.Logger
+
+	RateLimitNew *netshare.RateLimitSystem
+
+	Lexers []string
+
+	TitleMaxLen int
+	BodyMaxLen  int
+	MaxLifeTime int64
+
+	PublicURL   string
+	IdleTimeout time.Duration
+}
+
+func Load(db storage.Store, cfg config.Config) *Data {
+	return &Data{
+		DB:           db,
+		Log:          cfg.Log,
+		RateLimitNew: cfg.RateLimitNew,
+		Lexers:       chromaLexers.Names(false),
+		TitleMaxLen:  cfg.TitleMaxLen,
+		BodyMaxLen:   cfg.BodyMaxLen,
+		MaxLifeTime:  cfg.MaxLifeTime,
+		PublicURL:    strings.TrimSuffix(cfg.PublicURL, "/"),
+		IdleTimeout:  cfg.TCPIdleTimeout,
+	}
+}
+
+// ListenAndServe listens on the TCP address and turns every connection into a paste.
+func (data *Data) ListenAndServe(address string) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+
+	return data.Serve(listener)
+}
+
+// Serve accepts connections on the listener until it is closed.
+func (data *Data) Serve(listener net.Listener) error {
+	defer listener.Close()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+
+		go data.Hand(conn)
+	}
+}
+
+// Hand reads the connection until EOF or idle timeout, saves the text
+// and writes back the paste URL. The connection is always closed.
+func (data *Data) Hand(conn net.Conn) {
+	defer data.close(conn)
+
+	var clientAddr net.IP
+	if tcpAddr, ok := conn.RemoteAddr().(*net.TCPAddr); ok {
+		clientAddr = tcpAddr.IP
+	}
+
+	err := data.hand(conn, clientAddr)
+
+	if err == nil {
+		data.Log.TcpRequest(clientAddr, 200)
+
+	} else {
+		code, err := data.writeError(conn, err)
+		if err != nil {
+			data.Log.TcpError(clientAddr, err)
+		} else {
+			data.Log.TcpRequest(clientAddr, code)
+		}
+	}
+}
+
+// close closes the connection after the answer is sent.
+// If the text was not read to the end (for example it is too large), closing the connection
+// with unread data resets it and the client may lose the answer. So the rest of the data is discarded first.
+func (data *Data) close(conn net.Conn) {
+	if tcpConn, ok := conn.(*net.TCPConn); ok {
+		tcpConn.CloseWrite()
+	}
+
+	conn.SetReadDeadline(time.Now().Add(data.IdleTimeout))
+	io.Copy(io.Discard, io.LimitReader(conn, drainMaxSize))
+
+	conn.Close()
+}
+
+func (data *Data) hand(conn net.Conn, clientAddr net.IP) error {
+	// Check rate limit
+	err := data.RateLimitNew.CheckAndUse(clientAddr)
+	if err != nil {
+		return err
+	}
+
+	// Read text
+	conn.SetDeadline(time.Now().Add(connMaxTime))
+
+	text, err := netshare.ReadBody(&idleReader{conn: conn, idleTimeout: data.IdleTimeout, deadline: time.Now().Add(connMaxTime)}, data.BodyMaxLen)
+	if err != nil {
+		return err
+	}
+
+	// Create paste
+	paste, err := netshare.PasteAddFromText(text, clientAddr, data.DB, data.TitleMaxLen, data.BodyMaxLen, data.MaxLifeTime, data.Lexers)
+	if err != nil {
+		return err
+	}
+
+	// Write result
+	_, err = io.WriteString(conn, data.PublicURL+"/"+paste.ID+"\n")
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// idleReader reads the connection until the client stops sending data for idleTimeout.
+// Clients like netcat often do not close the connection after sending all data,
+// so the idle timeout is treated as the end of the text.
+type idleReader struct {
+	conn        net.Conn
+	idleTimeout time.Duration
+	deadline    time.Time
+}
+
+func (r *idleReader) Read(p []byte) (int, error) {
+	readDeadline := time.Now().Add(r.idleTimeout)
+	if readDeadline.After(r.deadline) {
+		readDeadline = r.deadline
+	}
+
+	r.conn.SetReadDeadline(readDeadline)
+
+	n, err := r.conn.Read(p)
+	if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+		if time.Now().Before(r.deadline) {
+			return n, io.EOF
+		}
+
+		return n, errTimeout
+	}
+
+	return n, err
+}
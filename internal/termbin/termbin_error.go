@@ -0,0 +1,74 @@
+// Copyright (C) 2021-2023 Leonid Maslakov.
+
+// This file is part of Lenpaste.
+
+// Lenpaste is free software: you can redistribute it
+// and/or modify it under the terms of the
+// GNU Affero Public License as published by the
+// Free Software Foundation, either version 3 of the License,
+// or (at your option) any later version.
+
+// Lenpaste is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+// or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero Public License for more details.
+
+// You should have received a copy of the GNU Affero Public License along with Lenpaste.
+// If not, see <https://www.gnu.org/licenses/>.
+
+package termbin
+
+import (
+	"errors"
+	"github.com/lcomrade/lenpaste/internal/netshare"
+	"io"
+	"strconv"
+)
+
+var errTimeout = errors.New("termbin: connection time is over")
+
+func (data *Data) writeError(w io.Writer, e error) (int, error) {
+	var errText string
+	var errCode int
+
+	// Dectect error
+	var eTmp429 *netshare.ErrTooManyRequests
+	var eTmpBan *netshare.ErrBanned
+
+	if e == netshare.ErrBadRequest {
+		errCode = 400
+		errText = "400 Bad Request"
+
+	} else if e == netshare.ErrForbidden {
+		errCode = 403
+		errText = "403 Forbidden"
+
+	} else if errors.As(e, &eTmpBan) {
+		errCode = 403
+		errText = netshare.BanMessage(eTmpBan)
+
+	} else if e == errTimeout {
+		errCode = 408
+		errText = "408 Request Timeout"
+
+	} else if e == netshare.ErrPayloadTooLarge {
+		errCode = 413
+		errText = "413 Payload Too Large"
+
+	} else if errors.As(e, &eTmp429) {
+		errCode = 429
+		errText = "429 Too Many Requests, retry after " + strconv.FormatInt(eTmp429.RetryAfter, 10) + " seconds"
+
+	} else {
+		errCode = 500
+		errText = "500 Internal Server Error"
+	}
+
+	// Write response
+	_, err := io.WriteString(w, errText+"\n")
+	if err != nil {
+		return 500, err
+	}
+
+	return errCode, nil
+}
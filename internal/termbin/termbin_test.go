@@ -0,0 +1,117 @@
+// Copyright (C) 2021-2023 Leonid Maslakov.
+
+// This file is part of Lenpaste.
+
+// Lenpaste is free software: you can redistribute it
+// and/or modify it under the terms of the
+// GNU Affero Public License as published by the
+// Free Software Foundation, either version 3 of the License,
+// or (at your option) any later version.
+
+// Lenpaste is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+// or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero Public License for more details.
+
+// You should have received a copy of the GNU Affero Public License along with Lenpaste.
+// If not, see <https://www.gnu.org/licenses/>.
+
+package termbin
+
+import (
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lcomrade/lenpaste/internal/config"
+	"github.com/lcomrade/lenpaste/internal/logger"
+	"github.com/lcomrade/lenpaste/internal/netshare"
+	"github.com/lcomrade/lenpaste/internal/storage"
+)
+
+func newTestServer(t *testing.T, rateLimit uint) (*Data, string) {
+	data := Load(storage.NewMemory(), config.Config{
+		Log:            logger.New("2006/01/02 15:04:05"),
+		RateLimitNew:   netshare.NewRateLimitSystem(rateLimit, 0, 0),
+		TitleMaxLen:    100,
+		BodyMaxLen:     20,
+		MaxLifeTime:    -1,
+		PublicURL:      "https://paste.example.org/",
+		TCPIdleTimeout: 200 * time.Millisecond,
+	})
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go data.Serve(listener)
+
+	return data, listener.Addr().String()
+}
+
+// send sends the text and returns the answer.
+// If closeWrite is false, the server must detect the end of the text by the idle timeout.
+func send(t *testing.T, address string, text string, closeWrite bool) string {
+	conn, err := net.Dial("tcp", address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	_, err = io.WriteString(conn, text)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if closeWrite {
+		conn.(*net.TCPConn).CloseWrite()
+	}
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	answer, err := io.ReadAll(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(answer)
+}
+
+func TestTermbin(t *testing.T) {
+	data, address := newTestServer(t, 0)
+
+	for _, closeWrite := range []bool{true, false} {
+		answer := send(t, address, "Hello\nworld\n", closeWrite)
+
+		if strings.HasPrefix(answer, "https://paste.example.org/") == false || strings.HasSuffix(answer, "\n") == false {
+			t.Fatal("unexpected answer:", answer)
+		}
+
+		paste, err := data.DB.PasteGet(strings.TrimSpace(strings.TrimPrefix(answer, "https://paste.example.org/")))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if paste.Body != "Hello\nworld\n" || paste.Syntax != "plaintext" {
+			t.Error("unexpected paste:", paste)
+		}
+	}
+}
+
+func TestTermbinErrors(t *testing.T) {
+	_, address := newTestServer(t, 1)
+
+	answer := send(t, address, strings.Repeat("a", 21), true)
+	if answer != "413 Payload Too Large\n" {
+		t.Error("unexpected answer:", answer)
+	}
+
+	answer = send(t, address, "text", true)
+	if strings.HasPrefix(answer, "429 Too Many Requests") == false {
+		t.Error("unexpected answer:", answer)
+	}
+}
@@ -0,0 +1,353 @@
+// Copyright (C) 2021-2023 Leonid Maslakov.
+
+// This file is part of Lenpaste.
+
+// Lenpaste is free software: you can redistribute it
+// and/or modify it under the terms of the
+// GNU Affero Public License as published by the
+// Free Software Foundation, either version 3 of the License,
+// or (at your option) any later version.
+
+// Lenpaste is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+// or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero Public License for more details.
+
+// You should have received a copy of the GNU Affero Public License along with Lenpaste.
+// If not, see <https://www.gnu.org/licenses/>.
+
+// Encrypted paste body is the base64 encoded AES-GCM nonce followed by the ciphertext.
+// The key is kept in the URL fragment, browsers never send it to the server.
+const cryptoNonceSize = 12;
+
+function cryptoSupported() {
+	return typeof window.crypto === "object" && typeof window.crypto.subtle === "object";
+}
+
+function cryptoBytesToBase64(bytes) {
+	let str = "";
+	for (let i = 0; bytes.length > i; i++) {
+		str = str + String.fromCharCode(bytes[i]);
+	}
+
+	return btoa(str);
+}
+
+function cryptoBase64ToBytes(str) {
+	let raw = atob(str);
+	let bytes = new Uint8Array(raw.length);
+	for (let i = 0; raw.length > i; i++) {
+		bytes[i] = raw.charCodeAt(i);
+	}
+
+	return bytes;
+}
+
+// Key is encoded with URL safe base64 without padding
+function cryptoKeyToString(bytes) {
+	return cryptoBytesToBase64(bytes).replace(/\+/g, "-").replace(/\//g, "_").replace(/=+$/, "");
+}
+
+function cryptoKeyFromString(str) {
+	str = str.replace(/-/g, "+").replace(/_/g, "/");
+	while (str.length % 4 != 0) {
+		str = str + "=";
+	}
+
+	return cryptoBase64ToBytes(str);
+}
+
+function cryptoChangeLineEnd(text, lineEnd) {
+	text = text.replace(/\r\n|\r/g, "\n");
+
+	switch (lineEnd) {
+		case "CRLF": return text.replace(/\n/g, "\r\n");
+		case "CR": return text.replace(/\n/g, "\r");
+		default: return text;
+	}
+}
+
+function cryptoGetLineEnd(text) {
+	if (text.indexOf("\r\n") != -1) {
+		return "CRLF";
+	}
+
+	if (text.indexOf("\r") != -1) {
+		return "CR";
+	}
+
+	return "LF";
+}
+
+// Encrypts the text. If keyStr is empty a new key is generated.
+// Returns promise with the encrypted body and the key.
+async function cryptoEncrypt(text, keyStr) {
+	let key;
+	if (keyStr == "") {
+		key = await crypto.subtle.generateKey({name: "AES-GCM", length: 256}, true, ["encrypt", "decrypt"]);
+	} else {
+		key = await crypto.subtle.importKey("raw", cryptoKeyFromString(keyStr), "AES-GCM", true, ["encrypt", "decrypt"]);
+	}
+
+	let nonce = crypto.getRandomValues(new Uint8Array(cryptoNonceSize));
+	let ciphertext = new Uint8Array(await crypto.subtle.encrypt({name: "AES-GCM", iv: nonce}, key, new TextEncoder().encode(text)));
+
+	let body = new Uint8Array(nonce.length + ciphertext.length);
+	body.set(nonce);
+	body.set(ciphertext, nonce.length);
+
+	let rawKey = new Uint8Array(await crypto.subtle.exportKey("raw", key));
+
+	return {body: cryptoBytesToBase64(body), key: cryptoKeyToString(rawKey)};
+}
+
+async function cryptoDecrypt(body, keyStr) {
+	let key = await crypto.subtle.importKey("raw", cryptoKeyFromString(keyStr), "AES-GCM", false, ["decrypt"]);
+
+	let raw = cryptoBase64ToBytes(body);
+	let text = await crypto.subtle.decrypt({name: "AES-GCM", iv: raw.slice(0, cryptoNonceSize)}, key, raw.slice(cryptoNonceSize));
+
+	return new TextDecoder().decode(text);
+}
+
+
+// The server does not know the text of the encrypted paste, so it is highlighted here.
+// Only the most common tokens are highlighted, class names are the same as in the server side highlighter.
+const cryptoKeywords = new Set([
+	"abstract", "and", "as", "async", "await", "break", "case", "catch", "class", "const", "continue",
+	"def", "default", "defer", "del", "delete", "do", "elif", "else", "end", "enum", "except", "export",
+	"extends", "extern", "finally", "fn", "for", "foreach", "from", "func", "function", "go", "goto",
+	"if", "impl", "implements", "import", "in", "include", "interface", "is", "lambda", "let", "loop",
+	"map", "match", "mod", "module", "mut", "namespace", "new", "not", "or", "package", "pass", "private",
+	"protected", "pub", "public", "raise", "range", "return", "select", "static", "struct", "super",
+	"switch", "then", "this", "throw", "throws", "trait", "try", "type", "typeof", "union", "unsafe",
+	"use", "using", "var", "void", "where", "while", "with", "yield"
+]);
+
+const cryptoConstants = new Set([
+	"true", "false", "null", "nil", "None", "True", "False", "undefined", "NULL", "self", "iota"
+]);
+
+function cryptoHighlightRegExp(syntax) {
+	let comments = ["//[^\\n]*", "/\\*[\\s\\S]*?(?:\\*/|$)"];
+
+	if (/python|bash|shell|^sh$|yaml|toml|ruby|perl|^r$|make|docker|cmake|nginx|powershell|elixir|nim|coffee|tcl/i.test(syntax)) {
+		comments = ["#[^\\n]*"];
+	} else if (/sql|lua|haskell|ada|elm|vhdl/i.test(syntax)) {
+		comments = ["--[^\\n]*", "/\\*[\\s\\S]*?(?:\\*/|$)"];
+	} else if (/lisp|clojure|scheme|racket|ini|asm|nasm/i.test(syntax)) {
+		comments = [";[^\\n]*"];
+	} else if (/erlang|tex|matlab|prolog/i.test(syntax)) {
+		comments = ["%[^\\n]*"];
+	}
+
+	return new RegExp(
+		"(" + comments.join("|") + ")" +
+		"|(\"(?:\\\\.|[^\"\\\\\\n])*\"?|'(?:\\\\.|[^'\\\\\\n])*'?|`[^`]*`?)" +
+		"|(\\b(?:0[xX][0-9a-fA-F]+|\\d[\\d_]*(?:\\.\\d+)?(?:[eE][+-]?\\d+)?)\\b)" +
+		"|([A-Za-z_$][\\w$]*)",
+		"g"
+	);
+}
+
+function cryptoEscapeHTML(text) {
+	return text.replace(/&/g, "&amp;").replace(/</g, "&lt;").replace(/>/g, "&gt;").replace(/"/g, "&quot;");
+}
+
+function cryptoTokenise(text, syntax) {
+	let tokens = [];
+
+	// Plain text is not highlighted
+	if (/^(plaintext|text|markdown)$/i.test(syntax)) {
+		tokens.push({class: "", text: text});
+		return tokens;
+	}
+
+	let re = cryptoHighlightRegExp(syntax);
+	let last = 0;
+	let match;
+
+	while ((match = re.exec(text)) != null) {
+		if (match.index > last) {
+			tokens.push({class: "", text: text.slice(last, match.index)});
+		}
+
+		let tokenClass = "";
+		if (match[1] != undefined) {
+			tokenClass = "c";
+		} else if (match[2] != undefined) {
+			tokenClass = "s";
+		} else if (match[3] != undefined) {
+			tokenClass = "m";
+		} else if (cryptoKeywords.has(match[4])) {
+			tokenClass = "k";
+		} else if (cryptoConstants.has(match[4])) {
+			tokenClass = "kc";
+		}
+
+		tokens.push({class: tokenClass, text: match[0]});
+		last = re.lastIndex;
+	}
+
+	if (last < text.length) {
+		tokens.push({class: "", text: text.slice(last)});
+	}
+
+	return tokens;
+}
+
+// Returns HTML code with the same structure as the server side highlighter makes.
+function cryptoHighlight(text, syntax) {
+	let lines = [""];
+
+	let tokens = cryptoTokenise(text.replace(/\r\n|\r/g, "\n"), syntax);
+	for (let i = 0; tokens.length > i; i++) {
+		let parts = tokens[i].text.split("\n");
+
+		for (let j = 0; parts.length > j; j++) {
+			if (j != 0) {
+				lines[lines.length - 1] = lines[lines.length - 1] + "\n";
+				lines.push("");
+			}
+
+			if (parts[j] == "") {
+				continue;
+			}
+
+			if (tokens[i].class != "") {
+				lines[lines.length - 1] = lines[lines.length - 1] + "<span class='" + tokens[i].class + "'>" + cryptoEscapeHTML(parts[j]) + "</span>";
+			} else {
+				lines[lines.length - 1] = lines[lines.length - 1] + cryptoEscapeHTML(parts[j]);
+			}
+		}
+	}
+
+	// Last empty line is not shown
+	if (lines.length > 1 && lines[lines.length - 1] == "") {
+		lines.pop();
+	}
+
+	let lineDigits = lines.length.toString().length;
+	let result = "";
+	for (let i = 0; lines.length > i; i++) {
+		let lineNumber = (i + 1).toString().padStart(lineDigits, " ");
+		result = result + "<span class='line'><span class='ln'>" + lineNumber + "</span><span class='cl'>" + lines[i] + "</span></span>";
+	}
+
+	return result;
+}
+
+
+document.addEventListener("DOMContentLoaded", () => {
+	let key = window.location.hash.slice(1);
+
+	// Keep key in the links to the same paste
+	let links = document.getElementsByClassName("js-keep-key");
+	for (let i = 0; links.length > i; i++) {
+		links[i].href = links[i].href + window.location.hash;
+	}
+
+	// Paste page
+	let pasteElement = document.getElementById("js-encrypted-paste");
+	if (pasteElement != null) {
+		let code = pasteElement.getElementsByTagName("code")[0];
+
+		// Link to the revisions page is a part of the translated text
+		let revisionsLinks = document.querySelectorAll("a[href='/" + pasteElement.dataset.id + "/revisions']");
+		for (let i = 0; revisionsLinks.length > i; i++) {
+			revisionsLinks[i].href = revisionsLinks[i].href + window.location.hash;
+		}
+
+		if (cryptoSupported() == false) {
+			code.textContent = "{{call .Translate `cryptoJS.NotSupported`}}";
+			return;
+		}
+
+		if (key == "") {
+			code.textContent = "{{call .Translate `cryptoJS.NoKey`}}";
+			return;
+		}
+
+		cryptoDecrypt(pasteElement.dataset.body, key).then((text) => {
+			code.innerHTML = cryptoHighlight(text, pasteElement.dataset.syntax);
+
+			let lineEnd = document.getElementById("js-line-end");
+			if (lineEnd != null) {
+				lineEnd.textContent = ", " + cryptoGetLineEnd(text);
+			}
+		}).catch(() => {
+			code.textContent = "{{call .Translate `cryptoJS.DecryptError`}}";
+		});
+	}
+
+	// Create paste form
+	let createPasteForm = document.getElementById("create-paste-form");
+	if (createPasteForm != null && cryptoSupported() == true) {
+		document.getElementById("js-encrypt").style.display = null;
+
+		// Capture phase is used to send the form before history.js does it
+		document.addEventListener("submit", (event) => {
+			if (event.target != createPasteForm || createPasteForm.elements["encrypted"].checked == false) {
+				return;
+			}
+
+			event.preventDefault();
+			event.stopPropagation();
+
+			let body = createPasteForm.elements["body"];
+			let text = cryptoChangeLineEnd(body.value, createPasteForm.elements["lineEnd"].value);
+
+			cryptoEncrypt(text, "").then((result) => {
+				// The key does not reach the server, but the browser keeps it after the redirect
+				body.value = result.body;
+				createPasteForm.action = "/#" + result.key;
+				createPasteForm.submit();
+			}).catch(() => {
+				alert("{{call .Translate `cryptoJS.EncryptError`}}");
+			});
+		}, true);
+	}
+
+	// Edit paste form
+	let editPasteForm = document.getElementById("edit-paste-form");
+	if (editPasteForm != null && editPasteForm.dataset.encrypted == "true") {
+		let body = editPasteForm.elements["body"];
+		let submit = editPasteForm.querySelector("button[type='submit']");
+
+		submit.disabled = true;
+
+		if (cryptoSupported() == false) {
+			alert("{{call .Translate `cryptoJS.NotSupported`}}");
+			return;
+		}
+
+		if (key == "") {
+			alert("{{call .Translate `cryptoJS.NoKey`}}");
+			return;
+		}
+
+		cryptoDecrypt(body.value, key).then((text) => {
+			body.value = text;
+			body.dispatchEvent(new Event("input"));
+			editPasteForm.elements["lineEnd"].value = cryptoGetLineEnd(text);
+			submit.disabled = false;
+		}).catch(() => {
+			alert("{{call .Translate `cryptoJS.DecryptError`}}");
+		});
+
+		editPasteForm.addEventListener("submit", (event) => {
+			event.preventDefault();
+
+			let text = cryptoChangeLineEnd(body.value, editPasteForm.elements["lineEnd"].value);
+
+			// The same key is used, so the old paste link is still valid
+			cryptoEncrypt(text, key).then((result) => {
+				body.value = result.body;
+				editPasteForm.action = editPasteForm.action + "#" + key;
+				editPasteForm.submit();
+			}).catch(() => {
+				alert("{{call .Translate `cryptoJS.EncryptError`}}");
+			});
+		});
+	}
+});
@@ -291,6 +291,15 @@ document.addEventListener("DOMContentLoaded", () => {
 					localStorage.setItem("history", JSON.stringify(history));	
 				}
 
+				// Remember delete and edit tokens
+				let maxAge = 60 * 60 * 24 * 360 * 50;
+				if (xhr.response.deleteTime != 0) {
+					maxAge = xhr.response.deleteTime - Math.floor(Date.now() / 1000);
+				}
+
+				document.cookie = "deleteToken=" + xhr.response.deleteToken + "; path=/" + xhr.response.id + "; max-age=" + maxAge + "; SameSite=Strict";
+				document.cookie = "editToken=" + xhr.response.editToken + "; path=/" + xhr.response.id + "; max-age=" + maxAge + "; SameSite=Strict";
+
 				// Redirect
 				window.location = window.location + xhr.response.id;
 			};
@@ -109,4 +109,53 @@ document.addEventListener("DOMContentLoaded", () => {
 
 	editor.addEventListener("input", updateSymbolCounter);
 	updateSymbolCounter();
+
+	// Add files support
+	var addFileButton = document.getElementById("js-add-file");
+	if (addFileButton == null) {
+		return;
+	}
+
+	var files = document.getElementById("js-files");
+	var fileTemplate = document.getElementById("js-file-template");
+
+	function updateFiles() {
+		let multiFile = files.children.length > 0;
+
+		document.getElementById("js-file-name").style.display = multiFile ? null : "none";
+
+		// Encrypted paste can have only one file
+		let encrypted = document.getElementsByName("encrypted")[0];
+		if (encrypted != null) {
+			encrypted.disabled = multiFile;
+			if (multiFile) {
+				encrypted.checked = false;
+			}
+		}
+	}
+
+	function addRemoveHandler(file) {
+		file.getElementsByClassName("js-remove-file")[0].addEventListener("click", (e) => {
+			e.preventDefault();
+			file.remove();
+			updateFiles();
+		});
+	}
+
+	// Files copied from the forked paste
+	for (let file of files.children) {
+		addRemoveHandler(file);
+	}
+	updateFiles();
+
+	addFileButton.style.display = null;
+	addFileButton.addEventListener("click", () => {
+		files.appendChild(fileTemplate.content.cloneNode(true));
+
+		let file = files.lastElementChild;
+		addRemoveHandler(file);
+
+		file.getElementsByTagName("input")[0].focus();
+		updateFiles();
+	});
 });
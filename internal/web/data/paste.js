@@ -40,6 +40,12 @@ document.addEventListener("DOMContentLoaded", () => {
 	createTime.textContent = dateToString(new Date(createTime.textContent));
 
 
+	let editTime = document.getElementById("editTime");
+	if (editTime != null) {
+		editTime.textContent = dateToString(new Date(editTime.textContent));
+	}
+
+
 	let deleteTime = document.getElementById("deleteTime");
 	if (deleteTime != null) {
 		deleteTime.textContent = dateToString(new Date(deleteTime.textContent));
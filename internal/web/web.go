@@ -21,25 +21,30 @@ package web
 import (
 	"embed"
 	chromaLexers "github.com/alecthomas/chroma/v2/lexers"
+	"github.com/lcomrade/lenpaste/internal/apiv2"
 	"github.com/lcomrade/lenpaste/internal/config"
 	"github.com/lcomrade/lenpaste/internal/logger"
 	"github.com/lcomrade/lenpaste/internal/netshare"
+	"github.com/lcomrade/lenpaste/internal/oidc"
 	"github.com/lcomrade/lenpaste/internal/storage"
 	"html/template"
 	"net/http"
 	"strings"
 	textTemplate "text/template"
+	"time"
 )
 
 //go:embed data/*
 var embFS embed.FS
 
 type Data struct {
-	DB  storage.DB
+	DB  storage.Store
 	Log logger.Logger
 
-	RateLimitNew *netshare.RateLimitSystem
-	RateLimitGet *netshare.RateLimitSystem
+	RateLimitNew    *netshare.RateLimitSystem
+	RateLimitGet    *netshare.RateLimitSystem
+	RateLimitAuth   *netshare.RateLimitSystem
+	RateLimitSearch *netshare.RateLimitSystem
 
 	Lexers      []string
 	Locales     Locales
@@ -55,8 +60,24 @@ type Data struct {
 	CodeJS         *textTemplate.Template
 	PastePage      *template.Template
 	PasteJS        *textTemplate.Template
+	CryptoJS       *textTemplate.Template
 	PasteContinue  *template.Template
+	PasteDelete    *template.Template
+	PastePassword  *template.Template
+	PasteEdit      *template.Template
+	Diff           *template.Template
+	PasteRevisions *template.Template
+	Search         *template.Template
+	Recent         *template.Template
 	Settings       *template.Template
+	Login          *template.Template
+	Register       *template.Template
+	Me             *template.Template
+	Admin          *template.Template
+	AdminPaste     *template.Template
+	AdminLog       *template.Template
+	AdminReports   *template.Template
+	Report         *template.Template
 	About          *template.Template
 	TermsOfUse     *template.Template
 	Authors        *template.Template
@@ -65,8 +86,11 @@ type Data struct {
 
 	Docs        *template.Template
 	DocsApiV1   *template.Template
+	DocsApiV2   *template.Template
 	DocsApiLibs *template.Template
 
+	OpenAPIv2 *apiv2.OpenAPI
+
 	EmbeddedPage     *template.Template
 	EmbeddedHelpPage *template.Template
 
@@ -86,13 +110,22 @@ type Data struct {
 
 	RobotsDisallow bool
 
-	LenPasswdFile string
+	Auth netshare.Auth
+	OIDC *oidc.Provider
+
+	PublicURL string
+
+	Accounts        bool
+	Registration    string
+	SessionLifetime time.Duration
+	ReportsAutoHide int
 
 	UiDefaultLifeTime string
 	UiDefaultTheme    string
+	UiDisableHistory  bool
 }
 
-func Load(db storage.DB, cfg config.Config) (*Data, error) {
+func Load(db storage.Store, cfg config.Config) (*Data, error) {
 	var data Data
 	var err error
 
@@ -102,6 +135,8 @@ func Load(db storage.DB, cfg config.Config) (*Data, error) {
 
 	data.RateLimitNew = cfg.RateLimitNew
 	data.RateLimitGet = cfg.RateLimitGet
+	data.RateLimitAuth = cfg.RateLimitAuth
+	data.RateLimitSearch = cfg.RateLimitSearch
 
 	data.Version = cfg.Version
 
@@ -110,7 +145,15 @@ func Load(db storage.DB, cfg config.Config) (*Data, error) {
 	data.MaxLifeTime = cfg.MaxLifeTime
 	data.UiDefaultLifeTime = cfg.UiDefaultLifetime
 	data.UiDefaultTheme = cfg.UiDefaultTheme
-	data.LenPasswdFile = cfg.LenPasswdFile
+	data.UiDisableHistory = cfg.UiDisableHistory
+	data.Auth = cfg.Auth
+	data.OIDC = cfg.OIDC
+	data.PublicURL = cfg.PublicURL
+
+	data.Accounts = cfg.Accounts
+	data.Registration = cfg.Registration
+	data.SessionLifetime = cfg.SessionLifetime
+	data.ReportsAutoHide = cfg.ReportsAutoHide
 
 	data.ServerAbout = cfg.ServerAbout
 	data.ServerRules = cfg.ServerRules
@@ -149,7 +192,7 @@ func Load(db storage.DB, cfg config.Config) (*Data, error) {
 	}
 
 	// main.tmpl
-	data.Main, err = template.ParseFS(embFS, "data/base.tmpl", "data/main.tmpl")
+	data.Main, err = data.parsePage("main.tmpl")
 	if err != nil {
 		return nil, err
 	}
@@ -174,7 +217,7 @@ func Load(db storage.DB, cfg config.Config) (*Data, error) {
 	}
 
 	// paste.tmpl
-	data.PastePage, err = template.ParseFS(embFS, "data/base.tmpl", "data/paste.tmpl")
+	data.PastePage, err = data.parsePage("paste.tmpl")
 	if err != nil {
 		return nil, err
 	}
@@ -185,68 +228,175 @@ func Load(db storage.DB, cfg config.Config) (*Data, error) {
 		return nil, err
 	}
 
+	// crypto.js
+	data.CryptoJS, err = textTemplate.ParseFS(embFS, "data/crypto.js")
+	if err != nil {
+		return nil, err
+	}
+
 	// paste_continue.tmpl
-	data.PasteContinue, err = template.ParseFS(embFS, "data/base.tmpl", "data/paste_continue.tmpl")
+	data.PasteContinue, err = data.parsePage("paste_continue.tmpl")
+	if err != nil {
+		return nil, err
+	}
+
+	// paste_delete.tmpl
+	data.PasteDelete, err = data.parsePage("paste_delete.tmpl")
+	if err != nil {
+		return nil, err
+	}
+
+	// paste_password.tmpl
+	data.PastePassword, err = data.parsePage("paste_password.tmpl")
+	if err != nil {
+		return nil, err
+	}
+
+	// paste_edit.tmpl
+	data.PasteEdit, err = data.parsePage("paste_edit.tmpl")
+	if err != nil {
+		return nil, err
+	}
+
+	// paste_revisions.tmpl
+	data.PasteRevisions, err = data.parsePage("paste_revisions.tmpl")
+	if err != nil {
+		return nil, err
+	}
+
+	// search.tmpl
+	data.Search, err = data.parsePage("search.tmpl")
+	if err != nil {
+		return nil, err
+	}
+
+	// recent.tmpl
+	data.Recent, err = data.parsePage("recent.tmpl")
+	if err != nil {
+		return nil, err
+	}
+
+	// diff.tmpl
+	data.Diff, err = data.parsePage("diff.tmpl")
 	if err != nil {
 		return nil, err
 	}
 
 	// settings.tmpl
-	data.Settings, err = template.ParseFS(embFS, "data/base.tmpl", "data/settings.tmpl")
+	data.Settings, err = data.parsePage("settings.tmpl")
+	if err != nil {
+		return nil, err
+	}
+
+	// login.tmpl
+	data.Login, err = data.parsePage("login.tmpl")
+	if err != nil {
+		return nil, err
+	}
+
+	// register.tmpl
+	data.Register, err = data.parsePage("register.tmpl")
+	if err != nil {
+		return nil, err
+	}
+
+	// me.tmpl
+	data.Me, err = data.parsePage("me.tmpl")
+	if err != nil {
+		return nil, err
+	}
+
+	// admin.tmpl
+	data.Admin, err = data.parsePage("admin.tmpl")
+	if err != nil {
+		return nil, err
+	}
+
+	// admin_paste.tmpl
+	data.AdminPaste, err = data.parsePage("admin_paste.tmpl")
+	if err != nil {
+		return nil, err
+	}
+
+	// admin_log.tmpl
+	data.AdminLog, err = data.parsePage("admin_log.tmpl")
+	if err != nil {
+		return nil, err
+	}
+
+	// admin_reports.tmpl
+	data.AdminReports, err = data.parsePage("admin_reports.tmpl")
+	if err != nil {
+		return nil, err
+	}
+
+	// report.tmpl
+	data.Report, err = data.parsePage("report.tmpl")
 	if err != nil {
 		return nil, err
 	}
 
 	// about.tmpl
-	data.About, err = template.ParseFS(embFS, "data/base.tmpl", "data/about.tmpl")
+	data.About, err = data.parsePage("about.tmpl")
 	if err != nil {
 		return nil, err
 	}
 
 	// terms.tmpl
-	data.TermsOfUse, err = template.ParseFS(embFS, "data/base.tmpl", "data/terms.tmpl")
+	data.TermsOfUse, err = data.parsePage("terms.tmpl")
 	if err != nil {
 		return nil, err
 	}
 
 	// authors.tmpl
-	data.Authors, err = template.ParseFS(embFS, "data/base.tmpl", "data/authors.tmpl")
+	data.Authors, err = data.parsePage("authors.tmpl")
 	if err != nil {
 		return nil, err
 	}
 
 	// license.tmpl
-	data.License, err = template.ParseFS(embFS, "data/base.tmpl", "data/license.tmpl")
+	data.License, err = data.parsePage("license.tmpl")
 	if err != nil {
 		return nil, err
 	}
 
 	// source_code.tmpl
-	data.SourceCodePage, err = template.ParseFS(embFS, "data/base.tmpl", "data/source_code.tmpl")
+	data.SourceCodePage, err = data.parsePage("source_code.tmpl")
 	if err != nil {
 		return nil, err
 	}
 
 	// docs.tmpl
-	data.Docs, err = template.ParseFS(embFS, "data/base.tmpl", "data/docs.tmpl")
+	data.Docs, err = data.parsePage("docs.tmpl")
 	if err != nil {
 		return nil, err
 	}
 
 	// docs_apiv1.tmpl
-	data.DocsApiV1, err = template.ParseFS(embFS, "data/base.tmpl", "data/docs_apiv1.tmpl")
+	data.DocsApiV1, err = data.parsePage("docs_apiv1.tmpl")
+	if err != nil {
+		return nil, err
+	}
+
+	// docs_apiv2.tmpl
+	data.DocsApiV2, err = data.parsePage("docs_apiv2.tmpl")
+	if err != nil {
+		return nil, err
+	}
+
+	data.OpenAPIv2, err = apiv2.OpenAPIDoc()
 	if err != nil {
 		return nil, err
 	}
 
 	// docs_api_libs.tmpl
-	data.DocsApiLibs, err = template.ParseFS(embFS, "data/base.tmpl", "data/docs_api_libs.tmpl")
+	data.DocsApiLibs, err = data.parsePage("docs_api_libs.tmpl")
 	if err != nil {
 		return nil, err
 	}
 
 	// error.tmpl
-	data.ErrorPage, err = template.ParseFS(embFS, "data/base.tmpl", "data/error.tmpl")
+	data.ErrorPage, err = data.parsePage("error.tmpl")
 	if err != nil {
 		return nil, err
 	}
@@ -258,7 +408,7 @@ func Load(db storage.DB, cfg config.Config) (*Data, error) {
 	}
 
 	// emb_help.tmpl
-	data.EmbeddedHelpPage, err = template.ParseFS(embFS, "data/base.tmpl", "data/emb_help.tmpl")
+	data.EmbeddedHelpPage, err = data.parsePage("emb_help.tmpl")
 	if err != nil {
 		return nil, err
 	}
@@ -266,63 +416,135 @@ func Load(db storage.DB, cfg config.Config) (*Data, error) {
 	return &data, nil
 }
 
+// parsePage parses the page template together with base.tmpl.
+func (data *Data) parsePage(name string) (*template.Template, error) {
+	return template.New("base.tmpl").Funcs(template.FuncMap{
+		"accountsEnabled": func() bool { return data.Accounts },
+		"historyEnabled":  func() bool { return data.UiDisableHistory == false },
+	}).ParseFS(embFS, "data/base.tmpl", "data/"+name)
+}
+
+// isResource reports whether the path is the style sheet or the script.
+func isResource(urlPath string) bool {
+	switch urlPath {
+	case "/style.css", "/main.js", "/history.js", "/code.js", "/paste.js", "/crypto.js":
+		return true
+	}
+
+	return false
+}
+
 func (data *Data) Handler(rw http.ResponseWriter, req *http.Request) {
 	// Process request
 	var err error
 
 	rw.Header().Set("Server", config.Software+"/"+data.Version)
 
-	switch req.URL.Path {
-	// Search engines
-	case "/robots.txt":
-		err = data.robotsTxtHand(rw, req)
-	case "/sitemap.xml":
-		err = data.sitemapHand(rw, req)
-	// Resources
-	case "/style.css":
-		err = data.styleCSSHand(rw, req)
-	case "/main.js":
-		err = data.mainJSHand(rw, req)
-	case "/history.js":
-		err = data.historyJSHand(rw, req)
-	case "/code.js":
-		err = data.codeJSHand(rw, req)
-	case "/paste.js":
-		err = data.pasteJSHand(rw, req)
-	case "/about":
-		err = data.aboutHand(rw, req)
-	case "/about/authors":
-		err = data.authorsHand(rw, req)
-	case "/about/license":
-		err = data.licenseHand(rw, req)
-	case "/about/source_code":
-		err = data.sourceCodePageHand(rw, req)
-	case "/docs":
-		err = data.docsHand(rw, req)
-	case "/docs/apiv1":
-		err = data.docsApiV1Hand(rw, req)
-	case "/docs/api_libs":
-		err = data.docsApiLibsHand(rw, req)
-	// Pages
-	case "/":
-		err = data.newPasteHand(rw, req)
-	case "/settings":
-		err = data.settingsHand(rw, req)
-	case "/terms":
-		err = data.termsOfUseHand(rw, req)
-	// Else
-	default:
-		if strings.HasPrefix(req.URL.Path, "/dl/") {
-			err = data.dlHand(rw, req)
-
-		} else if strings.HasPrefix(req.URL.Path, "/emb/") {
-			err = data.embeddedHand(rw, req)
-
-		} else if strings.HasPrefix(req.URL.Path, "/emb_help/") {
-			err = data.embeddedHelpHand(rw, req)
+	// Read the login session and check the ban list.
+	// Resources are not blocked, so the error page looks as usual.
+	req, err = data.loadSession(req)
+	if err == nil && isResource(req.URL.Path) == false {
+		err = netshare.CheckBanRequest(req, data.DB)
+	}
 
-		} else {
-			err = data.getPasteHand(rw, req)
+	if err == nil {
+		switch req.URL.Path {
+		// Search engines
+		case "/robots.txt":
+			err = data.robotsTxtHand(rw, req)
+		case "/sitemap.xml":
+			err = data.sitemapHand(rw, req)
+		// Resources
+		case "/style.css":
+			err = data.styleCSSHand(rw, req)
+		case "/main.js":
+			err = data.mainJSHand(rw, req)
+		case "/history.js":
+			err = data.historyJSHand(rw, req)
+		case "/code.js":
+			err = data.codeJSHand(rw, req)
+		case "/paste.js":
+			err = data.pasteJSHand(rw, req)
+		case "/crypto.js":
+			err = data.cryptoJSHand(rw, req)
+		case "/about":
+			err = data.aboutHand(rw, req)
+		case "/about/authors":
+			err = data.authorsHand(rw, req)
+		case "/about/license":
+			err = data.licenseHand(rw, req)
+		case "/about/source_code":
+			err = data.sourceCodePageHand(rw, req)
+		case "/docs":
+			err = data.docsHand(rw, req)
+		case "/docs/apiv1":
+			err = data.docsApiV1Hand(rw, req)
+		case "/docs/apiv2":
+			err = data.docsApiV2Hand(rw, req)
+		case "/docs/api_libs":
+			err = data.docsApiLibsHand(rw, req)
+		// Pages
+		case "/":
+			err = data.newPasteHand(rw, req)
+		case "/recent":
+			err = data.recentHand(rw, req)
+		case "/recent.atom":
+			err = data.recentAtomHand(rw, req)
+		case "/search":
+			err = data.searchHand(rw, req)
+		case "/settings":
+			err = data.settingsHand(rw, req)
+		case "/login":
+			err = data.loginHand(rw, req)
+		case "/login/oidc":
+			err = data.oidcLoginHand(rw, req)
+		case "/login/oidc/callback":
+			err = data.oidcCallbackHand(rw, req)
+		case "/logout":
+			err = data.logoutHand(rw, req)
+		case "/register":
+			err = data.registerHand(rw, req)
+		case "/me":
+			err = data.meHand(rw, req)
+		case "/admin":
+			err = data.adminHand(rw, req)
+		case "/admin/paste":
+			err = data.adminPasteHand(rw, req)
+		case "/admin/log":
+			err = data.adminLogHand(rw, req)
+		case "/admin/reports":
+			err = data.adminReportsHand(rw, req)
+		case "/terms":
+			err = data.termsOfUseHand(rw, req)
+		// Else
+		default:
+			if strings.HasPrefix(req.URL.Path, "/dl/") {
+				err = data.dlHand(rw, req)
+
+			} else if strings.HasPrefix(req.URL.Path, "/del/") {
+				err = data.deletePasteHand(rw, req)
+
+			} else if strings.HasPrefix(req.URL.Path, "/diff/") {
+				err = data.diffHand(rw, req)
+
+			} else if strings.HasPrefix(req.URL.Path, "/edit/") {
+				err = data.editPasteHand(rw, req)
+
+			} else if strings.HasPrefix(req.URL.Path, "/report/") {
+				err = data.reportHand(rw, req)
+
+			} else if strings.HasPrefix(req.URL.Path, "/emb/") {
+				err = data.embeddedHand(rw, req)
+
+			} else if strings.HasPrefix(req.URL.Path, "/emb_help/") {
+				err = data.embeddedHelpHand(rw, req)
+
+			} else if strings.HasSuffix(req.URL.Path, "/revisions") {
+				err = data.pasteRevisionsHand(rw, req)
+
+			} else {
+				err = data.getPasteHand(rw, req)
+			}
 		}
 	}
 
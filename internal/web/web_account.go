@@ -0,0 +1,200 @@
+// Copyright (C) 2021-2023 Leonid Maslakov.
+
+// This file is part of Lenpaste.
+
+// Lenpaste is free software: you can redistribute it
+// and/or modify it under the terms of the
+// GNU Affero Public License as published by the
+// Free Software Foundation, either version 3 of the License,
+// or (at your option) any later version.
+
+// Lenpaste is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+// or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero Public License for more details.
+
+// You should have received a copy of the GNU Affero Public License along with Lenpaste.
+// If not, see <https://www.gnu.org/licenses/>.
+
+package web
+
+import (
+	"github.com/lcomrade/lenpaste/internal/config"
+	"github.com/lcomrade/lenpaste/internal/netshare"
+	"github.com/lcomrade/lenpaste/internal/storage"
+	"html/template"
+	"net/http"
+)
+
+type loginTmpl struct {
+	CSRF         string
+	Name         string
+	WrongLogin   bool
+	RegisterLink bool
+	OIDC         bool
+
+	Translate func(string, ...interface{}) template.HTML
+}
+
+type registerTmpl struct {
+	CSRF           string
+	Name           string
+	NameMaxLen     int
+	PasswordMinLen int
+	PasswordMaxLen int
+	ByAdmin        bool
+	Created        string
+	Error          string // Locale key of the error message
+
+	Translate func(string, ...interface{}) template.HTML
+}
+
+// Pattern: /login
+func (data *Data) loginHand(rw http.ResponseWriter, req *http.Request) error {
+	if data.Accounts == false {
+		return netshare.ErrNotFound
+	}
+
+	csrf, err := csrfToken(rw, req)
+	if err != nil {
+		return err
+	}
+
+	tmplData := loginTmpl{
+		CSRF:         csrf,
+		RegisterLink: data.Registration == config.RegistrationOpen,
+		OIDC:         data.OIDC != nil,
+		Translate:    data.Locales.findLocale(req).translate,
+	}
+
+	// Log in
+	if req.Method == "POST" {
+		err = checkCSRF(req)
+		if err != nil {
+			return err
+		}
+
+		user, err := netshare.UserLoginFromForm(req, data.DB, data.RateLimitAuth)
+		if err == nil {
+			err = data.startSession(rw, req, user.ID)
+			if err != nil {
+				return err
+			}
+
+			writeRedirect(rw, req, "/me", 302)
+			return nil
+		}
+
+		if err != netshare.ErrWrongPassword {
+			return err
+		}
+
+		tmplData.Name = req.PostForm.Get("name")
+		tmplData.WrongLogin = true
+	}
+
+	rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+	return data.Login.Execute(rw, tmplData)
+}
+
+// Pattern: /logout
+func (data *Data) logoutHand(rw http.ResponseWriter, req *http.Request) error {
+	if data.Accounts == false {
+		return netshare.ErrNotFound
+	}
+
+	if req.Method != "POST" {
+		return netshare.ErrMethodNotAllowed
+	}
+
+	err := checkCSRF(req)
+	if err != nil {
+		return err
+	}
+
+	err = data.endSession(rw, req)
+	if err != nil {
+		return err
+	}
+
+	writeRedirect(rw, req, "/", 302)
+	return nil
+}
+
+// Pattern: /register
+func (data *Data) registerHand(rw http.ResponseWriter, req *http.Request) error {
+	if data.Accounts == false || data.Registration == config.RegistrationClosed {
+		return netshare.ErrNotFound
+	}
+
+	// Only administrators can create users if registration is not open
+	byAdmin := false
+	if data.Registration == config.RegistrationAdmin {
+		user, exist := netshare.RequestUser(req)
+		if exist == false || user.Admin == false {
+			return netshare.ErrForbidden
+		}
+
+		byAdmin = true
+	}
+
+	csrf, err := csrfToken(rw, req)
+	if err != nil {
+		return err
+	}
+
+	tmplData := registerTmpl{
+		CSRF:           csrf,
+		NameMaxLen:     netshare.MaxLengthUserName,
+		PasswordMinLen: netshare.MinLengthUserPassword,
+		PasswordMaxLen: netshare.MaxLengthUserPassword,
+		ByAdmin:        byAdmin,
+		Translate:      data.Locales.findLocale(req).translate,
+	}
+
+	// Create user
+	if req.Method == "POST" {
+		err = checkCSRF(req)
+		if err != nil {
+			return err
+		}
+
+		tmplData.Name = req.PostForm.Get("name")
+
+		if req.PostForm.Get("password") != req.PostForm.Get("password2") {
+			tmplData.Error = "register.PasswordMismatch"
+
+		} else {
+			user, err := netshare.UserAddFromForm(req, data.DB, data.RateLimitAuth)
+			switch err {
+			case nil:
+				// Administrator stays logged in and can create more users
+				if byAdmin {
+					tmplData.Created = user.Name
+					tmplData.Name = ""
+					break
+				}
+
+				err = data.startSession(rw, req, user.ID)
+				if err != nil {
+					return err
+				}
+
+				writeRedirect(rw, req, "/me", 302)
+				return nil
+
+			case storage.ErrUserExists:
+				tmplData.Error = "register.UserExists"
+
+			case netshare.ErrBadRequest, netshare.ErrPayloadTooLarge:
+				tmplData.Error = "register.BadRequest"
+
+			default:
+				return err
+			}
+		}
+	}
+
+	rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+	return data.Register.Execute(rw, tmplData)
+}
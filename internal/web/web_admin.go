@@ -0,0 +1,331 @@
+// Copyright (C) 2021-2023 Leonid Maslakov.
+
+// This file is part of Lenpaste.
+
+// Lenpaste is free software: you can redistribute it
+// and/or modify it under the terms of the
+// GNU Affero Public License as published by the
+// Free Software Foundation, either version 3 of the License,
+// or (at your option) any later version.
+
+// Lenpaste is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+// or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero Public License for more details.
+
+// You should have received a copy of the GNU Affero Public License along with Lenpaste.
+// If not, see <https://www.gnu.org/licenses/>.
+
+package web
+
+import (
+	"github.com/lcomrade/lenpaste/internal/netshare"
+	"github.com/lcomrade/lenpaste/internal/storage"
+	"html/template"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type adminTmpl struct {
+	CSRF  string
+	Stats storage.Stats
+	Bans  []adminBanTmpl
+
+	Translate func(string, ...interface{}) template.HTML
+}
+
+type adminBanTmpl struct {
+	ID            string
+	CIDR          string
+	Reason        string
+	CreateTimeStr string
+	ExpireTimeStr string // Empty if the ban never expires
+}
+
+type adminPasteTmpl struct {
+	CSRF string
+
+	ID            string
+	Title         string
+	Syntax        string
+	Visibility    string
+	Revision      int
+	CreateTimeStr string
+	EditTimeStr   string // Empty if the paste has never been edited
+	DeleteTimeStr string // Empty if the paste never expires
+	OneUse        bool
+	Encrypted     bool
+	Password      bool
+	Files         int
+	ForkedFrom    string
+	Author        string
+	AuthorEmail   string
+	AuthorURL     string
+	UserName      string
+	TokenID       string
+	CreatorIP     string
+	Hidden        bool
+
+	Translate func(string, ...interface{}) template.HTML
+}
+
+type adminLogTmpl struct {
+	Entries  []adminLogEntryTmpl
+	PrevPage int // 0 if there is no previous page
+	NextPage int // 0 if there is no next page
+
+	Translate func(string, ...interface{}) template.HTML
+}
+
+type adminLogEntryTmpl struct {
+	TimeStr string
+	Admin   string
+	Action  string
+	Target  string
+	Details string
+}
+
+func formatAdminTime(t int64) string {
+	return time.Unix(t, 0).UTC().Format("Mon, 02 Jan 2006 15:04:05 -0700")
+}
+
+// adminBanAdd bans the range from the "cidr", "reason" and "expiration" (in seconds) form fields.
+func (data *Data) adminBanAdd(req *http.Request, pasteID string) error {
+	lifeTime, err := strconv.ParseInt(req.PostForm.Get("expiration"), 10, 64)
+	if err != nil {
+		return netshare.ErrBadRequest
+	}
+
+	_, err = netshare.AdminBanAdd(req, data.DB, req.PostForm.Get("cidr"), req.PostForm.Get("reason"), lifeTime, pasteID)
+	return err
+}
+
+// checkAdmin returns false if the administrator is not logged in and the user is redirected to the login page.
+// The admin panel does not exist if user accounts are disabled.
+func (data *Data) checkAdmin(rw http.ResponseWriter, req *http.Request) (bool, error) {
+	if data.Accounts == false {
+		return false, netshare.ErrNotFound
+	}
+
+	_, err := netshare.RequestAdmin(req)
+	if err != nil {
+		if err == netshare.ErrUnauthorized {
+			writeRedirect(rw, req, "/login", 302)
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Pattern: /admin
+func (data *Data) adminHand(rw http.ResponseWriter, req *http.Request) error {
+	ok, err := data.checkAdmin(rw, req)
+	if ok == false {
+		return err
+	}
+
+	// Ban or unban
+	if req.Method == "POST" {
+		err = checkCSRF(req)
+		if err != nil {
+			return err
+		}
+
+		switch req.PostForm.Get("action") {
+		case "ban":
+			err = data.adminBanAdd(req, "")
+		case "unban":
+			err = netshare.AdminBanDelete(req, data.DB, req.PostForm.Get("id"))
+		default:
+			err = netshare.ErrBadRequest
+		}
+
+		if err != nil {
+			return err
+		}
+
+		writeRedirect(rw, req, "/admin", 302)
+		return nil
+	}
+
+	// Show statistics and bans
+	stats, err := data.DB.Stats()
+	if err != nil {
+		return err
+	}
+
+	bans, err := data.DB.BanList()
+	if err != nil {
+		return err
+	}
+
+	csrf, err := csrfToken(rw, req)
+	if err != nil {
+		return err
+	}
+
+	tmplData := adminTmpl{
+		CSRF:      csrf,
+		Stats:     stats,
+		Bans:      make([]adminBanTmpl, len(bans)),
+		Translate: data.Locales.findLocale(req).translate,
+	}
+
+	for i, ban := range bans {
+		tmplData.Bans[i] = adminBanTmpl{
+			ID:            ban.ID,
+			CIDR:          ban.CIDR,
+			Reason:        ban.Reason,
+			CreateTimeStr: formatAdminTime(ban.CreateTime),
+		}
+
+		if ban.ExpireTime != 0 {
+			tmplData.Bans[i].ExpireTimeStr = formatAdminTime(ban.ExpireTime)
+		}
+	}
+
+	rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+	return data.Admin.Execute(rw, tmplData)
+}
+
+// Pattern: /admin/paste
+func (data *Data) adminPasteHand(rw http.ResponseWriter, req *http.Request) error {
+	ok, err := data.checkAdmin(rw, req)
+	if ok == false {
+		return err
+	}
+
+	// Delete paste or ban its creator
+	if req.Method == "POST" {
+		err = checkCSRF(req)
+		if err != nil {
+			return err
+		}
+
+		pasteID := req.PostForm.Get("id")
+
+		switch req.PostForm.Get("action") {
+		case "delete":
+			err = netshare.AdminPasteDelete(req, data.DB, pasteID)
+			if err == nil {
+				writeRedirect(rw, req, "/admin", 302)
+			}
+
+		case "ban":
+			err = data.adminBanAdd(req, pasteID)
+			if err == nil {
+				writeRedirect(rw, req, "/admin/paste?id="+url.QueryEscape(pasteID), 302)
+			}
+
+		default:
+			err = netshare.ErrBadRequest
+		}
+
+		return err
+	}
+
+	// Paste URL can be pasted instead of the ID
+	pasteID := path.Base(strings.TrimSpace(req.URL.Query().Get("id")))
+	if pasteID == "." || pasteID == "/" {
+		return netshare.ErrBadRequest
+	}
+
+	// Show paste metadata
+	paste, err := netshare.AdminPasteGet(req, data.DB, pasteID)
+	if err != nil {
+		return err
+	}
+
+	csrf, err := csrfToken(rw, req)
+	if err != nil {
+		return err
+	}
+
+	tmplData := adminPasteTmpl{
+		CSRF:          csrf,
+		ID:            paste.ID,
+		Title:         paste.Title,
+		Syntax:        paste.Syntax,
+		Visibility:    paste.Visibility,
+		Revision:      paste.Revision,
+		CreateTimeStr: formatAdminTime(paste.CreateTime),
+		OneUse:        paste.OneUse,
+		Encrypted:     paste.Encrypted,
+		Password:      paste.PasswordHash != "",
+		Files:         len(paste.Files),
+		ForkedFrom:    paste.ForkedFrom,
+		Author:        paste.Author,
+		AuthorEmail:   paste.AuthorEmail,
+		AuthorURL:     paste.AuthorURL,
+		TokenID:       paste.TokenID,
+		CreatorIP:     paste.CreatorIP,
+		Hidden:        paste.Hidden,
+		Translate:     data.Locales.findLocale(req).translate,
+	}
+
+	if paste.EditTime != 0 {
+		tmplData.EditTimeStr = formatAdminTime(paste.EditTime)
+	}
+
+	if paste.DeleteTime != 0 {
+		tmplData.DeleteTimeStr = formatAdminTime(paste.DeleteTime)
+	}
+
+	// User can be already deleted
+	if paste.UserID != "" {
+		tmplData.UserName = paste.UserID
+
+		user, err := data.DB.UserGet(paste.UserID)
+		if err == nil {
+			tmplData.UserName = user.Name
+		} else if err != storage.ErrNotFoundUser {
+			return err
+		}
+	}
+
+	rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+	return data.AdminPaste.Execute(rw, tmplData)
+}
+
+// Pattern: /admin/log
+func (data *Data) adminLogHand(rw http.ResponseWriter, req *http.Request) error {
+	ok, err := data.checkAdmin(rw, req)
+	if ok == false {
+		return err
+	}
+
+	page, err := netshare.AuditList(req, data.DB)
+	if err != nil {
+		return err
+	}
+
+	tmplData := adminLogTmpl{
+		Entries:   make([]adminLogEntryTmpl, len(page.Entries)),
+		PrevPage:  page.Page - 1,
+		Translate: data.Locales.findLocale(req).translate,
+	}
+
+	if page.NextPage {
+		tmplData.NextPage = page.Page + 1
+	}
+
+	for i, entry := range page.Entries {
+		tmplData.Entries[i] = adminLogEntryTmpl{
+			TimeStr: formatAdminTime(entry.Time),
+			Admin:   entry.Admin,
+			Action:  entry.Action,
+			Target:  entry.Target,
+			Details: entry.Details,
+		}
+	}
+
+	rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+	return data.AdminLog.Execute(rw, tmplData)
+}
@@ -0,0 +1,89 @@
+// Copyright (C) 2021-2023 Leonid Maslakov.
+
+// This file is part of Lenpaste.
+
+// Lenpaste is free software: you can redistribute it
+// and/or modify it under the terms of the
+// GNU Affero Public License as published by the
+// Free Software Foundation, either version 3 of the License,
+// or (at your option) any later version.
+
+// Lenpaste is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+// or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero Public License for more details.
+
+// You should have received a copy of the GNU Affero Public License along with Lenpaste.
+// If not, see <https://www.gnu.org/licenses/>.
+
+package web
+
+import (
+	"github.com/lcomrade/lenpaste/internal/netshare"
+	"github.com/lcomrade/lenpaste/internal/storage"
+	"html/template"
+	"net/http"
+	"strings"
+)
+
+type pasteDeleteTmpl struct {
+	ID          string
+	DeleteToken string
+	Deleted     bool
+
+	Translate func(string, ...interface{}) template.HTML
+}
+
+// Pattern: /del/<id>/<token>
+func (data *Data) deletePasteHand(rw http.ResponseWriter, req *http.Request) error {
+	// Get paste ID and delete token
+	parts := strings.Split(string([]rune(req.URL.Path)[5:]), "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return netshare.ErrNotFound
+	}
+
+	tmplData := pasteDeleteTmpl{
+		ID:          parts[0],
+		DeleteToken: parts[1],
+		Translate:   data.Locales.findLocale(req).translate,
+	}
+
+	// Delete paste
+	if req.Method == "POST" {
+		err := data.RateLimitNew.CheckAndUse(netshare.GetClientAddr(req))
+		if err != nil {
+			return err
+		}
+
+		err = netshare.PasteDeleteByToken(req, data.DB, tmplData.ID, tmplData.DeleteToken)
+		if err != nil {
+			return err
+		}
+
+		// Forget owner tokens
+		for _, name := range []string{"deleteToken", "editToken"} {
+			http.SetCookie(rw, &http.Cookie{
+				Name:   name,
+				Value:  "",
+				Path:   "/" + tmplData.ID,
+				MaxAge: -1,
+			})
+		}
+
+		tmplData.Deleted = true
+
+		// Else show confirmation page
+	} else {
+		paste, err := data.DB.PasteGet(tmplData.ID)
+		if err != nil {
+			return err
+		}
+
+		if storage.CheckSecret(tmplData.DeleteToken, paste.DeleteTokenHash) == false {
+			return netshare.ErrForbidden
+		}
+	}
+
+	rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+	return data.PasteDelete.Execute(rw, tmplData)
+}
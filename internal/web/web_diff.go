@@ -0,0 +1,199 @@
+// Copyright (C) 2021-2023 Leonid Maslakov.
+
+// This file is part of Lenpaste.
+
+// Lenpaste is free software: you can redistribute it
+// and/or modify it under the terms of the
+// GNU Affero Public License as published by the
+// Free Software Foundation, either version 3 of the License,
+// or (at your option) any later version.
+
+// Lenpaste is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+// or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero Public License for more details.
+
+// You should have received a copy of the GNU Affero Public License along with Lenpaste.
+// If not, see <https://www.gnu.org/licenses/>.
+
+package web
+
+import (
+	"github.com/lcomrade/lenpaste/internal/diff"
+	"github.com/lcomrade/lenpaste/internal/netshare"
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const diffContext = 3
+
+type diffTmpl struct {
+	IDA       string
+	IDB       string
+	RevA      int
+	RevB      int
+	Query     string
+	SplitView bool
+
+	Hunks   []diffHunkTmpl
+	Added   int
+	Removed int
+
+	Background template.CSS
+
+	Translate func(string, ...interface{}) template.HTML
+}
+
+type diffHunkTmpl struct {
+	Header string
+	Lines  []diffLineTmpl // Unified view
+	Rows   []diffRowTmpl  // Side-by-side view
+}
+
+type diffLineTmpl struct {
+	Op      string
+	OldLine int
+	NewLine int
+	Code    template.HTML
+}
+
+type diffRowTmpl struct {
+	Left  *diffLineTmpl
+	Right *diffLineTmpl
+}
+
+// Pattern: /diff/<idA>/<idB>
+func (data *Data) diffHand(rw http.ResponseWriter, req *http.Request) error {
+	// Check rate limit
+	err := data.RateLimitGet.CheckAndUse(netshare.GetClientAddr(req))
+	if err != nil {
+		return err
+	}
+
+	// Get paste IDs
+	parts := strings.Split(string([]rune(req.URL.Path)[6:]), "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return netshare.ErrNotFound
+	}
+
+	query := req.URL.Query()
+
+	pasteA, pasteB, err := netshare.PasteGetForDiff(req, data.DB, data.RateLimitGet, parts[0], query.Get("revA"), "", parts[1], query.Get("revB"), "")
+	if err != nil {
+		return err
+	}
+
+	// Find difference
+	theme := data.Themes.findTheme(req, data.UiDefaultTheme)
+
+	codeA := theme.highlightLines(pasteA.Body, pasteA.Syntax)
+	codeB := theme.highlightLines(pasteB.Body, pasteB.Syntax)
+
+	lines := diff.Lines(diff.SplitLines(pasteA.Body), diff.SplitLines(pasteB.Body))
+
+	// Prepare template data
+	tmplData := diffTmpl{
+		IDA:        pasteA.ID,
+		IDB:        pasteB.ID,
+		RevA:       pasteA.Revision,
+		RevB:       pasteB.Revision,
+		SplitView:  query.Get("view") == "split",
+		Background: theme.highlightBackground(),
+		Translate:  data.Locales.findLocale(req).translate,
+	}
+
+	// Revisions must be kept when switching the view
+	if query.Get("revA") != "" || query.Get("revB") != "" {
+		tmplData.Query = "revA=" + strconv.Itoa(pasteA.Revision) + "&revB=" + strconv.Itoa(pasteB.Revision) + "&"
+	}
+
+	for _, hunk := range diff.Hunks(lines, diffContext) {
+		hunkTmpl := diffHunkTmpl{
+			Header: "@@ -" + strconv.Itoa(hunk.OldStart) + "," + strconv.Itoa(hunk.OldLines) + " +" + strconv.Itoa(hunk.NewStart) + "," + strconv.Itoa(hunk.NewLines) + " @@",
+		}
+
+		for _, line := range hunk.Lines {
+			lineTmpl := diffLineTmpl{
+				Op:      line.Op,
+				OldLine: line.OldLine,
+				NewLine: line.NewLine,
+			}
+
+			switch line.Op {
+			case diff.OpDelete:
+				lineTmpl.Code = lineCode(codeA, line.OldLine, line.Text)
+				tmplData.Removed++
+
+			case diff.OpInsert:
+				lineTmpl.Code = lineCode(codeB, line.NewLine, line.Text)
+				tmplData.Added++
+
+			default:
+				lineTmpl.Code = lineCode(codeB, line.NewLine, line.Text)
+			}
+
+			hunkTmpl.Lines = append(hunkTmpl.Lines, lineTmpl)
+		}
+
+		hunkTmpl.Rows = splitRows(hunkTmpl.Lines)
+		tmplData.Hunks = append(tmplData.Hunks, hunkTmpl)
+	}
+
+	// Show diff
+	rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+	return data.Diff.Execute(rw, tmplData)
+}
+
+// lineCode returns highlighted line by its number (starting from 1).
+func lineCode(code []template.HTML, lineNum int, text string) template.HTML {
+	if lineNum > 0 && lineNum <= len(code) {
+		return code[lineNum-1]
+	}
+
+	return template.HTML(template.HTMLEscapeString(text))
+}
+
+// splitRows puts deleted lines next to the inserted lines that replaced them.
+func splitRows(lines []diffLineTmpl) []diffRowTmpl {
+	var rows []diffRowTmpl
+
+	for i := 0; i < len(lines); {
+		// Unchanged line is shown on both sides
+		if lines[i].Op == diff.OpEqual {
+			rows = append(rows, diffRowTmpl{Left: &lines[i], Right: &lines[i]})
+			i++
+			continue
+		}
+
+		// Collect block of deleted and then inserted lines
+		var deleted, inserted []*diffLineTmpl
+
+		for i < len(lines) && lines[i].Op == diff.OpDelete {
+			deleted = append(deleted, &lines[i])
+			i++
+		}
+
+		for i < len(lines) && lines[i].Op == diff.OpInsert {
+			inserted = append(inserted, &lines[i])
+			i++
+		}
+
+		for j := 0; j < len(deleted) || j < len(inserted); j++ {
+			var row diffRowTmpl
+
+			if j < len(deleted) {
+				row.Left = deleted[j]
+			}
+
+			if j < len(inserted) {
+				row.Right = inserted[j]
+			}
+
+			rows = append(rows, row)
+		}
+	}
+
+	return rows
+}
@@ -19,8 +19,12 @@
 package web
 
 import (
+	"archive/zip"
+	"bytes"
 	chromaLexers "github.com/alecthomas/chroma/v2/lexers"
 	"github.com/lcomrade/lenpaste/internal/netshare"
+	"github.com/lcomrade/lenpaste/internal/storage"
+	"io"
 	"net/http"
 	"strings"
 	"time"
@@ -37,7 +41,19 @@ func (data *Data) dlHand(rw http.ResponseWriter, req *http.Request) error {
 	// Read DB
 	pasteID := string([]rune(req.URL.Path)[4:])
 
-	paste, err := data.DB.PasteGet(pasteID)
+	paste, err := netshare.PasteGetRevision(data.DB, pasteID, req.URL.Query().Get("rev"))
+	if err != nil {
+		return err
+	}
+
+	// Private paste can be opened only by its owner
+	err = netshare.PasteCheckVisibility(req, paste)
+	if err != nil {
+		return err
+	}
+
+	// Check paste password
+	err = netshare.PasteCheckPassword(req, paste, data.RateLimitGet, netshare.PastePassword(req, true))
 	if err != nil {
 		return err
 	}
@@ -53,6 +69,9 @@ func (data *Data) dlHand(rw http.ResponseWriter, req *http.Request) error {
 
 	// Get create time
 	createTime := time.Unix(paste.CreateTime, 0).UTC()
+	if paste.EditTime != 0 {
+		createTime = time.Unix(paste.EditTime, 0).UTC()
+	}
 
 	// Get file name
 	fileName := paste.ID
@@ -60,6 +79,11 @@ func (data *Data) dlHand(rw http.ResponseWriter, req *http.Request) error {
 		fileName = paste.Title
 	}
 
+	// Multi-file paste is downloaded as zip archive
+	if len(paste.Files) != 0 {
+		return writeZip(rw, req, fileName+".zip", createTime, paste.Files)
+	}
+
 	// Get file extension
 	fileExt := chromaLexers.Get(paste.Syntax).Config().Filenames[0][1:]
 	if strings.HasSuffix(fileName, fileExt) == false {
@@ -76,3 +100,39 @@ func (data *Data) dlHand(rw http.ResponseWriter, req *http.Request) error {
 
 	return nil
 }
+
+func writeZip(rw http.ResponseWriter, req *http.Request, fileName string, modTime time.Time, files []storage.PasteFile) error {
+	// Make archive
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+
+	for _, file := range files {
+		w, err := zw.CreateHeader(&zip.FileHeader{
+			Name:     file.Name,
+			Method:   zip.Deflate,
+			Modified: modTime,
+		})
+		if err != nil {
+			return err
+		}
+
+		_, err = io.WriteString(w, file.Body)
+		if err != nil {
+			return err
+		}
+	}
+
+	err := zw.Close()
+	if err != nil {
+		return err
+	}
+
+	// Write result
+	rw.Header().Set("Content-Type", "application/zip")
+	rw.Header().Set("Content-Disposition", "attachment; filename="+fileName)
+	rw.Header().Set("Expires", "0")
+
+	http.ServeContent(rw, req, fileName, modTime, bytes.NewReader(buf.Bytes()))
+
+	return nil
+}
@@ -19,6 +19,7 @@
 package web
 
 import (
+	"github.com/lcomrade/lenpaste/internal/apiv2"
 	"github.com/lcomrade/lenpaste/internal/netshare"
 	"html/template"
 	"net/http"
@@ -31,11 +32,23 @@ type docsTmpl struct {
 
 type docsApiV1Tmpl struct {
 	MaxLenAuthorAll int
+	MaxLenPassword  int
+	MaxLenFileName  int
+	MaxFiles        int
+	MaxLenQuery     int
+	SearchPerPage   int
+	ListPerPage     int
 
 	Highlight func(string, string) template.HTML
 	Translate func(string, ...interface{}) template.HTML
 }
 
+type docsApiV2Tmpl struct {
+	Doc *apiv2.OpenAPI
+
+	Translate func(string, ...interface{}) template.HTML
+}
+
 // Pattern: /docs
 func (data *Data) docsHand(rw http.ResponseWriter, req *http.Request) error {
 	rw.Header().Set("Content-Type", "text/html; charset=utf-8")
@@ -47,11 +60,26 @@ func (data *Data) docsApiV1Hand(rw http.ResponseWriter, req *http.Request) error
 	rw.Header().Set("Content-Type", "text/html; charset=utf-8")
 	return data.DocsApiV1.Execute(rw, docsApiV1Tmpl{
 		MaxLenAuthorAll: netshare.MaxLengthAuthorAll,
+		MaxLenPassword:  netshare.MaxLengthPassword,
+		MaxLenFileName:  netshare.MaxLengthFileName,
+		MaxFiles:        netshare.MaxFiles,
+		MaxLenQuery:     netshare.MaxLengthSearchQuery,
+		SearchPerPage:   netshare.SearchPerPage,
+		ListPerPage:     netshare.ListPerPage,
 		Translate:       data.Locales.findLocale(req).translate,
 		Highlight:       data.Themes.findTheme(req, data.UiDefaultTheme).tryHighlight,
 	})
 }
 
+// Pattern: /docs/apiv2
+func (data *Data) docsApiV2Hand(rw http.ResponseWriter, req *http.Request) error {
+	rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+	return data.DocsApiV2.Execute(rw, docsApiV2Tmpl{
+		Doc:       data.OpenAPIv2,
+		Translate: data.Locales.findLocale(req).translate,
+	})
+}
+
 // Pattern: /docs/api_libs
 func (data *Data) docsApiLibsHand(rw http.ResponseWriter, req *http.Request) error {
 	rw.Header().Set("Content-Type", "text/html; charset=utf-8")
@@ -0,0 +1,100 @@
+// Copyright (C) 2021-2023 Leonid Maslakov.
+
+// This file is part of Lenpaste.
+
+// Lenpaste is free software: you can redistribute it
+// and/or modify it under the terms of the
+// GNU Affero Public License as published by the
+// Free Software Foundation, either version 3 of the License,
+// or (at your option) any later version.
+
+// Lenpaste is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+// or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero Public License for more details.
+
+// You should have received a copy of the GNU Affero Public License along with Lenpaste.
+// If not, see <https://www.gnu.org/licenses/>.
+
+package web
+
+import (
+	"github.com/lcomrade/lenpaste/internal/netshare"
+	"github.com/lcomrade/lenpaste/internal/storage"
+	"html/template"
+	"net/http"
+	"strings"
+)
+
+type pasteEditTmpl struct {
+	ID        string
+	EditToken string
+
+	Title   string
+	Body    string
+	Syntax  string
+	LineEnd string
+
+	Encrypted bool
+
+	TitleMaxLen int
+	BodyMaxLen  int
+	Lexers      []string
+
+	Translate func(string, ...interface{}) template.HTML
+}
+
+// Pattern: /edit/<id>/<token>
+func (data *Data) editPasteHand(rw http.ResponseWriter, req *http.Request) error {
+	// Get paste ID and edit token
+	parts := strings.Split(string([]rune(req.URL.Path)[6:]), "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return netshare.ErrNotFound
+	}
+
+	pasteID := parts[0]
+	editToken := parts[1]
+
+	// Save new revision
+	if req.Method == "POST" {
+		_, err := netshare.PasteEditFromForm(req, data.DB, data.RateLimitNew, pasteID, editToken, data.TitleMaxLen, data.BodyMaxLen, data.Lexers)
+		if err != nil {
+			return err
+		}
+
+		writeRedirect(rw, req, "/"+pasteID, 302)
+		return nil
+	}
+
+	// Else show edit page
+	paste, err := data.DB.PasteGet(pasteID)
+	if err != nil {
+		return err
+	}
+
+	if storage.CheckSecret(editToken, paste.EditTokenHash) == false {
+		return netshare.ErrForbidden
+	}
+
+	if paste.OneUse == true || len(paste.Files) != 0 {
+		return netshare.ErrBadRequest
+	}
+
+	tmplData := pasteEditTmpl{
+		ID:          paste.ID,
+		EditToken:   editToken,
+		Title:       paste.Title,
+		Body:        paste.Body,
+		Syntax:      paste.Syntax,
+		Encrypted:   paste.Encrypted,
+		TitleMaxLen: data.TitleMaxLen,
+		BodyMaxLen:  data.BodyMaxLen,
+		Lexers:      data.Lexers,
+		Translate:   data.Locales.findLocale(req).translate,
+	}
+
+	tmplData.LineEnd = lineEndName(paste.Body)
+
+	rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+	return data.PasteEdit.Execute(rw, tmplData)
+}
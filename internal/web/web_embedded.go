@@ -34,8 +34,10 @@ type embTmpl struct {
 	Title         string
 	Body          template.HTML
 
-	ErrorNotFound bool
-	Translate     func(string, ...interface{}) template.HTML
+	ErrorNotFound          bool
+	ErrorPasswordProtected bool
+	ErrorEncrypted         bool
+	Translate              func(string, ...interface{}) template.HTML
 }
 
 // Pattern: /emb/
@@ -62,6 +64,36 @@ func (data *Data) embeddedHand(rw http.ResponseWriter, req *http.Request) error
 		}
 	}
 
+	// Private paste looks like a nonexistent one
+	err = netshare.PasteCheckVisibility(req, paste)
+	if err == netshare.ErrNotFound {
+		errorNotFound = true
+		paste = storage.Paste{}
+
+	} else if err != nil {
+		return err
+	}
+
+	// Password protected paste can be embedded only if the user has already unlocked it
+	errorPasswordProtected := false
+
+	err = netshare.PasteCheckPassword(req, paste, data.RateLimitGet, "")
+	if err == netshare.ErrPasswordRequired {
+		errorPasswordProtected = true
+		paste = storage.Paste{ID: paste.ID}
+
+	} else if err != nil {
+		return err
+	}
+
+	// Encrypted paste can be decrypted only on its page, the key is not known here
+	errorEncrypted := false
+
+	if paste.Encrypted {
+		errorEncrypted = true
+		paste.Body = ""
+	}
+
 	// Prepare template data
 	createTime := time.Unix(paste.CreateTime, 0).UTC()
 
@@ -73,8 +105,10 @@ func (data *Data) embeddedHand(rw http.ResponseWriter, req *http.Request) error
 		Title:         paste.Title,
 		Body:          tryHighlight(paste.Body, paste.Syntax, "monokai"),
 
-		ErrorNotFound: errorNotFound,
-		Translate:     data.Locales.findLocale(req).translate,
+		ErrorNotFound:          errorNotFound,
+		ErrorPasswordProtected: errorPasswordProtected,
+		ErrorEncrypted:         errorEncrypted,
+		Translate:              data.Locales.findLocale(req).translate,
 	}
 
 	// Show paste
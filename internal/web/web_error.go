@@ -28,10 +28,14 @@ import (
 )
 
 type errorTmpl struct {
-	Code      int
-	AdminName string
-	AdminMail string
-	Translate func(string, ...interface{}) template.HTML
+	Code          int
+	PasswordError bool
+	Banned        bool
+	BanReason     string
+	BanExpireStr  string // Empty if the ban never expires
+	AdminName     string
+	AdminMail     string
+	Translate     func(string, ...interface{}) template.HTML
 }
 
 func (data *Data) writeError(rw http.ResponseWriter, req *http.Request, e error) (int, error) {
@@ -44,6 +48,7 @@ func (data *Data) writeError(rw http.ResponseWriter, req *http.Request, e error)
 
 	// Dectect error
 	var eTmp429 *netshare.ErrTooManyRequests
+	var eTmpBan *netshare.ErrBanned
 
 	if e == netshare.ErrBadRequest {
 		errData.Code = 400
@@ -51,9 +56,31 @@ func (data *Data) writeError(rw http.ResponseWriter, req *http.Request, e error)
 	} else if e == netshare.ErrUnauthorized {
 		errData.Code = 401
 
+	} else if e == netshare.ErrPasswordRequired {
+		errData.Code = 401
+		errData.PasswordError = true
+
+	} else if e == netshare.ErrForbidden {
+		errData.Code = 403
+
+	} else if e == netshare.ErrWrongPassword {
+		errData.Code = 403
+		errData.PasswordError = true
+
+	} else if errors.As(e, &eTmpBan) {
+		errData.Code = 403
+		errData.Banned = true
+		errData.BanReason = eTmpBan.Reason
+		if eTmpBan.ExpireTime != 0 {
+			errData.BanExpireStr = formatAdminTime(eTmpBan.ExpireTime)
+		}
+
 	} else if e == storage.ErrNotFoundID {
 		errData.Code = 404
 
+	} else if e == storage.ErrNotFoundRevision {
+		errData.Code = 404
+
 	} else if e == netshare.ErrNotFound {
 		errData.Code = 404
 
@@ -23,6 +23,7 @@ import (
 	"github.com/lcomrade/lenpaste/internal/netshare"
 	"html/template"
 	"net/http"
+	"strconv"
 	"time"
 )
 
@@ -34,6 +35,13 @@ type pasteTmpl struct {
 	CreateTime int64
 	DeleteTime int64
 	OneUse     bool
+	Encrypted  bool
+
+	// Encrypted paste is decrypted and highlighted by crypto.js
+	EncryptedBody string
+	HighlightCSS  template.CSS
+
+	Files []pasteFileTmpl
 
 	LineEnd       string
 	CreateTimeStr string
@@ -43,14 +51,39 @@ type pasteTmpl struct {
 	AuthorEmail string
 	AuthorURL   string
 
+	ForkedFrom string
+	Visibility string
+
+	Revision      int
+	RevisionQuery string
+	EditTime      int64
+	EditTimeStr   string
+
+	DeleteToken string
+	EditToken   string
+
 	Translate func(string, ...interface{}) template.HTML
 }
 
+type pasteFileTmpl struct {
+	Anchor  string
+	Name    string
+	Syntax  string
+	LineEnd string
+	Body    template.HTML
+}
+
 type pasteContinueTmpl struct {
 	ID        string
 	Translate func(string, ...interface{}) template.HTML
 }
 
+type pastePasswordTmpl struct {
+	ID            string
+	WrongPassword bool
+	Translate     func(string, ...interface{}) template.HTML
+}
+
 func (data *Data) getPasteHand(rw http.ResponseWriter, req *http.Request) error {
 	// Check rate limit
 	err := data.RateLimitGet.CheckAndUse(netshare.GetClientAddr(req))
@@ -62,11 +95,47 @@ func (data *Data) getPasteHand(rw http.ResponseWriter, req *http.Request) error
 	pasteID := string([]rune(req.URL.Path)[1:])
 
 	// Read DB
-	paste, err := data.DB.PasteGet(pasteID)
+	revStr := req.URL.Query().Get("rev")
+
+	paste, err := netshare.PasteGetRevision(data.DB, pasteID, revStr)
+	if err != nil {
+		return err
+	}
+
+	// Private paste can be opened only by its owner
+	err = netshare.PasteCheckVisibility(req, paste)
+	if err != nil {
+		return err
+	}
+
+	// Check paste password
+	err = netshare.PasteCheckPassword(req, paste, data.RateLimitGet, netshare.PastePassword(req, false))
+	if err == netshare.ErrPasswordRequired || err == netshare.ErrWrongPassword {
+		tmplData := pastePasswordTmpl{
+			ID:            paste.ID,
+			WrongPassword: err == netshare.ErrWrongPassword,
+			Translate:     data.Locales.findLocale(req).translate,
+		}
+
+		return data.PastePassword.Execute(rw, tmplData)
+	}
+
 	if err != nil {
 		return err
 	}
 
+	// Remember that the user knows the password, the cookie is also needed for raw text and download
+	if paste.PasswordHash != "" {
+		http.SetCookie(rw, &http.Cookie{
+			Name:     netshare.PasteAccessCookie(paste.ID),
+			Value:    netshare.PasteAccessToken(paste, time.Now().Add(netshare.PasteAccessLifetime).Unix()),
+			Path:     "/",
+			MaxAge:   int(netshare.PasteAccessLifetime / time.Second),
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+		})
+	}
+
 	// If "one use" paste
 	if paste.OneUse == true {
 		// If continue button not pressed
@@ -91,15 +160,16 @@ func (data *Data) getPasteHand(rw http.ResponseWriter, req *http.Request) error
 	// Prepare template data
 	createTime := time.Unix(paste.CreateTime, 0).UTC()
 	deleteTime := time.Unix(paste.DeleteTime, 0).UTC()
+	editTime := time.Unix(paste.EditTime, 0).UTC()
 
 	tmplData := pasteTmpl{
 		ID:         paste.ID,
 		Title:      paste.Title,
-		Body:       data.Themes.findTheme(req, data.UiDefaultTheme).tryHighlight(paste.Body, paste.Syntax),
 		Syntax:     paste.Syntax,
 		CreateTime: paste.CreateTime,
 		DeleteTime: paste.DeleteTime,
 		OneUse:     paste.OneUse,
+		Encrypted:  paste.Encrypted,
 
 		CreateTimeStr: createTime.Format("Mon, 02 Jan 2006 15:04:05 -0700"),
 		DeleteTimeStr: deleteTime.Format("Mon, 02 Jan 2006 15:04:05 -0700"),
@@ -108,19 +178,61 @@ func (data *Data) getPasteHand(rw http.ResponseWriter, req *http.Request) error
 		AuthorEmail: paste.AuthorEmail,
 		AuthorURL:   paste.AuthorURL,
 
+		ForkedFrom: paste.ForkedFrom,
+		Visibility: paste.Visibility,
+
+		Revision: paste.Revision,
+		EditTime: paste.EditTime,
+
+		EditTimeStr: editTime.Format("Mon, 02 Jan 2006 15:04:05 -0700"),
+
+		DeleteToken: getCookie(req, "deleteToken"),
+		EditToken:   getCookie(req, "editToken"),
+
 		Translate: data.Locales.findLocale(req).translate,
 	}
 
-	// Get body line end
-	switch lineend.GetLineEnd(paste.Body) {
-	case "\r\n":
-		tmplData.LineEnd = "CRLF"
-	case "\r":
-		tmplData.LineEnd = "CR"
-	default:
-		tmplData.LineEnd = "LF"
+	// Links to raw text and download must point to the same revision
+	if revStr != "" {
+		tmplData.RevisionQuery = "?rev=" + strconv.Itoa(paste.Revision)
+	}
+
+	// Server does not know the text of the encrypted paste
+	theme := data.Themes.findTheme(req, data.UiDefaultTheme)
+
+	if paste.Encrypted {
+		tmplData.EncryptedBody = paste.Body
+		tmplData.HighlightCSS = theme.highlightCSS()
+
+		return data.PastePage.Execute(rw, tmplData)
+	}
+
+	tmplData.Body = theme.tryHighlight(paste.Body, paste.Syntax)
+	tmplData.LineEnd = lineEndName(paste.Body)
+
+	// Files of the multi-file paste
+	for i, file := range paste.Files {
+		tmplData.Files = append(tmplData.Files, pasteFileTmpl{
+			Anchor:  "file-" + strconv.Itoa(i+1),
+			Name:    file.Name,
+			Syntax:  file.Syntax,
+			LineEnd: lineEndName(file.Body),
+			Body:    theme.tryHighlight(file.Body, file.Syntax),
+		})
 	}
 
 	// Show paste
 	return data.PastePage.Execute(rw, tmplData)
 }
+
+// lineEndName returns the name of the text line end as it is shown in the interface.
+func lineEndName(text string) string {
+	switch lineend.GetLineEnd(text) {
+	case "\r\n":
+		return "CRLF"
+	case "\r":
+		return "CR"
+	default:
+		return "LF"
+	}
+}
@@ -25,6 +25,7 @@ import (
 	"github.com/alecthomas/chroma/v2/lexers"
 	"github.com/alecthomas/chroma/v2/styles"
 	"html/template"
+	"strings"
 )
 
 func tryHighlight(source string, lexer string, theme string) template.HTML {
@@ -62,3 +63,83 @@ func tryHighlight(source string, lexer string, theme string) template.HTML {
 
 	return template.HTML(buf.String())
 }
+
+// highlightLines highlights the source and returns each line separately without line end.
+// It is used where lines are shown out of order, for example in the diff view.
+func highlightLines(source string, lexer string, theme string) []template.HTML {
+	// Determine lexer
+	l := lexers.Get(lexer)
+	if l == nil {
+		l = lexers.Fallback
+	}
+
+	l = chroma.Coalesce(l)
+
+	s := styles.Get(theme)
+
+	source = strings.Replace(source, "\r\n", "\n", -1)
+	source = strings.Replace(source, "\r", "\n", -1)
+
+	it, err := l.Tokenise(nil, source)
+	if err != nil {
+		var result []template.HTML
+		for _, line := range strings.Split(source, "\n") {
+			result = append(result, template.HTML(template.HTMLEscapeString(line)))
+		}
+		return result
+	}
+
+	// Format
+	var result []template.HTML
+
+	for _, tokens := range chroma.SplitTokensIntoLines(it.Tokens()) {
+		var buf strings.Builder
+
+		for _, token := range tokens {
+			text := strings.TrimRight(token.Value, "\n")
+			if text == "" {
+				continue
+			}
+
+			// Background is set for the whole table, so that the diff colors are visible
+			entry := s.Get(token.Type)
+			entry.Background = 0
+
+			css := html.StyleEntryToCSS(entry)
+			if css != "" {
+				buf.WriteString(`<span style="` + css + `">` + template.HTMLEscapeString(text) + `</span>`)
+			} else {
+				buf.WriteString(template.HTMLEscapeString(text))
+			}
+		}
+
+		result = append(result, template.HTML(buf.String()))
+	}
+
+	return result
+}
+
+// highlightCSS returns CSS classes of the highlight theme.
+// It is used by crypto.js to highlight encrypted pastes on the client side.
+func highlightCSS(theme string) template.CSS {
+	f := html.New(
+		html.WithClasses(true),
+		html.TabWidth(4),
+		html.WithLineNumbers(true),
+		html.WrapLongLines(true),
+	)
+
+	var buf bytes.Buffer
+
+	err := f.WriteCSS(&buf, styles.Get(theme))
+	if err != nil {
+		return ""
+	}
+
+	return template.CSS(buf.String())
+}
+
+// highlightBackground returns inline CSS with the background and text colors of the highlight theme.
+func highlightBackground(theme string) template.CSS {
+	return template.CSS(html.StyleEntryToCSS(styles.Get(theme).Get(chroma.Background)))
+}
@@ -0,0 +1,130 @@
+// Copyright (C) 2021-2023 Leonid Maslakov.
+
+// This file is part of Lenpaste.
+
+// Lenpaste is free software: you can redistribute it
+// and/or modify it under the terms of the
+// GNU Affero Public License as published by the
+// Free Software Foundation, either version 3 of the License,
+// or (at your option) any later version.
+
+// Lenpaste is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+// or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero Public License for more details.
+
+// You should have received a copy of the GNU Affero Public License along with Lenpaste.
+// If not, see <https://www.gnu.org/licenses/>.
+
+package web
+
+import (
+	"github.com/lcomrade/lenpaste/internal/config"
+	"github.com/lcomrade/lenpaste/internal/netshare"
+	"html/template"
+	"net/http"
+	"time"
+)
+
+type meTmpl struct {
+	UserName     string
+	Admin        bool
+	CSRF         string
+	RegisterLink bool
+	MaxLifeTime  int64
+
+	Pastes   []mePasteTmpl
+	PrevPage int // 0 if there is no previous page
+	NextPage int // 0 if there is no next page
+
+	Translate func(string, ...interface{}) template.HTML
+}
+
+type mePasteTmpl struct {
+	ID            string
+	Title         string
+	Visibility    string
+	CreateTimeStr string
+	DeleteTimeStr string // Empty if the paste never expires
+}
+
+// Pattern: /me
+func (data *Data) meHand(rw http.ResponseWriter, req *http.Request) error {
+	if data.Accounts == false {
+		return netshare.ErrNotFound
+	}
+
+	user, exist := netshare.RequestUser(req)
+	if exist == false {
+		writeRedirect(rw, req, "/login", 302)
+		return nil
+	}
+
+	// Delete or extend the paste
+	if req.Method == "POST" {
+		err := checkCSRF(req)
+		if err != nil {
+			return err
+		}
+
+		pasteID := req.PostForm.Get("id")
+
+		switch req.PostForm.Get("action") {
+		case "delete":
+			err = netshare.PasteDeleteByToken(req, data.DB, pasteID, "")
+		case "extend":
+			_, err = netshare.PasteExtendFromForm(req, data.DB, pasteID, data.MaxLifeTime)
+		default:
+			err = netshare.ErrBadRequest
+		}
+
+		if err != nil {
+			return err
+		}
+
+		writeRedirect(rw, req, "/me", 302)
+		return nil
+	}
+
+	// Show user pastes
+	page, err := netshare.PasteListByUser(req, data.DB)
+	if err != nil {
+		return err
+	}
+
+	csrf, err := csrfToken(rw, req)
+	if err != nil {
+		return err
+	}
+
+	tmplData := meTmpl{
+		UserName:     user.Name,
+		Admin:        user.Admin,
+		CSRF:         csrf,
+		RegisterLink: user.Admin && data.Registration == config.RegistrationAdmin,
+		MaxLifeTime:  data.MaxLifeTime,
+		Pastes:       make([]mePasteTmpl, len(page.Pastes)),
+		PrevPage:     page.Page - 1,
+		Translate:    data.Locales.findLocale(req).translate,
+	}
+
+	if page.NextPage {
+		tmplData.NextPage = page.Page + 1
+	}
+
+	for i, paste := range page.Pastes {
+		tmplData.Pastes[i] = mePasteTmpl{
+			ID:            paste.ID,
+			Title:         paste.Title,
+			Visibility:    paste.Visibility,
+			CreateTimeStr: time.Unix(paste.CreateTime, 0).UTC().Format("Mon, 02 Jan 2006 15:04:05 -0700"),
+		}
+
+		if paste.DeleteTime != 0 {
+			tmplData.Pastes[i].DeleteTimeStr = time.Unix(paste.DeleteTime, 0).UTC().Format("Mon, 02 Jan 2006 15:04:05 -0700")
+		}
+	}
+
+	rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+	return data.Me.Execute(rw, tmplData)
+}
@@ -19,16 +19,19 @@
 package web
 
 import (
-	"github.com/lcomrade/lenpaste/internal/lenpasswd"
 	"github.com/lcomrade/lenpaste/internal/netshare"
+	"github.com/lcomrade/lenpaste/internal/storage"
 	"html/template"
 	"net/http"
+	"time"
 )
 
 type createTmpl struct {
 	TitleMaxLen       int
 	BodyMaxLen        int
 	AuthorAllMaxLen   int
+	PasswordMaxLen    int
+	FileNameMaxLen    int
 	MaxLifeTime       int64
 	UiDefaultLifeTime string
 	Lexers            []string
@@ -40,61 +43,176 @@ type createTmpl struct {
 
 	AuthOk bool
 
+	// Text of the forked paste
+	ForkOf       string
+	Title        string
+	Body         string
+	Syntax       string
+	LineEnd      string
+	FileName     string
+	Files        []createFileTmpl
+	FileTemplate createFileTmpl
+
 	Translate func(string, ...interface{}) template.HTML
 }
 
+type createFileTmpl struct {
+	Name           string
+	Body           string
+	Syntax         string
+	FileNameMaxLen int
+	Lexers         []string
+	Translate      func(string, ...interface{}) template.HTML
+}
+
 func (data *Data) newPasteHand(rw http.ResponseWriter, req *http.Request) error {
 	var err error
 
 	// Check auth
 	authOk := true
 
-	if data.LenPasswdFile != "" {
+	err = netshare.CheckCreateAuth(req, data.Auth)
+	if err == netshare.ErrUnauthorized {
 		authOk = false
-
-		user, pass, authExist := req.BasicAuth()
-		if authExist == true {
-			authOk, err = lenpasswd.LoadAndCheck(data.LenPasswdFile, user, pass)
-			if err != nil {
-				return err
-			}
+		if data.Auth.Provider != nil {
+			rw.Header().Add("WWW-Authenticate", "Basic")
 		}
 
-		if authOk == false {
-			rw.Header().Add("WWW-Authenticate", "Basic")
-			rw.WriteHeader(401)
+		// Show the page with the message, but do not create the paste
+		if req.Method == "POST" {
+			return err
 		}
+
+		rw.WriteHeader(401)
+
+	} else if err != nil {
+		return err
 	}
 
 	// Create paste if need
 	if req.Method == "POST" {
-		pasteID, _, _, err := netshare.PasteAddFromForm(req, data.DB, data.RateLimitNew, data.TitleMaxLen, data.BodyMaxLen, data.MaxLifeTime, data.Lexers)
+		paste, err := netshare.PasteAddFromForm(req, data.DB, data.RateLimitNew, data.TitleMaxLen, data.BodyMaxLen, data.MaxLifeTime, data.Lexers)
 		if err != nil {
 			return err
 		}
 
+		// Remember owner tokens
+		setPasteCookie(rw, paste.ID, paste.DeleteTime, "deleteToken", paste.DeleteToken)
+		setPasteCookie(rw, paste.ID, paste.DeleteTime, "editToken", paste.EditToken)
+
+		// Private paste can be opened only with the owner cookie, it is also needed for raw text and download
+		if paste.Visibility == storage.VisibilityPrivate {
+			maxAge := cookieMaxAge
+			if paste.DeleteTime != 0 {
+				maxAge = int(paste.DeleteTime - time.Now().Unix())
+			}
+
+			http.SetCookie(rw, &http.Cookie{
+				Name:     netshare.PasteOwnerCookie(paste.ID),
+				Value:    netshare.PasteOwnerToken(paste.ID, storage.HashSecret(paste.EditToken)),
+				Path:     "/",
+				MaxAge:   maxAge,
+				HttpOnly: true,
+				SameSite: http.SameSiteLaxMode,
+			})
+		}
+
 		// Redirect to paste
-		writeRedirect(rw, req, "/"+pasteID, 302)
+		writeRedirect(rw, req, "/"+paste.ID, 302)
 		return nil
 	}
 
+	// Logged in user is the default author
+	user, _ := netshare.RequestUser(req)
+
+	authorDefault := getCookie(req, "author")
+	if authorDefault == "" {
+		authorDefault = user.Name
+	}
+
+	authorEmailDefault := getCookie(req, "authorEmail")
+	if authorEmailDefault == "" {
+		authorEmailDefault = user.Email
+	}
+
 	// Else show create page
 	tmplData := createTmpl{
 		TitleMaxLen:        data.TitleMaxLen,
 		BodyMaxLen:         data.BodyMaxLen,
 		AuthorAllMaxLen:    netshare.MaxLengthAuthorAll,
+		PasswordMaxLen:     netshare.MaxLengthPassword,
+		FileNameMaxLen:     netshare.MaxLengthFileName,
 		MaxLifeTime:        data.MaxLifeTime,
 		UiDefaultLifeTime:  data.UiDefaultLifeTime,
 		Lexers:             data.Lexers,
 		ServerTermsExist:   data.ServerTermsExist,
-		AuthorDefault:      getCookie(req, "author"),
-		AuthorEmailDefault: getCookie(req, "authorEmail"),
+		AuthorDefault:      authorDefault,
+		AuthorEmailDefault: authorEmailDefault,
 		AuthorURLDefault:   getCookie(req, "authorURL"),
 		AuthOk:             authOk,
+		Syntax:             "plaintext",
 		Translate:          data.Locales.findLocale(req).translate,
 	}
 
+	tmplData.FileTemplate = createFileTmpl{
+		Syntax:         "plaintext",
+		FileNameMaxLen: netshare.MaxLengthFileName,
+		Lexers:         data.Lexers,
+		Translate:      tmplData.Translate,
+	}
+
+	// Copy text of the forked paste
+	forkID := req.URL.Query().Get("fork")
+	if forkID != "" {
+		err = data.RateLimitGet.CheckAndUse(netshare.GetClientAddr(req))
+		if err != nil {
+			return err
+		}
+
+		fork, err := netshare.PasteGetForFork(req, data.DB, data.RateLimitGet, forkID)
+		if err != nil {
+			return err
+		}
+
+		tmplData.ForkOf = fork.ID
+		tmplData.Title = fork.Title
+		tmplData.Body = fork.Body
+		tmplData.Syntax = fork.Syntax
+		tmplData.LineEnd = lineEndName(fork.Body)
+
+		for i, file := range fork.Files {
+			if i == 0 {
+				tmplData.FileName = file.Name
+				continue
+			}
+
+			fileTmpl := tmplData.FileTemplate
+			fileTmpl.Name = file.Name
+			fileTmpl.Body = file.Body
+			fileTmpl.Syntax = file.Syntax
+
+			tmplData.Files = append(tmplData.Files, fileTmpl)
+		}
+	}
+
 	rw.Header().Set("Content-Type", "text/html; charset=utf-8")
 
 	return data.Main.Execute(rw, tmplData)
 }
+
+// setPasteCookie sets a cookie that is sent only to the paste pages and expires together with the paste.
+func setPasteCookie(rw http.ResponseWriter, pasteID string, deleteTime int64, name string, value string) {
+	maxAge := cookieMaxAge
+	if deleteTime != 0 {
+		maxAge = int(deleteTime - time.Now().Unix())
+	}
+
+	http.SetCookie(rw, &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/" + pasteID,
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
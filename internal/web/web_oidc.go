@@ -0,0 +1,139 @@
+// Copyright (C) 2021-2023 Leonid Maslakov.
+
+// This file is part of Lenpaste.
+
+// Lenpaste is free software: you can redistribute it
+// and/or modify it under the terms of the
+// GNU Affero Public License as published by the
+// Free Software Foundation, either version 3 of the License,
+// or (at your option) any later version.
+
+// Lenpaste is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+// or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero Public License for more details.
+
+// You should have received a copy of the GNU Affero Public License along with Lenpaste.
+// If not, see <https://www.gnu.org/licenses/>.
+
+package web
+
+import (
+	"crypto/subtle"
+	"errors"
+	"github.com/lcomrade/lenpaste/internal/netshare"
+	"github.com/lcomrade/lenpaste/internal/oidc"
+	"net/http"
+	"strings"
+)
+
+// Cookie keeps the state, nonce and PKCE code verifier while the user logs in with the identity provider.
+const (
+	oidcCookie       = "oidc"
+	oidcCookieMaxAge = 10 * 60 // 10 minutes
+)
+
+// oidcRedirectURL returns the URL the identity provider redirects the user to after login.
+// It must be registered in the identity provider.
+func (data *Data) oidcRedirectURL(req *http.Request) string {
+	baseURL := strings.TrimSuffix(data.PublicURL, "/")
+	if baseURL == "" {
+		baseURL = netshare.GetProtocol(req) + "://" + netshare.GetHost(req)
+	}
+
+	return baseURL + "/login/oidc/callback"
+}
+
+func setOIDCCookie(rw http.ResponseWriter, req *http.Request, value string, maxAge int) {
+	http.SetCookie(rw, &http.Cookie{
+		Name:     oidcCookie,
+		Value:    value,
+		Path:     "/login/oidc",
+		MaxAge:   maxAge,
+		Secure:   netshare.GetProtocol(req) == "https",
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// Pattern: /login/oidc
+func (data *Data) oidcLoginHand(rw http.ResponseWriter, req *http.Request) error {
+	if data.OIDC == nil {
+		return netshare.ErrNotFound
+	}
+
+	state, err := oidc.NewRandom()
+	if err != nil {
+		return err
+	}
+
+	nonce, err := oidc.NewRandom()
+	if err != nil {
+		return err
+	}
+
+	codeVerifier, err := oidc.NewRandom()
+	if err != nil {
+		return err
+	}
+
+	setOIDCCookie(rw, req, state+"."+nonce+"."+codeVerifier, oidcCookieMaxAge)
+
+	rw.Header().Set("Location", data.OIDC.AuthURL(data.oidcRedirectURL(req), state, nonce, codeVerifier))
+	rw.WriteHeader(302)
+
+	return nil
+}
+
+// Pattern: /login/oidc/callback
+func (data *Data) oidcCallbackHand(rw http.ResponseWriter, req *http.Request) error {
+	if data.OIDC == nil {
+		return netshare.ErrNotFound
+	}
+
+	// Login attempt can be used only once
+	cookie := strings.Split(getCookie(req, oidcCookie), ".")
+	setOIDCCookie(rw, req, "", -1)
+
+	if len(cookie) != 3 {
+		return netshare.ErrForbidden
+	}
+
+	state, nonce, codeVerifier := cookie[0], cookie[1], cookie[2]
+
+	query := req.URL.Query()
+	if subtle.ConstantTimeCompare([]byte(query.Get("state")), []byte(state)) != 1 {
+		return netshare.ErrForbidden
+	}
+
+	// User canceled the login or the identity provider refused it
+	if query.Get("error") != "" {
+		return netshare.ErrForbidden
+	}
+
+	code := query.Get("code")
+	if code == "" {
+		return netshare.ErrBadRequest
+	}
+
+	claims, err := data.OIDC.Exchange(code, codeVerifier, data.oidcRedirectURL(req), nonce)
+	if err != nil {
+		data.Log.Error(errors.New("OpenID Connect login: " + err.Error()))
+		return netshare.ErrForbidden
+	}
+
+	user, err := netshare.OIDCUser(data.DB, claims.Subject, claims.VerifiedEmail())
+	if err != nil {
+		return err
+	}
+
+	err = data.startSession(rw, req, user.ID)
+	if err != nil {
+		return err
+	}
+
+	rw.Header().Set("Location", "/me")
+	rw.WriteHeader(302)
+
+	return nil
+}
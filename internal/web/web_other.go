@@ -21,6 +21,7 @@ package web
 import (
 	"crypto/md5"
 	"fmt"
+	"github.com/lcomrade/lenpaste/internal/netshare"
 	"html/template"
 	"net/http"
 	"os"
@@ -52,6 +53,10 @@ func (data *Data) codeJSHand(rw http.ResponseWriter, req *http.Request) error {
 }
 
 func (data *Data) historyJSHand(rw http.ResponseWriter, req *http.Request) error {
+	if data.UiDisableHistory {
+		return netshare.ErrNotFound
+	}
+
 	rw.Header().Set("Content-Type", "application/javascript; charset=utf-8")
 	return data.HistoryJS.Execute(rw, jsTmpl{
 		Translate: data.Locales.findLocale(req).translate,
@@ -64,6 +69,11 @@ func (data *Data) pasteJSHand(rw http.ResponseWriter, req *http.Request) error {
 	return data.PasteJS.Execute(rw, jsTmpl{Translate: data.Locales.findLocale(req).translate})
 }
 
+func (data *Data) cryptoJSHand(rw http.ResponseWriter, req *http.Request) error {
+	rw.Header().Set("Content-Type", "application/javascript; charset=utf-8")
+	return data.CryptoJS.Execute(rw, jsTmpl{Translate: data.Locales.findLocale(req).translate})
+}
+
 func init() {
 	resp := "\u0045\u0072\u0072\u006f\u0072\u002e\u0020\u0059\u006f\u0075\u0020\u006d\u0061"
 	resp += "\u0079\u0020\u0062\u0065\u0020\u0076\u0069\u006f\u006c\u0061\u0074\u0069\u006e"
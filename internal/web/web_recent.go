@@ -0,0 +1,199 @@
+// Copyright (C) 2021-2023 Leonid Maslakov.
+
+// This file is part of Lenpaste.
+
+// Lenpaste is free software: you can redistribute it
+// and/or modify it under the terms of the
+// GNU Affero Public License as published by the
+// Free Software Foundation, either version 3 of the License,
+// or (at your option) any later version.
+
+// Lenpaste is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+// or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero Public License for more details.
+
+// You should have received a copy of the GNU Affero Public License along with Lenpaste.
+// If not, see <https://www.gnu.org/licenses/>.
+
+package web
+
+import (
+	"encoding/xml"
+	"github.com/lcomrade/lenpaste/internal/config"
+	"github.com/lcomrade/lenpaste/internal/netshare"
+	"html/template"
+	"net/http"
+	"time"
+	"unicode/utf8"
+)
+
+// Body of the paste in the Atom feed is cut to this number of characters
+const atomMaxContentLen = 4096
+
+type recentTmpl struct {
+	Pastes   []recentPasteTmpl
+	PrevPage int // 0 if there is no previous page
+	NextPage int // 0 if there is no next page
+
+	Translate func(string, ...interface{}) template.HTML
+}
+
+type recentPasteTmpl struct {
+	ID            string
+	Title         string
+	Syntax        string
+	Author        string
+	CreateTimeStr string
+}
+
+// Pattern: /recent
+func (data *Data) recentHand(rw http.ResponseWriter, req *http.Request) error {
+	page, err := netshare.PasteList(req, data.DB, data.RateLimitGet)
+	if err != nil {
+		return err
+	}
+
+	// Prepare template data
+	tmplData := recentTmpl{
+		Pastes:    make([]recentPasteTmpl, len(page.Pastes)),
+		PrevPage:  page.Page - 1,
+		Translate: data.Locales.findLocale(req).translate,
+	}
+
+	if page.NextPage {
+		tmplData.NextPage = page.Page + 1
+	}
+
+	for i, paste := range page.Pastes {
+		tmplData.Pastes[i] = recentPasteTmpl{
+			ID:            paste.ID,
+			Title:         paste.Title,
+			Syntax:        paste.Syntax,
+			Author:        paste.Author,
+			CreateTimeStr: time.Unix(paste.CreateTime, 0).UTC().Format("Mon, 02 Jan 2006 15:04:05 -0700"),
+		}
+	}
+
+	rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+	return data.Recent.Execute(rw, tmplData)
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Link    []atomLink  `xml:"link"`
+	Author  atomAuthor  `xml:"author"`
+	Entry   []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomAuthor struct {
+	Name  string `xml:"name"`
+	Email string `xml:"email,omitempty"`
+	URI   string `xml:"uri,omitempty"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomContent struct {
+	Type string `xml:"type,attr"`
+	Text string `xml:",chardata"`
+}
+
+type atomEntry struct {
+	ID        string       `xml:"id"`
+	Title     string       `xml:"title"`
+	Updated   string       `xml:"updated"`
+	Published string       `xml:"published"`
+	Link      atomLink     `xml:"link"`
+	Author    *atomAuthor  `xml:"author,omitempty"`
+	Category  atomCategory `xml:"category"`
+	Content   atomContent  `xml:"content"`
+}
+
+// Pattern: /recent.atom
+func (data *Data) recentAtomHand(rw http.ResponseWriter, req *http.Request) error {
+	page, err := netshare.PasteList(req, data.DB, data.RateLimitGet)
+	if err != nil {
+		return err
+	}
+
+	baseURL := netshare.GetProtocol(req) + "://" + netshare.GetHost(req)
+
+	// Feed info
+	feed := atomFeed{
+		ID:      baseURL + "/recent",
+		Title:   string(data.Locales.findLocale(req).translate("recent.Title")) + " | " + config.Software,
+		Updated: time.Now().UTC().Format(time.RFC3339),
+		Link: []atomLink{
+			{Rel: "self", Type: "application/atom+xml", Href: baseURL + "/recent.atom"},
+			{Rel: "alternate", Type: "text/html", Href: baseURL + "/recent"},
+		},
+		Author: atomAuthor{Name: config.Software},
+	}
+
+	if data.AdminName != "" {
+		feed.Author = atomAuthor{Name: data.AdminName, Email: data.AdminMail}
+	}
+
+	// Pastes
+	for i, paste := range page.Pastes {
+		updateTime := paste.CreateTime
+		if paste.EditTime != 0 {
+			updateTime = paste.EditTime
+		}
+
+		// The newest paste is the first one
+		if i == 0 {
+			feed.Updated = time.Unix(updateTime, 0).UTC().Format(time.RFC3339)
+		}
+
+		entry := atomEntry{
+			ID:        baseURL + "/" + paste.ID,
+			Title:     paste.Title,
+			Updated:   time.Unix(updateTime, 0).UTC().Format(time.RFC3339),
+			Published: time.Unix(paste.CreateTime, 0).UTC().Format(time.RFC3339),
+			Link:      atomLink{Rel: "alternate", Type: "text/html", Href: baseURL + "/" + paste.ID},
+			Category:  atomCategory{Term: paste.Syntax},
+			Content:   atomContent{Type: "text", Text: paste.Body},
+		}
+
+		if entry.Title == "" {
+			entry.Title = paste.ID
+		}
+
+		// Author name is required by Atom
+		if paste.Author != "" || paste.AuthorEmail != "" {
+			entry.Author = &atomAuthor{Name: paste.Author, Email: paste.AuthorEmail, URI: paste.AuthorURL}
+			if entry.Author.Name == "" {
+				entry.Author.Name = paste.AuthorEmail
+			}
+		}
+
+		if utf8.RuneCountInString(paste.Body) > atomMaxContentLen {
+			entry.Content.Text = string([]rune(paste.Body)[:atomMaxContentLen]) + "..."
+		}
+
+		feed.Entry = append(feed.Entry, entry)
+	}
+
+	// Write response
+	rw.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+
+	_, err = rw.Write([]byte(xml.Header))
+	if err != nil {
+		return err
+	}
+
+	return xml.NewEncoder(rw).Encode(feed)
+}
@@ -0,0 +1,182 @@
+// Copyright (C) 2021-2023 Leonid Maslakov.
+
+// This file is part of Lenpaste.
+
+// Lenpaste is free software: you can redistribute it
+// and/or modify it under the terms of the
+// GNU Affero Public License as published by the
+// Free Software Foundation, either version 3 of the License,
+// or (at your option) any later version.
+
+// Lenpaste is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+// or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero Public License for more details.
+
+// You should have received a copy of the GNU Affero Public License along with Lenpaste.
+// If not, see <https://www.gnu.org/licenses/>.
+
+package web
+
+import (
+	"github.com/lcomrade/lenpaste/internal/netshare"
+	"github.com/lcomrade/lenpaste/internal/storage"
+	"html/template"
+	"net/http"
+)
+
+type reportTmpl struct {
+	ID            string
+	CSRF          string
+	Reasons       []string
+	CommentMaxLen int
+	Sent          bool
+
+	Translate func(string, ...interface{}) template.HTML
+}
+
+type adminReportsTmpl struct {
+	CSRF     string
+	Reports  []adminReportTmpl
+	PrevPage int // 0 if there is no previous page
+	NextPage int // 0 if there is no next page
+
+	Translate func(string, ...interface{}) template.HTML
+}
+
+type adminReportTmpl struct {
+	ID            string
+	PasteID       string
+	PasteTitle    string
+	PasteExist    bool
+	Hidden        bool
+	Reason        string
+	Comment       string
+	ReporterIP    string
+	CreatorIP     string
+	CreateTimeStr string
+}
+
+// Pattern: /report/
+// Reports can be checked only in the admin panel, so they are disabled without user accounts.
+func (data *Data) reportHand(rw http.ResponseWriter, req *http.Request) error {
+	if data.Accounts == false {
+		return netshare.ErrNotFound
+	}
+
+	// Get paste ID
+	pasteID := string([]rune(req.URL.Path)[8:])
+
+	csrf, err := csrfToken(rw, req)
+	if err != nil {
+		return err
+	}
+
+	tmplData := reportTmpl{
+		ID:            pasteID,
+		CSRF:          csrf,
+		Reasons:       netshare.ReportReasons,
+		CommentMaxLen: netshare.MaxLengthReportComment,
+		Translate:     data.Locales.findLocale(req).translate,
+	}
+
+	if req.Method == "POST" {
+		err = checkCSRF(req)
+		if err != nil {
+			return err
+		}
+
+		err = netshare.ReportAddFromForm(req, data.DB, data.RateLimitNew, pasteID, data.ReportsAutoHide)
+		if err != nil {
+			return err
+		}
+
+		tmplData.Sent = true
+
+	} else {
+		paste, err := data.DB.PasteGet(pasteID)
+		if err != nil {
+			return err
+		}
+
+		err = netshare.PasteCheckVisibility(req, paste)
+		if err != nil {
+			return err
+		}
+	}
+
+	rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+	return data.Report.Execute(rw, tmplData)
+}
+
+// Pattern: /admin/reports
+func (data *Data) adminReportsHand(rw http.ResponseWriter, req *http.Request) error {
+	ok, err := data.checkAdmin(rw, req)
+	if ok == false {
+		return err
+	}
+
+	// Resolve report
+	if req.Method == "POST" {
+		err = checkCSRF(req)
+		if err != nil {
+			return err
+		}
+
+		err = netshare.ReportResolve(req, data.DB, req.PostForm.Get("id"), req.PostForm.Get("action"), data.ReportsAutoHide)
+		if err != nil {
+			return err
+		}
+
+		writeRedirect(rw, req, "/admin/reports", 302)
+		return nil
+	}
+
+	// Show reports queue
+	page, err := netshare.ReportList(req, data.DB)
+	if err != nil {
+		return err
+	}
+
+	csrf, err := csrfToken(rw, req)
+	if err != nil {
+		return err
+	}
+
+	tmplData := adminReportsTmpl{
+		CSRF:      csrf,
+		Reports:   make([]adminReportTmpl, len(page.Reports)),
+		PrevPage:  page.Page - 1,
+		Translate: data.Locales.findLocale(req).translate,
+	}
+
+	if page.NextPage {
+		tmplData.NextPage = page.Page + 1
+	}
+
+	for i, report := range page.Reports {
+		tmplData.Reports[i] = adminReportTmpl{
+			ID:            report.ID,
+			PasteID:       report.PasteID,
+			Reason:        report.Reason,
+			Comment:       report.Comment,
+			ReporterIP:    report.ReporterIP,
+			CreateTimeStr: formatAdminTime(report.CreateTime),
+		}
+
+		// Paste can expire after it is reported
+		paste, err := data.DB.PasteGetAny(report.PasteID)
+		if err == nil {
+			tmplData.Reports[i].PasteExist = true
+			tmplData.Reports[i].PasteTitle = paste.Title
+			tmplData.Reports[i].Hidden = paste.Hidden
+			tmplData.Reports[i].CreatorIP = paste.CreatorIP
+
+		} else if err != storage.ErrNotFoundID {
+			return err
+		}
+	}
+
+	rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+	return data.AdminReports.Execute(rw, tmplData)
+}
@@ -0,0 +1,108 @@
+// Copyright (C) 2021-2023 Leonid Maslakov.
+
+// This file is part of Lenpaste.
+
+// Lenpaste is free software: you can redistribute it
+// and/or modify it under the terms of the
+// GNU Affero Public License as published by the
+// Free Software Foundation, either version 3 of the License,
+// or (at your option) any later version.
+
+// Lenpaste is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+// or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero Public License for more details.
+
+// You should have received a copy of the GNU Affero Public License along with Lenpaste.
+// If not, see <https://www.gnu.org/licenses/>.
+
+package web
+
+import (
+	"github.com/lcomrade/lenpaste/internal/netshare"
+	"html/template"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type pasteRevisionsTmpl struct {
+	ID        string
+	Title     string
+	Encrypted bool
+	Revisions []pasteRevisionTmpl
+
+	Translate func(string, ...interface{}) template.HTML
+}
+
+type pasteRevisionTmpl struct {
+	Revision      int
+	Title         string
+	Syntax        string
+	CreateTimeStr string
+	Latest        bool
+	PrevRevision  int // 0 for the first revision
+}
+
+// Pattern: /<id>/revisions
+func (data *Data) pasteRevisionsHand(rw http.ResponseWriter, req *http.Request) error {
+	// Check rate limit
+	err := data.RateLimitGet.CheckAndUse(netshare.GetClientAddr(req))
+	if err != nil {
+		return err
+	}
+
+	// Get paste ID
+	pasteID := strings.TrimSuffix(string([]rune(req.URL.Path)[1:]), "/revisions")
+
+	// Read DB
+	paste, err := data.DB.PasteGet(pasteID)
+	if err != nil {
+		return err
+	}
+
+	// "One use" paste must not be shown before the user confirms it
+	if paste.OneUse == true {
+		return netshare.ErrNotFound
+	}
+
+	// Private paste can be opened only by its owner
+	err = netshare.PasteCheckVisibility(req, paste)
+	if err != nil {
+		return err
+	}
+
+	// Password protected paste must be unlocked on its page first
+	err = netshare.PasteCheckPassword(req, paste, data.RateLimitGet, "")
+	if err != nil {
+		return err
+	}
+
+	revisions, err := data.DB.PasteRevisions(pasteID)
+	if err != nil {
+		return err
+	}
+
+	// Prepare template data
+	tmplData := pasteRevisionsTmpl{
+		ID:        paste.ID,
+		Title:     paste.Title,
+		Encrypted: paste.Encrypted,
+		Revisions: make([]pasteRevisionTmpl, len(revisions)),
+		Translate: data.Locales.findLocale(req).translate,
+	}
+
+	for i, rev := range revisions {
+		tmplData.Revisions[i] = pasteRevisionTmpl{
+			Revision:      rev.Revision,
+			Title:         rev.Title,
+			Syntax:        rev.Syntax,
+			CreateTimeStr: time.Unix(rev.CreateTime, 0).UTC().Format("Mon, 02 Jan 2006 15:04:05 -0700"),
+			Latest:        rev.Revision == paste.Revision,
+			PrevRevision:  rev.Revision - 1,
+		}
+	}
+
+	rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+	return data.PasteRevisions.Execute(rw, tmplData)
+}
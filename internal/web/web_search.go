@@ -0,0 +1,78 @@
+// Copyright (C) 2021-2023 Leonid Maslakov.
+
+// This file is part of Lenpaste.
+
+// Lenpaste is free software: you can redistribute it
+// and/or modify it under the terms of the
+// GNU Affero Public License as published by the
+// Free Software Foundation, either version 3 of the License,
+// or (at your option) any later version.
+
+// Lenpaste is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+// or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero Public License for more details.
+
+// You should have received a copy of the GNU Affero Public License along with Lenpaste.
+// If not, see <https://www.gnu.org/licenses/>.
+
+package web
+
+import (
+	"github.com/lcomrade/lenpaste/internal/netshare"
+	"html/template"
+	"net/http"
+	"time"
+)
+
+type searchTmpl struct {
+	Query       string
+	QueryMaxLen int
+	Results     []searchResultTmpl
+	PrevPage    int // 0 if there is no previous page
+	NextPage    int // 0 if there is no next page
+
+	Translate func(string, ...interface{}) template.HTML
+}
+
+type searchResultTmpl struct {
+	ID            string
+	Title         string
+	Syntax        string
+	CreateTimeStr string
+	Snippet       template.HTML
+}
+
+// Pattern: /search
+func (data *Data) searchHand(rw http.ResponseWriter, req *http.Request) error {
+	page, err := netshare.PasteSearch(req, data.DB, data.RateLimitSearch)
+	if err != nil {
+		return err
+	}
+
+	// Prepare template data
+	tmplData := searchTmpl{
+		Query:       page.Query,
+		QueryMaxLen: netshare.MaxLengthSearchQuery,
+		Results:     make([]searchResultTmpl, len(page.Results)),
+		PrevPage:    page.Page - 1,
+		Translate:   data.Locales.findLocale(req).translate,
+	}
+
+	if page.NextPage {
+		tmplData.NextPage = page.Page + 1
+	}
+
+	for i, result := range page.Results {
+		tmplData.Results[i] = searchResultTmpl{
+			ID:            result.ID,
+			Title:         result.Title,
+			Syntax:        result.Syntax,
+			CreateTimeStr: time.Unix(result.CreateTime, 0).UTC().Format("Mon, 02 Jan 2006 15:04:05 -0700"),
+			Snippet:       template.HTML(netshare.SearchSnippetHTML(result.Snippet)),
+		}
+	}
+
+	rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+	return data.Search.Execute(rw, tmplData)
+}
@@ -0,0 +1,136 @@
+// Copyright (C) 2021-2023 Leonid Maslakov.
+
+// This file is part of Lenpaste.
+
+// Lenpaste is free software: you can redistribute it
+// and/or modify it under the terms of the
+// GNU Affero Public License as published by the
+// Free Software Foundation, either version 3 of the License,
+// or (at your option) any later version.
+
+// Lenpaste is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+// or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU Affero Public License for more details.
+
+// You should have received a copy of the GNU Affero Public License along with Lenpaste.
+// If not, see <https://www.gnu.org/licenses/>.
+
+package web
+
+import (
+	"crypto/subtle"
+	"github.com/lcomrade/lenpaste/internal/netshare"
+	"github.com/lcomrade/lenpaste/internal/storage"
+	"net/http"
+	"time"
+)
+
+const (
+	sessionCookie = "session"
+	csrfCookie    = "csrf"
+)
+
+// loadSession returns the request that carries the user of the login session cookie.
+// Unknown and expired sessions are ignored.
+func (data *Data) loadSession(req *http.Request) (*http.Request, error) {
+	if data.Accounts == false {
+		return req, nil
+	}
+
+	sessionID := getCookie(req, sessionCookie)
+	if sessionID == "" {
+		return req, nil
+	}
+
+	user, err := netshare.SessionUser(data.DB, sessionID)
+	if err != nil {
+		if err == storage.ErrNotFoundSession || err == storage.ErrNotFoundUser {
+			return req, nil
+		}
+
+		return req, err
+	}
+
+	return netshare.WithUser(req, user), nil
+}
+
+// startSession creates a new login session of the user and sets the session cookie.
+func (data *Data) startSession(rw http.ResponseWriter, req *http.Request, userID string) error {
+	sessionID, expireTime, err := netshare.SessionAdd(data.DB, userID, data.SessionLifetime)
+	if err != nil {
+		return err
+	}
+
+	http.SetCookie(rw, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    sessionID,
+		Path:     "/",
+		MaxAge:   int(expireTime - time.Now().Unix()),
+		Secure:   netshare.GetProtocol(req) == "https",
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	return nil
+}
+
+// endSession deletes the login session and its cookie.
+func (data *Data) endSession(rw http.ResponseWriter, req *http.Request) error {
+	sessionID := getCookie(req, sessionCookie)
+	if sessionID != "" {
+		err := data.DB.SessionDelete(storage.HashSecret(sessionID))
+		if err != nil && err != storage.ErrNotFoundSession {
+			return err
+		}
+	}
+
+	http.SetCookie(rw, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		Secure:   netshare.GetProtocol(req) == "https",
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	return nil
+}
+
+// csrfToken returns the token that must be sent in the "csrf" field of the forms.
+// The same token is stored in the cookie, so other sites can not send the form.
+func csrfToken(rw http.ResponseWriter, req *http.Request) (string, error) {
+	token := getCookie(req, csrfCookie)
+	if token != "" {
+		return token, nil
+	}
+
+	token, _, err := storage.NewSecret()
+	if err != nil {
+		return "", err
+	}
+
+	http.SetCookie(rw, &http.Cookie{
+		Name:     csrfCookie,
+		Value:    token,
+		Path:     "/",
+		Secure:   netshare.GetProtocol(req) == "https",
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+
+	return token, nil
+}
+
+// checkCSRF compares the "csrf" form field with the CSRF cookie.
+func checkCSRF(req *http.Request) error {
+	req.ParseForm()
+
+	token := getCookie(req, csrfCookie)
+	if token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(req.PostForm.Get("csrf"))) != 1 {
+		return netshare.ErrForbidden
+	}
+
+	return nil
+}
@@ -19,7 +19,6 @@
 package web
 
 import (
-	"github.com/lcomrade/lenpaste/internal/lenpasswd"
 	"github.com/lcomrade/lenpaste/internal/netshare"
 	"html/template"
 	"net/http"
@@ -51,16 +50,12 @@ func (data *Data) settingsHand(rw http.ResponseWriter, req *http.Request) error
 	// Check auth
 	authOk := true
 
-	if data.LenPasswdFile != "" {
+	err = netshare.CheckCreateAuth(req, data.Auth)
+	if err == netshare.ErrUnauthorized {
 		authOk = false
 
-		user, pass, authExist := req.BasicAuth()
-		if authExist == true {
-			authOk, err = lenpasswd.LoadAndCheck(data.LenPasswdFile, user, pass)
-			if err != nil {
-				return err
-			}
-		}
+	} else if err != nil {
+		return err
 	}
 
 	// Show settings page
@@ -22,8 +22,12 @@ import (
 	"github.com/lcomrade/lenpaste/internal/netshare"
 	"io"
 	"net/http"
+	"time"
 )
 
+// Only the newest public pastes are listed in sitemap.xml
+const sitemapMaxPastes = 1000
+
 func (data *Data) robotsTxtHand(rw http.ResponseWriter, req *http.Request) error {
 	// Generate robots.txt
 	robotsTxt := "User-agent: *\nDisallow: /\n"
@@ -50,6 +54,12 @@ func (data *Data) sitemapHand(rw http.ResponseWriter, req *http.Request) error {
 		return netshare.ErrNotFound
 	}
 
+	// Check rate limit
+	err := data.RateLimitGet.CheckAndUse(netshare.GetClientAddr(req))
+	if err != nil {
+		return err
+	}
+
 	// Get protocol and host
 	proto := netshare.GetProtocol(req)
 	host := netshare.GetHost(req)
@@ -61,11 +71,28 @@ func (data *Data) sitemapHand(rw http.ResponseWriter, req *http.Request) error {
 	sitemapXML = sitemapXML + "<url><loc>" + proto + "://" + host + "/about" + "</loc></url>\n"
 	sitemapXML = sitemapXML + "<url><loc>" + proto + "://" + host + "/docs/apiv1" + "</loc></url>\n"
 	sitemapXML = sitemapXML + "<url><loc>" + proto + "://" + host + "/docs/api_libs" + "</loc></url>\n"
+	sitemapXML = sitemapXML + "<url><loc>" + proto + "://" + host + "/recent" + "</loc></url>\n"
+
+	// Public pastes
+	pastes, err := data.DB.PasteList(sitemapMaxPastes, 0)
+	if err != nil {
+		return err
+	}
+
+	for _, paste := range pastes {
+		updateTime := paste.CreateTime
+		if paste.EditTime != 0 {
+			updateTime = paste.EditTime
+		}
+
+		sitemapXML = sitemapXML + "<url><loc>" + proto + "://" + host + "/" + paste.ID + "</loc><lastmod>" + time.Unix(updateTime, 0).UTC().Format("2006-01-02") + "</lastmod></url>\n"
+	}
+
 	sitemapXML = sitemapXML + "</urlset>\n"
 
 	// Write response
 	rw.Header().Set("Content-Type", "text/xml; charset=utf-8")
-	_, err := io.WriteString(rw, sitemapXML)
+	_, err = io.WriteString(rw, sitemapXML)
 	if err != nil {
 		return err
 	}
@@ -205,3 +205,15 @@ func (theme Theme) theme(s string) string {
 func (theme Theme) tryHighlight(source string, lexer string) template.HTML {
 	return tryHighlight(source, lexer, theme.theme("highlight.Theme"))
 }
+
+func (theme Theme) highlightLines(source string, lexer string) []template.HTML {
+	return highlightLines(source, lexer, theme.theme("highlight.Theme"))
+}
+
+func (theme Theme) highlightCSS() template.CSS {
+	return highlightCSS(theme.theme("highlight.Theme"))
+}
+
+func (theme Theme) highlightBackground() template.CSS {
+	return highlightBackground(theme.theme("highlight.Theme"))
+}